@@ -0,0 +1,167 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/sink/discord"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// ackFooterText renders "Acked by Alice at 3:41 PM, Bob at 3:45 PM" for a message.
+func ackFooterText(db *sql.DB, messageID string, opts discord.RenderOptions) (string, error) {
+	acks, err := postgres.Acknowledgments(db, messageID)
+	if err != nil || len(acks) == 0 {
+		return "", err
+	}
+	entries := make([]string, len(acks))
+	for idx, ack := range acks {
+		entries[idx] = fmt.Sprintf(opts.T("ack_entry"), ack.UserName, ack.AckedAt.In(opts.Location).Format(opts.T("ack_time_format")))
+	}
+	return fmt.Sprintf(opts.T("ack_footer"), strings.Join(entries, ", ")), nil
+}
+
+// withAckFooter puts the ack line under the original footer of the message's last embed,
+// replacing any ack line from an earlier edit.
+func withAckFooter(embeds []json.RawMessage, ackLine string) ([]json.RawMessage, error) {
+	if len(embeds) == 0 {
+		return embeds, nil
+	}
+	last := len(embeds) - 1
+	var embed map[string]json.RawMessage
+	if err := json.Unmarshal(embeds[last], &embed); err != nil {
+		return nil, fmt.Errorf("error decoding embed: %w", err)
+	}
+	var footer map[string]interface{}
+	json.Unmarshal(embed["footer"], &footer)
+	if footer == nil {
+		footer = make(map[string]interface{})
+	}
+	text, _ := footer["text"].(string)
+	text, _, _ = strings.Cut(text, "\n")
+	footer["text"] = discord.Truncate(strings.TrimSpace(text+"\n"+ackLine), discord.MaxFooterText)
+
+	raw, err := json.Marshal(footer)
+	if err != nil {
+		return nil, err
+	}
+	embed["footer"] = raw
+	if embeds[last], err = json.Marshal(embed); err != nil {
+		return nil, err
+	}
+	return embeds, nil
+}
+
+// alertRouteForMessage finds the route and source an alert message was posted for.
+func alertRouteForMessage(cfg *Config, db *sql.DB, messageID string) (string, string, error) {
+	var route, source string
+	err := db.QueryRow(cfg.SQL(`SELECT am.route, u.{source} FROM alert_messages am JOIN {incidents} u ON u.{id} = am.incident_id
+		WHERE am.message_id = $1 ORDER BY am.id LIMIT 1`), messageID).Scan(&route, &source)
+	if err != nil {
+		return "", "", fmt.Errorf("error finding alert for message %s: %w", messageID, err)
+	}
+	return route, source, nil
+}
+
+// handleComponent records an Acknowledge click and updates the message footer in place.
+func (a *app) handleComponent(interaction Interaction) interactionResponse {
+	cfg := a.config.Current()
+	opts := cfg.RenderOptions(RouteConfig{}, "")
+	if interaction.Data.CustomID != discord.AckButtonID || interaction.Message == nil {
+		return ephemeralReply(opts.T("cmd_error"), nil)
+	}
+	messageID := interaction.Message.ID
+
+	if routeName, source, err := alertRouteForMessage(cfg, a.db, messageID); err != nil {
+		log.Printf("Warning: %v", err)
+	} else if route, ok := cfg.Route(routeName); ok {
+		opts = cfg.RenderOptions(route, source)
+	}
+
+	if _, err := postgres.RecordAcknowledgment(a.db, messageID, interaction.userID(), interaction.user().DisplayName(), "button"); err != nil {
+		log.Printf("Error handling acknowledgment: %v", err)
+		return ephemeralReply(opts.T("cmd_error"), nil)
+	}
+	ackLine, err := ackFooterText(a.db, messageID, opts)
+	if err == nil {
+		var embeds []json.RawMessage
+		if embeds, err = withAckFooter(interaction.Message.Embeds, ackLine); err == nil {
+			return interactionResponse{Type: responseUpdateMessage, Data: map[string]interface{}{"embeds": embeds}}
+		}
+	}
+	log.Printf("Error rendering acknowledgments: %v", err)
+	return ephemeralReply(opts.T("cmd_error"), nil)
+}
+
+// syncReactionAcks records ✅ reactions on recent active alerts in routes that take
+// acknowledgments and refreshes the footer of any message that gained one.
+func (a *app) syncReactionAcks(cfg *Config) {
+	rows, err := a.db.Query(cfg.SQL(`SELECT DISTINCT am.route, am.message_id, u.{source} FROM alert_messages am
+		JOIN {incidents} u ON u.{id} = am.incident_id
+		WHERE u.{status} = 'active' AND am.part = 0 AND am.created_at > now() - interval '24 hours'`))
+	if err != nil {
+		log.Printf("Error querying messages for reaction acks: %v", err)
+		return
+	}
+	type ackMessage struct{ route, messageID, source string }
+	var messages []ackMessage
+	for rows.Next() {
+		var m ackMessage
+		if err := rows.Scan(&m.route, &m.messageID, &m.source); err != nil {
+			log.Printf("Error scanning alert message: %v", err)
+			continue
+		}
+		messages = append(messages, m)
+	}
+	rows.Close()
+
+	for _, m := range messages {
+		route, ok := cfg.Route(m.route)
+		if !ok || !route.Acknowledge {
+			continue
+		}
+		bot, ok := route.Messenger().(discord.BotMessenger)
+		if !ok {
+			continue
+		}
+		users, err := bot.Reactions(m.messageID, discord.AckEmoji)
+		if err != nil {
+			log.Printf("Warning: could not read reactions in route %q: %v", route.Name, err)
+			continue
+		}
+		changed := false
+		for _, u := range users {
+			added, err := postgres.RecordAcknowledgment(a.db, m.messageID, u.ID, u.DisplayName(), "reaction")
+			if err != nil {
+				log.Printf("Error saving reaction acknowledgment: %v", err)
+				continue
+			}
+			changed = changed || added
+		}
+		if !changed {
+			continue
+		}
+
+		opts := cfg.RenderOptions(route, m.source)
+		ackLine, err := ackFooterText(a.db, m.messageID, opts)
+		if err != nil {
+			log.Printf("Error rendering acknowledgments: %v", err)
+			continue
+		}
+		embeds, err := bot.FetchEmbeds(m.messageID)
+		if err == nil {
+			embeds, err = withAckFooter(embeds, ackLine)
+		}
+		if err == nil {
+			err = bot.Edit(m.messageID, map[string]interface{}{"embeds": embeds})
+		}
+		if err != nil {
+			log.Printf("Error updating acknowledgment footer in route %q: %v", route.Name, err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
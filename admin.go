@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/enrich"
+	"github.com/mtickle/unity-alerts/sink/discord"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// Discord permission bits that make a member a server admin for /admin.
+const (
+	permissionAdministrator = 1 << 3
+	permissionManageGuild   = 1 << 5
+)
+
+// errAdminInput is an /admin argument problem shown to the caller rather than logged.
+type errAdminInput struct{ error }
+
+// isAdmin reports whether the invoking member may manage the server's routes and mutes. /admin
+// and /mute are hidden from other members by default_member_permissions, but that can be
+// changed per server.
+func (i Interaction) isAdmin() bool {
+	if i.GuildID == "" || i.Member == nil {
+		return false
+	}
+	perms, err := strconv.ParseUint(i.Member.Permissions, 10, 64)
+	return err == nil && perms&(permissionAdministrator|permissionManageGuild) != 0
+}
+
+// splitList splits a comma-separated list, keeping case. "all" or an empty string means none.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	if len(items) == 1 && strings.EqualFold(items[0], "all") {
+		return nil
+	}
+	return items
+}
+
+// handleAdmin runs the /admin route, filter, pause and resume subcommands, which change the
+// server's tenant routes. The server must already be a tenant; see `unity-alerts tenant`.
+func (a *app) handleAdmin(interaction Interaction, opts discord.RenderOptions) interactionResponse {
+	if !interaction.isAdmin() {
+		return ephemeralReply(opts.T("admin_forbidden"), nil)
+	}
+	if len(interaction.Data.Options) == 0 {
+		return ephemeralReply(opts.T("cmd_error"), nil)
+	}
+	tenant, found, err := postgres.FindTenant(a.db, interaction.GuildID)
+	if err != nil {
+		log.Printf("Error handling /admin: %v", err)
+		return ephemeralReply(opts.T("cmd_error"), nil)
+	}
+	if !found {
+		return ephemeralReply(opts.T("admin_no_tenant"), nil)
+	}
+
+	sub := interaction.Data.Options[0]
+	name := strings.TrimSpace(option(sub.Options, "route").stringValue())
+	var reply string
+	switch sub.Name {
+	case "route":
+		if len(sub.Options) == 0 {
+			return ephemeralReply(opts.T("cmd_error"), nil)
+		}
+		action := sub.Options[0]
+		name = strings.TrimSpace(option(action.Options, "name").stringValue())
+		switch action.Name {
+		case "add":
+			reply, err = a.adminAddRoute(tenant, name, interaction.ChannelID, action.Options, opts)
+		case "remove":
+			var removed bool
+			if removed, err = postgres.DeleteTenantRoute(a.db, tenant.ID, name); err == nil {
+				reply = fmt.Sprintf(opts.T("admin_route_removed"), discord.SanitizeFeedText(name))
+				if !removed {
+					reply = fmt.Sprintf(opts.T("admin_route_not_found"), discord.SanitizeFeedText(name))
+				}
+			}
+		case "list":
+			var routes []postgres.TenantRoute
+			if routes, _, err = postgres.TenantRoutes(a.db, tenant.ID); err == nil {
+				return ephemeralReply("", []discord.Embed{buildAdminRouteListEmbed(tenant, routes, opts)})
+			}
+		default:
+			err = fmt.Errorf("unknown subcommand %q", action.Name)
+		}
+	case "filter":
+		reply, err = a.adminFilter(tenant, name, sub.Options, opts)
+	case "pause":
+		d, perr := parseMuteDuration(option(sub.Options, "duration").stringValue())
+		mode, merr := parsePauseMode(option(sub.Options, "mode").stringValue())
+		if input := errors.Join(perr, merr); input != nil {
+			return ephemeralReply(fmt.Sprintf(opts.T("admin_bad_input"), discord.SanitizeFeedText(input.Error())), nil)
+		}
+		until := time.Now().Add(d)
+		var changed int64
+		if changed, err = postgres.PauseTenantRoutes(a.db, tenant.ID, name, until, mode); err == nil {
+			reply = fmt.Sprintf(opts.T("admin_paused_"+mode), changed, opts.FormatLocalTime(until))
+			if changed == 0 && name != "" {
+				reply = fmt.Sprintf(opts.T("admin_route_not_found"), discord.SanitizeFeedText(name))
+			}
+		}
+	case "resume":
+		var changed int64
+		if changed, err = postgres.ResumeTenantRoutes(a.db, tenant.ID, name); err == nil {
+			reply = fmt.Sprintf(opts.T("admin_resumed"), changed)
+			if changed == 0 && name != "" {
+				reply = fmt.Sprintf(opts.T("admin_route_not_found"), discord.SanitizeFeedText(name))
+			}
+		}
+	default:
+		err = fmt.Errorf("unknown subcommand %q", sub.Name)
+	}
+
+	if input, ok := err.(errAdminInput); ok {
+		return ephemeralReply(fmt.Sprintf(opts.T("admin_bad_input"), discord.SanitizeFeedText(input.Error())), nil)
+	}
+	if err != nil {
+		log.Printf("Error handling /admin %s: %v", sub.Name, err)
+		return ephemeralReply(opts.T("cmd_error"), nil)
+	}
+	return ephemeralReply(reply, nil)
+}
+
+// adminAddRoute creates or replaces a tenant route delivering to a channel in bot mode.
+func (a *app) adminAddRoute(tenant postgres.Tenant, name, channelID string, options []InteractionOption, opts discord.RenderOptions) (string, error) {
+	if o := option(options, "channel"); o != nil {
+		channelID = o.stringValue()
+	}
+	route := RouteConfig{
+		Name:        name,
+		ChannelID:   channelID,
+		Sources:     splitList(option(options, "sources").stringValue()),
+		MinSeverity: int(option(options, "min_severity").floatValue(0)),
+	}
+	data, err := json.Marshal(route)
+	if err != nil {
+		return "", err
+	}
+	if err := a.saveAdminRoute(tenant, postgres.TenantRoute{TenantID: tenant.ID, Name: name, Config: data}); err != nil {
+		return "", err
+	}
+	log.Printf("Tenant %s saved route %q for channel %s.", tenant.ID, name, channelID)
+	return fmt.Sprintf(opts.T("admin_route_saved"), discord.SanitizeFeedText(name), channelID), nil
+}
+
+// adminFilter changes the filters given to /admin filter on an existing tenant route,
+// leaving the rest of its settings as they are.
+func (a *app) adminFilter(tenant postgres.Tenant, name string, options []InteractionOption, opts discord.RenderOptions) (string, error) {
+	routes, _, err := postgres.TenantRoutes(a.db, tenant.ID)
+	if err != nil {
+		return "", err
+	}
+	var stored *postgres.TenantRoute
+	for n := range routes {
+		if routes[n].Name == name {
+			stored = &routes[n]
+		}
+	}
+	if stored == nil {
+		return fmt.Sprintf(opts.T("admin_route_not_found"), discord.SanitizeFeedText(name)), nil
+	}
+
+	// Edit the stored JSON rather than a RouteConfig so that settings made with
+	// `unity-alerts tenant` survive unchanged.
+	settings := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(stored.Config, &settings); err != nil {
+		return "", err
+	}
+	set := func(key string, value interface{}, clear bool) {
+		if clear {
+			delete(settings, key)
+			return
+		}
+		settings[key], _ = json.Marshal(value)
+	}
+	if o := option(options, "sources"); o != nil {
+		sources := splitList(o.stringValue())
+		set("sources", sources, len(sources) == 0)
+	}
+	if o := option(options, "jurisdictions"); o != nil {
+		jurisdictions := splitList(o.stringValue())
+		set("jurisdictions", jurisdictions, len(jurisdictions) == 0)
+	}
+	if o := option(options, "min_severity"); o != nil {
+		severity := int(o.floatValue(0))
+		set("min_severity", severity, severity == 0)
+	}
+	if o := option(options, "near"); o != nil {
+		location := strings.TrimSpace(o.stringValue())
+		if strings.EqualFold(location, "off") {
+			set("near", nil, true)
+		} else {
+			lat, lon, ok := parseCoordinates(location)
+			if !ok {
+				if lat, lon, err = enrich.Geocode(context.Background(), os.Getenv("GOOGLE_MAPS_API_KEY"), location); err != nil {
+					return "", errAdminInput{err}
+				}
+			}
+			set("near", Area{Latitude: lat, Longitude: lon, Radius: option(options, "radius").stringValue()}, false)
+		}
+	}
+
+	if stored.Config, err = json.Marshal(settings); err != nil {
+		return "", err
+	}
+	if err := a.saveAdminRoute(tenant, *stored); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(opts.T("admin_filter_saved"), discord.SanitizeFeedText(name)), nil
+}
+
+// saveAdminRoute validates a route changed through /admin and stores it. Validation errors
+// are shown to the caller.
+func (a *app) saveAdminRoute(tenant postgres.Tenant, stored postgres.TenantRoute) error {
+	if stored.Name == "" {
+		return errAdminInput{fmt.Errorf("the route needs a name")}
+	}
+	if _, err := parseTenantRoute(stored, tenant); err != nil {
+		return errAdminInput{err}
+	}
+	return postgres.SaveTenantRoute(a.db, stored)
+}
+
+// buildAdminRouteListEmbed shows one field per tenant route with its delivery, filters and
+// pause state.
+func buildAdminRouteListEmbed(tenant postgres.Tenant, routes []postgres.TenantRoute, opts discord.RenderOptions) discord.Embed {
+	embed := discord.Embed{
+		Title:     discord.Truncate(fmt.Sprintf(opts.T("admin_route_list_title"), discord.SanitizeFeedText(tenant.Name)), discord.MaxEmbedTitle),
+		Color:     3447003, // Blue
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+	if len(routes) == 0 {
+		embed.Fields = []discord.EmbedField{{Name: discord.ZeroWidthSpace, Value: opts.T("admin_route_none")}}
+		return embed
+	}
+	for _, r := range routes {
+		if len(embed.Fields) == discord.MaxFieldsPerEmbed {
+			break
+		}
+		var route RouteConfig
+		json.Unmarshal(r.Config, &route)
+		embed.Fields = append(embed.Fields, discord.EmbedField{
+			Name:  discord.Truncate(discord.SanitizeFeedText(r.Name), discord.MaxFieldName),
+			Value: discord.Truncate(describeAdminRoute(route, r, opts), discord.MaxFieldValue),
+		})
+	}
+	return embed
+}
+
+// describeAdminRoute summarizes a tenant route, e.g. "<#123> · NCDOT · severity 3+".
+func describeAdminRoute(route RouteConfig, r postgres.TenantRoute, opts discord.RenderOptions) string {
+	var parts []string
+	if route.ChannelID != "" {
+		parts = append(parts, "<#"+route.ChannelID+">")
+	} else {
+		parts = append(parts, opts.T("admin_route_webhook"))
+	}
+	for _, list := range [][]string{route.Sources, route.Jurisdictions} {
+		if len(list) > 0 {
+			parts = append(parts, discord.SanitizeFeedText(strings.Join(list, ", ")))
+		}
+	}
+	if route.MinSeverity > 0 {
+		parts = append(parts, fmt.Sprintf(opts.T("admin_route_severity"), route.MinSeverity))
+	}
+	if route.Near != nil {
+		parts = append(parts, fmt.Sprintf(opts.T("admin_route_near"), route.Near.Latitude, route.Near.Longitude))
+	}
+	if r.PausedUntil.Valid && r.PausedUntil.Time.After(time.Now()) {
+		parts = append(parts, fmt.Sprintf(opts.T("admin_route_paused"), opts.FormatLocalTime(r.PausedUntil.Time)))
+	}
+	return strings.Join(parts, " · ")
+}
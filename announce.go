@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/sink/discord"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// defaultAnnounceCooldown spaces out spoken announcements when the config doesn't.
+const defaultAnnounceCooldown = 5 * time.Minute
+
+// AnnounceConfig posts a route's serious alerts as text-to-speech messages, e.g. "Structure
+// fire reported on Main Street", which Discord clients read aloud to members viewing the
+// channel. ChannelID defaults to the route's channel; a voice channel's text chat works too.
+// The bot never joins a voice call: speaking there would take an Opus encoder and a speech
+// engine, so members only hear announcements while they have the channel open.
+type AnnounceConfig struct {
+	ChannelID   string `json:"channel_id,omitempty"`
+	MinSeverity int    `json:"min_severity"`       // Uses the route's severities.
+	Cooldown    string `json:"cooldown,omitempty"` // Least time between announcements (default "5m").
+}
+
+func (c *AnnounceConfig) validate(route RouteConfig) error {
+	if c == nil {
+		return nil
+	}
+	if _, ok := route.Messenger().(discord.BotMessenger); !ok {
+		return fmt.Errorf("route %q: announce requires channel_id (bot mode)", route.Name)
+	}
+	if c.MinSeverity <= 0 {
+		return fmt.Errorf("route %q: announce.min_severity must be positive", route.Name)
+	}
+	if c.Cooldown != "" {
+		if d, err := time.ParseDuration(c.Cooldown); err != nil || d < 0 {
+			return fmt.Errorf("route %q: announce.cooldown: invalid duration %q", route.Name, c.Cooldown)
+		}
+	}
+	return nil
+}
+
+// announce reads a newly delivered alert aloud when the route asks for it, the incident is
+// severe enough and the cooldown has passed.
+func (a *app) announce(cfg *Config, route RouteConfig, p *pendingIncident) {
+	c := route.Announce
+	if c == nil || !p.live || p.incident.IsTest || route.severity(p.incident) < c.MinSeverity {
+		return
+	}
+	bot, ok := route.Messenger().(discord.BotMessenger)
+	if !ok {
+		return
+	}
+	if c.ChannelID != "" {
+		bot.ChannelID = route.expand(c.ChannelID)
+	}
+	cooldown := defaultAnnounceCooldown
+	if c.Cooldown != "" {
+		cooldown, _ = time.ParseDuration(c.Cooldown)
+	}
+	claimed, err := postgres.ClaimAnnouncement(a.db, route.Name, cooldown)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+	if !claimed {
+		log.Printf("Skipping announcement of incident %d in route %q: cooldown.", p.incident.ID, route.Name)
+		return
+	}
+	text := announcement(cfg.RenderOptions(route, p.incident.Source), p.incident)
+	if _, err := bot.Announce(text); err != nil {
+		log.Printf("Error announcing incident %d in route %q: %v", p.incident.ID, route.Name, err)
+	}
+}
+
+// announcement is the sentence spoken for an incident, naming its road when known.
+func announcement(opts discord.RenderOptions, i incident.Incident) string {
+	eventType := capitalize(strings.ToLower(i.EventType))
+	if road := incident.Corridor(i); road != "" {
+		return fmt.Sprintf(opts.T("announce_road"), eventType, spokenCase(road))
+	}
+	return fmt.Sprintf(opts.T("announce_address"), eventType, spokenCase(opts.Address(i)))
+}
+
+// spokenCase title-cases feed text such as "MAIN ST", which speech engines otherwise spell
+// out letter by letter.
+func spokenCase(s string) string {
+	words := strings.Fields(strings.ToLower(s))
+	for n, w := range words {
+		words[n] = capitalize(w)
+	}
+	return strings.Join(words, " ")
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return ""
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	return string(unicode.ToUpper(r)) + s[size:]
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+)
+
+// backfill sends the incidents reported in a date range to one route, oldest first and
+// labeled as backfill, to seed a new channel with recent context. The incidents themselves
+// are left alone, and their other routes get nothing.
+//
+//	unity-alerts backfill --from 2024-06-01 --to 2024-06-07 --route archive-channel
+func (a *app) backfill(args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	from := fs.String("from", "", "first day to backfill, YYYY-MM-DD")
+	to := fs.String("to", "", "last day to backfill, YYYY-MM-DD (default: today)")
+	routeName := fs.String("route", "", "route to send the incidents to")
+	delay := fs.Duration("delay", 3*time.Second, "pause between alerts, on top of the usual 2s, to stay under the sink's rate limits")
+	all := fs.Bool("all", false, "send every incident, even those the route does not accept")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg := a.currentConfig()
+	route, ok := cfg.Route(*routeName)
+	if *routeName == "" || !ok {
+		return fmt.Errorf("backfill requires --route naming a configured route")
+	}
+	loc := cfg.Location(route)
+	start, err := time.ParseInLocation(time.DateOnly, *from, loc)
+	if err != nil {
+		return fmt.Errorf("backfill requires --from as YYYY-MM-DD")
+	}
+	end := time.Now().In(loc)
+	if *to != "" {
+		if end, err = time.ParseInLocation(time.DateOnly, *to, loc); err != nil {
+			return fmt.Errorf("invalid --to %q", *to)
+		}
+	}
+	end = time.Date(end.Year(), end.Month(), end.Day()+1, 0, 0, 0, 0, loc)
+	if !start.Before(end) {
+		return fmt.Errorf("--from must not be after --to")
+	}
+	if *delay < 0 {
+		return fmt.Errorf("--delay must not be negative")
+	}
+
+	incidents, _, err := queryIncidents(cfg, a.db, "WHERE {timestamp} >= $1 AND {timestamp} < $2 AND NOT {is_test} ORDER BY {timestamp}, {id}", start, end)
+	if err != nil {
+		return err
+	}
+	sent, failed := 0, 0
+	for _, inc := range incidents {
+		if !*all && !route.Matches(inc) {
+			continue
+		}
+		if sent+failed > 0 {
+			time.Sleep(*delay)
+		}
+		inc.Backfill = true
+		if _, err := a.deliverNow(cfg, inc, []RouteConfig{route}); err != nil {
+			log.Printf("Error backfilling incident %d: %v", inc.ID, err)
+			failed++
+			continue
+		}
+		sent++
+	}
+	log.Printf("Backfilled %d of %d incident(s) from %s to %s into route %q.", sent, len(incidents),
+		start.Format(time.DateOnly), end.AddDate(0, 0, -1).Format(time.DateOnly), route.Name)
+	if failed > 0 {
+		return fmt.Errorf("%d incident(s) could not be backfilled", failed)
+	}
+	return nil
+}
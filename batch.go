@@ -0,0 +1,134 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/lib/pq"
+)
+
+// writeChunk is how many incidents' updates are written in one transaction.
+const writeChunk = 200
+
+// incidentWrites buffers the updates a job makes to the incidents table, so that draining a
+// large backlog costs a multi-row UPDATE per chunk of incidents instead of a round trip per
+// incident. Outside jobs, updates are written immediately. So is the discord_message_id of an
+// incident that was alerted, since it is what keeps the alert from being sent again: only
+// tags, and the discord_message_id of incidents handled without an alert or cleared, wait
+// for their chunk. A run that dies before a chunk is written, or fails to write it, loses those
+// updates, and the next run picks the incidents up again and repeats the work.
+type incidentWrites struct {
+	mu         sync.Mutex
+	cfg        *Config                // Set while a job runs.
+	messageIDs map[int]sql.NullString // The discord_message_id to set: the first alert's, "" when handled, or NULL once cleared.
+	tags       map[int][]string
+}
+
+// batchWrites runs a job, buffering its updates to the incidents table and writing them in
+// chunks.
+func (a *app) batchWrites(cfg *Config, run func() error) error {
+	w := &a.writes
+	w.mu.Lock()
+	w.cfg = cfg
+	w.mu.Unlock()
+	err := run()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.cfg = nil
+	if flushErr := w.flush(a, cfg); flushErr != nil {
+		return errors.Join(err, flushErr)
+	}
+	return err
+}
+
+// setMessageID sets an incident's discord_message_id, which marks it handled. The ID of an
+// alert that went out is written at once, even during a job.
+func (a *app) setMessageID(cfg *Config, id int, messageID sql.NullString) error {
+	w := &a.writes
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.cfg == nil || messageID.String != "" {
+		delete(w.messageIDs, id)
+		_, err := a.db.Exec(cfg.SQL("UPDATE {incidents} SET {discord_message_id} = $1 WHERE {id} = $2"), messageID, id)
+		return err
+	}
+	if w.messageIDs == nil {
+		w.messageIDs = make(map[int]sql.NullString)
+	}
+	w.messageIDs[id] = messageID
+	return w.flushFull(a)
+}
+
+// setTags sets an incident's tags.
+func (a *app) setTags(cfg *Config, id int, tags []string) error {
+	w := &a.writes
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.cfg == nil {
+		_, err := a.db.Exec(cfg.SQL("UPDATE {incidents} SET {tags} = $1 WHERE {id} = $2"), pq.Array(tags), id)
+		return err
+	}
+	if w.tags == nil {
+		w.tags = make(map[int][]string)
+	}
+	w.tags[id] = tags
+	return w.flushFull(a)
+}
+
+// flushFull writes the buffered updates once they fill a chunk.
+func (w *incidentWrites) flushFull(a *app) error {
+	if len(w.messageIDs)+len(w.tags) < writeChunk {
+		return nil
+	}
+	return w.flush(a, w.cfg)
+}
+
+// flush writes the buffered updates in one transaction. On failure they are dropped, and the
+// incidents they belong to are processed again by a later run.
+func (w *incidentWrites) flush(a *app, cfg *Config) error {
+	if len(w.messageIDs)+len(w.tags) == 0 {
+		return nil
+	}
+	messageIDs, tags := w.messageIDs, w.tags
+	w.messageIDs, w.tags = nil, nil
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to save %d incident(s): %w", len(messageIDs)+len(tags), err)
+	}
+	defer tx.Rollback()
+	if len(messageIDs) > 0 {
+		ids := make([]int64, 0, len(messageIDs))
+		values := make([]sql.NullString, 0, len(messageIDs))
+		for id, v := range messageIDs {
+			ids = append(ids, int64(id))
+			values = append(values, v)
+		}
+		if _, err := tx.Exec(cfg.SQL(`UPDATE {incidents} AS i SET {discord_message_id} = v.message_id
+			FROM unnest($1::bigint[], $2::text[]) AS v(id, message_id) WHERE i.{id} = v.id`), pq.Array(ids), pq.Array(values)); err != nil {
+			return fmt.Errorf("failed to save discord_message_id of %d incident(s): %w", len(ids), err)
+		}
+	}
+	if len(tags) > 0 {
+		// Arrays of differing lengths can't be unnested row by row, so each row's tags are
+		// passed as an array literal.
+		ids := make([]int64, 0, len(tags))
+		values := make([]sql.NullString, 0, len(tags))
+		for id, t := range tags {
+			literal, _ := pq.StringArray(t).Value()
+			s, ok := literal.(string)
+			ids = append(ids, int64(id))
+			values = append(values, sql.NullString{String: s, Valid: ok})
+		}
+		if _, err := tx.Exec(cfg.SQL(`UPDATE {incidents} AS i SET {tags} = v.tags::text[]
+			FROM unnest($1::bigint[], $2::text[]) AS v(id, tags) WHERE i.{id} = v.id`), pq.Array(ids), pq.Array(values)); err != nil {
+			return fmt.Errorf("failed to save tags of %d incident(s): %w", len(ids), err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save %d incident(s): %w", len(messageIDs)+len(tags), err)
+	}
+	return nil
+}
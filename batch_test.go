@@ -0,0 +1,85 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// unreachableDB returns a database whose every query fails, so tests can tell when updates
+// are written instead of buffered.
+func unreachableDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestBatchWrites(t *testing.T) {
+	tests := []struct {
+		name      string
+		updates   int
+		wantWrite int    // The update that is written rather than buffered; 0 when none is.
+		wantErr   string // From writing the rest at the end of the job.
+	}{
+		{"nothing to write", 0, 0, ""},
+		{"buffered until the job ends", 3, 0, "failed to save 3 incident(s)"},
+		{"a full chunk is written", writeChunk, writeChunk, ""},
+		{"then buffering starts over", writeChunk + 2, writeChunk, "failed to save 2 incident(s)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &app{db: unreachableDB(t)}
+			cfg := &Config{}
+			err := a.batchWrites(cfg, func() error {
+				for n := 1; n <= tt.updates; n++ {
+					var err error
+					if n%2 == 0 {
+						err = a.setTags(cfg, n, []string{"crash"})
+					} else {
+						err = a.setMessageID(cfg, n, sql.NullString{String: "", Valid: true})
+					}
+					if (err != nil) != (n == tt.wantWrite) {
+						return fmt.Errorf("update %d returned %v", n, err)
+					}
+				}
+				return nil
+			})
+			if (err == nil) != (tt.wantErr == "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("batchWrites() = %v, want %q", err, tt.wantErr)
+			}
+			if len(a.writes.messageIDs)+len(a.writes.tags) != 0 {
+				t.Errorf("%d update(s) left buffered", len(a.writes.messageIDs)+len(a.writes.tags))
+			}
+		})
+	}
+}
+
+func TestWritesOutsideJobs(t *testing.T) {
+	a := &app{db: unreachableDB(t)}
+	if err := a.setMessageID(&Config{}, 1, sql.NullString{}); err == nil {
+		t.Error("setMessageID outside a job was buffered, want it written")
+	}
+	if err := a.setTags(&Config{}, 1, nil); err == nil {
+		t.Error("setTags outside a job was buffered, want it written")
+	}
+}
+
+func TestSentMessageIDWrittenAtOnce(t *testing.T) {
+	a := &app{db: unreachableDB(t)}
+	cfg := &Config{}
+	a.batchWrites(cfg, func() error {
+		a.setMessageID(cfg, 1, sql.NullString{String: "", Valid: true})
+		if err := a.setMessageID(cfg, 1, sql.NullString{String: "1234", Valid: true}); err == nil {
+			t.Error("the discord_message_id of a sent alert was buffered, want it written")
+		}
+		if _, ok := a.writes.messageIDs[1]; ok {
+			t.Error("the handled marker it replaces is still buffered")
+		}
+		return nil
+	})
+}
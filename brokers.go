@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// brokerTimeout bounds connecting to a NATS server or Kafka broker, and each publish.
+const brokerTimeout = 10 * time.Second
+
+// isKafkaURL reports whether a broker URL names a Kafka cluster rather than a NATS server.
+func isKafkaURL(raw string) bool {
+	return strings.HasPrefix(raw, "kafka://") || strings.HasPrefix(raw, "kafka+tls://")
+}
+
+// kafkaCluster is the Kafka cluster a kafka:// URL names.
+type kafkaCluster struct {
+	brokers   []string
+	transport *kafka.Transport
+}
+
+// parseKafkaURL reads kafka://[user:pass@]host:port,host:port[?sasl=scram-sha-512], or
+// kafka+tls:// for brokers that require TLS. A user and password authenticate with SASL
+// PLAIN, or SCRAM-SHA-256 or SCRAM-SHA-512 when sasl names it. Brokers default to port 9092.
+func parseKafkaURL(raw string) (kafkaCluster, error) {
+	if !isKafkaURL(raw) {
+		return kafkaCluster{}, fmt.Errorf("Kafka URL must start with kafka:// or kafka+tls://")
+	}
+	// A list of brokers isn't a valid URL host, so it is split off before parsing the rest.
+	scheme, rest, _ := strings.Cut(raw, "://")
+	hosts, query, _ := strings.Cut(rest, "?")
+	var userinfo string
+	if at := strings.LastIndex(hosts, "@"); at >= 0 {
+		userinfo, hosts = hosts[:at+1], hosts[at+1:]
+	}
+	u, err := url.Parse(scheme + "://" + userinfo + "brokers?" + query)
+	if err != nil {
+		return kafkaCluster{}, fmt.Errorf("invalid Kafka URL: %w", err)
+	}
+	var brokers []string
+	for _, b := range strings.Split(strings.TrimSuffix(hosts, "/"), ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			if _, _, err := net.SplitHostPort(b); err != nil {
+				b = net.JoinHostPort(b, "9092")
+			}
+			brokers = append(brokers, b)
+		}
+	}
+	if len(brokers) == 0 {
+		return kafkaCluster{}, fmt.Errorf("Kafka URL names no brokers")
+	}
+
+	transport := &kafka.Transport{ClientID: "unity-alerts", DialTimeout: brokerTimeout}
+	if u.Scheme == "kafka+tls" {
+		transport.TLS = &tls.Config{}
+	}
+	mechanism := strings.ToLower(u.Query().Get("sasl"))
+	if u.User == nil {
+		if mechanism != "" {
+			return kafkaCluster{}, fmt.Errorf("Kafka URL sets sasl=%s without a user and password", mechanism)
+		}
+		return kafkaCluster{brokers: brokers, transport: transport}, nil
+	}
+	user := u.User.Username()
+	pass, _ := u.User.Password()
+	var m sasl.Mechanism
+	switch mechanism {
+	case "", "plain":
+		m = plain.Mechanism{Username: user, Password: pass}
+	case "scram-sha-256":
+		m, err = scram.Mechanism(scram.SHA256, user, pass)
+	case "scram-sha-512":
+		m, err = scram.Mechanism(scram.SHA512, user, pass)
+	default:
+		return kafkaCluster{}, fmt.Errorf("Kafka URL has unknown sasl mechanism %q; use plain, scram-sha-256 or scram-sha-512", mechanism)
+	}
+	if err != nil {
+		return kafkaCluster{}, fmt.Errorf("failed to set up Kafka SASL: %w", err)
+	}
+	transport.SASL = m
+	return kafkaCluster{brokers: brokers, transport: transport}, nil
+}
+
+// writer returns a producer that waits for each partition leader to acknowledge a record,
+// choosing the partition by the record's key.
+func (c kafkaCluster) writer() *kafka.Writer {
+	return &kafka.Writer{
+		Addr:                   kafka.TCP(c.brokers...),
+		Balancer:               &kafka.Hash{},
+		RequiredAcks:           kafka.RequireOne,
+		MaxAttempts:            3,
+		BatchSize:              1, // Records are written one at a time; don't wait for more.
+		AllowAutoTopicCreation: true,
+		Transport:              c.transport,
+	}
+}
+
+// connectNATS connects to a nats:// or tls:// server, authenticating with the URL's user
+// info: a user and password, or a token alone as the user. The connection reconnects by
+// itself and resubscribes, logging when it is lost and back.
+func connectNATS(serverURL string) (*nats.Conn, error) {
+	conn, err := nats.Connect(serverURL,
+		nats.Name("unity-alerts"),
+		nats.Timeout(brokerTimeout),
+		nats.MaxReconnects(-1),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				log.Printf("Warning: lost the NATS connection: %v", err)
+			}
+		}),
+		nats.ReconnectHandler(func(c *nats.Conn) {
+			log.Printf("Reconnected to NATS server %s.", c.ConnectedUrlRedacted())
+		}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS server: %w", err)
+	}
+	return conn, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mtickle/unity-alerts/enrich"
+	"github.com/mtickle/unity-alerts/incident"
+)
+
+// defaultIncidentTimeout is each incident's time budget when the config doesn't set one.
+const defaultIncidentTimeout = 20 * time.Second
+
+func validIncidentTimeout(v string) error {
+	if v == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+		return fmt.Errorf("incident_timeout: invalid duration %q", v)
+	}
+	return nil
+}
+
+// incidentTimeout is how long one incident may spend on enrichment and sending.
+func (c *Config) incidentTimeout() time.Duration {
+	if c.IncidentTimeout == "" {
+		return defaultIncidentTimeout
+	}
+	d, _ := time.ParseDuration(c.IncidentTimeout)
+	return d
+}
+
+// newPending enriches an incident for the routes within its time budget. When the lookups
+// overrun it, they are cancelled and the incident goes out without them.
+func (a *app) newPending(cfg *Config, i incident.Incident, routes []RouteConfig) *pendingIncident {
+	p := &pendingIncident{incident: i, routes: routes}
+	a.enrichPending(cfg, p)
+	return p
+}
+
+// enrichPending runs the lookups for a pending incident within its time budget, keeping the
+// enrichments already marked skipped.
+func (a *app) enrichPending(cfg *Config, p *pendingIncident) {
+	i := p.incident
+	p.budget = cfg.incidentTimeout()
+	opts := cfg.enrichOptions(i, p.routes)
+	skipped := p.enrichment.Skipped
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), p.budget)
+	defer cancel()
+	done := make(chan enrich.Result, 1)
+	go func() {
+		defer recoverAndReport(a.reporter, incidentTags(i))
+		done <- enrich.Incident(ctx, a.db, i, opts)
+	}()
+	select {
+	case p.enrichment = <-done:
+	case <-ctx.Done():
+		log.Printf("Warning: enriching incident %d took longer than %s; sending it without enrichments.", i.ID, p.budget)
+		p.enrichment = enrich.Result{Skipped: requestedEnrichments(opts)}
+	}
+	for _, name := range skipped {
+		p.enrichment.Skipped = withSkipped(p.enrichment.Skipped, name)
+	}
+	p.budget -= time.Since(start)
+}
+
+// spend charges time taken sending an incident to its budget. Once the budget is used up,
+// the remaining routes get the alert without its camera image or other uploads, which are
+// what make sending slow.
+func (p *pendingIncident) spend(d time.Duration) {
+	p.budget -= d
+	if p.budget > 0 || p.enrichment.Attachment == nil && p.enrichment.Geometry == nil {
+		return
+	}
+	log.Printf("Warning: incident %d is over its time budget; sending it to the remaining routes without enrichments.", p.incident.ID)
+	skipped := p.enrichment.Skipped
+	if p.enrichment.Attachment != nil {
+		skipped = withSkipped(skipped, enrich.SkippedCameras)
+	}
+	p.enrichment = enrich.Result{NearbyCameras: p.enrichment.NearbyCameras, StreetViewURL: p.enrichment.StreetViewURL, Skipped: skipped}
+}
+
+// requestedEnrichments names the lookups opts asks for.
+func requestedEnrichments(opts enrich.Options) []string {
+	var names []string
+	if opts.Cameras {
+		names = append(names, enrich.SkippedCameras)
+	}
+	if opts.StreetView {
+		names = append(names, enrich.SkippedStreetView)
+	}
+	return names
+}
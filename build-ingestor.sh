@@ -1,6 +1,23 @@
 set -e
+# ./build-ingestor.sh builds the binary; ./build-ingestor.sh image [push] builds (and publishes)
+# the distroless container image instead, named by $IMAGE and tagged with both the version and
+# latest, which deploy/kubernetes runs.
+if [ "$1" = "image" ]; then
+    VERSION=$(git describe --tags --always --dirty 2>/dev/null || echo dev)
+    IMAGE=${IMAGE:-unity-alerts}
+    echo ">>> Building the distroless image $IMAGE:$VERSION..."
+    docker build --target distroless --build-arg VERSION="$VERSION" -t "$IMAGE:$VERSION" -t "$IMAGE:latest" .
+    if [ "$2" = "push" ]; then
+        echo ">>> Publishing $IMAGE:$VERSION and $IMAGE:latest..."
+        docker push "$IMAGE:$VERSION"
+        docker push "$IMAGE:latest"
+    fi
+    echo ">>> Image '$IMAGE:$VERSION' is ready."
+    exit 0
+fi
 echo ">>> Pulling latest changes from the Git repository..."
 git pull
+VERSION=$(git describe --tags --always --dirty 2>/dev/null || echo dev)
 echo ">>> Building the Go application..."
-go build -o unity-alerts main.go
-echo ">>> Build complete! Binary 'unity-alerts' is ready."
\ No newline at end of file
+go build -ldflags "-X main.version=$VERSION" -o unity-alerts .
+echo ">>> Build complete! Binary 'unity-alerts' is ready."
@@ -0,0 +1,216 @@
+// Package camera finds the traffic cameras nearest an incident and captures still frames from them.
+package camera
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png" // Some feeds serve PNG stills.
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mtickle/unity-alerts/internal/breaker"
+)
+
+// Camera holds the info for a nearby traffic camera.
+type Camera struct {
+	Name     string
+	ImageURL string
+}
+
+// MaxGridCameras is the most frames Capture combines into one image.
+const MaxGridCameras = 4
+
+// maxImageBytes caps a camera download, so a misbehaving camera cannot exhaust memory.
+const maxImageBytes = 8 << 20
+
+// Capture downloads frames from the cameras and combines the first MaxGridCameras usable ones
+// into one image: a single watermarked frame, or a labeled grid when several cameras answer.
+// Placeholder frames are skipped, so listing spare cameras lets later ones fill in. Each camera
+// used is logged in camera_captures. It returns the image, a file name to upload it under and
+// the cameras shown, in order. Downloads still running when ctx is done fail.
+func Capture(ctx context.Context, db *sql.DB, incidentID int, cameras []Camera, loc *time.Location) ([]byte, string, []Camera, error) {
+	capturedAt := time.Now().In(loc)
+	placeholders := placeholderHashes(ctx, db)
+
+	var shown []Camera
+	var frames []image.Image
+	var undecoded []byte // The first frame that could not be decoded, sent as is if nothing else works.
+	var undecodedCamera Camera
+	for n, result := range fetchAll(ctx, cameras) {
+		if result.err != nil {
+			log.Printf("Warning: failed to capture camera %s: %v", cameras[n].Name, result.err)
+			health.failed.Add(1)
+			continue
+		}
+		frame, _, err := image.Decode(bytes.NewReader(result.data))
+		if err != nil {
+			log.Printf("Warning: failed to decode image from camera %s: %v", cameras[n].Name, err)
+			health.failed.Add(1)
+			if undecoded == nil {
+				undecoded, undecodedCamera = result.data, cameras[n]
+			}
+			continue
+		}
+		if isPlaceholder(frame, placeholders) {
+			log.Printf("Camera %s returned a placeholder frame; skipping.", cameras[n].Name)
+			health.placeholders.Add(1)
+			continue
+		}
+		health.captured.Add(1)
+		if len(shown) < MaxGridCameras {
+			shown = append(shown, cameras[n])
+			frames = append(frames, frame)
+		}
+	}
+
+	var data []byte
+	switch {
+	case len(frames) > 0:
+		var img *image.RGBA
+		if len(frames) == 1 {
+			img = watermark(frames[0], caption(shown[0], incidentID, capturedAt))
+		} else {
+			captions := make([][]string, len(shown))
+			for n, cam := range shown {
+				captions[n] = caption(cam, incidentID, capturedAt)
+			}
+			img = grid(frames, captions)
+		}
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, "", nil, fmt.Errorf("failed to encode camera image: %w", err)
+		}
+		data = buf.Bytes()
+	case undecoded != nil:
+		data, shown = undecoded, []Camera{undecodedCamera}
+	default:
+		return nil, "", nil, fmt.Errorf("no camera returned an image")
+	}
+
+	fileName := fmt.Sprintf("incident_%d_cam_%s.jpg", incidentID, capturedAt.Format("20060102150405"))
+	for _, cam := range shown {
+		_, err := db.ExecContext(ctx, "INSERT INTO camera_captures (incident_id, camera_name, file_name) VALUES ($1, $2, $3)",
+			incidentID, cam.Name, fileName)
+		if err != nil {
+			log.Printf("Warning: failed to log camera capture to DB: %v", err)
+		}
+	}
+
+	log.Printf("Successfully captured %d camera frame(s) as %s", len(shown), fileName)
+	return data, fileName, shown, nil
+}
+
+type fetchResult struct {
+	data []byte
+	err  error
+}
+
+// fetchAll downloads every camera's current frame in parallel, returning results in camera order.
+func fetchAll(ctx context.Context, cameras []Camera) []fetchResult {
+	results := make([]fetchResult, len(cameras))
+	var wg sync.WaitGroup
+	for n, cam := range cameras {
+		wg.Add(1)
+		go func(n int, cam Camera) {
+			defer wg.Done()
+			log.Printf("Capturing image from camera: %s", cam.Name)
+			results[n].data, results[n].err = fetch(ctx, cam)
+		}(n, cam)
+	}
+	wg.Wait()
+	return results
+}
+
+// client fetches frames through per-host breakers, so a dead camera server is skipped rather
+// than timing out once for every incident.
+var client = breaker.Client(15 * time.Second)
+
+// fetch downloads a camera's current frame.
+func fetch(ctx context.Context, camera Camera) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", camera.ImageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image URL: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("received non-200 status code for image: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	if len(data) > maxImageBytes {
+		return nil, fmt.Errorf("image is larger than %d bytes", maxImageBytes)
+	}
+	return data, nil
+}
+
+// ByName looks up a camera in traffic_cameras.
+func ByName(db *sql.DB, name string) (Camera, error) {
+	cam := Camera{Name: name}
+	err := db.QueryRow("SELECT image_url FROM traffic_cameras WHERE name = $1", name).Scan(&cam.ImageURL)
+	if err == sql.ErrNoRows {
+		return cam, fmt.Errorf("camera %q not found", name)
+	}
+	if err != nil {
+		return cam, fmt.Errorf("error querying camera: %w", err)
+	}
+	return cam, nil
+}
+
+// Within preferredRadiusMeters of an incident, cameras assigned to its corridor, then those on
+// its road and facing it (within maxFacingDegrees of the direction to the incident), are
+// preferred over closer ones.
+const (
+	preferredRadiusMeters = 2000
+	maxFacingDegrees      = 60
+)
+
+// FindNearby returns the cameras to show for an incident on corridor at a point: nearby cameras
+// manually assigned to the corridor first, then nearby cameras on the same road and facing the
+// incident, then the closest ones. An assigned camera farther away than preferredRadiusMeters
+// takes its place by distance, since a long corridor's cameras may be miles from the incident.
+// Denylisted cameras are skipped.
+func FindNearby(ctx context.Context, db *sql.DB, corridor string, lat, lon float64, limit int) ([]Camera, error) {
+	var cameras []Camera
+	query := `
+		SELECT c.name, c.image_url
+		FROM traffic_cameras c
+		CROSS JOIN (SELECT ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography AS pt) p
+		LEFT JOIN camera_assignments a ON a.camera_name = c.name AND upper(a.corridor) = upper($3)
+		WHERE c.name NOT IN (SELECT camera_name FROM camera_denylist)
+		ORDER BY CASE WHEN ST_DWithin(c.geom, p.pt, $5) THEN a.rank END NULLS LAST,
+			CASE WHEN ST_DWithin(c.geom, p.pt, $5) THEN
+				CASE WHEN upper(c.road) = upper($3) OR upper($3) LIKE upper(c.road) || ' %' THEN 0 ELSE 2 END +
+				CASE WHEN abs(mod((degrees(ST_Azimuth(c.geom, p.pt)) - c.bearing + 540)::numeric, 360) - 180) <= $6 THEN 0 ELSE 1 END
+			ELSE 3 END,
+			c.geom <-> p.pt
+		LIMIT $4;
+	`
+	rows, err := db.QueryContext(ctx, query, lon, lat, corridor, limit, preferredRadiusMeters, maxFacingDegrees)
+	if err != nil {
+		return nil, fmt.Errorf("error querying for nearby cameras: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cam Camera
+		if err := rows.Scan(&cam.Name, &cam.ImageURL); err != nil {
+			return nil, fmt.Errorf("error scanning camera row: %w", err)
+		}
+		cameras = append(cameras, cam)
+	}
+	return cameras, nil
+}
@@ -0,0 +1,63 @@
+package camera
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Size of one frame in a grid; 16:9 like most DOT cameras.
+const (
+	tileWidth  = 480
+	tileHeight = 270
+)
+
+// grid lays frames out two per row, each scaled to fit its tile and labeled with its caption.
+func grid(frames []image.Image, captions [][]string) *image.RGBA {
+	cols := min(2, len(frames))
+	rows := (len(frames) + cols - 1) / cols
+	img := image.NewRGBA(image.Rect(0, 0, cols*tileWidth, rows*tileHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	for n, frame := range frames {
+		tile := image.Rect(0, 0, tileWidth, tileHeight).Add(image.Pt(n%cols*tileWidth, n/cols*tileHeight))
+		scaleInto(img, fit(frame.Bounds(), tile), frame)
+		drawCaption(img, tile, captions[n])
+	}
+	return img
+}
+
+// fit is the largest rectangle with src's aspect ratio centered in tile.
+func fit(src, tile image.Rectangle) image.Rectangle {
+	w, h := tile.Dx(), tile.Dx()*src.Dy()/max(1, src.Dx())
+	if h > tile.Dy() {
+		w, h = tile.Dy()*src.Dx()/max(1, src.Dy()), tile.Dy()
+	}
+	min := tile.Min.Add(image.Pt((tile.Dx()-w)/2, (tile.Dy()-h)/2))
+	return image.Rectangle{Min: min, Max: min.Add(image.Pt(w, h))}
+}
+
+// scaleInto resamples src into r of dst, averaging the source pixels behind each
+// destination pixel so downscaled frames stay legible.
+func scaleInto(dst *image.RGBA, r image.Rectangle, src image.Image) {
+	sb := src.Bounds()
+	if r.Empty() || sb.Empty() {
+		return
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		sy0 := sb.Min.Y + (y-r.Min.Y)*sb.Dy()/r.Dy()
+		sy1 := max(sy0+1, sb.Min.Y+(y-r.Min.Y+1)*sb.Dy()/r.Dy())
+		for x := r.Min.X; x < r.Max.X; x++ {
+			sx0 := sb.Min.X + (x-r.Min.X)*sb.Dx()/r.Dx()
+			sx1 := max(sx0+1, sb.Min.X+(x-r.Min.X+1)*sb.Dx()/r.Dx())
+			var rs, gs, bs, n uint32
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, _ := src.At(sx, sy).RGBA()
+					rs, gs, bs, n = rs+cr, gs+cg, bs+cb, n+1
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{uint8(rs / n >> 8), uint8(gs / n >> 8), uint8(bs / n >> 8), 255})
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package camera
+
+import "sync/atomic"
+
+// Health counts camera fetch outcomes since startup.
+type Health struct {
+	Captured     int64 // Frames downloaded and decoded.
+	Failed       int64 // Downloads or decodes that failed.
+	Placeholders int64 // Frames skipped as blank or "camera unavailable" images.
+}
+
+var health struct {
+	captured, failed, placeholders atomic.Int64
+}
+
+// CurrentHealth returns the counts so far.
+func CurrentHealth() Health {
+	return Health{Captured: health.captured.Load(), Failed: health.failed.Load(), Placeholders: health.placeholders.Load()}
+}
@@ -0,0 +1,114 @@
+package camera
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"image"
+	"log"
+	"math"
+	"math/bits"
+)
+
+// placeholderDistance is the most hash bits a frame may differ by and still match a placeholder,
+// allowing for JPEG noise and a changing timestamp on the placeholder.
+const placeholderDistance = 6
+
+// minFrameContrast is the lowest luminance standard deviation of a real frame; flat grey or
+// black images fall below it.
+const minFrameContrast = 4.0
+
+// Hash is a 64-bit difference hash of a frame: each bit compares neighbouring pixels of a 9×8
+// greyscale thumbnail, so re-encoded or slightly altered copies hash within a few bits.
+func Hash(img image.Image) uint64 {
+	thumb := image.NewRGBA(image.Rect(0, 0, 9, 8))
+	scaleInto(thumb, thumb.Bounds(), img)
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			hash <<= 1
+			if luminance(thumb, x, y) < luminance(thumb, x+1, y) {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+func luminance(img *image.RGBA, x, y int) float64 {
+	c := img.RGBAAt(x, y)
+	return 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
+}
+
+// isBlank reports whether a frame is essentially one flat color.
+func isBlank(img image.Image) bool {
+	thumb := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	scaleInto(thumb, thumb.Bounds(), img)
+	var sum, sumSquares float64
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			l := luminance(thumb, x, y)
+			sum += l
+			sumSquares += l * l
+		}
+	}
+	n := float64(32 * 32)
+	mean := sum / n
+	return math.Sqrt(math.Max(0, sumSquares/n-mean*mean)) < minFrameContrast
+}
+
+// isPlaceholder reports whether a frame is blank or matches a known placeholder hash.
+func isPlaceholder(img image.Image, placeholders []uint64) bool {
+	if isBlank(img) {
+		return true
+	}
+	hash := Hash(img)
+	for _, p := range placeholders {
+		if bits.OnesCount64(hash^p) <= placeholderDistance {
+			return true
+		}
+	}
+	return false
+}
+
+// placeholderHashes loads the known placeholder hashes. Errors are logged and leave only the
+// blank-frame check in effect.
+func placeholderHashes(ctx context.Context, db *sql.DB) []uint64 {
+	rows, err := db.QueryContext(ctx, "SELECT hash FROM camera_placeholders")
+	if err != nil {
+		log.Printf("Warning: failed to load camera placeholders: %v", err)
+		return nil
+	}
+	defer rows.Close()
+	var hashes []uint64
+	for rows.Next() {
+		var hash int64
+		if err := rows.Scan(&hash); err != nil {
+			log.Printf("Warning: failed to read camera placeholder: %v", err)
+			return hashes
+		}
+		hashes = append(hashes, uint64(hash))
+	}
+	return hashes
+}
+
+// AddPlaceholder fetches a camera's current frame, which should be showing its "unavailable"
+// image, and records its hash so matching frames are skipped from now on.
+func AddPlaceholder(db *sql.DB, camera Camera) (uint64, error) {
+	data, err := fetch(context.Background(), camera)
+	if err != nil {
+		return 0, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode camera image: %w", err)
+	}
+	hash := Hash(img)
+	_, err = db.Exec("INSERT INTO camera_placeholders (hash, camera_name) VALUES ($1, $2) ON CONFLICT (hash) DO NOTHING",
+		int64(hash), camera.Name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save camera placeholder: %w", err)
+	}
+	return hash, nil
+}
@@ -0,0 +1,55 @@
+package camera
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Registered is a camera as listed by a camera registry.
+type Registered struct {
+	Name      string   `json:"name"`
+	ImageURL  string   `json:"image_url"`
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Road      string   `json:"road,omitempty"`
+	Bearing   *float64 `json:"bearing,omitempty"` // Degrees clockwise from north that it faces.
+}
+
+// Sync adds the registry's cameras to traffic_cameras and updates the ones already there,
+// matched by name. Cameras missing from the registry are kept, since a registry outage often
+// looks like a short list. It returns the number of cameras added.
+func Sync(db *sql.DB, cameras []Registered) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin camera sync: %w", err)
+	}
+	defer tx.Rollback()
+
+	var added int
+	for _, cam := range cameras {
+		var inserted bool
+		err := tx.QueryRow(`
+			WITH updated AS (
+				UPDATE traffic_cameras
+				SET image_url = $2, geom = ST_SetSRID(ST_MakePoint($4, $3), 4326)::geography,
+					road = COALESCE(NULLIF($5, ''), road), bearing = COALESCE($6, bearing)
+				WHERE name = $1
+				RETURNING 1
+			)
+			INSERT INTO traffic_cameras (name, image_url, geom, road, bearing)
+			SELECT $1, $2, ST_SetSRID(ST_MakePoint($4, $3), 4326)::geography, NULLIF($5, ''), $6
+			WHERE NOT EXISTS (SELECT 1 FROM updated)
+			RETURNING true`,
+			cam.Name, cam.ImageURL, cam.Latitude, cam.Longitude, cam.Road, cam.Bearing).Scan(&inserted)
+		if err != nil && err != sql.ErrNoRows {
+			return 0, fmt.Errorf("failed to sync camera %s: %w", cam.Name, err)
+		}
+		if inserted {
+			added++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit camera sync: %w", err)
+	}
+	return added, nil
+}
@@ -0,0 +1,50 @@
+package camera
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"time"
+
+	"github.com/mtickle/unity-alerts/internal/bitmapfont"
+)
+
+// jpegQuality is the quality of re-encoded frames.
+const jpegQuality = 90
+
+// caption is the text burned into a camera's frame. DOT frames rarely carry a timestamp of
+// their own, so without it readers cannot tell how fresh an image is.
+func caption(camera Camera, incidentID int, capturedAt time.Time) []string {
+	return []string{
+		camera.Name,
+		fmt.Sprintf("%s | INCIDENT #%d", capturedAt.Format("2006-01-02 15:04:05 MST"), incidentID),
+	}
+}
+
+// watermark copies a frame and writes lines along its bottom edge.
+func watermark(src image.Image, lines []string) *image.RGBA {
+	bounds := src.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(img, img.Bounds(), src, bounds.Min, draw.Src)
+	drawCaption(img, img.Bounds(), lines)
+	return img
+}
+
+// drawCaption darkens a band along the bottom of r and writes lines into it, scaling the text
+// with the width of r and cutting lines that do not fit.
+func drawCaption(img *image.RGBA, r image.Rectangle, lines []string) {
+	scale := max(1, r.Dx()/400)
+	pad := 3 * scale
+	lineHeight := (bitmapfont.GlyphHeight + 3) * scale
+	band := image.Rect(r.Min.X, r.Max.Y-len(lines)*lineHeight-2*pad+3*scale, r.Max.X, r.Max.Y)
+	draw.Draw(img, band, image.NewUniform(color.RGBA{A: 160}), image.Point{}, draw.Over)
+
+	for n, line := range lines {
+		runes := []rune(line)
+		for len(runes) > 0 && bitmapfont.TextWidth(string(runes), scale) > r.Dx()-2*pad {
+			runes = runes[:len(runes)-1]
+		}
+		bitmapfont.Draw(img, image.Pt(band.Min.X+pad, band.Min.Y+pad+n*lineHeight), string(runes), scale, color.White)
+	}
+}
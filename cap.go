@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/enrich"
+	"github.com/mtickle/unity-alerts/i18n"
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/sink/discord"
+)
+
+// capTimeFormat is CAP's dateTime format, which requires a numeric offset rather than "Z".
+const capTimeFormat = "2006-01-02T15:04:05-07:00"
+
+// CAPConfig publishes the incidents a route accepts as Common Alerting Protocol 1.2 alerts at
+// /api/cap, for emergency-management tools that speak CAP.
+type CAPConfig struct {
+	// Sender identifies this alerter to CAP consumers, e.g. "alerts@example.org".
+	Sender string `json:"sender"`
+	// Route's filters, privacy, verbosity, language and timezone apply to the alerts.
+	Route string `json:"route"`
+}
+
+func (c *CAPConfig) validate(cfg *Config) error {
+	if c == nil {
+		return nil
+	}
+	if c.Sender == "" || strings.ContainsAny(c.Sender, " ,<&") {
+		return fmt.Errorf("cap.sender must be set and have no spaces, commas, < or &")
+	}
+	if _, ok := cfg.Route(c.Route); !ok {
+		return fmt.Errorf("cap.route: unknown route %q", c.Route)
+	}
+	return nil
+}
+
+// capAlert is a CAP 1.2 alert message with a single info block.
+type capAlert struct {
+	XMLName    xml.Name `xml:"urn:oasis:names:tc:emergency:cap:1.2 alert"`
+	Identifier string   `xml:"identifier"`
+	Sender     string   `xml:"sender"`
+	Sent       string   `xml:"sent"`
+	Status     string   `xml:"status"`
+	MsgType    string   `xml:"msgType"`
+	Scope      string   `xml:"scope"`
+	Info       capInfo  `xml:"info"`
+}
+
+type capInfo struct {
+	Language     string         `xml:"language"`
+	Category     string         `xml:"category"`
+	Event        string         `xml:"event"`
+	ResponseType string         `xml:"responseType,omitempty"`
+	Urgency      string         `xml:"urgency"`
+	Severity     string         `xml:"severity"`
+	Certainty    string         `xml:"certainty"`
+	SenderName   string         `xml:"senderName,omitempty"`
+	Headline     string         `xml:"headline"`
+	Description  string         `xml:"description,omitempty"`
+	Parameters   []capParameter `xml:"parameter"`
+	Area         capArea        `xml:"area"`
+}
+
+type capParameter struct {
+	ValueName string `xml:"valueName"`
+	Value     string `xml:"value"`
+}
+
+type capArea struct {
+	AreaDesc string `xml:"areaDesc"`
+	Circle   string `xml:"circle,omitempty"` // "lat,lon radius" with the radius in km.
+}
+
+// capCategory maps a feed to the closest CAP category.
+func capCategory(inc incident.Incident) string {
+	switch inc.Source {
+	case incident.SourceNCDOT:
+		return "Transport"
+	case incident.SourceArcGISPolice:
+		return "Security"
+	}
+	if strings.Contains(strings.ToUpper(inc.EventType), "FIRE") {
+		return "Fire"
+	}
+	return "Rescue"
+}
+
+// capSeverity maps the route's severity for an incident to CAP's scale.
+func capSeverity(severity int) string {
+	switch {
+	case severity >= 4:
+		return "Extreme"
+	case severity == 3:
+		return "Severe"
+	case severity == 2:
+		return "Moderate"
+	case severity == 1:
+		return "Minor"
+	}
+	return "Unknown"
+}
+
+// buildCAPAlert renders one incident, as the CAP route would show it, as a CAP alert. Cleared
+// incidents are sent as an all-clear with past urgency.
+func buildCAPAlert(cfg *Config, route RouteConfig, inc incident.Incident, status string) capAlert {
+	opts := cfg.RenderOptions(route, inc.Source)
+	language := i18n.DefaultLanguage
+	for _, lang := range []string{cfg.Language, route.Language} {
+		if lang != "" {
+			language = lang
+		}
+	}
+
+	alert := capAlert{
+		Identifier: fmt.Sprintf("unity-alerts-%d", inc.ID),
+		Sender:     cfg.CAP.Sender,
+		Sent:       inc.Timestamp.In(opts.Location).Format(capTimeFormat),
+		Status:     "Actual",
+		MsgType:    "Alert",
+		Scope:      "Public",
+		Info: capInfo{
+			Language:   language,
+			Category:   capCategory(inc),
+			Event:      inc.EventType,
+			Urgency:    "Immediate",
+			Severity:   capSeverity(route.severity(inc)),
+			Certainty:  "Observed",
+			SenderName: inc.Source,
+			Headline:   discord.Truncate(fmt.Sprintf("%s — %s", inc.EventType, opts.Address(inc)), 160),
+			Area:       capArea{AreaDesc: opts.Address(inc)},
+		},
+	}
+	if inc.IsTest {
+		alert.Status = "Test"
+	}
+	if status == "cleared" {
+		alert.Info.ResponseType = "AllClear"
+		alert.Info.Urgency = "Past"
+	}
+	if payload, err := discord.BuildPayload("", inc, enrich.Result{}, opts); err == nil {
+		alert.Info.Description = discord.PlainText("", payload.Embeds)
+	}
+	if inc.Latitude.Valid && inc.Longitude.Valid && !opts.Private(inc) {
+		alert.Info.Area.Circle = fmt.Sprintf("%.6f,%.6f 0", inc.Latitude.Float64, inc.Longitude.Float64)
+	}
+	if jurisdiction := incident.Jurisdiction(inc); jurisdiction != "" {
+		alert.Info.Parameters = append(alert.Info.Parameters, capParameter{"jurisdiction", jurisdiction})
+	}
+	if len(inc.Tags) > 0 {
+		alert.Info.Parameters = append(alert.Info.Parameters, capParameter{"tags", strings.Join(inc.Tags, " ")})
+	}
+	return alert
+}
+
+// capFeed is an Atom feed with one CAP alert inline per entry, the usual way CAP alerts are
+// published for polling.
+type capFeed struct {
+	XMLName xml.Name       `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string         `xml:"id"`
+	Title   string         `xml:"title"`
+	Updated string         `xml:"updated"`
+	Author  string         `xml:"author>name"`
+	Entries []capFeedEntry `xml:"entry"`
+}
+
+type capFeedEntry struct {
+	ID      string `xml:"id"`
+	Title   string `xml:"title"`
+	Updated string `xml:"updated"`
+	Link    struct {
+		Href string `xml:"href,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"link"`
+	Content struct {
+		Type  string   `xml:"type,attr"`
+		Alert capAlert `xml:"alert"`
+	} `xml:"content"`
+}
+
+// routeIncidents returns the incidents matching clause that a route accepts, with keyword
+// rules applied, and their statuses.
+func (a *app) routeIncidents(cfg *Config, route RouteConfig, clause string, args ...interface{}) ([]incident.Incident, []string, error) {
+	incidents, statuses, err := queryIncidents(cfg, a.db, clause, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	var accepted []incident.Incident
+	var acceptedStatuses []string
+	for n, inc := range incidents {
+		if !cfg.Filter.Apply(&inc) || !route.Matches(inc) {
+			continue
+		}
+		accepted = append(accepted, inc)
+		acceptedStatuses = append(acceptedStatuses, statuses[n])
+	}
+	return accepted, acceptedStatuses, nil
+}
+
+// handleCAP serves GET /api/cap?hours=24, an Atom feed of the CAP route's recent incidents,
+// and GET /api/cap/123, one incident's CAP alert.
+func (a *app) handleCAP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cfg := a.currentConfig()
+	if cfg.CAP == nil {
+		http.NotFound(w, r)
+		return
+	}
+	route, _ := cfg.Route(cfg.CAP.Route)
+
+	if idText := strings.TrimPrefix(r.URL.Path, "/api/cap/"); idText != r.URL.Path && idText != "" {
+		id, err := strconv.Atoi(idText)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		incidents, statuses, err := a.routeIncidents(cfg, route, "WHERE {id} = $1", id)
+		if err != nil {
+			log.Printf("Error serving CAP alert: %v", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if len(incidents) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		writeXML(w, "application/cap+xml", buildCAPAlert(cfg, route, incidents[0], statuses[0]))
+		return
+	}
+
+	hours, err := strconv.Atoi(r.URL.Query().Get("hours"))
+	if err != nil || hours <= 0 || hours > 168 {
+		hours = 24
+	}
+	incidents, statuses, err := a.routeIncidents(cfg, route, "WHERE {timestamp} >= $1 ORDER BY {timestamp} DESC, {id} DESC LIMIT 500",
+		time.Now().Add(-time.Duration(hours)*time.Hour))
+	if err != nil {
+		log.Printf("Error serving CAP feed: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	feed := capFeed{
+		ID:      "urn:unity-alerts:cap:" + cfg.CAP.Sender,
+		Title:   "unity-alerts CAP feed",
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Author:  cfg.CAP.Sender,
+	}
+	for n, inc := range incidents {
+		alert := buildCAPAlert(cfg, route, inc, statuses[n])
+		var entry capFeedEntry
+		entry.ID = "urn:unity-alerts:cap:" + alert.Identifier
+		entry.Title = alert.Info.Headline
+		entry.Updated = inc.Timestamp.UTC().Format(time.RFC3339)
+		entry.Link.Href = fmt.Sprintf("/api/cap/%d", inc.ID)
+		entry.Link.Type = "application/cap+xml"
+		entry.Content.Type = "application/cap+xml"
+		entry.Content.Alert = alert
+		feed.Entries = append(feed.Entries, entry)
+	}
+	writeXML(w, "application/atom+xml", feed)
+}
+
+// writeXML encodes v as an XML document.
+func writeXML(w http.ResponseWriter, contentType string, v interface{}) {
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Printf("Warning: failed to encode XML response: %v", err)
+	}
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// maxCAPLinks caps the alerts fetched by link from one feed per poll.
+const maxCAPLinks = 50
+
+var capFeedClient = &http.Client{Timeout: 20 * time.Second}
+
+// CAPFeedConfig polls a feed of Common Alerting Protocol alerts, such as the IPAWS public feed
+// or a county emergency-management Atom feed, and stores its actual alerts as CAP incidents,
+// which routes then filter like any other source.
+type CAPFeedConfig struct {
+	// Name is shown as the alert's source, e.g. "IPAWS".
+	Name string `json:"name"`
+	// URL returns an Atom feed of alerts (inline or linked), a list of alerts or a single
+	// alert. ${VAR} references are expanded.
+	URL string `json:"url"`
+	// Geocodes keeps only alerts for these SAME, FIPS or UGC codes, e.g. "037183" for Wake
+	// County. Without them, alerts are kept when their area falls inside the configured bounds.
+	Geocodes []string `json:"geocodes,omitempty"`
+	// PollInterval polls the feed less often than every run, e.g. "15m" for a slow feed, so it
+	// does not hold up the others. Feeds are polled at most as often as the new_incidents job
+	// runs.
+	PollInterval string `json:"poll_interval,omitempty"`
+}
+
+func (f CAPFeedConfig) validate() error {
+	if f.Name == "" || f.URL == "" {
+		return fmt.Errorf("needs a name and url")
+	}
+	if f.PollInterval != "" {
+		if d, err := time.ParseDuration(f.PollInterval); err != nil || d <= 0 {
+			return fmt.Errorf("invalid poll_interval %q", f.PollInterval)
+		}
+	}
+	return nil
+}
+
+// cursor names the feed's row in source_cursors.
+func (f CAPFeedConfig) cursor() string {
+	return "cap:" + f.Name
+}
+
+// due reports whether the feed's poll interval has passed since it was last polled.
+func (f CAPFeedConfig) due(cursor postgres.Cursor, now time.Time) bool {
+	if f.PollInterval == "" || !cursor.PolledAt.Valid {
+		return true
+	}
+	interval, _ := time.ParseDuration(f.PollInterval)
+	// Allow for the time the previous run took to reach the feed.
+	return now.Sub(cursor.PolledAt.Time) >= interval-time.Second
+}
+
+// capMessage is a CAP 1.1 or 1.2 alert as read from a feed; namespaces are ignored.
+type capMessage struct {
+	Identifier string    `xml:"identifier"`
+	Sender     string    `xml:"sender"`
+	Sent       string    `xml:"sent"`
+	Status     string    `xml:"status"`
+	MsgType    string    `xml:"msgType"`
+	References string    `xml:"references"`
+	Info       []capText `xml:"info"`
+}
+
+type capText struct {
+	Language     string   `xml:"language"`
+	Event        string   `xml:"event"`
+	ResponseType []string `xml:"responseType"`
+	Urgency      string   `xml:"urgency"`
+	Severity     string   `xml:"severity"`
+	Certainty    string   `xml:"certainty"`
+	Expires      string   `xml:"expires"`
+	SenderName   string   `xml:"senderName"`
+	Headline     string   `xml:"headline"`
+	Description  string   `xml:"description"`
+	Instruction  string   `xml:"instruction"`
+	Area         []struct {
+		AreaDesc string         `xml:"areaDesc"`
+		Polygon  []string       `xml:"polygon"`
+		Circle   []string       `xml:"circle"`
+		Geocode  []capParameter `xml:"geocode"`
+	} `xml:"area"`
+}
+
+// capSeverities maps CAP severities to the 1-4 scale routes' min_severity uses.
+var capSeverities = map[string]int{"Minor": 1, "Moderate": 2, "Severe": 3, "Extreme": 4}
+
+// parseCAPFeed reads every alert in a feed document, and the links to CAP alerts when it has
+// none inline.
+func parseCAPFeed(data []byte) ([]capMessage, []string, error) {
+	var alerts []capMessage
+	var links []string
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse CAP feed: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "alert":
+			var m capMessage
+			if err := dec.DecodeElement(&m, &start); err != nil {
+				return nil, nil, fmt.Errorf("failed to parse CAP alert: %w", err)
+			}
+			alerts = append(alerts, m)
+		case "link":
+			var href, linkType string
+			for _, attr := range start.Attr {
+				switch attr.Name.Local {
+				case "href":
+					href = attr.Value
+				case "type":
+					linkType = attr.Value
+				}
+			}
+			if href != "" && strings.Contains(linkType, "cap") {
+				links = append(links, href)
+			}
+		}
+	}
+	if len(alerts) > 0 {
+		links = nil
+	}
+	return alerts, links, nil
+}
+
+// fetchCAP downloads a feed or alert.
+func fetchCAP(url string) ([]byte, error) {
+	resp, err := capFeedClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch CAP feed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch CAP feed: %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 20<<20))
+}
+
+// pollCAPFeeds stores the new alerts of every CAP feed that is due as incidents, and clears
+// the incidents of alerts that were cancelled, updated or have expired. Each feed keeps its own
+// cursor, so alerts sent before the latest one it stored are skipped.
+func (a *app) pollCAPFeeds(cfg *Config) {
+	if len(cfg.CAPFeeds) == 0 {
+		return
+	}
+	for _, feed := range cfg.CAPFeeds {
+		cursor, err := postgres.SourceCursor(a.db, feed.cursor())
+		if err != nil {
+			log.Printf("Error polling CAP feed %s: %v", feed.Name, err)
+			continue
+		}
+		now := time.Now()
+		if !feed.due(cursor, now) {
+			continue
+		}
+		stored, highWater, err := a.pollCAPFeed(cfg, feed, cursor.HighWater.Time)
+		if err := postgres.SaveSourceCursor(a.db, feed.cursor(), now, highWater); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		if err != nil {
+			log.Printf("Error polling CAP feed %s: %v", feed.Name, err)
+			continue
+		}
+		if stored > 0 {
+			log.Printf("Stored %d new alert(s) from CAP feed %s.", stored, feed.Name)
+		}
+	}
+	if _, err := a.db.Exec(cfg.SQL(`UPDATE {incidents} SET {status} = 'cleared'
+		WHERE {source} = $1 AND {status} = 'active' AND NULLIF({details}->'raw_incident'->>'expires', '')::timestamptz < now()`),
+		incident.SourceCAP); err != nil {
+		log.Printf("Warning: failed to clear expired CAP alerts: %v", err)
+	}
+}
+
+// pollCAPFeed fetches one feed and stores its alerts sent since the feed's high-water mark,
+// returning how many were new and the new high-water mark, which is zero on failure.
+func (a *app) pollCAPFeed(cfg *Config, feed CAPFeedConfig, since time.Time) (int, time.Time, error) {
+	data, err := fetchCAP(os.ExpandEnv(feed.URL))
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	alerts, links, err := parseCAPFeed(data)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	for n, link := range links {
+		if n == maxCAPLinks {
+			log.Printf("Warning: CAP feed %s links more than %d alerts; fetching the first %d.", feed.Name, maxCAPLinks, maxCAPLinks)
+			break
+		}
+		if a.capLinks[link] {
+			continue
+		}
+		data, err := fetchCAP(link)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		linked, _, err := parseCAPFeed(data)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		if a.capLinks == nil || len(a.capLinks) > 10000 {
+			a.capLinks = make(map[string]bool)
+		}
+		a.capLinks[link] = true
+		alerts = append(alerts, linked...)
+	}
+
+	stored, highWater := 0, since
+	for _, m := range alerts {
+		sent, err := time.Parse(time.RFC3339, m.Sent)
+		if err == nil && sent.Before(since) {
+			continue
+		}
+		isNew, err := a.storeCAPAlert(cfg, feed, m)
+		if err != nil {
+			return stored, time.Time{}, err
+		}
+		if isNew {
+			stored++
+		}
+		if sent.After(highWater) {
+			highWater = sent
+		}
+	}
+	return stored, highWater, nil
+}
+
+// storeCAPAlert stores one actual alert as an active incident unless it is already stored. A
+// cancellation, update or all-clear clears the incidents of the alerts it references first.
+func (a *app) storeCAPAlert(cfg *Config, feed CAPFeedConfig, m capMessage) (bool, error) {
+	if m.Status != "Actual" || m.Identifier == "" || len(m.Info) == 0 {
+		return false, nil
+	}
+	info := m.Info[0]
+	for _, i := range m.Info {
+		if cfg.Language != "" && strings.HasPrefix(strings.ToLower(i.Language), cfg.Language) {
+			info = i
+			break
+		}
+	}
+
+	allClear := false
+	for _, r := range info.ResponseType {
+		allClear = allClear || r == "AllClear"
+	}
+	if m.MsgType == "Update" || m.MsgType == "Cancel" || allClear {
+		var referenced []string
+		for _, ref := range strings.Fields(m.References) {
+			if parts := strings.Split(ref, ","); len(parts) == 3 {
+				referenced = append(referenced, parts[1])
+			}
+		}
+		if len(referenced) > 0 {
+			if _, err := a.db.Exec(cfg.SQL(`UPDATE {incidents} SET {status} = 'cleared'
+				WHERE {source} = $1 AND {source_id} = ANY($2) AND {status} = 'active'`), incident.SourceCAP, pq.Array(referenced)); err != nil {
+				return false, fmt.Errorf("failed to clear referenced CAP alerts: %w", err)
+			}
+		}
+		if m.MsgType == "Cancel" || allClear {
+			return false, nil
+		}
+	}
+	if expires, err := time.Parse(time.RFC3339, info.Expires); err == nil && expires.Before(time.Now()) {
+		return false, nil
+	}
+
+	var areaNames []string
+	var polygons [][][][2]float64
+	matchesGeocode := false
+	for _, area := range info.Area {
+		areaNames = append(areaNames, area.AreaDesc)
+		for _, p := range area.Polygon {
+			if ring := parseCAPPolygon(p); ring != nil {
+				polygons = append(polygons, [][][2]float64{ring})
+			}
+		}
+		for _, c := range area.Circle {
+			if ring := parseCAPCircle(c); ring != nil {
+				polygons = append(polygons, [][][2]float64{ring})
+			}
+		}
+		for _, code := range area.Geocode {
+			for _, want := range feed.Geocodes {
+				matchesGeocode = matchesGeocode || code.Value == want
+			}
+		}
+	}
+
+	var lat, lon float64
+	located := false
+	if len(polygons) > 0 {
+		lat, lon = ringCenter(polygons[0][0])
+		located = true
+	}
+	switch {
+	case len(feed.Geocodes) > 0:
+		if !matchesGeocode {
+			return false, nil
+		}
+	case cfg.Bounds != nil:
+		if !located || !cfg.Bounds.plausible(lat, lon) {
+			return false, nil
+		}
+	}
+
+	sent, err := time.Parse(time.RFC3339, m.Sent)
+	if err != nil {
+		sent = time.Now()
+	}
+	raw := map[string]interface{}{
+		"identifier":    m.Identifier,
+		"sender":        m.Sender,
+		"sender_name":   info.SenderName,
+		"msg_type":      m.MsgType,
+		"headline":      info.Headline,
+		"description":   info.Description,
+		"instruction":   info.Instruction,
+		"severity":      capSeverities[info.Severity],
+		"severity_name": info.Severity,
+		"urgency":       info.Urgency,
+		"certainty":     info.Certainty,
+		"expires":       info.Expires,
+	}
+	details := map[string]interface{}{"feed": feed.Name, "raw_incident": raw}
+	if len(polygons) > 0 {
+		details["geometry"] = map[string]interface{}{"type": "MultiPolygon", "coordinates": polygons}
+	}
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return false, fmt.Errorf("error encoding CAP alert details: %w", err)
+	}
+
+	var latitude, longitude interface{}
+	if located {
+		latitude, longitude = lat, lon
+	}
+	result, err := a.db.Exec(cfg.SQL(`INSERT INTO {incidents} ({source}, {source_id}, {event_type}, {address}, {latitude}, {longitude}, {timestamp}, {details}, {status}, {is_test})
+		SELECT $1, $2, $3, $4, $5, $6, $7, $8, 'active', false
+		WHERE NOT EXISTS (SELECT 1 FROM {incidents} WHERE {source} = $1 AND {source_id} = $2)`),
+		incident.SourceCAP, m.Identifier, info.Event, strings.Join(areaNames, "; "), latitude, longitude, sent, detailsJSON)
+	if err != nil {
+		return false, fmt.Errorf("failed to store CAP alert %s: %w", m.Identifier, err)
+	}
+	inserted, _ := result.RowsAffected()
+	return inserted > 0, nil
+}
+
+// parseCAPPolygon reads a CAP polygon, "lat,lon lat,lon ...", as a ring of [longitude,
+// latitude] points, or nil when it is malformed.
+func parseCAPPolygon(s string) [][2]float64 {
+	var ring [][2]float64
+	for _, pair := range strings.Fields(s) {
+		lat, lon, ok := parseCoordinates(pair)
+		if !ok {
+			return nil
+		}
+		ring = append(ring, [2]float64{lon, lat})
+	}
+	if len(ring) < 4 {
+		return nil
+	}
+	return ring
+}
+
+// parseCAPCircle approximates a CAP circle, "lat,lon radius" with the radius in km, as a
+// 24-sided ring.
+func parseCAPCircle(s string) [][2]float64 {
+	center, radiusText, ok := strings.Cut(strings.TrimSpace(s), " ")
+	if !ok {
+		return nil
+	}
+	lat, lon, ok := parseCoordinates(center)
+	radius, err := strconv.ParseFloat(strings.TrimSpace(radiusText), 64)
+	if !ok || err != nil || radius <= 0 {
+		return nil
+	}
+	const sides = 24
+	dLat := radius / 111.32
+	dLon := dLat / math.Cos(lat*math.Pi/180)
+	var ring [][2]float64
+	for n := 0; n <= sides; n++ {
+		angle := 2 * math.Pi * float64(n%sides) / sides
+		ring = append(ring, [2]float64{lon + dLon*math.Cos(angle), lat + dLat*math.Sin(angle)})
+	}
+	return ring
+}
+
+// ringCenter is the average of a ring's points, which is good enough to place an alert's area
+// on a map and check it against the bounds.
+func ringCenter(ring [][2]float64) (lat, lon float64) {
+	points := ring
+	if len(points) > 1 && points[0] == points[len(points)-1] {
+		points = points[:len(points)-1]
+	}
+	for _, p := range points {
+		lon += p[0]
+		lat += p[1]
+	}
+	return lat / float64(len(points)), lon / float64(len(points))
+}
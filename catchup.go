@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/sink/discord"
+)
+
+// CatchUpConfig keeps a run after extended downtime from posting hundreds of stale alerts.
+// When at least MinBacklog new incidents are older than StaleAfter, the ones each route would
+// have alerted are summarized in one digest per route instead; only the newer ones get
+// individual alerts.
+type CatchUpConfig struct {
+	MinBacklog int    `json:"min_backlog,omitempty"` // Default 25.
+	StaleAfter string `json:"stale_after,omitempty"` // Default "30m".
+}
+
+func (c *CatchUpConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.MinBacklog < 0 {
+		return fmt.Errorf("catch_up.min_backlog must not be negative")
+	}
+	if c.StaleAfter != "" {
+		if d, err := time.ParseDuration(c.StaleAfter); err != nil || d <= 0 {
+			return fmt.Errorf("catch_up.stale_after: invalid duration %q", c.StaleAfter)
+		}
+	}
+	return nil
+}
+
+// settings returns the configuration with defaults filled in.
+func (c CatchUpConfig) settings() (minBacklog int, staleAfter time.Duration) {
+	minBacklog, staleAfter = 25, 30*time.Minute
+	if c.MinBacklog > 0 {
+		minBacklog = c.MinBacklog
+	}
+	if c.StaleAfter != "" {
+		staleAfter, _ = time.ParseDuration(c.StaleAfter)
+	}
+	return minBacklog, staleAfter
+}
+
+// staleBacklog returns the IDs of the incidents older than stale_after when there are at least
+// min_backlog of them, or nil when the run isn't catching up.
+func staleBacklog(cfg *Config, incidents []incident.Incident) map[int]bool {
+	if cfg.CatchUp == nil {
+		return nil
+	}
+	minBacklog, staleAfter := cfg.CatchUp.settings()
+	cutoff := time.Now().Add(-staleAfter)
+	stale := make(map[int]bool)
+	for _, i := range incidents {
+		if i.Timestamp.Before(cutoff) && !i.IsTest {
+			stale[i.ID] = true
+		}
+	}
+	if len(stale) < minBacklog {
+		return nil
+	}
+	log.Printf("Catching up on %d incident(s) older than %s; posting a digest instead of alerts.", len(stale), staleAfter)
+	return stale
+}
+
+// catchUp posts one digest per route of the stale incidents that passed the filters, mutes,
+// pauses and schedules, and returns the incidents still to be alerted individually. A stale
+// incident is marked handled once every route it was headed for has its digest; if one of
+// those fails, it waits for the next run's digest.
+func (a *app) catchUp(cfg *Config, pending []*pendingIncident, stale map[int]bool) []*pendingIncident {
+	if len(stale) == 0 {
+		return pending
+	}
+	var fresh, digested []*pendingIncident
+	for _, p := range pending {
+		if stale[p.incident.ID] {
+			digested = append(digested, p)
+		} else {
+			fresh = append(fresh, p)
+		}
+	}
+
+	failed := make(map[string]bool)
+	for _, route := range cfg.Routes {
+		var matched []incident.Incident
+		for _, p := range digested {
+			if hasRoute(p.routes, route.Name) {
+				matched = append(matched, p.incident)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		opts := cfg.RenderOptions(route, "")
+		payload := discord.WebhookPayload{Username: opts.T("bot_username"), Embeds: []discord.Embed{buildCatchUpEmbed(matched, opts)}}
+		if _, err := reportMessenger(route).Send(payload); err != nil {
+			log.Printf("Error posting catch-up digest to route %q: %v", route.Name, err)
+			failed[route.Name] = true
+		}
+	}
+	for _, p := range digested {
+		sent := true
+		for _, route := range p.routes {
+			sent = sent && !failed[route.Name]
+		}
+		if sent {
+			a.markHandled(cfg, p.incident)
+		}
+	}
+	return fresh
+}
+
+func hasRoute(routes []RouteConfig, name string) bool {
+	for _, route := range routes {
+		if route.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// buildCatchUpEmbed summarizes a stale backlog by source and event type.
+func buildCatchUpEmbed(incidents []incident.Incident, opts discord.RenderOptions) discord.Embed {
+	bySource := make(map[string]int)
+	byType := make(map[string]int)
+	from, to := incidents[0].Timestamp, incidents[0].Timestamp
+	for _, i := range incidents {
+		bySource[i.Source]++
+		byType[strings.TrimSpace(i.EventType)]++
+		if i.Timestamp.Before(from) {
+			from = i.Timestamp
+		}
+		if i.Timestamp.After(to) {
+			to = i.Timestamp
+		}
+	}
+
+	var sources []string
+	for source, n := range bySource {
+		sources = append(sources, fmt.Sprintf("%s: %d", source, n))
+	}
+	sort.Strings(sources)
+
+	types := make([]string, 0, len(byType))
+	for t := range byType {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(x, y int) bool {
+		if byType[types[x]] != byType[types[y]] {
+			return byType[types[x]] > byType[types[y]]
+		}
+		return types[x] < types[y]
+	})
+	var topTypes []string
+	for _, t := range types {
+		topTypes = append(topTypes, fmt.Sprintf("%s — %d", discord.SanitizeFeedText(t), byType[t]))
+	}
+
+	return discord.Embed{
+		Title: discord.Truncate("⏪ "+fmt.Sprintf(opts.T("catchup_title"), len(incidents)), discord.MaxEmbedTitle),
+		Color: 9807270, // Grey
+		Fields: []discord.EmbedField{
+			{Name: opts.T("catchup_period"), Value: opts.FormatLocalTime(from) + " – " + opts.FormatLocalTime(to)},
+			{Name: opts.T("stats_by_source"), Value: strings.Join(sources, "\n"), Inline: true},
+			{Name: opts.T("stats_top_types"), Value: discord.Truncate(strings.Join(topTypes, "\n"), discord.MaxFieldValue), Inline: true},
+		},
+		Footer:    discord.EmbedFooter{Text: opts.T("catchup_footer")},
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,88 @@
+// Package chart renders small PNG charts for report embeds, using only the standard library.
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+
+	"github.com/mtickle/unity-alerts/internal/bitmapfont"
+)
+
+// Size of a rendered chart, in pixels.
+const (
+	Width  = 720
+	Height = 300
+)
+
+var (
+	background = color.RGBA{0x2b, 0x2d, 0x31, 0xff} // Discord's dark theme.
+	barColor   = color.RGBA{0x58, 0x65, 0xf2, 0xff}
+	highColor  = color.RGBA{0xe6, 0x7e, 0x22, 0xff}
+	refColor   = color.RGBA{0xdb, 0xde, 0xe1, 0xff}
+	textColor  = color.RGBA{0xdb, 0xde, 0xe1, 0xff}
+	axisColor  = color.RGBA{0x4e, 0x50, 0x58, 0xff}
+)
+
+// Bars renders a bar chart as PNG. Each bar is captioned with its label (empty labels are
+// skipped); the bar at index highlight, if any, is drawn in a second color. When reference
+// has a value per bar, it is marked across the bar, e.g. an average to compare against.
+func Bars(title string, labels []string, values, reference []float64, highlight int) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, Width, Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	bitmapfont.Draw(img, image.Pt(16, 12), title, 2, textColor)
+
+	top := 12 + 2*bitmapfont.GlyphHeight + 16
+	plot := image.Rect(48, top, Width-16, Height-28)
+	draw.Draw(img, image.Rect(plot.Min.X, plot.Max.Y, plot.Max.X, plot.Max.Y+1), image.NewUniform(axisColor), image.Point{}, draw.Src)
+
+	peak := 0.0
+	for n, v := range values {
+		peak = math.Max(peak, v)
+		if n < len(reference) {
+			peak = math.Max(peak, reference[n])
+		}
+	}
+	if peak == 0 {
+		peak = 1
+	}
+	peakLabel := fmt.Sprintf("%g", math.Round(peak*10)/10)
+	bitmapfont.Draw(img, image.Pt(plot.Min.X-8-bitmapfont.TextWidth(peakLabel, 1), plot.Min.Y), peakLabel, 1, textColor)
+	bitmapfont.Draw(img, image.Pt(plot.Min.X-8-bitmapfont.TextWidth("0", 1), plot.Max.Y-bitmapfont.GlyphHeight), "0", 1, textColor)
+
+	if len(values) == 0 {
+		return encode(img)
+	}
+	slot := plot.Dx() / len(values)
+	height := func(v float64) int { return int(math.Round(v / peak * float64(plot.Dy()))) }
+	for n, v := range values {
+		x := plot.Min.X + n*slot
+		c := barColor
+		if n == highlight {
+			c = highColor
+		}
+		bar := image.Rect(x+slot/6, plot.Max.Y-height(v), x+slot-slot/6, plot.Max.Y)
+		draw.Draw(img, bar, image.NewUniform(c), image.Point{}, draw.Src)
+		if n < len(reference) {
+			y := plot.Max.Y - height(reference[n])
+			draw.Draw(img, image.Rect(x+1, y-1, x+slot-1, y+1), image.NewUniform(refColor), image.Point{}, draw.Src)
+		}
+		if n < len(labels) && labels[n] != "" {
+			lx := x + (slot-bitmapfont.TextWidth(labels[n], 1))/2
+			bitmapfont.Draw(img, image.Pt(lx, plot.Max.Y+8), labels[n], 1, textColor)
+		}
+	}
+	return encode(img)
+}
+
+func encode(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode chart: %w", err)
+	}
+	return buf.Bytes(), nil
+}
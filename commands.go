@@ -0,0 +1,453 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mtickle/unity-alerts/camera"
+	"github.com/mtickle/unity-alerts/enrich"
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/sink/discord"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// runSubcommand dispatches the one-off commands that run instead of a normal poll.
+func (a *app) runSubcommand(name string, args []string) error {
+	switch name {
+	case "replay":
+		return a.replay(args)
+	case "simulate":
+		return a.simulate(args)
+	case "status":
+		return a.status(args)
+	case "mute":
+		return a.mute(args)
+	case "watch":
+		return a.watch(args)
+	case "tenant":
+		return a.tenant(args)
+	case "placeholder":
+		return a.placeholder(args)
+	case "stats":
+		return a.stats(args)
+	case "export":
+		return a.export(args)
+	case "backfill":
+		return a.backfill(args)
+	default:
+		return fmt.Errorf("unknown command %q", name)
+	}
+}
+
+// subcommand splits a command's subcommand, one of names, from the rest of its arguments.
+func subcommand(command string, args []string, names ...string) (string, []string, error) {
+	if len(args) == 0 || !slices.Contains(names, args[0]) {
+		return "", nil, fmt.Errorf("%s requires a subcommand: %s", command, strings.Join(names, ", "))
+	}
+	return args[0], args[1:], nil
+}
+
+// replay rebuilds an incident's alert from its stored details and sends it again. The
+// messages previously sent to the target routes are replaced only once the new alert is out,
+// and are edited to point to it. With --edit, the recorded messages are re-rendered in place
+// instead, keeping their position in the channel.
+//
+//	unity-alerts replay --id 123 [--to route-name] [--force] [--edit]
+func (a *app) replay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	id := fs.Int("id", 0, "incident ID to resend")
+	to := fs.String("to", "", "only send to this route (default: every route that accepts the incident)")
+	force := fs.Bool("force", false, "send even if the incident has cleared or the route does not accept its source")
+	edit := fs.Bool("edit", false, "edit the messages already sent instead of sending new ones")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *id == 0 {
+		return fmt.Errorf("replay requires --id")
+	}
+
+	cfg := a.currentConfig()
+	if *edit {
+		routes, err := a.rerender(cfg, *id, *to, *force)
+		if err != nil {
+			return err
+		}
+		log.Printf("Re-rendered incident %d in %d route(s).", *id, routes)
+		return nil
+	}
+	routes, err := a.resend(cfg, *id, *to, *force)
+	if err != nil {
+		return err
+	}
+	log.Printf("Replayed incident %d to %d route(s).", *id, routes)
+	return nil
+}
+
+// replayTarget loads an incident to replay and the routes it goes to: the one named by to,
+// or every route that accepts it when to is empty. force allows a cleared incident, and a
+// route that does not accept it.
+func (a *app) replayTarget(cfg *Config, id int, to string, force bool) (incident.Incident, string, []RouteConfig, error) {
+	incidents, statuses, err := queryIncidents(cfg, a.db, "WHERE {id} = $1", id)
+	if err != nil {
+		return incident.Incident{}, "", nil, err
+	}
+	if len(incidents) == 0 {
+		return incident.Incident{}, "", nil, fmt.Errorf("incident %d not found", id)
+	}
+	inc, status := incidents[0], statuses[0]
+	if status != "active" && !force {
+		return inc, status, nil, fmt.Errorf("incident %d is %s; use --force to replay it anyway", inc.ID, status)
+	}
+
+	var routes []RouteConfig
+	if to != "" {
+		route, ok := cfg.Route(to)
+		if !ok {
+			return inc, status, nil, fmt.Errorf("no route named %q", to)
+		}
+		if !route.Matches(inc) && !force {
+			return inc, status, nil, fmt.Errorf("route %q does not accept %s incidents; use --force to send anyway", route.Name, inc.Source)
+		}
+		routes = []RouteConfig{route}
+	} else {
+		for _, route := range cfg.Routes {
+			if route.Matches(inc) {
+				routes = append(routes, route)
+			}
+		}
+	}
+	if len(routes) == 0 {
+		return inc, status, nil, fmt.Errorf("no route accepts %s incidents", inc.Source)
+	}
+	return inc, status, routes, nil
+}
+
+// resend sends an incident's alert again to one route, or to every route that accepts it
+// when to is empty, and returns how many routes it went to. force sends even if the incident
+// has cleared or the route does not accept it. A route's earlier messages are only retired
+// once its new alert went out, so a failed resend leaves the old alert in place.
+func (a *app) resend(cfg *Config, id int, to string, force bool) (int, error) {
+	inc, status, routes, err := a.replayTarget(cfg, id, to, force)
+	if err != nil {
+		return 0, err
+	}
+	previous, err := postgres.AlertMessagesFor(a.db, inc.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	p := a.enrichNow(cfg, inc, routes)
+	p.resend = true
+	if err := a.sendNow(cfg, p); err != nil {
+		return 0, err
+	}
+	for _, route := range routes {
+		if !p.done[route.Name] {
+			log.Printf("Warning: incident %d was not resent to route %q; keeping its earlier alert.", inc.ID, route.Name)
+			continue
+		}
+		a.retireMessages(cfg, route, inc, previous)
+	}
+	// A cleared incident keeps a NULL discord_message_id so it is not cleared a second time.
+	if status == "active" {
+		a.finishIncident(cfg, p)
+	}
+	return len(p.done), nil
+}
+
+// retireMessages edits the messages a route had for an incident before it was resent to point
+// to the new alert, and forgets them so clearing the incident only updates the new one.
+// Batched messages hold other incidents too; they are left alone and still clear with it.
+func (a *app) retireMessages(cfg *Config, route RouteConfig, inc incident.Incident, previous []postgres.AlertMessage) {
+	opts := cfg.RenderOptions(route, inc.Source)
+	messenger := route.messengerFor(inc)
+	for _, m := range previous {
+		if m.Route != route.Name || m.EmbedIndex.Valid {
+			continue
+		}
+		err := discord.UpdateReplaced(messenger, m.MessageID, opts)
+		if err != nil && !discord.IsNotFound(err) {
+			// Kept, so the old message is still updated when the incident clears.
+			log.Printf("Warning: failed to update replaced alert %s in route %q: %v", m.MessageID, route.Name, err)
+			continue
+		}
+		if bot, ok := messenger.(discord.BotMessenger); ok && m.Pinned && err == nil {
+			if err := bot.Unpin(m.MessageID); err != nil {
+				log.Printf("Warning: failed to unpin replaced alert in route %q: %v", route.Name, err)
+			}
+		}
+		if err := postgres.DeleteAlertMessage(a.db, inc.ID, route.Name, m.MessageID); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
+
+// rerender rebuilds an incident's alert and edits it into the messages already sent to one
+// route, or to every route that accepts it when to is empty, and returns how many routes were
+// updated. Edits can't upload files, so a camera frame only shows when it is hosted; batched
+// messages are skipped, since their other embeds belong to other incidents.
+func (a *app) rerender(cfg *Config, id int, to string, force bool) (int, error) {
+	inc, _, routes, err := a.replayTarget(cfg, id, to, force)
+	if err != nil {
+		return 0, err
+	}
+	previous, err := postgres.AlertMessagesFor(a.db, inc.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	p := a.enrichNow(cfg, inc, routes)
+	if p.enrichment.AttachmentURL == "" {
+		p.enrichment.Attachment, p.enrichment.AttachmentName = nil, ""
+	}
+	mapsAPIKey := os.Getenv("GOOGLE_MAPS_API_KEY")
+	updated := 0
+	for _, route := range routes {
+		opts := cfg.RenderOptions(route, inc.Source)
+		payload, err := a.buildAlert(cfg, mapsAPIKey, opts, p)
+		if err != nil {
+			log.Printf("Error building alert for route %q: %v", route.Name, err)
+			continue
+		}
+		parts := discord.SplitPayload(payload, opts.T("field_continued"))
+		messenger := route.messengerFor(inc)
+		edited := false
+		for _, m := range previous {
+			if m.Route != route.Name {
+				continue
+			}
+			if m.EmbedIndex.Valid {
+				log.Printf("Skipping batched alert %s in route %q; use replay without --edit to send it on its own.", m.MessageID, route.Name)
+				continue
+			}
+			if m.Part >= len(parts) {
+				continue
+			}
+			start := time.Now()
+			err := messenger.Edit(m.MessageID, parts[m.Part])
+			a.logDelivery(newDelivery(inc.ID, route.Name, "rerender", parts[m.Part], m.MessageID, start, err))
+			if err != nil {
+				log.Printf("Error editing alert %s in route %q: %v", m.MessageID, route.Name, err)
+				continue
+			}
+			edited = true
+		}
+		if edited {
+			updated++
+		} else {
+			log.Printf("Warning: route %q has no message for incident %d to edit.", route.Name, inc.ID)
+		}
+	}
+	if updated == 0 {
+		return 0, fmt.Errorf("incident %d has no alert to edit in any route", inc.ID)
+	}
+	return updated, nil
+}
+
+// deliverNow enriches one incident and sends it to the given routes outside the normal cycle.
+func (a *app) deliverNow(cfg *Config, inc incident.Incident, routes []RouteConfig) (*pendingIncident, error) {
+	p := a.enrichNow(cfg, inc, routes)
+	if err := a.sendNow(cfg, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// enrichNow enriches one incident for the given routes outside the normal cycle.
+func (a *app) enrichNow(cfg *Config, inc incident.Incident, routes []RouteConfig) *pendingIncident {
+	located := checkCoordinates(cfg, &inc)
+	p := a.newPending(cfg, inc, routes)
+	if !located {
+		p.enrichment.Skipped = withSkipped(p.enrichment.Skipped, enrich.SkippedLocation)
+	}
+	a.images.publish(a.db, &p.enrichment)
+	return p
+}
+
+// sendNow sends an enriched incident to its routes, failing when none received it.
+func (a *app) sendNow(cfg *Config, p *pendingIncident) error {
+	mapsAPIKey := os.Getenv("GOOGLE_MAPS_API_KEY")
+	for _, route := range p.routes {
+		a.deliver(cfg, mapsAPIKey, route, p)
+	}
+	if len(p.done) == 0 {
+		return fmt.Errorf("incident %d was not delivered to any route", p.incident.ID)
+	}
+	return nil
+}
+
+// simulate inserts a realistic synthetic incident, flagged as a test, and runs it through
+// enrichment and delivery like a real one. No feed ever clears it, so it is marked cleared
+// once sent and the next poll updates its alerts like a real clearance; --keep leaves it
+// active until cleared by hand.
+//
+//	unity-alerts simulate --source NCDOT --severity 3 --lat 35.78 --lon -78.64 [--address ...] [--type ...] [--keep]
+func (a *app) simulate(args []string) error {
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	source := fs.String("source", "NCDOT", "incident source: NCDOT, RWECC or ArcGIS_Police")
+	severity := fs.Int("severity", 2, "NCDOT severity (1-3)")
+	lat := fs.Float64("lat", 35.7796, "latitude")
+	lon := fs.Float64("lon", -78.6382, "longitude")
+	address := fs.String("address", "I-40 W near Exit 298, Raleigh, NC", "address shown in the alert")
+	eventType := fs.String("type", "", "event type (default depends on --source)")
+	keep := fs.Bool("keep", false, "leave the incident active after sending it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	raw, defaultType, err := simulatedRawIncident(*source, *severity, *address)
+	if err != nil {
+		return err
+	}
+	if *eventType == "" {
+		*eventType = defaultType
+	}
+	details, err := json.Marshal(map[string]interface{}{"raw_incident": raw, "weather": nil})
+	if err != nil {
+		return fmt.Errorf("error creating incident details: %w", err)
+	}
+
+	inc := incident.Incident{
+		Source:    *source,
+		SourceID:  fmt.Sprintf("SIM-%d", time.Now().UnixNano()),
+		EventType: *eventType,
+		Address:   *address,
+		Latitude:  sql.NullFloat64{Float64: *lat, Valid: true},
+		Longitude: sql.NullFloat64{Float64: *lon, Valid: true},
+		Timestamp: time.Now().UTC(),
+		Details:   details,
+		IsTest:    true,
+	}
+	// The empty discord_message_id keeps a concurrent poll from picking the incident up first.
+	cfg := a.currentConfig()
+	err = a.db.QueryRow(cfg.SQL(`INSERT INTO {incidents} ({source}, {source_id}, {event_type}, {address}, {latitude}, {longitude}, {timestamp}, {details}, {status}, {discord_message_id}, {is_test})
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 'active', '', true) RETURNING {id}`),
+		inc.Source, inc.SourceID, inc.EventType, inc.Address, inc.Latitude, inc.Longitude, inc.Timestamp, inc.Details).Scan(&inc.ID)
+	if err != nil {
+		return fmt.Errorf("failed to insert simulated incident: %w", err)
+	}
+	log.Printf("Inserted simulated %s incident %d (%s).", inc.Source, inc.ID, inc.SourceID)
+	if !*keep {
+		defer a.clearSimulated(cfg, inc.ID)
+	}
+
+	var routes []RouteConfig
+	for _, route := range cfg.Routes {
+		if route.Matches(inc) {
+			routes = append(routes, route)
+		}
+	}
+	if len(routes) == 0 {
+		log.Printf("No route accepts %s incidents; nothing was sent.", inc.Source)
+		return nil
+	}
+	p, err := a.deliverNow(cfg, inc, routes)
+	if err != nil {
+		return err
+	}
+	a.finishIncident(cfg, p)
+	log.Printf("Simulated incident %d delivered to %d route(s).", inc.ID, len(routes))
+	return nil
+}
+
+// clearSimulated marks a simulated incident cleared, so it doesn't linger among the active
+// incidents.
+func (a *app) clearSimulated(cfg *Config, id int) {
+	if _, err := a.db.Exec(cfg.SQL("UPDATE {incidents} SET {status} = 'cleared' WHERE {id} = $1 AND {is_test}"), id); err != nil {
+		log.Printf("Warning: failed to clear simulated incident %d: %v", id, err)
+		return
+	}
+	log.Printf("Marked simulated incident %d cleared; the next poll updates its alerts.", id)
+}
+
+// simulatedRawIncident builds a raw_incident in the shape each feed's ingestor stores.
+func simulatedRawIncident(source string, severity int, address string) (map[string]interface{}, string, error) {
+	switch source {
+	case "NCDOT":
+		return map[string]interface{}{
+			"reason":   "Vehicle crash blocking the right lane. Expect delays.",
+			"road":     "I-40 W",
+			"location": address,
+			"severity": severity,
+		}, "Vehicle Crash", nil
+	case "RWECC":
+		return map[string]interface{}{
+			"problem":      "STRUCTURE FIRE",
+			"jurisdiction": "RALEIGH",
+		}, "STRUCTURE FIRE", nil
+	case "ArcGIS_Police":
+		return map[string]interface{}{
+			"case_number":       "P00000000",
+			"crime_description": "Larceny - From Motor Vehicle",
+			"agency":            "Raleigh Police Department",
+		}, "Larceny - From Motor Vehicle", nil
+	default:
+		return nil, "", fmt.Errorf("unknown incident source: %s", source)
+	}
+}
+
+// placeholder records the frame a camera is showing right now as a "camera unavailable"
+// placeholder, so matching frames are no longer attached to alerts.
+//
+//	unity-alerts placeholder --camera "I-40 at Wade Ave"
+func (a *app) placeholder(args []string) error {
+	fs := flag.NewFlagSet("placeholder", flag.ContinueOnError)
+	name := fs.String("camera", "", "name of a camera currently showing its placeholder image")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" {
+		return fmt.Errorf("placeholder requires --camera")
+	}
+	cam, err := camera.ByName(a.db, *name)
+	if err != nil {
+		return err
+	}
+	hash, err := camera.AddPlaceholder(a.db, cam)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Recorded placeholder %016x from camera %s.\n", hash, cam.Name)
+	return nil
+}
+
+// status prints the delivery log, answering "did alert X actually go out and when".
+//
+//	unity-alerts status [--id 123] [--limit 20]
+func (a *app) status(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	id := fs.Int("id", 0, "only show deliveries for this incident")
+	limit := fs.Int("limit", 20, "number of deliveries to show")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	deliveries, err := postgres.Deliveries(a.db, *id, *limit)
+	if err != nil {
+		return err
+	}
+	if *id == 0 {
+		latency, err := postgres.DeliveryLatency(a.db, time.Now().Add(-24*time.Hour))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Alert latency over the last 24h (%d alerts):\n", latency.Alerts)
+		fmt.Printf("  ingest    p50 %-10s p95 %s\n", latency.Ingest.P50, latency.Ingest.P95)
+		fmt.Printf("  pipeline  p50 %-10s p95 %s\n", latency.Pipeline.P50, latency.Pipeline.P95)
+		fmt.Printf("  total     p50 %-10s p95 %s\n\n", latency.Total.P50, latency.Total.P95)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tINCIDENT\tSINK\tKIND\tSTATUS\tLATENCY\tATTACHMENT\tSKIPPED\tMESSAGE\tERROR")
+	for _, d := range deliveries {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%d\t%s\t%d\t%s\t%s\t%s\n", d.CreatedAt.Local().Format(time.DateTime), d.IncidentID, d.Sink, d.Kind,
+			d.HTTPStatus, d.Latency, d.AttachmentBytes, strings.Join(d.Skipped, ","), d.MessageID, discord.Truncate(d.Error, 60))
+	}
+	return w.Flush()
+}
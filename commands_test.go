@@ -0,0 +1,30 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSubcommand(t *testing.T) {
+	tests := []struct {
+		args     []string
+		wantSub  string
+		wantRest []string
+		wantErr  bool
+	}{
+		{[]string{"list"}, "list", nil, false},
+		{[]string{"remove", "--id", "12"}, "remove", []string{"--id", "12"}, false},
+		{nil, "", nil, true},
+		{[]string{"--list"}, "", nil, true},
+		{[]string{"delete", "--id", "12"}, "", nil, true},
+	}
+	for _, tt := range tests {
+		sub, rest, err := subcommand("mute", tt.args, "add", "list", "remove")
+		if sub != tt.wantSub || strings.Join(rest, " ") != strings.Join(tt.wantRest, " ") || (err != nil) != tt.wantErr {
+			t.Errorf("subcommand(%q) = %q, %q, %v", tt.args, sub, rest, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), "add, list, remove") {
+			t.Errorf("subcommand(%q) error %q doesn't name the subcommands", tt.args, err)
+		}
+	}
+}
@@ -0,0 +1,863 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mtickle/unity-alerts/i18n"
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/sink/amqp"
+	"github.com/mtickle/unity-alerts/sink/apprise"
+	"github.com/mtickle/unity-alerts/sink/discord"
+	"github.com/mtickle/unity-alerts/sink/matrix"
+	"github.com/mtickle/unity-alerts/sink/signalcli"
+	"github.com/mtickle/unity-alerts/sink/whatsapp"
+	"github.com/mtickle/unity-alerts/sink/xmpp"
+)
+
+// Config is the reloadable part of the configuration, read from the JSON file named by CONFIG_FILE
+// or from CONFIG_JSON.
+// Credentials stay in the environment; string values may reference them as ${VAR}.
+type Config struct {
+	Timezone string        `json:"timezone,omitempty"` // IANA name, e.g. "America/New_York".
+	Language string        `json:"language,omitempty"` // Locale file name, e.g. "es".
+	Routes   []RouteConfig `json:"routes"`
+
+	// MapStyle is "light" (default), "dark", or "auto" for a dark basemap overnight.
+	MapStyle string `json:"map_style,omitempty"`
+
+	Features       FeatureFlags            `json:"features,omitempty"`
+	SourceFeatures map[string]FeatureFlags `json:"source_features,omitempty"`
+
+	// SourcePriority raises a source's incidents in the send queue when there is a backlog.
+	SourcePriority map[string]int `json:"source_priority,omitempty"`
+
+	// Queue orders the alerts of a backlog by urgency and freshness.
+	Queue *QueueConfig `json:"queue,omitempty"`
+
+	// Bounds is where incidents are expected. Coordinates outside it, or at 0,0, are replaced by
+	// geocoding the address, or dropped when that fails.
+	Bounds *BoundingBox `json:"bounds,omitempty"`
+
+	// CatchUp summarizes a large backlog of stale incidents, as after downtime, in a digest.
+	CatchUp *CatchUpConfig `json:"catch_up,omitempty"`
+
+	// IncidentTimeout bounds the time one incident may spend on enrichment and sending
+	// (default "20s"). Past it, the alert goes out without camera images and other uploads,
+	// so a slow camera can't hold up the rest of the queue.
+	IncidentTimeout string `json:"incident_timeout,omitempty"`
+
+	// Degradation decides whether alerts go out when an enrichment fails.
+	Degradation DegradationConfig `json:"degradation,omitempty"`
+
+	// Filter drops incidents by event type or crime description before enrichment.
+	Filter FilterConfig `json:"filter,omitempty"`
+
+	// Overlap merges reports of one event from different feeds into a single alert.
+	Overlap *OverlapConfig `json:"overlap,omitempty"`
+
+	// StreetViewDailyLimit caps the Street View images requested per day (default 100).
+	StreetViewDailyLimit int `json:"street_view_daily_limit,omitempty"`
+
+	// LatencySLO warns when the 95th percentile time from an incident being stored to its alert
+	// being delivered exceeds it over the last hour, e.g. "2m".
+	LatencySLO string `json:"latency_slo,omitempty"`
+
+	// Stats posts a daily statistics report.
+	Stats *StatsConfig `json:"stats,omitempty"`
+
+	// Events publishes incident lifecycle events to NATS or Kafka.
+	Events *EventsConfig `json:"events,omitempty"`
+
+	// Jobs schedules the parts of each run separately in daemon mode, by job name:
+	// new_incidents, cleared_incidents, camera_sync, digests, retention or health. A schedule is
+	// a cron expression such as "*/10 * * * *", a shorthand such as "@hourly", or
+	// "@every 30s". Jobs left out run at every POLL_INTERVAL, except camera_sync, which runs
+	// daily.
+	Jobs map[string]string `json:"jobs,omitempty"`
+
+	// Maintenance ignores sources entirely during their feeds' maintenance windows.
+	Maintenance []MaintenanceWindow `json:"maintenance,omitempty"`
+
+	// Syslog sends incident lifecycle events to a syslog collector as CEF or RFC 5424.
+	Syslog *SyslogConfig `json:"syslog,omitempty"`
+
+	// CAPFeeds polls CAP feeds, such as IPAWS, and stores their alerts as CAP incidents.
+	CAPFeeds []CAPFeedConfig `json:"cap_feeds,omitempty"`
+
+	// CAP publishes a route's incidents as CAP 1.2 alerts at /api/cap.
+	CAP *CAPConfig `json:"cap,omitempty"`
+
+	// Digest emails subscribers who prefer it a daily digest instead of real-time pings.
+	Digest *DigestConfig `json:"digest,omitempty"`
+
+	// Spikes posts a meta-alert when incidents arrive much faster than usual.
+	Spikes *SpikeConfig `json:"spikes,omitempty"`
+
+	// Leaderboard posts a weekly report of the roads and locations with the most incidents.
+	Leaderboard *LeaderboardConfig `json:"leaderboard,omitempty"`
+
+	// WeatherReport posts a monthly report on how weather changes incident rates.
+	WeatherReport *WeatherReportConfig `json:"weather_report,omitempty"`
+
+	// Escalations page an on-call service through PagerDuty or Opsgenie for critical incidents.
+	Escalations []EscalationConfig `json:"escalations,omitempty"`
+
+	// Retention archives old incidents to files and deletes them from the database.
+	Retention *RetentionConfig `json:"retention,omitempty"`
+
+	// Ops reports the alerting pipeline's own failures.
+	Ops *OpsConfig `json:"ops,omitempty"`
+
+	// Database adapts the incident queries to a differently named table or columns.
+	Database DatabaseConfig `json:"database,omitempty"`
+
+	dbFeatures map[string]FeatureFlags // Loaded from the feature_flags table each run.
+}
+
+// RouteConfig delivers incidents from a set of sources to one Discord channel, through either
+// a webhook or, when channel_id is set, the bot named by DISCORD_BOT_TOKEN. Routes with
+// matrix_room (a room ID such as "!abc:matrix.org") post there instead, as the user owning
+// MATRIX_ACCESS_TOKEN on MATRIX_HOMESERVER, and routes with signal_group (a group ID such as
+// "group.abc=" or a phone number) send through the signal-cli REST API at SIGNAL_API_URL from
+// SIGNAL_NUMBER, batching each run's alerts since Signal messages cannot be edited. Any of
+// these, as well as Telegram and email, can also be given as an Apprise-style notify_url such
+// as "tgram://${TELEGRAM_BOT_TOKEN}/${TELEGRAM_CHAT_ID}"; see package apprise. Routes with
+// whatsapp send template messages through the WhatsApp Business Cloud API as WHATSAPP_TOKEN.
+// Routes with xmpp_room post to that multi-user chat room as XMPP_JID (with XMPP_PASSWORD,
+// and optionally XMPP_NICK and an XMPP_SERVER host:port), and routes with amqp publish to
+// the RabbitMQ broker at AMQP_URL.
+type RouteConfig struct {
+	Name        string          `json:"name"`
+	NotifyURL   string          `json:"notify_url,omitempty"`
+	WebhookURL  string          `json:"webhook_url,omitempty"`
+	ChannelID   string          `json:"channel_id,omitempty"`
+	MatrixRoom  string          `json:"matrix_room,omitempty"`
+	SignalGroup string          `json:"signal_group,omitempty"`
+	XMPPRoom    string          `json:"xmpp_room,omitempty"`
+	WhatsApp    *WhatsAppConfig `json:"whatsapp,omitempty"`
+	AMQP        *AMQPConfig     `json:"amqp,omitempty"`
+	Sources     []string        `json:"sources,omitempty"`  // Empty means every source.
+	Timezone    string          `json:"timezone,omitempty"` // Overrides Config.Timezone.
+	Language    string          `json:"language,omitempty"` // Overrides Config.Language.
+	Features    FeatureFlags    `json:"features,omitempty"`
+
+	// MapStyle overrides Config.MapStyle.
+	MapStyle string `json:"map_style,omitempty"`
+
+	// Tenant is the community a route loaded from the tenant_routes table belongs to. Its
+	// settings are taken literally, without expanding ${VAR} references.
+	Tenant string `json:"-"`
+
+	// PausedUntil and PauseMode are set on a route paused in a mode that holds its incidents
+	// until then, with /admin pause or the pauses table; see Config.withPauses.
+	PausedUntil time.Time `json:"-"`
+	PauseMode   string    `json:"-"`
+
+	// Tags only accepts incidents tagged with one of these by the filter's keyword rules, and
+	// ExcludeTags drops incidents with any of these.
+	Tags        []string `json:"tags,omitempty"`
+	ExcludeTags []string `json:"exclude_tags,omitempty"`
+
+	// Jurisdictions only accepts calls from these municipalities, e.g. "WAKE FOREST" and
+	// "WAKE COUNTY". Incidents whose feed has no jurisdiction are not affected.
+	Jurisdictions []string `json:"jurisdictions,omitempty"`
+
+	// MinSeverity only accepts incidents at or above this severity. Sources without a severity
+	// are looked up by event type in Severities ("*" matches any type) and otherwise count as 0.
+	MinSeverity int            `json:"min_severity,omitempty"`
+	Severities  map[string]int `json:"severities,omitempty"`
+
+	// Schedule restricts the route to time windows, e.g. weekdays 06:00–20:00.
+	Schedule *Schedule `json:"schedule,omitempty"`
+
+	// RepeatWindow (e.g. "15m") catches alerts for an address that already had one within the
+	// window. RepeatAction "suppress" (default) drops them; "thread" posts them as replies to
+	// the earlier alert, which requires channel_id.
+	RepeatWindow string `json:"repeat_window,omitempty"`
+	RepeatAction string `json:"repeat_action,omitempty"`
+
+	// BatchCorridors groups incidents on the same road from one run into a single message.
+	BatchCorridors bool `json:"batch_corridors,omitempty"`
+
+	// Pin pins matching alerts until they clear. Bot mode only, and not with BatchCorridors.
+	Pin *PinRule `json:"pin,omitempty"`
+
+	// Crosspost publishes alerts in an announcement channel to following servers. Bot mode only.
+	// Discord allows 10 crossposts per channel per hour; CrosspostHourlyLimit can lower that.
+	Crosspost            bool `json:"crosspost,omitempty"`
+	CrosspostHourlyLimit int  `json:"crosspost_hourly_limit,omitempty"`
+
+	// Acknowledge adds an Acknowledge button and tracks ✅ reactions, listing who acked in
+	// the embed footer. Bot mode only.
+	Acknowledge bool `json:"acknowledge,omitempty"`
+
+	// Announce posts serious alerts as text-to-speech messages. Bot mode only.
+	Announce *AnnounceConfig `json:"announce,omitempty"`
+
+	// Privacy shows police incidents by their hundred block on a wider map, without case
+	// numbers or Street View, for public channels where exact addresses are inappropriate.
+	Privacy bool `json:"privacy,omitempty"`
+
+	// Verbosity is a preset for how much alerts show: "minimal" for public channels leaves out
+	// secondary fields, tags, navigation links, weather and camera images (and skips fetching
+	// them, unless features turns them back on), "standard" is the default, and "detailed" adds
+	// every raw field from the feed, for ops channels.
+	Verbosity string `json:"verbosity,omitempty"`
+
+	// TextFallback repeats each alert as plain text in the message content, for screen readers
+	// and clients that hide embeds. Discord routes only; other services always get plain text.
+	TextFallback bool `json:"text_fallback,omitempty"`
+
+	// Near only accepts incidents inside this area.
+	Near *Area `json:"near,omitempty"`
+
+	// RepostCleared posts the cleared notice as a new message when the alert it would have
+	// edited was deleted. Otherwise the clear is skipped for that message.
+	RepostCleared bool `json:"repost_cleared,omitempty"`
+}
+
+// discordCrosspostHourlyLimit is Discord's per-channel crosspost rate limit.
+const discordCrosspostHourlyLimit = 10
+
+// crosspostLimit returns the effective hourly crosspost budget for the route.
+func (r RouteConfig) crosspostLimit() int {
+	if r.CrosspostHourlyLimit > 0 && r.CrosspostHourlyLimit < discordCrosspostHourlyLimit {
+		return r.CrosspostHourlyLimit
+	}
+	return discordCrosspostHourlyLimit
+}
+
+// PinRule selects the incidents whose alerts are pinned: those at or above MinSeverity,
+// or whose event type is listed.
+type PinRule struct {
+	MinSeverity int      `json:"min_severity,omitempty"`
+	EventTypes  []string `json:"event_types,omitempty"`
+}
+
+// Matches reports whether an incident should be pinned.
+func (p *PinRule) Matches(inc incident.Incident) bool {
+	if p == nil {
+		return false
+	}
+	if p.MinSeverity > 0 && incident.Severity(inc)+inc.Priority >= p.MinSeverity {
+		return true
+	}
+	for _, eventType := range p.EventTypes {
+		if strings.EqualFold(eventType, inc.EventType) {
+			return true
+		}
+	}
+	return false
+}
+
+// WhatsAppConfig sends a route's alerts from a WhatsApp Business phone number to a list of
+// members, such as a neighborhood group's. Template must be an approved template with an
+// image header and two body parameters: the alert title and its details. ClearedTemplate, if
+// set, takes the same body parameters and announces clears.
+type WhatsAppConfig struct {
+	PhoneNumberID   string   `json:"phone_number_id"`
+	To              []string `json:"to"` // Phone numbers in international format.
+	Template        string   `json:"template"`
+	ClearedTemplate string   `json:"cleared_template,omitempty"`
+	Language        string   `json:"language,omitempty"` // Template language code (default "en_US").
+}
+
+// AMQPConfig publishes a route's alerts to an exchange. Exchange and RoutingKey are templates
+// in which {source}, {event_type} and {route} are replaced for each incident, the event type
+// in lowercase with anything but letters and digits as hyphens.
+type AMQPConfig struct {
+	Exchange   string `json:"exchange,omitempty"`    // Default "amq.topic".
+	RoutingKey string `json:"routing_key,omitempty"` // Default "unity-alerts.{source}.{event_type}".
+}
+
+var amqpPlaceholder = regexp.MustCompile(`\{[a-z_]*\}`)
+
+func (a *AMQPConfig) validate(route string) error {
+	for _, template := range []string{a.Exchange, a.RoutingKey} {
+		for _, p := range amqpPlaceholder.FindAllString(template, -1) {
+			if p != "{source}" && p != "{event_type}" && p != "{route}" {
+				return fmt.Errorf("route %q: amqp: unknown placeholder %s", route, p)
+			}
+		}
+	}
+	return nil
+}
+
+// render fills in an exchange or routing key template for an incident.
+func (a *AMQPConfig) render(template string, route string, i incident.Incident) string {
+	eventType := strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(i.EventType), "-"), "-")
+	return strings.NewReplacer("{source}", i.Source, "{event_type}", eventType, "{route}", route).Replace(template)
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Webhook returns the route's webhook URL with environment references expanded.
+func (r RouteConfig) Webhook() string {
+	return r.expand(r.WebhookURL)
+}
+
+// expand resolves ${VAR} references in a route setting. Tenant routes are managed outside the
+// deployment, so their settings may not read its environment.
+func (r RouteConfig) expand(s string) string {
+	if r.Tenant != "" {
+		return s
+	}
+	return os.ExpandEnv(s)
+}
+
+// Messenger returns the delivery channel for the route.
+func (r RouteConfig) Messenger() discord.Messenger {
+	if r.NotifyURL != "" {
+		messenger, _ := apprise.Parse(r.expand(r.NotifyURL)) // Checked when the config loads.
+		if webhook, ok := messenger.(discord.WebhookMessenger); ok {
+			webhook.TextFallback = r.TextFallback
+			return webhook
+		}
+		return messenger
+	}
+	if r.XMPPRoom != "" {
+		nick := os.Getenv("XMPP_NICK")
+		if nick == "" {
+			nick = "unity-alerts"
+		}
+		return xmpp.Messenger{JID: os.Getenv("XMPP_JID"), Password: os.Getenv("XMPP_PASSWORD"), Server: os.Getenv("XMPP_SERVER"),
+			Room: r.expand(r.XMPPRoom), Nick: nick}
+	}
+	if r.AMQP != nil {
+		return r.messengerFor(incident.Incident{})
+	}
+	if w := r.WhatsApp; w != nil {
+		language := w.Language
+		if language == "" {
+			language = "en_US"
+		}
+		var to []string
+		for _, number := range w.To {
+			to = append(to, r.expand(number))
+		}
+		return whatsapp.Messenger{Token: os.Getenv("WHATSAPP_TOKEN"), PhoneNumberID: r.expand(w.PhoneNumberID), To: to,
+			Template: w.Template, ClearedTemplate: w.ClearedTemplate, Language: language}
+	}
+	if r.SignalGroup != "" {
+		return signalcli.Messenger{APIURL: os.Getenv("SIGNAL_API_URL"), Number: os.Getenv("SIGNAL_NUMBER"), Recipient: r.expand(r.SignalGroup)}
+	}
+	if r.MatrixRoom != "" {
+		return matrix.Messenger{Homeserver: os.Getenv("MATRIX_HOMESERVER"), AccessToken: os.Getenv("MATRIX_ACCESS_TOKEN"), RoomID: r.expand(r.MatrixRoom)}
+	}
+	if r.ChannelID != "" {
+		return discord.BotMessenger{Token: os.Getenv("DISCORD_BOT_TOKEN"), ChannelID: r.expand(r.ChannelID), AckButton: r.Acknowledge,
+			TextFallback: r.TextFallback}
+	}
+	return discord.WebhookMessenger{URL: r.Webhook(), TextFallback: r.TextFallback}
+}
+
+// messengerFor returns the delivery channel for the route's messages about one incident. It
+// differs from Messenger only on AMQP routes, whose exchange and routing key depend on it.
+func (r RouteConfig) messengerFor(i incident.Incident) discord.Messenger {
+	if r.AMQP == nil || r.NotifyURL != "" || r.XMPPRoom != "" {
+		return r.Messenger()
+	}
+	exchange, key := r.AMQP.Exchange, r.AMQP.RoutingKey
+	if exchange == "" {
+		exchange = "amq.topic"
+	}
+	if key == "" {
+		key = "unity-alerts.{source}.{event_type}"
+	}
+	return amqp.Messenger{URL: os.Getenv("AMQP_URL"), Exchange: r.AMQP.render(exchange, r.Name, i), RoutingKey: r.AMQP.render(key, r.Name, i),
+		Route: r.Name, Source: i.Source, EventType: i.EventType}
+}
+
+// Matches reports whether the route accepts incidents from this source, jurisdiction, severity,
+// area and tags.
+func (r RouteConfig) Matches(inc incident.Incident) bool {
+	if r.MinSeverity > 0 && r.severity(inc) < r.MinSeverity {
+		return false
+	}
+	if (len(r.Tags) > 0 && !hasTag(inc, r.Tags)) || hasTag(inc, r.ExcludeTags) {
+		return false
+	}
+	if !r.inJurisdiction(inc) || !r.Near.Contains(inc) {
+		return false
+	}
+	if len(r.Sources) == 0 {
+		return true
+	}
+	for _, source := range r.Sources {
+		if source == inc.Source {
+			return true
+		}
+	}
+	return false
+}
+
+// repeatWindow is the parsed RepeatWindow, or 0 when repeats are not caught.
+func (r RouteConfig) repeatWindow() time.Duration {
+	d, _ := time.ParseDuration(r.RepeatWindow)
+	return d
+}
+
+// inJurisdiction checks the incident's jurisdiction against the route's allowlist.
+func (r RouteConfig) inJurisdiction(inc incident.Incident) bool {
+	jurisdiction := incident.Jurisdiction(inc)
+	if len(r.Jurisdictions) == 0 || jurisdiction == "" {
+		return true
+	}
+	for _, j := range r.Jurisdictions {
+		if strings.EqualFold(strings.TrimSpace(j), jurisdiction) {
+			return true
+		}
+	}
+	return false
+}
+
+// severity is the feed's severity, or the route's mapping of the event type when there is none,
+// plus any priority boost from keyword rules.
+func (r RouteConfig) severity(inc incident.Incident) int {
+	if s := incident.Severity(inc); s > 0 {
+		return s + inc.Priority
+	}
+	for eventType, s := range r.Severities {
+		if strings.EqualFold(eventType, inc.EventType) {
+			return s + inc.Priority
+		}
+	}
+	return r.Severities["*"] + inc.Priority
+}
+
+// Route looks up a route by name.
+func (c *Config) Route(name string) (RouteConfig, bool) {
+	for _, route := range c.Routes {
+		if route.Name == name {
+			return route, true
+		}
+	}
+	return RouteConfig{}, false
+}
+
+// RenderOptions resolves the presentation settings for an incident source on a route,
+// falling back to the global ones.
+func (c *Config) RenderOptions(route RouteConfig, source string) discord.RenderOptions {
+	opts := discord.DefaultRenderOptions()
+	opts.Features = FeatureFlags{}
+	for _, feature := range []string{discord.FeatureCameras, discord.FeatureMaps, discord.FeatureWeather, discord.FeatureStreetView, discord.FeatureNavigate} {
+		opts.Features[feature] = c.FeatureEnabled(feature, source, route)
+	}
+	opts.Location = c.Location(route)
+	opts.DarkMaps = c.darkMaps(route, time.Now().In(opts.Location))
+	opts.Privacy = route.Privacy
+	opts.Verbosity = route.Verbosity
+	for _, lang := range []string{route.Language, c.Language} {
+		if lang == "" {
+			continue
+		}
+		if locale, err := i18n.For(lang); err == nil {
+			opts.Locale = locale
+			break
+		}
+	}
+	return opts
+}
+
+// Location is the route's timezone, falling back to the global one and then the default.
+func (c *Config) Location(route RouteConfig) *time.Location {
+	for _, name := range []string{route.Timezone, c.Timezone} {
+		if name == "" {
+			continue
+		}
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	return discord.DefaultRenderOptions().Location
+}
+
+// Overnight hours, local to the route, when map_style "auto" switches to the dark basemap.
+const (
+	nightStartHour = 19
+	nightEndHour   = 7
+)
+
+// darkMaps reports whether maps rendered at now use the dark style.
+func (c *Config) darkMaps(route RouteConfig, now time.Time) bool {
+	style := route.MapStyle
+	if style == "" {
+		style = c.MapStyle
+	}
+	switch style {
+	case discord.MapStyleDark:
+		return true
+	case discord.MapStyleAuto:
+		return now.Hour() >= nightStartHour || now.Hour() < nightEndHour
+	}
+	return false
+}
+
+func validMapStyle(style string) bool {
+	switch style {
+	case "", discord.MapStyleLight, discord.MapStyleDark, discord.MapStyleAuto:
+		return true
+	}
+	return false
+}
+
+// defaultConfig reproduces the original single-webhook behaviour from DISCORD_HOOK.
+func defaultConfig() *Config {
+	return &Config{
+		Timezone: os.Getenv("TIMEZONE"),
+		Language: os.Getenv("ALERT_LANGUAGE"), // Not LANGUAGE, which GNU gettext uses for values like "en_US:en".
+		Routes:   []RouteConfig{{Name: "default", WebhookURL: "${DISCORD_HOOK}"}},
+	}
+}
+
+// loadConfig reads and validates a config file.
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return parseConfig(data, "config file "+path)
+}
+
+// parseConfig parses and validates a config read from name.
+func parseConfig(data []byte, name string) (*Config, error) {
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return &cfg, nil
+}
+
+func (c *Config) validate() error {
+	if len(c.Routes) == 0 {
+		return fmt.Errorf("at least one route is required")
+	}
+	if c.Timezone != "" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone: %w", err)
+		}
+	}
+	if _, err := i18n.For(c.Language); err != nil {
+		return fmt.Errorf("language: %w", err)
+	}
+	if !validMapStyle(c.MapStyle) {
+		return fmt.Errorf("map_style must be \"light\", \"dark\" or \"auto\", not %q", c.MapStyle)
+	}
+	if err := c.Filter.compile(); err != nil {
+		return err
+	}
+	if c.LatencySLO != "" {
+		if d, err := time.ParseDuration(c.LatencySLO); err != nil || d <= 0 {
+			return fmt.Errorf("latency_slo: invalid duration %q", c.LatencySLO)
+		}
+	}
+	if c.StreetViewDailyLimit < 0 {
+		return fmt.Errorf("street_view_daily_limit must not be negative")
+	}
+	if err := c.Events.validate(); err != nil {
+		return err
+	}
+	if err := validateJobs(c.Jobs); err != nil {
+		return err
+	}
+	if err := c.Syslog.validate(c); err != nil {
+		return err
+	}
+	for n, w := range c.Maintenance {
+		if err := w.validate(c.Location(RouteConfig{})); err != nil {
+			return fmt.Errorf("maintenance[%d]: %w", n, err)
+		}
+	}
+	for n, feed := range c.CAPFeeds {
+		if err := feed.validate(); err != nil {
+			return fmt.Errorf("cap_feeds[%d]: %w", n, err)
+		}
+	}
+	if err := c.CAP.validate(c); err != nil {
+		return err
+	}
+	if err := c.Stats.validate(c); err != nil {
+		return err
+	}
+	if err := c.Digest.validate(); err != nil {
+		return err
+	}
+	if err := c.Spikes.validate(c); err != nil {
+		return err
+	}
+	if err := c.Leaderboard.validate(c); err != nil {
+		return err
+	}
+	if err := c.WeatherReport.validate(c); err != nil {
+		return err
+	}
+	if err := validateEscalations(c); err != nil {
+		return err
+	}
+	if err := c.Ops.validate(c); err != nil {
+		return err
+	}
+	if err := validIncidentTimeout(c.IncidentTimeout); err != nil {
+		return err
+	}
+	if err := c.Retention.validate(); err != nil {
+		return err
+	}
+	if err := c.Queue.validate(); err != nil {
+		return err
+	}
+	if err := c.CatchUp.validate(); err != nil {
+		return err
+	}
+	if err := c.Degradation.validate(); err != nil {
+		return err
+	}
+	if err := c.Bounds.validate(); err != nil {
+		return err
+	}
+	if err := c.Overlap.validate(); err != nil {
+		return err
+	}
+	if err := c.Database.validate(); err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for i := range c.Routes {
+		if c.Routes[i].Name == "" {
+			return fmt.Errorf("route %d has no name", i)
+		}
+		if seen[c.Routes[i].Name] {
+			return fmt.Errorf("duplicate route name %q", c.Routes[i].Name)
+		}
+		seen[c.Routes[i].Name] = true
+		if err := c.Routes[i].validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate checks one route's delivery settings and options. Routes loaded for a tenant may
+// only deliver through a Discord webhook or channel.
+func (r *RouteConfig) validate() error {
+	if r.Tenant != "" && (r.NotifyURL != "" || r.XMPPRoom != "" || r.AMQP != nil || r.WhatsApp != nil || r.SignalGroup != "" || r.MatrixRoom != "") {
+		return fmt.Errorf("route %q: tenant routes must use webhook_url or channel_id", r.Name)
+	}
+	if r.NotifyURL != "" {
+		if _, err := apprise.Parse(os.ExpandEnv(r.NotifyURL)); err != nil {
+			return fmt.Errorf("route %q: notify_url: %w", r.Name, err)
+		}
+	} else if r.XMPPRoom != "" {
+		if os.Getenv("XMPP_JID") == "" || os.Getenv("XMPP_PASSWORD") == "" {
+			return fmt.Errorf("route %q uses xmpp_room but XMPP_JID or XMPP_PASSWORD is not set", r.Name)
+		}
+	} else if r.AMQP != nil {
+		if os.Getenv("AMQP_URL") == "" {
+			return fmt.Errorf("route %q uses amqp but AMQP_URL is not set", r.Name)
+		}
+		if err := r.AMQP.validate(r.Name); err != nil {
+			return err
+		}
+	} else if w := r.WhatsApp; w != nil {
+		if os.Getenv("WHATSAPP_TOKEN") == "" {
+			return fmt.Errorf("route %q uses whatsapp but WHATSAPP_TOKEN is not set", r.Name)
+		}
+		if w.PhoneNumberID == "" || len(w.To) == 0 || w.Template == "" {
+			return fmt.Errorf("route %q: whatsapp requires phone_number_id, to and template", r.Name)
+		}
+	} else if r.SignalGroup != "" {
+		if os.Getenv("SIGNAL_API_URL") == "" || os.Getenv("SIGNAL_NUMBER") == "" {
+			return fmt.Errorf("route %q uses signal_group but SIGNAL_API_URL or SIGNAL_NUMBER is not set", r.Name)
+		}
+	} else if r.MatrixRoom != "" {
+		if os.Getenv("MATRIX_HOMESERVER") == "" || os.Getenv("MATRIX_ACCESS_TOKEN") == "" {
+			return fmt.Errorf("route %q uses matrix_room but MATRIX_HOMESERVER or MATRIX_ACCESS_TOKEN is not set", r.Name)
+		}
+	} else if r.ChannelID != "" {
+		if os.Getenv("DISCORD_BOT_TOKEN") == "" {
+			return fmt.Errorf("route %q uses channel_id but DISCORD_BOT_TOKEN is not set", r.Name)
+		}
+	} else if r.Webhook() == "" {
+		return fmt.Errorf("route %q has no webhook_url, channel_id, matrix_room, signal_group, whatsapp, xmpp_room, amqp or notify_url", r.Name)
+	} else if err := validWebhookURL(r.Webhook()); err != nil {
+		return fmt.Errorf("route %q: %w", r.Name, err)
+	}
+
+	messenger := r.Messenger()
+	if _, bot := messenger.(discord.BotMessenger); !bot && (r.Pin != nil || r.Crosspost || r.Acknowledge) {
+		return fmt.Errorf("route %q: pinning, crossposting and acknowledgments require channel_id (bot mode)", r.Name)
+	}
+	if r.BatchCorridors {
+		// Batches are updated from the sent message's embeds, which only these can read back.
+		switch messenger.(type) {
+		case discord.WebhookMessenger, discord.BotMessenger, matrix.Messenger:
+		default:
+			return fmt.Errorf("route %q: batch_corridors is not supported by this route's service", r.Name)
+		}
+	}
+	if r.Pin != nil && r.BatchCorridors {
+		// A batch message holds several incidents, so it has no single one to pin for.
+		return fmt.Errorf("route %q: pin can't be combined with batch_corridors", r.Name)
+	}
+	if err := r.Announce.validate(*r); err != nil {
+		return err
+	}
+	if r.Timezone != "" {
+		if _, err := time.LoadLocation(r.Timezone); err != nil {
+			return fmt.Errorf("route %q has invalid timezone: %w", r.Name, err)
+		}
+	}
+	if r.Language != "" {
+		if _, err := i18n.For(r.Language); err != nil {
+			return fmt.Errorf("route %q: %w", r.Name, err)
+		}
+	}
+	switch r.Verbosity {
+	case "", discord.VerbosityMinimal, discord.VerbosityStandard, discord.VerbosityDetailed:
+	default:
+		return fmt.Errorf("route %q: verbosity must be \"minimal\", \"standard\" or \"detailed\", not %q", r.Name, r.Verbosity)
+	}
+	if !validMapStyle(r.MapStyle) {
+		return fmt.Errorf("route %q: map_style must be \"light\", \"dark\" or \"auto\", not %q", r.Name, r.MapStyle)
+	}
+	if r.RepeatWindow != "" {
+		if d, err := time.ParseDuration(r.RepeatWindow); err != nil || d <= 0 {
+			return fmt.Errorf("route %q has invalid repeat_window %q", r.Name, r.RepeatWindow)
+		}
+	}
+	switch r.RepeatAction {
+	case "", "suppress":
+	case "thread":
+		if r.ChannelID == "" {
+			return fmt.Errorf("route %q: repeat_action \"thread\" requires channel_id (bot mode)", r.Name)
+		}
+	default:
+		return fmt.Errorf("route %q: repeat_action must be \"suppress\" or \"thread\", not %q", r.Name, r.RepeatAction)
+	}
+	if err := r.Schedule.validate(); err != nil {
+		return fmt.Errorf("route %q: %w", r.Name, err)
+	}
+	if err := r.Near.validate(); err != nil {
+		return fmt.Errorf("route %q: near: %w", r.Name, err)
+	}
+	return nil
+}
+
+// ConfigStore holds the active Config and swaps it atomically on reload.
+type ConfigStore struct {
+	path    string
+	mu      sync.RWMutex
+	current *Config
+	modTime time.Time
+}
+
+// newConfigStore loads CONFIG_FILE. When it is unset, the config is read from the CONFIG_JSON
+// environment variable instead, as when a Kubernetes ConfigMap is passed in the environment,
+// or falls back to the DISCORD_HOOK default. Only a config file is reloaded.
+func newConfigStore(path string) (*ConfigStore, error) {
+	store := &ConfigStore{path: path}
+	if inline := os.Getenv("CONFIG_JSON"); path == "" && inline != "" {
+		cfg, err := parseConfig([]byte(inline), "CONFIG_JSON")
+		if err != nil {
+			return nil, err
+		}
+		store.current = cfg
+		return store, nil
+	}
+	if path == "" {
+		if os.Getenv("DISCORD_HOOK") == "" {
+			return nil, fmt.Errorf("DISCORD_HOOK must be set when CONFIG_FILE is not")
+		}
+		cfg := defaultConfig()
+		if err := cfg.validate(); err != nil {
+			return nil, fmt.Errorf("invalid configuration from the environment: %w", err)
+		}
+		store.current = cfg
+		return store, nil
+	}
+	if err := store.Reload(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Current returns the active configuration. Callers should take one snapshot per run.
+func (s *ConfigStore) Current() *Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// Reload re-reads the config file. On error the previous configuration stays active.
+func (s *ConfigStore) Reload() error {
+	if s.path == "" {
+		return nil
+	}
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat config file: %w", err)
+	}
+	cfg, err := loadConfig(s.path)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.current = cfg
+	s.modTime = info.ModTime()
+	s.mu.Unlock()
+	log.Printf("Loaded configuration from %s (%d routes).", s.path, len(cfg.Routes))
+	return nil
+}
+
+// Watch reloads the configuration on SIGHUP or when the file's modification time changes,
+// until ctx is cancelled.
+func (s *ConfigStore) Watch(ctx context.Context) {
+	if s.path == "" {
+		return
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			log.Println("Received SIGHUP, reloading configuration.")
+		case <-ticker.C:
+			info, err := os.Stat(s.path)
+			if err != nil {
+				continue
+			}
+			s.mu.Lock()
+			unchanged := info.ModTime().Equal(s.modTime)
+			// Remember the new mtime even if the reload fails, so a broken file is reported once.
+			s.modTime = info.ModTime()
+			s.mu.Unlock()
+			if unchanged {
+				continue
+			}
+			log.Println("Config file changed, reloading configuration.")
+		}
+		if err := s.Reload(); err != nil {
+			log.Printf("Error reloading configuration, keeping previous version: %v", err)
+		}
+	}
+}
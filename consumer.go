@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+const (
+	// defaultQueueTopic is the subject or topic incidents are consumed from when
+	// INCIDENT_QUEUE_TOPIC is unset.
+	defaultQueueTopic = "unity-alerts.incidents"
+	// queueGroup shares NATS messages between replicas, though only the leader subscribes.
+	queueGroup = "unity-alerts"
+	// queueRetryDelay is how long the consumer waits after a broker or database error.
+	queueRetryDelay = 10 * time.Second
+)
+
+// queueConsumer returns the consumer for INCIDENT_QUEUE_URL, or nil when it is unset. The
+// consumer stores the incidents that ingestors publish to the queue, in the /ingest format,
+// and wakes the daemon to alert on them straight away instead of at the next poll.
+//
+// With NATS, messages published while no replica is subscribed are lost, so the poll interval
+// still matters; Kafka offsets are saved in queue_offsets and consumption resumes where it
+// left off.
+func (a *app) queueConsumer() (func(ctx context.Context, wake chan<- struct{}), error) {
+	url := os.Getenv("INCIDENT_QUEUE_URL")
+	if url == "" {
+		return nil, nil
+	}
+	topic := os.Getenv("INCIDENT_QUEUE_TOPIC")
+	if topic == "" {
+		topic = defaultQueueTopic
+	}
+	switch {
+	case isKafkaURL(url):
+		cluster, err := parseKafkaURL(url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid INCIDENT_QUEUE_URL: %w", err)
+		}
+		return func(ctx context.Context, wake chan<- struct{}) {
+			a.consumeKafka(ctx, &kafka.Client{Addr: kafka.TCP(cluster.brokers...), Transport: cluster.transport}, topic, wake)
+		}, nil
+	case strings.HasPrefix(url, "nats://"), strings.HasPrefix(url, "tls://"):
+		return func(ctx context.Context, wake chan<- struct{}) {
+			a.consumeNATS(ctx, url, topic, wake)
+		}, nil
+	}
+	return nil, fmt.Errorf("INCIDENT_QUEUE_URL must be a nats://, tls://, kafka:// or kafka+tls:// URL")
+}
+
+// consumeNATS subscribes to the subject until ctx is done. Once connected, the connection
+// reconnects and resubscribes by itself; connecting is retried until it first succeeds.
+func (a *app) consumeNATS(ctx context.Context, url, subject string, wake chan<- struct{}) {
+	log.Printf("Consuming incidents from NATS subject %s.", subject)
+	for ctx.Err() == nil {
+		conn, err := connectNATS(url)
+		if err == nil {
+			err = readNATS(ctx, conn, subject, a.storeQueued, wake)
+			conn.Close()
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Warning: incident queue: %v", err)
+			sleepContext(ctx, queueRetryDelay)
+		}
+	}
+}
+
+// readNATS hands each message on the subject to store until ctx is done, waking the daemon
+// when store changed an incident.
+func readNATS(ctx context.Context, conn *nats.Conn, subject string, store func(context.Context, []byte) (bool, error), wake chan<- struct{}) error {
+	sub, err := conn.QueueSubscribeSync(subject, queueGroup)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to NATS subject %s: %w", subject, err)
+	}
+	defer sub.Unsubscribe()
+	for {
+		msg, err := sub.NextMsgWithContext(ctx)
+		if errors.Is(err, nats.ErrSlowConsumer) {
+			log.Printf("Warning: incident queue: fell behind NATS subject %s; some incidents were dropped.", subject)
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		changed, err := store(ctx, msg.Data)
+		if err != nil {
+			return nil // Shutting down.
+		}
+		if changed {
+			wakeDaemon(wake)
+		}
+	}
+}
+
+// consumeKafka fetches from every partition of the topic until ctx is done, saving each
+// partition's offset once the incidents before it are stored. A partition with no saved offset
+// starts from the oldest record retained; incidents already stored are left alone.
+func (a *app) consumeKafka(ctx context.Context, client *kafka.Client, topic string, wake chan<- struct{}) {
+	log.Printf("Consuming incidents from Kafka topic %s.", topic)
+
+	offsets := make(map[int]int64)
+	for ctx.Err() == nil {
+		partitions, err := kafkaPartitions(ctx, client, topic)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Warning: incident queue: %v", err)
+				sleepContext(ctx, queueRetryDelay)
+			}
+			continue
+		}
+		for partition := 0; partition < partitions && ctx.Err() == nil; partition++ {
+			if err := a.consumePartition(ctx, client, topic, partition, offsets, wake); err != nil && ctx.Err() == nil {
+				log.Printf("Warning: incident queue: %v", err)
+				sleepContext(ctx, queueRetryDelay)
+			}
+		}
+	}
+}
+
+// consumePartition fetches once from a partition, stores what it got and moves the offset on.
+func (a *app) consumePartition(ctx context.Context, client *kafka.Client, topic string, partition int, offsets map[int]int64, wake chan<- struct{}) error {
+	offset, ok := offsets[partition]
+	if !ok {
+		saved, found, err := postgres.QueueOffset(a.db, topic, int32(partition))
+		if err != nil {
+			return err
+		}
+		if offset = saved; !found {
+			if offset, err = kafkaEarliest(ctx, client, topic, partition); err != nil {
+				return err
+			}
+		}
+		offsets[partition] = offset
+	}
+
+	values, next, err := kafkaFetch(ctx, client, topic, partition, offset)
+	if errors.Is(err, kafka.OffsetOutOfRange) {
+		log.Printf("Warning: incident queue: offset %d of %s partition %d is out of range; starting from the oldest retained.", offset, topic, partition)
+		delete(offsets, partition)
+		offset, err = kafkaEarliest(ctx, client, topic, partition)
+		if err != nil {
+			return err
+		}
+		offsets[partition] = offset
+		return postgres.SaveQueueOffset(a.db, topic, int32(partition), offset)
+	}
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for _, value := range values {
+		stored, err := a.storeQueued(ctx, value)
+		if err != nil {
+			return err
+		}
+		changed = changed || stored
+	}
+	if changed {
+		wakeDaemon(wake)
+	}
+	if next == offset {
+		return nil
+	}
+	offsets[partition] = next
+	return postgres.SaveQueueOffset(a.db, topic, int32(partition), next)
+}
+
+// kafkaPartitions returns the number of partitions in the topic.
+func kafkaPartitions(ctx context.Context, client *kafka.Client, topic string) (int, error) {
+	resp, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get Kafka metadata: %w", err)
+	}
+	for _, t := range resp.Topics {
+		if t.Name != topic {
+			continue
+		}
+		if t.Error != nil {
+			return 0, fmt.Errorf("Kafka topic %s: %w", topic, t.Error)
+		}
+		if len(t.Partitions) == 0 {
+			return 0, fmt.Errorf("Kafka topic %s has no partitions", topic)
+		}
+		return len(t.Partitions), nil
+	}
+	return 0, fmt.Errorf("Kafka topic %s not found", topic)
+}
+
+// kafkaEarliest returns the oldest offset a partition still retains.
+func kafkaEarliest(ctx context.Context, client *kafka.Client, topic string, partition int) (int64, error) {
+	resp, err := client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{topic: {kafka.FirstOffsetOf(partition)}},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list Kafka offsets: %w", err)
+	}
+	for _, p := range resp.Topics[topic] {
+		if p.Partition != partition {
+			continue
+		}
+		if p.Error != nil {
+			return 0, fmt.Errorf("failed to list offsets of Kafka topic %s partition %d: %w", topic, partition, p.Error)
+		}
+		return p.FirstOffset, nil
+	}
+	return 0, fmt.Errorf("Kafka returned no offsets for topic %s partition %d", topic, partition)
+}
+
+// kafkaFetch fetches a partition's records from an offset on, waiting up to a second for the
+// first to arrive, and returns their values and the offset after the last. An offset deleted
+// by retention or past the end of the partition fails with kafka.OffsetOutOfRange.
+func kafkaFetch(ctx context.Context, client *kafka.Client, topic string, partition int, offset int64) ([][]byte, int64, error) {
+	resp, err := client.Fetch(ctx, &kafka.FetchRequest{
+		Topic:     topic,
+		Partition: partition,
+		Offset:    offset,
+		MinBytes:  1,
+		MaxBytes:  4 << 20,
+		MaxWait:   time.Second,
+	})
+	if err != nil {
+		return nil, offset, fmt.Errorf("failed to fetch from Kafka: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, offset, fmt.Errorf("failed to fetch from Kafka topic %s partition %d: %w", topic, partition, resp.Error)
+	}
+	var values [][]byte
+	next := offset
+	for {
+		r, err := resp.Records.ReadRecord()
+		if errors.Is(err, io.EOF) {
+			return values, next, nil
+		}
+		if err != nil {
+			return nil, offset, fmt.Errorf("failed to read Kafka records: %w", err)
+		}
+		// A batch can start before the offset asked for.
+		if r.Offset < offset {
+			continue
+		}
+		var value []byte
+		if r.Value != nil {
+			if value, err = io.ReadAll(r.Value); err != nil {
+				return nil, offset, fmt.Errorf("failed to read Kafka record: %w", err)
+			}
+		}
+		values = append(values, value)
+		next = r.Offset + 1
+	}
+}
+
+// storeQueued stores the incidents in a queue message, retrying database errors until ctx is
+// done, and reports whether any were created or cleared. Messages that don't match the /ingest
+// schema are logged and skipped, so one bad publish can't stall the queue.
+func (a *app) storeQueued(ctx context.Context, body []byte) (bool, error) {
+	incidents, err := parseIngested(body)
+	if err != nil {
+		log.Printf("Warning: skipping invalid incident queue message: %v", err)
+		return false, nil
+	}
+	changed := false
+	for _, i := range incidents {
+		if err := i.validate(); err != nil {
+			log.Printf("Warning: skipping invalid %s incident %s from the queue: %v", i.Source, i.SourceID, err)
+			continue
+		}
+		for {
+			result, err := a.storeIngested(a.currentConfig(), i)
+			if err == nil {
+				changed = changed || result.Result != "unchanged"
+				break
+			}
+			log.Printf("Error ingesting %s incident %s from the queue: %v", i.Source, i.SourceID, err)
+			if !sleepContext(ctx, queueRetryDelay) {
+				return changed, ctx.Err()
+			}
+		}
+	}
+	return changed, nil
+}
+
+// wakeDaemon asks the daemon for a cycle without waiting for it.
+func wakeDaemon(wake chan<- struct{}) {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// sleepContext waits for d, returning false if ctx is done first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
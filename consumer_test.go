@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+)
+
+func TestKafkaPartitions(t *testing.T) {
+	client := &kafka.Client{Addr: kafka.TCP("broker:9092"), Transport: &fakeKafka{partitions: 3}}
+	partitions, err := kafkaPartitions(context.Background(), client, "unity-alerts.incidents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partitions != 3 {
+		t.Errorf("partitions = %d, want 3", partitions)
+	}
+
+	client.Transport = &fakeKafka{}
+	if _, err := kafkaPartitions(context.Background(), client, "unity-alerts.incidents"); err == nil {
+		t.Error("kafkaPartitions succeeded for a topic with no partitions, want an error")
+	}
+}
+
+func TestKafkaFetch(t *testing.T) {
+	broker := &fakeKafka{partitions: 2, first: 40, log: map[int][][]byte{
+		1: {[]byte(`{"id": "a"}`), []byte(`{"id": "b"}`), []byte(`{"id": "c"}`)},
+	}}
+	client := &kafka.Client{Addr: kafka.TCP("broker:9092"), Transport: broker}
+	ctx := context.Background()
+
+	earliest, err := kafkaEarliest(ctx, client, "incidents", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if earliest != 40 {
+		t.Errorf("earliest = %d, want 40", earliest)
+	}
+
+	tests := []struct {
+		name      string
+		partition int
+		offset    int64
+		values    []string
+		next      int64
+	}{
+		{"from the start", 1, 40, []string{`{"id": "a"}`, `{"id": "b"}`, `{"id": "c"}`}, 43},
+		// The batch starts at 40; the records before the offset were already consumed.
+		{"from the middle of a batch", 1, 42, []string{`{"id": "c"}`}, 43},
+		{"at the end", 1, 43, nil, 43},
+		{"empty partition", 0, 40, nil, 40},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, next, err := kafkaFetch(ctx, client, "incidents", tt.partition, tt.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, v := range values {
+				got = append(got, string(v))
+			}
+			if !reflect.DeepEqual(got, tt.values) || next != tt.next {
+				t.Errorf("kafkaFetch = %q, %d, want %q, %d", got, next, tt.values, tt.next)
+			}
+		})
+	}
+
+	for _, offset := range []int64{12, 44} {
+		if _, next, err := kafkaFetch(ctx, client, "incidents", 1, offset); !errors.Is(err, kafka.OffsetOutOfRange) || next != offset {
+			t.Errorf("kafkaFetch from %d = %d, %v, want %d, an out of range error", offset, next, err, offset)
+		}
+	}
+}
+
+// natsRecorder collects what readNATS hands to store.
+type natsRecorder struct {
+	mu       sync.Mutex
+	received []string
+}
+
+func (r *natsRecorder) store(_ context.Context, data []byte) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.received = append(r.received, string(data))
+	return true, nil
+}
+
+func (r *natsRecorder) has(data string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range r.received {
+		if d == data {
+			return true
+		}
+	}
+	return false
+}
+
+// publishUntilReceived publishes data to the subject until the recorder has it, as readNATS
+// subscribes, and resubscribes after reconnecting, in the background.
+func publishUntilReceived(t *testing.T, url, subject, data string, rec *natsRecorder) {
+	t.Helper()
+	pub, err := nats.Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pub.Close()
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); {
+		if err := pub.Publish(subject, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+		pub.Flush()
+		time.Sleep(50 * time.Millisecond)
+		if rec.has(data) {
+			return
+		}
+	}
+	t.Fatalf("%s was never received", data)
+}
+
+func TestReadNATS(t *testing.T) {
+	s := runNATSServer(t)
+	conn, err := connectNATS(s.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rec := &natsRecorder{}
+	wake := make(chan struct{}, 1)
+	done := make(chan error)
+	go func() { done <- readNATS(ctx, conn, "unity-alerts.incidents", rec.store, wake) }()
+
+	publishUntilReceived(t, s.ClientURL(), "unity-alerts.incidents", `{"id": "1"}`, rec)
+	select {
+	case <-wake:
+	default:
+		t.Error("readNATS did not wake the daemon")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("readNATS = %v after ctx was done, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("readNATS did not return after ctx was done")
+	}
+}
+
+// TestReadNATSReconnects restarts the server under readNATS, which should carry on with the
+// messages published after the restart.
+func TestReadNATSReconnects(t *testing.T) {
+	s := runNATSServer(t)
+	url, port := s.ClientURL(), s.Addr().(*net.TCPAddr).Port
+	conn, err := connectNATS(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rec := &natsRecorder{}
+	go readNATS(ctx, conn, "unity-alerts.incidents", rec.store, make(chan struct{}, 1))
+	publishUntilReceived(t, url, "unity-alerts.incidents", `{"id": "before"}`, rec)
+
+	s.Shutdown()
+	s.WaitForShutdown()
+	runNATSServerOn(t, port)
+	publishUntilReceived(t, url, "unity-alerts.incidents", `{"id": "after"}`, rec)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"os"
+
+	"github.com/mtickle/unity-alerts/enrich"
+	"github.com/mtickle/unity-alerts/incident"
+)
+
+// BoundingBox is the area incidents are expected to fall in, in degrees.
+type BoundingBox struct {
+	South float64 `json:"south"`
+	West  float64 `json:"west"`
+	North float64 `json:"north"`
+	East  float64 `json:"east"`
+}
+
+func (b *BoundingBox) validate() error {
+	if b == nil {
+		return nil
+	}
+	if b.South < -90 || b.North > 90 || b.South >= b.North {
+		return fmt.Errorf("bounds: south must be below north, within ±90")
+	}
+	if b.West < -180 || b.East > 180 || b.West >= b.East {
+		return fmt.Errorf("bounds: west must be left of east, within ±180")
+	}
+	return nil
+}
+
+// Area is a circle around a location, e.g. {"latitude": 35.78, "longitude": -78.64,
+// "radius": "1mi"}.
+type Area struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Radius    string  `json:"radius,omitempty"` // Default 1 mile.
+
+	meters float64
+}
+
+// validate parses the radius; it must run before Contains.
+func (a *Area) validate() error {
+	if a == nil {
+		return nil
+	}
+	meters, err := parseRadius(a.Radius)
+	if err != nil {
+		return err
+	}
+	a.meters = meters
+	return nil
+}
+
+// Contains reports whether the incident is inside the area. Every incident is inside a nil
+// area, and none without coordinates is inside any other. An incident covering polygons, such
+// as a CAP warning, is also inside when the area's center is inside one of them.
+func (a *Area) Contains(inc incident.Incident) bool {
+	if a == nil {
+		return true
+	}
+	for _, ring := range incident.Polygons(inc) {
+		if ringContains(ring, a.Latitude, a.Longitude) {
+			return true
+		}
+	}
+	if !inc.Latitude.Valid || !inc.Longitude.Valid {
+		return false
+	}
+	return distanceMeters(a.Latitude, a.Longitude, inc.Latitude.Float64, inc.Longitude.Float64) <= a.meters
+}
+
+// ringContains reports whether a point is inside a ring of [longitude, latitude] points, by
+// counting the edges a ray from the point crosses.
+func ringContains(ring [][2]float64, lat, lon float64) bool {
+	inside := false
+	for n, prev := 0, len(ring)-1; n < len(ring); prev, n = n, n+1 {
+		x1, y1, x2, y2 := ring[n][0], ring[n][1], ring[prev][0], ring[prev][1]
+		if (y1 > lat) != (y2 > lat) && lon < (x2-x1)*(lat-y1)/(y2-y1)+x1 {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// plausible reports whether a point could be a real incident location: not the 0,0 feeds use
+// for a missing position, and inside the bounding box when there is one.
+func (b *BoundingBox) plausible(lat, lon float64) bool {
+	if math.Abs(lat) < 0.001 && math.Abs(lon) < 0.001 {
+		return false
+	}
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return false
+	}
+	return b == nil || (lat >= b.South && lat <= b.North && lon >= b.West && lon <= b.East)
+}
+
+// checkCoordinates repairs an incident whose coordinates are implausible by geocoding its
+// address. When that fails too, the coordinates are dropped, so the alert goes out without a
+// map, cameras or anything else located by them, and it returns false.
+func checkCoordinates(cfg *Config, i *incident.Incident) bool {
+	if !i.Latitude.Valid || !i.Longitude.Valid || cfg.Bounds.plausible(i.Latitude.Float64, i.Longitude.Float64) {
+		return true
+	}
+	log.Printf("Warning: incident %d has implausible coordinates %f,%f; geocoding its address.", i.ID, i.Latitude.Float64, i.Longitude.Float64)
+	i.Latitude, i.Longitude = sql.NullFloat64{}, sql.NullFloat64{}
+	if i.Address == "" {
+		return false
+	}
+	lat, lon, err := enrich.Geocode(context.Background(), os.Getenv("GOOGLE_MAPS_API_KEY"), i.Address)
+	if err != nil {
+		log.Printf("Warning: sending incident %d without a location: %v", i.ID, err)
+		return false
+	}
+	if !cfg.Bounds.plausible(lat, lon) {
+		log.Printf("Warning: sending incident %d without a location: %q geocodes to %f,%f, outside the bounds.", i.ID, i.Address, lat, lon)
+		return false
+	}
+	i.Latitude = sql.NullFloat64{Float64: lat, Valid: true}
+	i.Longitude = sql.NullFloat64{Float64: lon, Valid: true}
+	return true
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// runDaemon runs the scheduler's jobs while this replica is the elected leader, until SIGINT
+// or SIGTERM is received: the unscheduled ones every interval, and the scheduled ones when
+// they are due. If consume is not nil, it runs while this replica leads, and incidents are
+// processed as soon as it signals wake. Under systemd with Type=notify, it reports readiness and
+// status, and feeds the watchdog if WatchdogSec is set.
+func runDaemon(db *sql.DB, reporter ErrorReporter, interval time.Duration, s *scheduler, consume func(ctx context.Context, wake chan<- struct{})) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	elector := postgres.NewLeaderElector(db, runLockKey())
+	defer elector.Resign()
+
+	log.Printf("Running as daemon, polling every %s.", interval)
+	startWatchdog(ctx, s.a)
+	notifyStatus("Started.", "READY=1")
+
+	wake := make(chan struct{}, 1)
+	var stopConsumer context.CancelFunc
+	defer func() {
+		if stopConsumer != nil {
+			stopConsumer()
+		}
+	}()
+
+	wasLeader := true
+	nextPoll := time.Now()
+	woken := false
+	for {
+		poll := !time.Now().Before(nextPoll)
+		if poll {
+			nextPoll = time.Now().Add(interval)
+		}
+		if elector.IsLeader(ctx) {
+			wasLeader = true
+			if consume != nil && stopConsumer == nil {
+				stopConsumer = startConsumer(ctx, consume, wake)
+			}
+			var force []string
+			if woken {
+				force = []string{jobNewIncidents, jobClearedIncidents}
+			}
+			if err := s.run(poll, force...); err != nil {
+				log.Printf("Error during run: %v", err)
+				reporter.Report(err, "error", nil)
+				notifyStatus("Leading; the last run at " + time.Now().Format(time.TimeOnly) + " failed: " + err.Error())
+			} else {
+				notifyStatus("Leading; last run at " + time.Now().Format(time.TimeOnly) + ".")
+			}
+		} else {
+			if stopConsumer != nil {
+				stopConsumer()
+				stopConsumer = nil
+			}
+			if wasLeader {
+				log.Println("Another replica is the leader; standing by.")
+				notifyStatus("Standing by; another replica is the leader.")
+				wasLeader = false
+			}
+		}
+
+		wait := time.Until(nextPoll)
+		if next := s.nextRun(); !next.IsZero() && time.Until(next) < wait {
+			wait = time.Until(next)
+		}
+		timer := time.NewTimer(wait)
+		woken = false
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			log.Println("Shutting down.")
+			notifyStatus("Shutting down.", "STOPPING=1")
+			return
+		case <-timer.C:
+		case <-wake:
+			woken = true
+		}
+		timer.Stop()
+	}
+}
+
+// startConsumer runs consume in the background until the returned function is called.
+func startConsumer(ctx context.Context, consume func(ctx context.Context, wake chan<- struct{}), wake chan<- struct{}) context.CancelFunc {
+	ctx, cancel := context.WithCancel(ctx)
+	go consume(ctx, wake)
+	return cancel
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// postgresDSN builds the connection string from the current environment.
+func postgresDSN() string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=require",
+		os.Getenv("DATABASE_HOST"), os.Getenv("DATABASE_PORT"), os.Getenv("DATABASE_USERNAME"),
+		os.Getenv("DATABASE_PASSWORD"), os.Getenv("DATABASE_NAME"))
+}
+
+// envConnector rebuilds the DSN for every new connection so a rotated DATABASE_PASSWORD
+// is picked up without restarting.
+type envConnector struct{}
+
+func (envConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	connector, err := pq.NewConnector(postgresDSN())
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+func (envConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// Startup connection retries, overridable with DB_CONNECT_RETRIES and DB_CONNECT_BACKOFF.
+const (
+	defaultConnectRetries = 6
+	defaultConnectBackoff = time.Second
+)
+
+// connectDB waits for the database to accept connections; see postgres.Connect.
+func connectDB(db *sql.DB) error {
+	retries, backoff := defaultConnectRetries, defaultConnectBackoff
+	if v := os.Getenv("DB_CONNECT_RETRIES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid DB_CONNECT_RETRIES %q", v)
+		}
+		retries = n
+	}
+	if v := os.Getenv("DB_CONNECT_BACKOFF"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid DB_CONNECT_BACKOFF %q", v)
+		}
+		backoff = d
+	}
+	return postgres.Connect(db, retries, backoff)
+}
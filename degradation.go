@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/mtickle/unity-alerts/enrich"
+)
+
+// defaultHoldFor is how long a held incident waits for its enrichments when the config
+// doesn't say.
+const defaultHoldFor = 5 * time.Minute
+
+// DegradationConfig decides, per enrichment, what happens to an alert when it fails: "send"
+// sends the alert without it, the default, and "hold" keeps the incident back to try again
+// next run, until the incident is HoldFor old. Location covers coordinates that were
+// implausible and could not be geocoded (see Bounds), which leaves the alert without a map.
+// A camera capture whose camera_captures row could not be written is attached regardless.
+// Every skipped enrichment is recorded with the delivery.
+type DegradationConfig struct {
+	Location   string `json:"location,omitempty"`
+	Cameras    string `json:"cameras,omitempty"`
+	StreetView string `json:"street_view,omitempty"`
+	HoldFor    string `json:"hold_for,omitempty"` // Default "5m".
+}
+
+func (d DegradationConfig) validate() error {
+	for name, policy := range d.policies() {
+		if policy != "" && policy != "send" && policy != "hold" {
+			return fmt.Errorf("degradation.%s must be \"send\" or \"hold\", not %q", name, policy)
+		}
+	}
+	if d.HoldFor != "" {
+		if v, err := time.ParseDuration(d.HoldFor); err != nil || v <= 0 {
+			return fmt.Errorf("degradation.hold_for: invalid duration %q", d.HoldFor)
+		}
+	}
+	return nil
+}
+
+func (d DegradationConfig) policies() map[string]string {
+	return map[string]string{
+		enrich.SkippedLocation:   d.Location,
+		enrich.SkippedCameras:    d.Cameras,
+		enrich.SkippedStreetView: d.StreetView,
+	}
+}
+
+// holds reports whether an incident missing the skipped enrichments should wait for the next
+// run rather than be sent now.
+func (d DegradationConfig) holds(incidentID int, reported time.Time, skipped []string) bool {
+	holdFor := defaultHoldFor
+	if d.HoldFor != "" {
+		holdFor, _ = time.ParseDuration(d.HoldFor)
+	}
+	if time.Since(reported) >= holdFor {
+		return false
+	}
+	policies := d.policies()
+	for _, name := range skipped {
+		if policies[name] == "hold" {
+			log.Printf("Holding incident %d for the next run: %s enrichment failed.", incidentID, name)
+			return true
+		}
+	}
+	return false
+}
+
+// withSkipped adds an enrichment to a list of skipped ones.
+func withSkipped(skipped []string, name string) []string {
+	if slices.Contains(skipped, name) {
+		return skipped
+	}
+	return append(skipped, name)
+}
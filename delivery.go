@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/mtickle/unity-alerts/sink/discord"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// newDelivery fills in the outcome of a request that took since start and returned err.
+func newDelivery(incidentID int, sink, kind string, payload interface{}, messageID string, start time.Time, err error) postgres.Delivery {
+	d := postgres.Delivery{IncidentID: incidentID, Sink: sink, Kind: kind, MessageID: messageID, Latency: time.Since(start)}
+	if payload != nil {
+		if body, jsonErr := json.Marshal(payload); jsonErr == nil {
+			sum := sha256.Sum256(body)
+			d.PayloadHash = hex.EncodeToString(sum[:])
+		}
+	}
+	var statusErr *discord.StatusError
+	switch {
+	case err == nil:
+		d.HTTPStatus = 200
+	case errors.As(err, &statusErr):
+		d.HTTPStatus = statusErr.StatusCode
+		d.Error = err.Error()
+	default:
+		d.Error = err.Error()
+	}
+	return d
+}
+
+// attachmentSize is the combined size of the files that were uploaded.
+func attachmentSize(attachments ...discord.Attachment) int64 {
+	var total int64
+	for _, attachment := range attachments {
+		total += int64(len(attachment.Data))
+	}
+	return total
+}
+
+// logDelivery records a delivery, logging rather than failing when the audit insert fails,
+// and counts it when it failed.
+func (a *app) logDelivery(d postgres.Delivery) {
+	if d.Error != "" {
+		a.failedDeliveries.Add(1)
+	}
+	if err := postgres.InsertDelivery(a.db, d); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/sink/apprise"
+	"github.com/mtickle/unity-alerts/sink/discord"
+	"github.com/mtickle/unity-alerts/sink/email"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// maxDigestIncidents is how many incidents one digest lists.
+const maxDigestIncidents = discord.MaxFieldsPerEmbed
+
+// DigestConfig emails each subscriber who chose a daily digest over real-time pings (with
+// /preferences) a list of the previous day's incidents inside their subscriptions.
+type DigestConfig struct {
+	// Mailer is a mailto:// URL, as for a route's notify_url and usually a ${VAR} reference,
+	// naming the SMTP server and sender. Its to= address is replaced by each subscriber's.
+	Mailer string `json:"mailer"`
+	At     string `json:"at,omitempty"` // Time of day in the configured timezone, "15:04" format (default 07:00).
+}
+
+func (d *DigestConfig) validate() error {
+	if d == nil {
+		return nil
+	}
+	if _, err := d.messenger("digest@example.com"); err != nil {
+		return fmt.Errorf("digest.mailer: %w", err)
+	}
+	if d.At != "" {
+		if _, err := time.Parse("15:04", d.At); err != nil {
+			return fmt.Errorf("digest.at: invalid time %q", d.At)
+		}
+	}
+	return nil
+}
+
+// messenger returns the mailer addressed to one subscriber.
+func (d DigestConfig) messenger(to string) (email.Messenger, error) {
+	messenger, err := apprise.Parse(os.ExpandEnv(d.Mailer))
+	if err != nil {
+		return email.Messenger{}, err
+	}
+	mailer, ok := messenger.(email.Messenger)
+	if !ok {
+		return email.Messenger{}, fmt.Errorf("must be a mailto:// URL")
+	}
+	mailer.To = []string{to}
+	return mailer, nil
+}
+
+// parseEmail reads the address given to /preferences or the portal; "off" or "" clears it.
+func parseEmail(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.EqualFold(s, "off") {
+		return "", nil
+	}
+	addr, err := mail.ParseAddress(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid email address %q", s)
+	}
+	return addr.Address, nil
+}
+
+// sendDigests emails yesterday's digest to each digest subscriber once the configured time of
+// day has passed.
+func (a *app) sendDigests(cfg *Config) {
+	if cfg.Digest == nil || a.notifyDiscord == "0" {
+		return
+	}
+	loc := cfg.Location(RouteConfig{})
+	at := cfg.Digest.At
+	if at == "" {
+		at = "07:00"
+	}
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if now.Sub(today) < time.Duration(clockMinutes(at))*time.Minute {
+		return
+	}
+	day := today.AddDate(0, 0, -1)
+
+	subs, err := postgres.DigestSubscribers(a.db)
+	if err != nil {
+		log.Printf("Error loading digest subscribers: %v", err)
+		return
+	}
+	sent := 0
+	for _, s := range subs {
+		claimed, err := postgres.ClaimDigest(a.db, s.UserID, day)
+		if err != nil || !claimed {
+			if err != nil {
+				log.Printf("Warning: %v", err)
+			}
+			continue
+		}
+		if err := a.sendDigest(cfg, s, day, today); err != nil {
+			log.Printf("Error sending digest to user %s: %v", s.UserID, err)
+			a.reporter.Report(fmt.Errorf("sending digest: %w", err), "error", map[string]string{"user": s.UserID})
+			if err := postgres.ReleaseDigest(a.db, s.UserID, day); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+			continue
+		}
+		sent++
+	}
+	if sent > 0 {
+		log.Printf("Sent %d digest(s) for %s.", sent, day.Format(time.DateOnly))
+	}
+}
+
+// sendDigest mails one subscriber the incidents between from and to inside any of their
+// subscriptions, rendered as the same incident list the slash commands reply with.
+func (a *app) sendDigest(cfg *Config, s postgres.DigestSubscriber, from, to time.Time) error {
+	incidents, statuses, err := queryIncidents(cfg, a.db, `WHERE {timestamp} >= $1 AND {timestamp} < $2 AND NOT {is_test}
+		AND {latitude} IS NOT NULL AND {longitude} IS NOT NULL
+		AND EXISTS (SELECT 1 FROM subscriptions s WHERE s.user_id = $3
+		    AND ST_DWithin(ST_SetSRID(ST_MakePoint({longitude}, {latitude}), 4326)::geography,
+		                   ST_SetSRID(ST_MakePoint(s.longitude, s.latitude), 4326)::geography, s.radius_meters)
+		    AND (cardinality(s.event_types) = 0 OR EXISTS (SELECT 1 FROM unnest(s.event_types) t WHERE {event_type} ILIKE '%' || replace(replace(replace(t, '\', '\\'), '%', '\%'), '_', '\_') || '%' ESCAPE '\')))
+		ORDER BY {timestamp}`, from, to, s.UserID)
+	if err != nil {
+		return err
+	}
+	var wanted []incident.Incident
+	var wantedStatuses []string
+	for n, i := range incidents {
+		if wantsIncident(s.Subscriber, i) {
+			wanted = append(wanted, i)
+			wantedStatuses = append(wantedStatuses, statuses[n])
+		}
+	}
+
+	opts := cfg.RenderOptions(RouteConfig{Timezone: s.Timezone}, "")
+	title := fmt.Sprintf(opts.T("digest_title"), from.Format(opts.T("digest_date_format")))
+	shown := len(wanted)
+	if shown > maxDigestIncidents {
+		shown = maxDigestIncidents
+	}
+	embed := buildIncidentListEmbed(title, wanted[:shown], wantedStatuses[:shown], opts)
+	if len(wanted) == 0 {
+		embed.Fields[0].Value = opts.T("digest_none")
+	}
+	footer := opts.T("digest_footer")
+	if more := len(wanted) - shown; more > 0 {
+		footer = fmt.Sprintf(opts.T("digest_more"), more) + "\n" + footer
+	}
+	embed.Footer = discord.EmbedFooter{Text: footer}
+
+	mailer, err := cfg.Digest.messenger(s.Email)
+	if err != nil {
+		return err
+	}
+	_, err = mailer.Send(discord.WebhookPayload{Embeds: []discord.Embed{embed}})
+	return err
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/sink/discord"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// runningLongPercentile is the share of similar incidents that cleared sooner before an active
+// one counts as running long.
+const runningLongPercentile = 0.95
+
+// runningLongMinSamples is how many cleared incidents a percentile needs to be trusted.
+const runningLongMinSamples = 20
+
+// recordDuration stores how long a cleared incident was active, for time-to-clear percentiles.
+func (a *app) recordDuration(cfg *Config, id int) {
+	incidents, _, err := queryIncidents(cfg, a.db, "WHERE {id} = $1", id)
+	if err != nil || len(incidents) != 1 {
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		return
+	}
+	i := incidents[0]
+	if i.IsTest || i.Timestamp.IsZero() {
+		return
+	}
+	if err := postgres.RecordIncidentDuration(a.db, i.ID, i.Source, i.EventType, incident.RoadName(i), i.Timestamp); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// flagLongRunning posts a follow-up to the alerts of active incidents that have outlasted 95%
+// of similar incidents, on routes with the running_long feature. Bot routes get it as a reply
+// to the alert.
+func (a *app) flagLongRunning(cfg *Config) {
+	incidents, _, err := queryIncidents(cfg, a.db, "WHERE {status} = 'active' AND {discord_message_id} <> '' AND NOT {is_test}")
+	if err != nil {
+		log.Printf("Error loading active incidents: %v", err)
+		return
+	}
+	for _, i := range incidents {
+		road := incident.RoadName(i)
+		typical, samples, err := postgres.ClearTimePercentile(a.db, i.EventType, road, runningLongPercentile, runningLongMinSamples)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			return
+		}
+		active := time.Since(i.Timestamp)
+		if typical == 0 || active <= typical {
+			continue
+		}
+		messages, err := postgres.AlertMessagesFor(a.db, i.ID)
+		if err != nil {
+			log.Printf("Error loading alert messages: %v", err)
+			continue
+		}
+		var routes []postgres.AlertMessage
+		for _, m := range messages {
+			if route, ok := cfg.Route(m.Route); ok && m.Part == 0 && cfg.FeatureEnabled(discord.FeatureRunningLong, i.Source, route) {
+				routes = append(routes, m)
+			}
+		}
+		if len(routes) == 0 {
+			continue
+		}
+		claimed, err := postgres.ClaimRunningLong(a.db, i.ID)
+		if err != nil || !claimed {
+			if err != nil {
+				log.Printf("Warning: %v", err)
+			}
+			continue
+		}
+		log.Printf("Incident %d (%s) has been active for %s, longer than %.0f%% of similar incidents.", i.ID, i.EventType, active.Round(time.Minute), runningLongPercentile*100)
+		a.publishEvent(cfg, eventUpdated, i, incidentEvent{Reason: "running_long"})
+		for _, m := range routes {
+			route, _ := cfg.Route(m.Route)
+			opts := cfg.RenderOptions(route, i.Source)
+			messenger := reportMessenger(route)
+			if bot, ok := messenger.(discord.BotMessenger); ok {
+				bot.ReplyTo = m.MessageID
+				messenger = bot
+			}
+			payload := discord.WebhookPayload{Username: opts.T("bot_username"), Embeds: []discord.Embed{buildRunningLongEmbed(i, active, typical, samples, opts)}}
+			if _, err := messenger.Send(payload); err != nil {
+				log.Printf("Error sending running long follow-up to route %q: %v", route.Name, err)
+			}
+		}
+	}
+}
+
+// buildRunningLongEmbed renders the follow-up for an incident active longer than typical.
+func buildRunningLongEmbed(i incident.Incident, active, typical time.Duration, samples int, opts discord.RenderOptions) discord.Embed {
+	return discord.Embed{
+		Title: "⏳ " + opts.T("running_long_title"),
+		Color: 15844367, // Gold
+		Fields: []discord.EmbedField{
+			{Name: opts.T("field_address"), Value: discord.SanitizeFeedText(opts.Address(i))},
+			{Name: opts.T("running_long_active"), Value: active.Round(time.Minute).String(), Inline: true},
+			{Name: opts.T("running_long_typical"), Value: fmt.Sprintf(opts.T("running_long_typical_value"), typical.Round(time.Minute), samples), Inline: true},
+		},
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+}
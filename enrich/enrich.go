@@ -0,0 +1,157 @@
+// Package enrich gathers the extra context sent with an alert: nearby traffic camera frames
+// and geocoded locations.
+package enrich
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/mtickle/unity-alerts/camera"
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// spareCameras are looked up beyond the grid's size to stand in for cameras showing a placeholder.
+const spareCameras = 2
+
+// Result holds the per-incident lookups shared by every route the incident is sent to.
+type Result struct {
+	NearbyCameras  []camera.Camera
+	Attachment     []byte // The captured camera image, as JPEG.
+	AttachmentName string
+	AttachmentURL  string // A hosted copy of Attachment; when set, alerts link to it instead of uploading.
+
+	// Geometry is a GeoJSON file with the extent of an NCDOT closure, when the feed has one.
+	Geometry     []byte
+	GeometryName string
+
+	// StreetViewURL is a Street View image of the location, when requested and available.
+	StreetViewURL string
+
+	// Skipped lists the enrichments that failed and are missing, e.g. SkippedCameras.
+	Skipped []string
+
+	// AttachedCameras counts the cameras, from the front of NearbyCameras, whose frames are in
+	// the attachment.
+	AttachedCameras int
+}
+
+// ImageURL is where an alert's embed finds the camera image: its hosted URL, or the uploaded
+// attachment. It is empty when no frame was captured.
+func (r Result) ImageURL() string {
+	if r.AttachmentURL != "" {
+		return r.AttachmentURL
+	}
+	if r.AttachmentName != "" {
+		return "attachment://" + r.AttachmentName
+	}
+	return ""
+}
+
+// OtherCameras are the nearby cameras not shown in the attachment, to be linked instead.
+// Without a capture the closest camera is assumed to take the image slot.
+func (r Result) OtherCameras() []camera.Camera {
+	skip := r.AttachedCameras
+	if skip == 0 {
+		skip = 1
+	}
+	if len(r.NearbyCameras) <= skip {
+		return nil
+	}
+	return r.NearbyCameras[skip:]
+}
+
+// Names of enrichments in Result.Skipped. SkippedLocation is set by callers that had to drop an
+// incident's coordinates.
+const (
+	SkippedLocation   = "location"
+	SkippedCameras    = "cameras"
+	SkippedStreetView = "street_view"
+)
+
+// Options selects the optional lookups for an incident, based on what its routes show.
+type Options struct {
+	Cameras  bool           // Capture camera frames.
+	Location *time.Location // Timezone of the capture time on camera frames.
+
+	StreetView      bool // Look up a Street View image, at most StreetViewLimit a day.
+	StreetViewLimit int
+	MapsAPIKey      string
+}
+
+// Incident saves the incident's closure geometry, if any, looks up Street View imagery when
+// asked, and when at least one route wants camera imagery, finds nearby cameras and captures
+// frames from the closest ones into a single image. Lookups still running when ctx is done
+// are abandoned.
+func Incident(ctx context.Context, db *sql.DB, i incident.Incident, opts Options) Result {
+	var result Result
+
+	var err error
+	if opts.StreetView && i.Latitude.Valid && i.Longitude.Valid {
+		result.StreetViewURL, err = streetView(ctx, db, opts, i.Latitude.Float64, i.Longitude.Float64)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			result.Skipped = append(result.Skipped, SkippedStreetView)
+		}
+	}
+
+	result.Geometry, result.GeometryName, err = closureGeoJSON(i)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	if !opts.Cameras {
+		return result
+	}
+	if i.Latitude.Valid && i.Longitude.Valid {
+		var err error
+		result.NearbyCameras, err = camera.FindNearby(ctx, db, incident.Corridor(i), i.Latitude.Float64, i.Longitude.Float64, camera.MaxGridCameras+spareCameras)
+		if err != nil {
+			log.Printf("Could not fetch nearby cameras: %v", err)
+			result.Skipped = append(result.Skipped, SkippedCameras)
+		}
+	}
+
+	if len(result.NearbyCameras) > 0 {
+		data, name, shown, err := camera.Capture(ctx, db, i.ID, result.NearbyCameras, opts.Location)
+		if err != nil {
+			log.Printf("Failed to capture camera image: %v", err)
+			result.Skipped = append(result.Skipped, SkippedCameras)
+			return result
+		}
+		result.Attachment, result.AttachmentName, result.AttachedCameras = data, name, len(shown)
+		result.NearbyCameras = append(shown, without(result.NearbyCameras, shown)...)
+	}
+	return result
+}
+
+// without returns the cameras in all that are not in exclude.
+func without(all, exclude []camera.Camera) []camera.Camera {
+	var rest []camera.Camera
+	for _, c := range all {
+		if !slices.Contains(exclude, c) {
+			rest = append(rest, c)
+		}
+	}
+	return rest
+}
+
+// streetView returns a Street View image URL for a point, unless the daily limit is used up.
+func streetView(ctx context.Context, db *sql.DB, opts Options, lat, lon float64) (string, error) {
+	url, err := streetViewURL(ctx, opts.MapsAPIKey, lat, lon)
+	if err != nil {
+		return "", err
+	}
+	ok, err := postgres.TakeStreetViewQuota(db, opts.StreetViewLimit)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("daily Street View limit of %d reached", opts.StreetViewLimit)
+	}
+	return url, nil
+}
@@ -0,0 +1,53 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/mtickle/unity-alerts/internal/breaker"
+)
+
+// Geocode resolves a free-form address to coordinates with the Google Geocoding API.
+func Geocode(ctx context.Context, apiKey, address string) (float64, float64, error) {
+	if apiKey == "" {
+		return 0, 0, fmt.Errorf("geocoding requires GOOGLE_MAPS_API_KEY")
+	}
+	endpoint := fmt.Sprintf("https://maps.googleapis.com/maps/api/geocode/json?address=%s&key=%s",
+		url.QueryEscape(address), url.QueryEscape(apiKey))
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	resp, err := breaker.Client(5 * time.Second).Do(req)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to call geocoding API: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return 0, 0, fmt.Errorf("geocoding API returned non-200 status: %s", resp.Status)
+	}
+
+	var result struct {
+		Status  string `json:"status"`
+		Results []struct {
+			Geometry struct {
+				Location struct {
+					Lat float64 `json:"lat"`
+					Lng float64 `json:"lng"`
+				} `json:"location"`
+			} `json:"geometry"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, 0, fmt.Errorf("failed to decode geocoding response: %w", err)
+	}
+	if result.Status != "OK" || len(result.Results) == 0 {
+		return 0, 0, fmt.Errorf("no geocoding result for %q (status %s)", address, result.Status)
+	}
+	loc := result.Results[0].Geometry.Location
+	return loc.Lat, loc.Lng, nil
+}
@@ -0,0 +1,35 @@
+package enrich
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mtickle/unity-alerts/incident"
+)
+
+// closureGeoJSON renders an incident's closure geometry as a GeoJSON file that map apps can
+// open. It returns the file's contents and name, or nil when there is no geometry.
+func closureGeoJSON(i incident.Incident) ([]byte, string, error) {
+	geometry := incident.ClosureGeometry(i)
+	if geometry == nil {
+		return nil, "", nil
+	}
+	feature := map[string]interface{}{
+		"type":     "Feature",
+		"geometry": geometry,
+		"properties": map[string]interface{}{
+			"incident_id": i.ID,
+			"event_type":  i.EventType,
+			"address":     i.Address,
+			"road":        incident.Corridor(i),
+		},
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"type":     "FeatureCollection",
+		"features": []interface{}{feature},
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("error encoding closure geometry: %w", err)
+	}
+	return data, fmt.Sprintf("incident_%d_closure.geojson", i.ID), nil
+}
@@ -0,0 +1,42 @@
+package enrich
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mtickle/unity-alerts/internal/breaker"
+)
+
+// streetViewURL returns a Street View Static API image of a point. It first asks the free
+// metadata endpoint whether imagery exists there, so no billed request returns a blank image.
+func streetViewURL(ctx context.Context, apiKey string, lat, lon float64) (string, error) {
+	if apiKey == "" {
+		return "", fmt.Errorf("street view requires GOOGLE_MAPS_API_KEY")
+	}
+	location := fmt.Sprintf("location=%.6f,%.6f&key=%s", lat, lon, apiKey)
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://maps.googleapis.com/maps/api/streetview/metadata?"+location, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := breaker.Client(5 * time.Second).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to call Street View metadata API: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("street view metadata API returned non-200 status: %s", resp.Status)
+	}
+	var metadata struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+		return "", fmt.Errorf("failed to decode Street View metadata: %w", err)
+	}
+	if metadata.Status != "OK" {
+		return "", fmt.Errorf("no Street View imagery at %.6f,%.6f (status %s)", lat, lon, metadata.Status)
+	}
+	return "https://maps.googleapis.com/maps/api/streetview?size=600x400&" + location, nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/incident"
+)
+
+// ErrorReporter forwards panics and delivery failures to an external error tracker.
+type ErrorReporter interface {
+	Report(err error, level string, tags map[string]string)
+}
+
+// newErrorReporter picks a reporter based on SENTRY_DSN or ERROR_REPORT_URL.
+// With neither set, errors are only logged as before.
+func newErrorReporter() ErrorReporter {
+	if dsn := os.Getenv("SENTRY_DSN"); dsn != "" {
+		r, err := newSentryReporter(dsn, os.Getenv("SENTRY_ENVIRONMENT"))
+		if err != nil {
+			log.Printf("Warning: invalid SENTRY_DSN, error reporting disabled: %v", err)
+			return noopReporter{}
+		}
+		log.Println("Error reporting enabled (Sentry).")
+		return r
+	}
+	if hookURL := os.Getenv("ERROR_REPORT_URL"); hookURL != "" {
+		log.Println("Error reporting enabled (generic webhook).")
+		return &hookReporter{url: hookURL, client: &http.Client{Timeout: 5 * time.Second}}
+	}
+	return noopReporter{}
+}
+
+// incidentTags returns the incident context attached to every report. The address is left
+// out: reports leave our infrastructure, and the incident ID is enough to look it up.
+func incidentTags(inc incident.Incident) map[string]string {
+	return map[string]string{
+		"incident_id": strconv.Itoa(inc.ID),
+		"source":      inc.Source,
+		"source_id":   inc.SourceID,
+		"event_type":  inc.EventType,
+	}
+}
+
+// recoverAndReport turns a panic into a report so one bad incident can't take the whole run down.
+// It must be called directly via defer.
+func recoverAndReport(reporter ErrorReporter, tags map[string]string) {
+	if r := recover(); r != nil {
+		err := fmt.Errorf("panic: %v", r)
+		log.Printf("Recovered from %v\n%s", err, debug.Stack())
+		reporter.Report(err, "fatal", tags)
+	}
+}
+
+type noopReporter struct{}
+
+func (noopReporter) Report(error, string, map[string]string) {}
+
+// hookReporter POSTs a small JSON document to an arbitrary URL.
+type hookReporter struct {
+	url    string
+	client *http.Client
+}
+
+func (h *hookReporter) Report(err error, level string, tags map[string]string) {
+	hostname, _ := os.Hostname()
+	body, _ := json.Marshal(map[string]interface{}{
+		"service":   "unity-alerts",
+		"host":      hostname,
+		"level":     level,
+		"error":     redact(err.Error()),
+		"context":   tags,
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	})
+	resp, postErr := h.client.Post(h.url, "application/json", bytes.NewReader(body))
+	if postErr != nil {
+		log.Printf("Warning: failed to send error report: %v", postErr)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		log.Printf("Warning: error report hook returned %s", resp.Status)
+	}
+}
+
+// sentryReporter sends events to Sentry's envelope endpoint without pulling in the SDK.
+type sentryReporter struct {
+	endpoint    string
+	publicKey   string
+	environment string
+	client      *http.Client
+}
+
+func newSentryReporter(dsn, environment string) (*sentryReporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("dsn is missing public key")
+	}
+	projectID := strings.Trim(u.Path, "/")
+	if projectID == "" {
+		return nil, fmt.Errorf("dsn is missing project id")
+	}
+	return &sentryReporter{
+		endpoint:    fmt.Sprintf("%s://%s/api/%s/envelope/", u.Scheme, u.Host, projectID),
+		publicKey:   u.User.Username(),
+		environment: environment,
+		client:      &http.Client{Timeout: 5 * time.Second},
+	}, nil
+}
+
+func (s *sentryReporter) Report(err error, level string, tags map[string]string) {
+	idBytes := make([]byte, 16)
+	rand.Read(idBytes)
+	eventID := hex.EncodeToString(idBytes)
+	now := time.Now().UTC().Format(time.RFC3339)
+	hostname, _ := os.Hostname()
+
+	event := map[string]interface{}{
+		"event_id":    eventID,
+		"timestamp":   now,
+		"level":       level,
+		"platform":    "go",
+		"logger":      "unity-alerts",
+		"server_name": hostname,
+		"environment": s.environment,
+		"tags":        tags,
+		"exception": map[string]interface{}{
+			"values": []map[string]string{{"type": fmt.Sprintf("%T", err), "value": redact(err.Error())}},
+		},
+	}
+	eventJSON, _ := json.Marshal(event)
+
+	var envelope bytes.Buffer
+	fmt.Fprintf(&envelope, "{\"event_id\":%q,\"sent_at\":%q}\n", eventID, now)
+	fmt.Fprintf(&envelope, "{\"type\":\"event\",\"length\":%d}\n", len(eventJSON))
+	envelope.Write(eventJSON)
+	envelope.WriteByte('\n')
+
+	req, reqErr := http.NewRequest("POST", s.endpoint, &envelope)
+	if reqErr != nil {
+		log.Printf("Warning: failed to build Sentry request: %v", reqErr)
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=unity-alerts/1.0, sentry_key=%s", s.publicKey))
+
+	resp, sendErr := s.client.Do(req)
+	if sendErr != nil {
+		log.Printf("Warning: failed to send error report to Sentry: %v", sendErr)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(resp.Body)
+		log.Printf("Warning: Sentry returned non-2xx status: %s. Body: %s", resp.Status, string(body))
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/sink/oncall"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// EscalationConfig opens a PagerDuty or Opsgenie alert for matching incidents, such as a water
+// main break near a facility, and resolves it when the incident clears. Incidents match when
+// their event type is listed or their severity reaches MinSeverity, and, with Near, when they
+// are inside the area. Escalations don't depend on routes.
+type EscalationConfig struct {
+	Name     string `json:"name"`
+	Provider string `json:"provider"` // "pagerduty" or "opsgenie".
+
+	// Key is the PagerDuty integration's routing key or the Opsgenie API key, usually a
+	// ${VAR} reference. OPSGENIE_API_URL selects another Opsgenie region.
+	Key string `json:"key"`
+
+	EventTypes  []string `json:"event_types,omitempty"`
+	MinSeverity int      `json:"min_severity,omitempty"`
+	Near        *Area    `json:"near,omitempty"`
+
+	// Severity is the alert's PagerDuty severity ("critical" by default, or "error",
+	// "warning" or "info"), mapped to Opsgenie priorities P1, P2, P3 and P5.
+	Severity string `json:"severity,omitempty"`
+}
+
+func validateEscalations(c *Config) error {
+	seen := make(map[string]bool)
+	for n := range c.Escalations {
+		e := &c.Escalations[n]
+		if e.Name == "" {
+			return fmt.Errorf("escalation %d has no name", n)
+		}
+		if seen[e.Name] {
+			return fmt.Errorf("duplicate escalation name %q", e.Name)
+		}
+		seen[e.Name] = true
+		if e.Provider != "pagerduty" && e.Provider != "opsgenie" {
+			return fmt.Errorf("escalation %q: provider must be \"pagerduty\" or \"opsgenie\", not %q", e.Name, e.Provider)
+		}
+		if os.ExpandEnv(e.Key) == "" {
+			return fmt.Errorf("escalation %q has no key", e.Name)
+		}
+		if len(e.EventTypes) == 0 && e.MinSeverity <= 0 {
+			return fmt.Errorf("escalation %q needs event_types or min_severity", e.Name)
+		}
+		switch e.Severity {
+		case "", "critical", "error", "warning", "info":
+		default:
+			return fmt.Errorf("escalation %q: invalid severity %q", e.Name, e.Severity)
+		}
+		if err := e.Near.validate(); err != nil {
+			return fmt.Errorf("escalation %q: %w", e.Name, err)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether an incident should be escalated.
+func (e EscalationConfig) Matches(inc incident.Incident) bool {
+	matched := e.MinSeverity > 0 && incident.Severity(inc)+inc.Priority >= e.MinSeverity
+	for _, eventType := range e.EventTypes {
+		if strings.EqualFold(eventType, inc.EventType) {
+			matched = true
+		}
+	}
+	return matched && e.Near.Contains(inc)
+}
+
+func (e EscalationConfig) pager() oncall.Pager {
+	if e.Provider == "opsgenie" {
+		return oncall.Opsgenie{APIKey: os.ExpandEnv(e.Key), APIURL: os.Getenv("OPSGENIE_API_URL")}
+	}
+	return oncall.PagerDuty{RoutingKey: os.ExpandEnv(e.Key)}
+}
+
+// escalationKey identifies an incident's alert in the on-call service.
+func escalationKey(incidentID int) string {
+	return fmt.Sprintf("unity-alerts-%d", incidentID)
+}
+
+// escalate opens an alert for each escalation the incident matches. Test incidents and dry
+// runs are never escalated.
+func (a *app) escalate(cfg *Config, i incident.Incident) {
+	if a.notifyDiscord == "0" || i.IsTest {
+		return
+	}
+	for _, e := range cfg.Escalations {
+		if !e.Matches(i) {
+			continue
+		}
+		key := escalationKey(i.ID)
+		claimed, err := postgres.ClaimEscalation(a.db, i.ID, e.Name, key)
+		if err != nil {
+			log.Printf("Error: %v", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		if err := e.pager().Trigger(escalationAlert(cfg, e, i, key)); err != nil {
+			log.Printf("Error escalating incident %d via %q: %v", i.ID, e.Name, err)
+			tags := incidentTags(i)
+			tags["escalation"] = e.Name
+			a.reporter.Report(fmt.Errorf("escalating incident: %w", err), "error", tags)
+			if err := postgres.ReleaseEscalation(a.db, i.ID, e.Name); err != nil {
+				log.Printf("Warning: failed to release escalation: %v", err)
+			}
+			continue
+		}
+		log.Printf("Escalated incident %d (%s) via %q.", i.ID, i.EventType, e.Name)
+	}
+}
+
+// escalationAlert describes the incident for the on-call service.
+func escalationAlert(cfg *Config, e EscalationConfig, i incident.Incident, key string) oncall.Alert {
+	severity := e.Severity
+	if severity == "" {
+		severity = "critical"
+	}
+	details := map[string]string{
+		"event_type": i.EventType,
+		"address":    i.Address,
+		"source":     i.Source,
+		"reported":   i.Timestamp.In(cfg.Location(RouteConfig{})).Format(time.RFC1123),
+	}
+	summary := fmt.Sprintf("%s at %s", i.EventType, i.Address)
+	if i.Latitude.Valid && i.Longitude.Valid {
+		details["map"] = fmt.Sprintf("https://www.google.com/maps/search/?api=1&query=%f,%f", i.Latitude.Float64, i.Longitude.Float64)
+		if e.Near != nil {
+			miles := distanceMeters(e.Near.Latitude, e.Near.Longitude, i.Latitude.Float64, i.Longitude.Float64) / metersPerMile
+			details["distance"] = fmt.Sprintf("%.1f mi", miles)
+			summary += fmt.Sprintf(" (%.1f mi away)", miles)
+		}
+	}
+	return oncall.Alert{DedupKey: key, Summary: summary, Source: "unity-alerts/" + i.Source, Severity: severity, Details: details}
+}
+
+// resolveEscalations resolves the alerts of escalated incidents that have cleared.
+func (a *app) resolveEscalations(cfg *Config) {
+	if len(cfg.Escalations) == 0 || a.notifyDiscord == "0" {
+		return
+	}
+	rows, err := a.db.Query(cfg.SQL(`SELECT e.incident_id, e.name, e.dedup_key
+		FROM escalations e JOIN {incidents} i ON i.{id} = e.incident_id
+		WHERE e.resolved_at IS NULL AND i.{status} = 'cleared'`))
+	if err != nil {
+		log.Printf("Error querying open escalations: %v", err)
+		return
+	}
+	type openEscalation struct {
+		incidentID int
+		name, key  string
+	}
+	var open []openEscalation
+	for rows.Next() {
+		var o openEscalation
+		if err := rows.Scan(&o.incidentID, &o.name, &o.key); err != nil {
+			log.Printf("Error scanning escalation: %v", err)
+			continue
+		}
+		open = append(open, o)
+	}
+	rows.Close()
+
+	for _, o := range open {
+		var e *EscalationConfig
+		for n := range cfg.Escalations {
+			if cfg.Escalations[n].Name == o.name {
+				e = &cfg.Escalations[n]
+			}
+		}
+		if e == nil {
+			log.Printf("Warning: escalation %q no longer exists; resolve incident %d's alert by hand.", o.name, o.incidentID)
+		} else if err := e.pager().Resolve(o.key); err != nil {
+			log.Printf("Error resolving escalation %q for incident %d: %v", o.name, o.incidentID, err)
+			continue
+		} else {
+			log.Printf("Resolved escalation %q for cleared incident %d.", o.name, o.incidentID)
+		}
+		if err := postgres.ResolveEscalation(a.db, o.incidentID, o.name); err != nil {
+			log.Printf("Error: %v", err)
+		}
+	}
+}
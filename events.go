@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// Incident lifecycle event types.
+const (
+	eventCreated   = "created"   // A new incident was picked up, after the keyword rules ran.
+	eventUpdated   = "updated"   // Something changed about an active incident, e.g. it is running long.
+	eventCleared   = "cleared"   // The incident cleared and its alerts were marked.
+	eventDelivered = "delivered" // An alert for the incident was sent to a route.
+)
+
+// EventsConfig publishes every incident lifecycle event to NATS or Kafka, so downstream
+// systems can react without polling the database.
+type EventsConfig struct {
+	// URL is nats://[user:pass@]host:4222, tls://host:4222, kafka://broker:9092,broker:9092 or
+	// kafka+tls://[user:pass@]broker:9093[?sasl=scram-sha-512]. ${VAR} references are expanded.
+	URL string `json:"url"`
+	// Prefix starts every subject or topic (default "unity-alerts"); events are published to
+	// <prefix>.<type>, e.g. unity-alerts.created.
+	Prefix string `json:"prefix,omitempty"`
+}
+
+func (e *EventsConfig) validate() error {
+	if e == nil {
+		return nil
+	}
+	url := os.ExpandEnv(e.URL)
+	if isKafkaURL(url) {
+		if _, err := parseKafkaURL(url); err != nil {
+			return fmt.Errorf("events.url: %w", err)
+		}
+	} else if !strings.HasPrefix(url, "nats://") && !strings.HasPrefix(url, "tls://") {
+		return fmt.Errorf("events.url must be a nats://, tls://, kafka:// or kafka+tls:// URL")
+	}
+	if strings.ContainsAny(e.Prefix, " \t*>") {
+		return fmt.Errorf("events.prefix must not contain spaces or wildcards")
+	}
+	return nil
+}
+
+// incidentEvent is the JSON body of a lifecycle event.
+type incidentEvent struct {
+	Type       string          `json:"type"`
+	At         time.Time       `json:"at"`
+	IncidentID int             `json:"incident_id"`
+	Source     string          `json:"source"`
+	SourceID   string          `json:"source_id"`
+	EventType  string          `json:"event_type"`
+	Address    string          `json:"address"`
+	Latitude   *float64        `json:"latitude"`
+	Longitude  *float64        `json:"longitude"`
+	Timestamp  time.Time       `json:"timestamp"`
+	Tags       []string        `json:"tags,omitempty"`
+	Details    json.RawMessage `json:"details,omitempty"`    // Only on created events.
+	Route      string          `json:"route,omitempty"`      // On delivered events.
+	MessageID  string          `json:"message_id,omitempty"` // On delivered events.
+	Reason     string          `json:"reason,omitempty"`     // On updated events.
+}
+
+// eventPublisher keeps the connection to the NATS server or Kafka cluster between events.
+type eventPublisher struct {
+	url   string
+	nats  *nats.Conn
+	kafka *kafka.Writer
+}
+
+// publish sends one message, connecting first if needed, and waits for the server or
+// partition leader to confirm it has the message.
+func (p *eventPublisher) publish(subject string, key, body []byte) error {
+	if isKafkaURL(p.url) {
+		if p.kafka == nil {
+			cluster, err := parseKafkaURL(p.url)
+			if err != nil {
+				return err
+			}
+			p.kafka = cluster.writer()
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), brokerTimeout)
+		defer cancel()
+		return p.kafka.WriteMessages(ctx, kafka.Message{Topic: subject, Key: key, Value: body})
+	}
+	if p.nats == nil {
+		conn, err := connectNATS(p.url)
+		if err != nil {
+			return err
+		}
+		p.nats = conn
+	}
+	if err := p.nats.Publish(subject, body); err != nil {
+		return err
+	}
+	return p.nats.FlushTimeout(brokerTimeout)
+}
+
+// close disconnects.
+func (p *eventPublisher) close() {
+	if p.nats != nil {
+		p.nats.Close()
+		p.nats = nil
+	}
+	if p.kafka != nil {
+		p.kafka.Close()
+		p.kafka = nil
+	}
+}
+
+// publishEvent hands a lifecycle event for an incident to the in-process subscribers and the
+// syslog collector, and publishes it when events are configured, logging rather than failing when the broker is
+// unavailable. event carries the fields only some types have, such as a delivered event's
+// route. Test and backfilled incidents are left out.
+func (a *app) publishEvent(cfg *Config, eventType string, i incident.Incident, event incidentEvent) {
+	if i.IsTest || i.Backfill {
+		return
+	}
+	event.Type, event.At = eventType, time.Now().UTC()
+	event.IncidentID, event.Source, event.SourceID = i.ID, i.Source, i.SourceID
+	event.EventType, event.Address, event.Timestamp, event.Tags = i.EventType, i.Address, i.Timestamp, i.Tags
+	if i.Latitude.Valid && i.Longitude.Valid {
+		event.Latitude, event.Longitude = &i.Latitude.Float64, &i.Longitude.Float64
+	}
+	if eventType == eventCreated && json.Valid(i.Details) {
+		event.Details = i.Details
+	}
+	a.hub.broadcast(event)
+	a.sendSyslog(cfg, event, i)
+	if cfg.Events == nil {
+		return
+	}
+
+	url := os.ExpandEnv(cfg.Events.URL)
+	if a.events == nil || a.events.url != url {
+		if a.events != nil {
+			a.events.close()
+		}
+		a.events = &eventPublisher{url: url}
+	}
+	prefix := cfg.Events.Prefix
+	if prefix == "" {
+		prefix = "unity-alerts"
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Warning: failed to encode %s event: %v", eventType, err)
+		return
+	}
+	if err := a.events.publish(prefix+"."+eventType, []byte(strconv.Itoa(i.ID)), body); err != nil {
+		log.Printf("Warning: failed to publish %s event for incident %d: %v", eventType, i.ID, err)
+	}
+}
+
+// publishCreated publishes an incident's created event the first time it is picked up. An
+// incident that waits, held back or over the per-run limit, is picked up again every run.
+func (a *app) publishCreated(cfg *Config, i incident.Incident) {
+	if i.IsTest || i.Backfill {
+		return
+	}
+	claimed, err := postgres.ClaimEvent(a.db, i.ID, eventCreated)
+	if err != nil {
+		// Published anyway: a duplicate is better than a missing event.
+		log.Printf("Warning: %v", err)
+	} else if !claimed {
+		return
+	}
+	a.publishEvent(cfg, eventCreated, i, incidentEvent{})
+}
+
+// eventHub fans lifecycle events out to subscribers in this process, such as API streams.
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[chan incidentEvent]bool
+}
+
+// subscribe returns a channel of the events published from now on, and a function that
+// unsubscribes and closes it. A subscriber that falls more than a few events behind misses
+// the ones that don't fit, rather than holding up the pipeline.
+func (h *eventHub) subscribe() (<-chan incidentEvent, func()) {
+	ch := make(chan incidentEvent, 64)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs == nil {
+		h.subs = make(map[chan incidentEvent]bool)
+	}
+	h.subs[ch] = true
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if h.subs[ch] {
+			delete(h.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (h *eventHub) broadcast(event incidentEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
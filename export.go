@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/internal/parquet"
+)
+
+// exportColumns are the incident columns every export starts with. The flattened details
+// follow, as raw_<field> for the feed's record, weather_<field> for the stored forecast and
+// details_<field> for anything else.
+var exportColumns = []parquet.Column{
+	{Name: "id", Type: parquet.Int64},
+	{Name: "source", Type: parquet.String},
+	{Name: "source_id", Type: parquet.String},
+	{Name: "event_type", Type: parquet.String},
+	{Name: "address", Type: parquet.String},
+	{Name: "road", Type: parquet.String},
+	{Name: "latitude", Type: parquet.Double},
+	{Name: "longitude", Type: parquet.Double},
+	{Name: "timestamp", Type: parquet.Timestamp},
+	{Name: "status", Type: parquet.String},
+	{Name: "is_test", Type: parquet.Bool},
+}
+
+// export dumps the incidents reported in a date range, with their details flattened into
+// columns, for analysis in DuckDB or pandas.
+//
+//	unity-alerts export --from 2024-01-01 --to 2024-02-01 --format parquet --dest s3://bucket/incidents/2024-01.parquet
+//	unity-alerts export --from 2024-01-01 --format csv --dest incidents.csv
+func (a *app) export(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	from := fs.String("from", "", "first day to export, YYYY-MM-DD")
+	to := fs.String("to", "", "day after the last one to export, YYYY-MM-DD (default: today)")
+	format := fs.String("format", "csv", "csv or parquet")
+	dest := fs.String("dest", "-", "file path, s3://bucket/key, or - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "csv" && *format != "parquet" {
+		return fmt.Errorf("--format must be csv or parquet, not %q", *format)
+	}
+	cfg := a.currentConfig()
+	loc := cfg.Location(RouteConfig{})
+	start, err := time.ParseInLocation(time.DateOnly, *from, loc)
+	if err != nil {
+		return fmt.Errorf("export requires --from as YYYY-MM-DD")
+	}
+	now := time.Now().In(loc)
+	end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if *to != "" {
+		if end, err = time.ParseInLocation(time.DateOnly, *to, loc); err != nil {
+			return fmt.Errorf("invalid --to %q", *to)
+		}
+	}
+
+	incidents, statuses, err := queryIncidents(cfg, a.db, "WHERE {timestamp} >= $1 AND {timestamp} < $2 ORDER BY {timestamp}, {id}", start, end)
+	if err != nil {
+		return err
+	}
+	columns, rows := exportRows(incidents, statuses)
+	if err := exportTo(*dest, *format, columns, rows); err != nil {
+		return err
+	}
+	if *dest != "-" {
+		fmt.Fprintf(os.Stderr, "Exported %d incidents to %s.\n", len(rows), *dest)
+	}
+	return nil
+}
+
+// exportTo writes rows in format to dest: a file path, an s3://bucket/key URL, or - for
+// stdout. S3 uploads are staged in a temporary file rather than in memory.
+func exportTo(dest, format string, columns []parquet.Column, rows [][]interface{}) error {
+	if dest == "-" {
+		return writeExport(os.Stdout, format, columns, rows)
+	}
+	var f *os.File
+	var err error
+	if strings.HasPrefix(dest, "s3://") {
+		if f, err = os.CreateTemp("", "unity-alerts-export-*"); err == nil {
+			defer os.Remove(f.Name())
+		}
+	} else {
+		f, err = os.Create(dest)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer f.Close()
+	if err := writeExport(f, format, columns, rows); err != nil {
+		return err
+	}
+	if strings.HasPrefix(dest, "s3://") {
+		return uploadS3(dest, f)
+	}
+	return f.Close()
+}
+
+// writeExport encodes rows as csv or parquet.
+func writeExport(w io.Writer, format string, columns []parquet.Column, rows [][]interface{}) error {
+	bw := bufio.NewWriter(w)
+	var err error
+	if format == "parquet" {
+		err = parquet.Write(bw, columns, rows)
+	} else {
+		err = writeExportCSV(bw, columns, rows)
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode export: %w", err)
+	}
+	return nil
+}
+
+// exportRows flattens incidents into rows. Detail columns are the union of every incident's
+// fields, sorted by name, with values kept as text: strings as is, anything else as JSON.
+func exportRows(incidents []incident.Incident, statuses []string) ([]parquet.Column, [][]interface{}) {
+	flattened := make([]map[string]string, len(incidents))
+	names := make(map[string]bool)
+	for n, i := range incidents {
+		flattened[n] = flattenDetails(i.Details)
+		for name := range flattened[n] {
+			names[name] = true
+		}
+	}
+	var detailNames []string
+	for name := range names {
+		detailNames = append(detailNames, name)
+	}
+	sort.Strings(detailNames)
+
+	columns := append([]parquet.Column(nil), exportColumns...)
+	for _, name := range detailNames {
+		columns = append(columns, parquet.Column{Name: name, Type: parquet.String})
+	}
+
+	rows := make([][]interface{}, len(incidents))
+	for n, i := range incidents {
+		row := []interface{}{int64(i.ID), i.Source, i.SourceID, i.EventType, i.Address, incident.RoadName(i), nil, nil, i.Timestamp, statuses[n], i.IsTest}
+		if i.Latitude.Valid && i.Longitude.Valid {
+			row[6], row[7] = i.Latitude.Float64, i.Longitude.Float64
+		}
+		for _, name := range detailNames {
+			if v, ok := flattened[n][name]; ok {
+				row = append(row, v)
+			} else {
+				row = append(row, nil)
+			}
+		}
+		rows[n] = row
+	}
+	return columns, rows
+}
+
+// flattenDetails turns the details JSON into prefixed columns, one level deep.
+func flattenDetails(details json.RawMessage) map[string]string {
+	var top map[string]json.RawMessage
+	json.Unmarshal(details, &top)
+	values := make(map[string]string)
+	for key, raw := range top {
+		prefix := ""
+		switch key {
+		case "raw_incident":
+			prefix = "raw_"
+		case "weather":
+			prefix = "weather_"
+		}
+		var nested map[string]json.RawMessage
+		if prefix != "" && json.Unmarshal(raw, &nested) == nil {
+			for field, value := range nested {
+				if v, ok := exportValue(value); ok {
+					values[prefix+field] = v
+				}
+			}
+			continue
+		}
+		if v, ok := exportValue(raw); ok {
+			values["details_"+key] = v
+		}
+	}
+	return values
+}
+
+// exportValue renders a JSON value as text, reporting false for null.
+func exportValue(raw json.RawMessage) (string, bool) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", false
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s, true
+	}
+	return string(raw), true
+}
+
+// writeExportCSV writes the rows with a header, leaving nulls empty.
+func writeExportCSV(w io.Writer, columns []parquet.Column, rows [][]interface{}) error {
+	cw := csv.NewWriter(w)
+	header := make([]string, len(columns))
+	for n, col := range columns {
+		header[n] = col.Name
+	}
+	cw.Write(header)
+	record := make([]string, len(columns))
+	for _, row := range rows {
+		for n, v := range row {
+			switch v := v.(type) {
+			case nil:
+				record[n] = ""
+			case time.Time:
+				record[n] = v.UTC().Format(time.RFC3339)
+			case float64:
+				record[n] = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				record[n] = fmt.Sprint(v)
+			}
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// uploadS3 stores a file at an s3://bucket/key URL, signing the request with the AWS_*
+// credentials. S3_ENDPOINT points it at an S3-compatible store such as MinIO, addressed
+// path-style.
+func uploadS3(dest string, f *os.File) error {
+	u, err := url.Parse(dest)
+	if err != nil || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return fmt.Errorf("invalid S3 destination %q; want s3://bucket/key", dest)
+	}
+	creds := awsCredentialsFromEnv()
+	if !creds.complete() {
+		return fmt.Errorf("S3 export requires AWS_REGION, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	var segments []string
+	for _, s := range strings.Split(strings.Trim(u.Path, "/"), "/") {
+		segments = append(segments, url.PathEscape(s))
+	}
+	path := "/" + strings.Join(segments, "/")
+	endpoint := fmt.Sprintf("https://%s.s3.%s.amazonaws.com", u.Host, creds.region)
+	if custom := os.Getenv("S3_ENDPOINT"); custom != "" {
+		endpoint = strings.TrimRight(custom, "/")
+		path = "/" + url.PathEscape(u.Host) + path
+	}
+
+	hash := sha256.New()
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read export file: %w", err)
+	}
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return fmt.Errorf("failed to read export file: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read export file: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPut, endpoint+path, io.NopCloser(f))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	payloadHash := hex.EncodeToString(hash.Sum(nil))
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	creds.sign(req, "s3", payloadHash, time.Now())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload to S3: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("S3 returned non-200 status: %s. Body: %s", resp.Status, string(body))
+	}
+	return nil
+}
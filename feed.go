@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// feedMaxMessage caps a filter document.
+	feedMaxMessage = 64 << 10
+	// feedWriteTimeout bounds each write to a feed client.
+	feedWriteTimeout = 10 * time.Second
+)
+
+// feedUpgrader accepts any origin: the feed is authorized by the API token, never by cookies,
+// so a page on another origin can do nothing through it that its own script couldn't.
+var feedUpgrader = websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+
+// feedFilter is the filter document a WebSocket feed client sends to choose its events, e.g.
+// {"bbox": {"south": 35.7, "west": -78.8, "north": 35.9, "east": -78.5}, "types": ["created"]}.
+// Sending another replaces it, so a map client can follow the view as it pans and zooms.
+type feedFilter struct {
+	BBox    *BoundingBox `json:"bbox,omitempty"`    // Only events for incidents located inside it.
+	Sources []string     `json:"sources,omitempty"` // Default every source.
+	Types   []string     `json:"types,omitempty"`   // Default created, updated and cleared.
+}
+
+func (f *feedFilter) validate() error {
+	if err := f.BBox.validate(); err != nil {
+		return err
+	}
+	for _, t := range f.Types {
+		switch t {
+		case eventCreated, eventUpdated, eventCleared, eventDelivered:
+		default:
+			return fmt.Errorf("unknown event type %q", t)
+		}
+	}
+	if len(f.Types) == 0 {
+		f.Types = []string{eventCreated, eventUpdated, eventCleared}
+	}
+	return nil
+}
+
+func (f *feedFilter) matches(event incidentEvent) bool {
+	if !contains(f.Types, event.Type) || (len(f.Sources) > 0 && !contains(f.Sources, event.Source)) {
+		return false
+	}
+	if f.BBox != nil {
+		return event.Latitude != nil && event.Longitude != nil && f.BBox.plausible(*event.Latitude, *event.Longitude)
+	}
+	return true
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// feedReply acknowledges a filter document, or says what was wrong with it.
+type feedReply struct {
+	Type   string      `json:"type"` // "subscribed" or "error".
+	Filter *feedFilter `json:"filter,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// handleFeed serves the WebSocket feed at /api/ws. Nothing is sent until the client sends a
+// filter document; from then on each matching lifecycle event is sent as the same JSON
+// published to NATS or Kafka, whose type is never "subscribed" or "error".
+func (a *app) handleFeed(w http.ResponseWriter, r *http.Request) {
+	ws, err := feedUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // The upgrader has replied.
+	}
+	conn := &feedConn{Conn: ws}
+	defer conn.close()
+	conn.SetReadLimit(feedMaxMessage)
+
+	events, unsubscribe := a.hub.subscribe()
+	defer unsubscribe()
+
+	filters := make(chan *feedFilter, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var f feedFilter
+			if err := json.Unmarshal(message, &f); err != nil {
+				err = fmt.Errorf("invalid filter document: %w", err)
+				conn.writeJSON(feedReply{Type: "error", Error: err.Error()})
+				continue
+			}
+			if err := f.validate(); err != nil {
+				conn.writeJSON(feedReply{Type: "error", Error: err.Error()})
+				continue
+			}
+			conn.writeJSON(feedReply{Type: "subscribed", Filter: &f})
+			select {
+			case <-filters: // Drop a filter the sender hasn't picked up yet.
+			default:
+			}
+			filters <- &f
+		}
+	}()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	var filter *feedFilter
+	for {
+		select {
+		case <-done:
+			return
+		case filter = <-filters:
+		case <-keepAlive.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(feedWriteTimeout)); err != nil {
+				return
+			}
+		case event := <-events:
+			if filter == nil || !filter.matches(event) {
+				continue
+			}
+			if err := conn.writeJSON(event); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// feedConn is a feed client's connection. Replies to filter documents and events are written
+// from different goroutines, and the connection allows only one writer at a time.
+type feedConn struct {
+	*websocket.Conn
+	mu sync.Mutex
+}
+
+func (c *feedConn) writeJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Warning: failed to encode WebSocket message: %v", err)
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.SetWriteDeadline(time.Now().Add(feedWriteTimeout))
+	return c.WriteMessage(websocket.TextMessage, data)
+}
+
+// close sends a normal close and disconnects.
+func (c *feedConn) close() {
+	c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	c.Close()
+}
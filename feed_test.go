@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestFeedFilterMatches(t *testing.T) {
+	lat, lon := 35.78, -78.64
+	located := incidentEvent{Type: eventCreated, Source: "NCDOT", Latitude: &lat, Longitude: &lon}
+	unlocated := incidentEvent{Type: eventCreated, Source: "NCDOT"}
+	raleigh := &BoundingBox{South: 35.7, West: -78.8, North: 35.9, East: -78.5}
+	tests := []struct {
+		name   string
+		filter feedFilter
+		event  incidentEvent
+		want   bool
+	}{
+		{"default types", feedFilter{}, located, true},
+		{"delivered not by default", feedFilter{}, incidentEvent{Type: eventDelivered}, false},
+		{"other source", feedFilter{Sources: []string{"Raleigh"}}, located, false},
+		{"inside the box", feedFilter{BBox: raleigh}, located, true},
+		{"outside the box", feedFilter{BBox: &BoundingBox{South: 36, West: -80, North: 36.1, East: -79.9}}, located, false},
+		{"unlocated with a box", feedFilter{BBox: raleigh}, unlocated, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.filter.validate(); err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.filter.matches(tt.event); got != tt.want {
+				t.Errorf("matches = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+// dialFeed serves the feed for a and connects a client to it.
+func dialFeed(t *testing.T, a *app) *websocket.Conn {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(a.handleFeed))
+	t.Cleanup(server.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	return conn
+}
+
+func TestFeed(t *testing.T) {
+	a := &app{}
+	conn := dialFeed(t, a)
+
+	var reply feedReply
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"types": ["exploded"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.ReadJSON(&reply); err != nil || reply.Type != "error" {
+		t.Fatalf("reply to an invalid filter = %+v, %v, want an error", reply, err)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"sources": ["NCDOT"], "types": ["cleared"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.ReadJSON(&reply); err != nil || reply.Type != "subscribed" || reply.Filter == nil || len(reply.Filter.Types) != 1 {
+		t.Fatalf("reply to a filter = %+v, %v, want subscribed", reply, err)
+	}
+
+	// The filter reaches the sender asynchronously, so broadcast until an event gets through.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for ctx.Err() == nil {
+			a.hub.broadcast(incidentEvent{Type: eventCreated, IncidentID: 1, Source: "NCDOT"})
+			a.hub.broadcast(incidentEvent{Type: eventCleared, IncidentID: 2, Source: "Raleigh"})
+			a.hub.broadcast(incidentEvent{Type: eventCleared, IncidentID: 3, Source: "NCDOT"})
+			time.Sleep(20 * time.Millisecond)
+		}
+	}()
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event incidentEvent
+	if err := json.Unmarshal(message, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.IncidentID != 3 || event.Type != eventCleared {
+		t.Errorf("received %s", message)
+	}
+}
+
+func TestFeedMessageTooLarge(t *testing.T) {
+	conn := dialFeed(t, &app{})
+	filter := `{"sources": ["` + strings.Repeat("x", feedMaxMessage) + `"]}`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(filter)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Errorf("ReadMessage = %v, want a close for a message too big", err)
+	}
+}
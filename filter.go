@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mtickle/unity-alerts/incident"
+)
+
+// FilterConfig drops unwanted incident categories before any enrichment. Patterns are
+// case-insensitive regular expressions matched against the event type and crime description.
+type FilterConfig struct {
+	Include []string `json:"include,omitempty"` // When set, only matching incidents are kept.
+	Exclude []string `json:"exclude,omitempty"` // Matching incidents are dropped, even if included.
+
+	// Rules match the raw details JSON and may suppress, boost or tag an incident.
+	Rules []KeywordRule `json:"rules,omitempty"`
+
+	include, exclude []*regexp.Regexp
+}
+
+// KeywordRule acts on incidents whose details match Pattern, a case-insensitive regular
+// expression, and that are inside Near, e.g. boosting anything mentioning "I-540",
+// suppressing "ALARM" calls or tagging incidents near a school "school-zone". A rule needs a
+// pattern, an area or both.
+type KeywordRule struct {
+	Pattern  string   `json:"pattern,omitempty"`
+	Near     *Area    `json:"near,omitempty"`
+	Suppress bool     `json:"suppress,omitempty"`
+	Boost    int      `json:"boost,omitempty"` // Added to the incident's priority.
+	Tags     []string `json:"tags,omitempty"`  // Stored with the incident; routes can select by them.
+
+	re *regexp.Regexp
+}
+
+// compile parses the patterns; Apply uses the compiled form.
+func (f *FilterConfig) compile() error {
+	var err error
+	if f.include, err = compilePatterns("filter.include", f.Include); err != nil {
+		return err
+	}
+	if f.exclude, err = compilePatterns("filter.exclude", f.Exclude); err != nil {
+		return err
+	}
+	for idx := range f.Rules {
+		r := &f.Rules[idx]
+		if r.Pattern == "" && r.Near == nil {
+			return fmt.Errorf("filter.rules[%d] needs a pattern or near", idx)
+		}
+		if r.re, err = regexp.Compile("(?i)" + r.Pattern); err != nil {
+			return fmt.Errorf("filter.rules[%d]: invalid pattern %q: %w", idx, r.Pattern, err)
+		}
+		if err := r.Near.validate(); err != nil {
+			return fmt.Errorf("filter.rules[%d]: %w", idx, err)
+		}
+		for n, tag := range r.Tags {
+			r.Tags[n] = normalizeTag(tag)
+		}
+	}
+	return nil
+}
+
+// normalizeTag lowercases a tag so that "School-Zone" and "school-zone" are one tag.
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// hasTag reports whether the incident carries one of the tags.
+func hasTag(i incident.Incident, tags []string) bool {
+	for _, want := range tags {
+		for _, tag := range i.Tags {
+			if normalizeTag(want) == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func compilePatterns(field string, patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, p := range patterns {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid pattern %q: %w", field, p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// Apply reports whether an incident passes the include and exclude lists and no rule
+// suppresses it, and adds the priority and tags of every matching rule.
+func (f FilterConfig) Apply(i *incident.Incident) bool {
+	categories := incident.Categories(*i)
+	if len(f.include) > 0 && !matchesAny(f.include, categories) {
+		return false
+	}
+	if matchesAny(f.exclude, categories) {
+		return false
+	}
+	for _, r := range f.Rules {
+		if r.re == nil || !r.re.Match(i.Details) || !r.Near.Contains(*i) {
+			continue
+		}
+		if r.Suppress {
+			return false
+		}
+		i.Priority += r.Boost
+		for _, tag := range r.Tags {
+			if !hasTag(*i, []string{tag}) {
+				i.Tags = append(i.Tags, tag)
+			}
+		}
+	}
+	return true
+}
+
+func matchesAny(patterns []*regexp.Regexp, values []string) bool {
+	for _, re := range patterns {
+		for _, v := range values {
+			if re.MatchString(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
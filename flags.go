@@ -0,0 +1,128 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mtickle/unity-alerts/enrich"
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/sink/discord"
+)
+
+// FeatureFlags toggles optional enrichment stages by name.
+type FeatureFlags map[string]bool
+
+// builtinSourceFeatures are per-source defaults that global settings don't override. Police
+// incidents have never carried traffic camera frames.
+var builtinSourceFeatures = map[string]FeatureFlags{
+	incident.SourceArcGISPolice: {discord.FeatureCameras: false},
+}
+
+// builtinFeatures are off unless configured, because they cost API quota or post extra messages.
+var builtinFeatures = FeatureFlags{discord.FeatureStreetView: false, discord.FeatureRunningLong: false}
+
+// minimalFeatures are off on routes with verbosity "minimal" unless the route's features
+// turn them back on.
+var minimalFeatures = FeatureFlags{discord.FeatureCameras: false, discord.FeatureWeather: false, discord.FeatureStreetView: false, discord.FeatureNavigate: false}
+
+// features are the route's own feature flags, over the defaults of its verbosity preset.
+func (r RouteConfig) features() FeatureFlags {
+	if r.Verbosity != discord.VerbosityMinimal {
+		return r.Features
+	}
+	flags := make(FeatureFlags, len(minimalFeatures)+len(r.Features))
+	for name, enabled := range minimalFeatures {
+		flags[name] = enabled
+	}
+	for name, enabled := range r.Features {
+		flags[name] = enabled
+	}
+	return flags
+}
+
+// loadFeatureFlags reads overrides from the feature_flags table, keyed by scope
+// ("global", "source:<name>" or "route:<name>").
+func loadFeatureFlags(db *sql.DB) (map[string]FeatureFlags, error) {
+	rows, err := db.Query("SELECT scope, name, enabled FROM feature_flags")
+	if err != nil {
+		return nil, fmt.Errorf("error querying feature flags: %w", err)
+	}
+	defer rows.Close()
+
+	flags := make(map[string]FeatureFlags)
+	for rows.Next() {
+		var scope, name string
+		var enabled bool
+		if err := rows.Scan(&scope, &name, &enabled); err != nil {
+			return nil, fmt.Errorf("error scanning feature flag row: %w", err)
+		}
+		if flags[scope] == nil {
+			flags[scope] = make(FeatureFlags)
+		}
+		flags[scope][name] = enabled
+	}
+	return flags, rows.Err()
+}
+
+// FeatureEnabled resolves a flag for an incident source on a route. The most specific setting
+// wins: route, then source, then the source's builtin default, then global, and at each
+// configured level the feature_flags table overrides the config file. A global setting thus
+// doesn't turn cameras back on for police incidents; only the source's own setting does.
+// Unset flags fall back to FEATURE_<NAME> in the environment, then to enabled (or disabled,
+// for builtinFeatures).
+func (c *Config) FeatureEnabled(feature, source string, route RouteConfig) bool {
+	levels := []struct {
+		scope string // "" for the builtin defaults, which the table doesn't override.
+		flags FeatureFlags
+	}{
+		{"route:" + route.Name, route.features()},
+		{"source:" + source, c.SourceFeatures[source]},
+		{"", builtinSourceFeatures[source]},
+		{"global", c.Features},
+	}
+	for _, level := range levels {
+		if enabled, ok := c.dbFeatures[level.scope][feature]; ok && level.scope != "" {
+			return enabled
+		}
+		if enabled, ok := level.flags[feature]; ok {
+			return enabled
+		}
+	}
+	if v := os.Getenv("FEATURE_" + strings.ToUpper(feature)); v != "" {
+		return v != "0" && v != "false"
+	}
+	if enabled, ok := builtinFeatures[feature]; ok {
+		return enabled
+	}
+	return true
+}
+
+// defaultStreetViewDailyLimit applies when street_view_daily_limit is not set.
+const defaultStreetViewDailyLimit = 100
+
+// enrichOptions requests the lookups that at least one of the incident's routes will show.
+// Street View imagery is only used on police alerts.
+func (c *Config) enrichOptions(i incident.Incident, routes []RouteConfig) enrich.Options {
+	opts := enrich.Options{
+		Location:        c.Location(RouteConfig{}),
+		StreetViewLimit: c.StreetViewDailyLimit,
+		MapsAPIKey:      os.Getenv("GOOGLE_MAPS_API_KEY"),
+	}
+	if opts.StreetViewLimit == 0 {
+		opts.StreetViewLimit = defaultStreetViewDailyLimit
+	}
+	for _, route := range routes {
+		opts.Cameras = opts.Cameras || c.FeatureEnabled(discord.FeatureCameras, i.Source, route)
+		opts.StreetView = opts.StreetView || (i.Source == incident.SourceArcGISPolice && c.FeatureEnabled(discord.FeatureStreetView, i.Source, route))
+	}
+	return opts
+}
+
+// withFeatureOverrides returns a copy of the config carrying the database flag overrides for one run.
+func (c *Config) withFeatureOverrides(dbFeatures map[string]FeatureFlags) *Config {
+	cfg := *c
+	cfg.dbFeatures = dbFeatures
+	return &cfg
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/mtickle/unity-alerts/incident"
+	unityalertsv1 "github.com/mtickle/unity-alerts/proto/unityalerts/v1"
+)
+
+//go:generate protoc -I proto --go_out=proto --go_opt=paths=source_relative --go-grpc_out=proto --go-grpc_opt=paths=source_relative unityalerts/v1/incidents.proto
+
+// grpcServer implements the service in proto/unityalerts/v1/incidents.proto.
+type grpcServer struct {
+	unityalertsv1.UnimplementedIncidentsServer
+	a *app
+}
+
+// newGRPCServer returns a gRPC server for the API, checking each call's token before its
+// handler runs.
+func newGRPCServer(a *app, opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := authorizeGRPC(ctx, info.FullMethod); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := authorizeGRPC(ss.Context(), info.FullMethod); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	)
+	s := grpc.NewServer(opts...)
+	unityalertsv1.RegisterIncidentsServer(s, &grpcServer{a: a})
+	return s
+}
+
+// startGRPCServer serves the gRPC API on GRPC_ADDR until ctx is cancelled, over TLS with the
+// certificate in GRPC_TLS_CERT and GRPC_TLS_KEY when they are set, and otherwise in plaintext
+// for a service mesh or ingress to terminate TLS. It does nothing when GRPC_ADDR is unset.
+func startGRPCServer(ctx context.Context, a *app) error {
+	addr := os.Getenv("GRPC_ADDR")
+	if addr == "" {
+		return nil
+	}
+	var opts []grpc.ServerOption
+	if certFile, keyFile := os.Getenv("GRPC_TLS_CERT"), os.Getenv("GRPC_TLS_KEY"); certFile != "" || keyFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load GRPC_TLS_CERT and GRPC_TLS_KEY: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on GRPC_ADDR: %w", err)
+	}
+	s := newGRPCServer(a, opts...)
+
+	go func() {
+		<-ctx.Done()
+		// Streams only end when their clients cancel, so stop waiting for them after a while.
+		stopped := make(chan struct{})
+		go func() {
+			s.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			s.Stop()
+		}
+	}()
+	go func() {
+		log.Printf("Listening for gRPC requests on %s", addr)
+		if err := s.Serve(lis); err != nil {
+			log.Printf("Error serving gRPC: %v", err)
+		}
+	}()
+	return nil
+}
+
+// authorizeGRPC checks the "authorization" metadata: INGEST_TOKEN for SubmitIncident, which
+// like /ingest is never open, API_TOKEN for Resend, which sends alerts and so is unavailable
+// without one, and API_TOKEN when it is set for the read-only methods.
+func authorizeGRPC(ctx context.Context, fullMethod string) error {
+	method := path.Base(fullMethod)
+	token := os.Getenv("API_TOKEN")
+	switch method {
+	case "SubmitIncident":
+		if token = os.Getenv("INGEST_TOKEN"); token == "" {
+			return status.Errorf(codes.PermissionDenied, "SubmitIncident requires INGEST_TOKEN to be set")
+		}
+	case "Resend":
+		if token == "" {
+			return status.Errorf(codes.PermissionDenied, "Resend requires API_TOKEN to be set")
+		}
+	}
+	if token == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if auth := md.Get("authorization"); len(auth) != 1 || subtle.ConstantTimeCompare([]byte(auth[0]), []byte("Bearer "+token)) != 1 {
+		return status.Errorf(codes.Unauthenticated, "unauthorized")
+	}
+	return nil
+}
+
+// incidentMessage converts an incident to a UnifiedIncident.
+func incidentMessage(i incident.Incident, status string, details json.RawMessage) *unityalertsv1.UnifiedIncident {
+	m := &unityalertsv1.UnifiedIncident{
+		Id:          int64(i.ID),
+		Source:      i.Source,
+		SourceId:    i.SourceID,
+		EventType:   i.EventType,
+		Address:     i.Address,
+		Status:      status,
+		DetailsJson: string(details),
+		Tags:        i.Tags,
+	}
+	if i.Latitude.Valid && i.Longitude.Valid {
+		m.Latitude, m.Longitude = &i.Latitude.Float64, &i.Longitude.Float64
+	}
+	if !i.Timestamp.IsZero() {
+		m.Timestamp = timestamppb.New(i.Timestamp)
+	}
+	return m
+}
+
+func (s *grpcServer) ListIncidents(ctx context.Context, req *unityalertsv1.ListIncidentsRequest) (*unityalertsv1.ListIncidentsResponse, error) {
+	hours, limit := int(req.Hours), int(req.Limit)
+	if hours <= 0 || hours > 168 {
+		hours = 24
+	}
+	if limit <= 0 || limit > 500 {
+		limit = 500
+	}
+
+	a := s.a
+	cfg := a.currentConfig()
+	clause := "WHERE {timestamp} >= $1 AND ($2::text = '' OR {source} = $2) ORDER BY {timestamp} DESC, {id} DESC LIMIT $3"
+	args := []interface{}{time.Now().Add(-time.Duration(hours) * time.Hour), req.Source, limit}
+	var incidents []incident.Incident
+	var statuses []string
+	var err error
+	if req.Route != "" {
+		route, ok := cfg.Route(req.Route)
+		if !ok {
+			return nil, status.Errorf(codes.NotFound, "no route named %q", req.Route)
+		}
+		incidents, statuses, err = a.routeIncidents(cfg, route, clause, args...)
+	} else {
+		incidents, statuses, err = queryIncidents(cfg, a.db, clause, args...)
+	}
+	if err != nil {
+		log.Printf("Error serving ListIncidents: %v", err)
+		return nil, status.Errorf(codes.Internal, "internal error")
+	}
+
+	resp := &unityalertsv1.ListIncidentsResponse{}
+	for n, i := range incidents {
+		resp.Incidents = append(resp.Incidents, incidentMessage(i, statuses[n], i.Details))
+	}
+	return resp, nil
+}
+
+// StreamIncidents sends the events published in this process.
+func (s *grpcServer) StreamIncidents(req *unityalertsv1.StreamIncidentsRequest, stream unityalertsv1.Incidents_StreamIncidentsServer) error {
+	types, sources := make(map[string]bool), make(map[string]bool)
+	for _, t := range req.Types {
+		types[t] = true
+	}
+	for _, source := range req.Sources {
+		sources[source] = true
+	}
+
+	events, unsubscribe := s.a.hub.subscribe()
+	defer unsubscribe()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event := <-events:
+			if (len(types) > 0 && !types[event.Type]) || (len(sources) > 0 && !sources[event.Source]) {
+				continue
+			}
+			if err := stream.Send(eventMessage(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// eventMessage converts an event to an IncidentEvent.
+func eventMessage(event incidentEvent) *unityalertsv1.IncidentEvent {
+	i := incident.Incident{
+		ID:        event.IncidentID,
+		Source:    event.Source,
+		SourceID:  event.SourceID,
+		EventType: event.EventType,
+		Address:   event.Address,
+		Timestamp: event.Timestamp,
+		Tags:      event.Tags,
+	}
+	if event.Latitude != nil && event.Longitude != nil {
+		i.Latitude = sql.NullFloat64{Float64: *event.Latitude, Valid: true}
+		i.Longitude = sql.NullFloat64{Float64: *event.Longitude, Valid: true}
+	}
+	status := "active"
+	if event.Type == eventCleared {
+		status = "cleared"
+	}
+	m := &unityalertsv1.IncidentEvent{
+		Type:      event.Type,
+		Incident:  incidentMessage(i, status, event.Details),
+		Route:     event.Route,
+		MessageId: event.MessageID,
+		Reason:    event.Reason,
+	}
+	if !event.At.IsZero() {
+		m.At = timestamppb.New(event.At)
+	}
+	return m
+}
+
+func (s *grpcServer) SubmitIncident(ctx context.Context, req *unityalertsv1.SubmitIncidentRequest) (*unityalertsv1.SubmitIncidentResponse, error) {
+	i := ingestedIncident{
+		Source:    req.Source,
+		SourceID:  req.SourceId,
+		EventType: req.EventType,
+		Address:   req.Address,
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		Status:    req.Status,
+	}
+	if req.Timestamp != nil {
+		if err := req.Timestamp.CheckValid(); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+		}
+		t := req.Timestamp.AsTime()
+		i.Timestamp = &t
+	}
+	if req.DetailsJson != "" {
+		if i.Details = json.RawMessage(req.DetailsJson); !json.Valid(i.Details) {
+			return nil, status.Errorf(codes.InvalidArgument, "details_json is not valid JSON")
+		}
+	}
+	if err := i.validate(); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+
+	result, err := s.a.storeIngested(s.a.currentConfig(), i)
+	if err != nil {
+		log.Printf("Error ingesting %s incident %s: %v", i.Source, i.SourceID, err)
+		return nil, status.Errorf(codes.Internal, "internal error")
+	}
+	return &unityalertsv1.SubmitIncidentResponse{Id: int64(result.ID), Result: result.Result}, nil
+}
+
+// Resend waits for any cycle in progress so that the incident isn't sent twice at once.
+func (s *grpcServer) Resend(ctx context.Context, req *unityalertsv1.ResendRequest) (*unityalertsv1.ResendResponse, error) {
+	if req.Id <= 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "id is required")
+	}
+
+	a := s.a
+	a.cycleMu.Lock()
+	defer a.cycleMu.Unlock()
+	routes, err := a.resend(a.currentConfig(), int(req.Id), req.Route, req.Force)
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "%v", err)
+	}
+	log.Printf("Resent incident %d to %d route(s) via gRPC.", req.Id, routes)
+	return &unityalertsv1.ResendResponse{Routes: int32(routes)}, nil
+}
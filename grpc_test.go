@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	unityalertsv1 "github.com/mtickle/unity-alerts/proto/unityalerts/v1"
+)
+
+// dialGRPC serves the API for a in memory and returns a client for it.
+func dialGRPC(t *testing.T, a *app) unityalertsv1.IncidentsClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	s := newGRPCServer(a)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return unityalertsv1.NewIncidentsClient(conn)
+}
+
+func TestGRPCAuthorization(t *testing.T) {
+	client := dialGRPC(t, &app{})
+	// Both requests are invalid, so a call that gets past authorization fails with
+	// InvalidArgument without reaching the database.
+	submit := func(ctx context.Context) error {
+		_, err := client.SubmitIncident(ctx, &unityalertsv1.SubmitIncidentRequest{Source: "nowhere"})
+		return err
+	}
+	resend := func(ctx context.Context) error {
+		_, err := client.Resend(ctx, &unityalertsv1.ResendRequest{})
+		return err
+	}
+	tests := []struct {
+		name               string
+		apiToken, ingToken string
+		call               func(context.Context) error
+		authorization      string
+		want               codes.Code
+	}{
+		{"submit without INGEST_TOKEN", "api", "", submit, "Bearer api", codes.PermissionDenied},
+		{"submit with INGEST_TOKEN", "api", "ingest", submit, "Bearer ingest", codes.InvalidArgument},
+		{"submit with API_TOKEN", "api", "ingest", submit, "Bearer api", codes.Unauthenticated},
+		{"resend without API_TOKEN", "", "ingest", resend, "", codes.PermissionDenied},
+		{"resend with API_TOKEN", "api", "", resend, "Bearer api", codes.InvalidArgument},
+		{"resend without a token", "api", "", resend, "", codes.Unauthenticated},
+		{"resend with the wrong token", "api", "", resend, "Bearer nope", codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("API_TOKEN", tt.apiToken)
+			t.Setenv("INGEST_TOKEN", tt.ingToken)
+			ctx := context.Background()
+			if tt.authorization != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", tt.authorization)
+			}
+			if got := status.Code(tt.call(ctx)); got != tt.want {
+				t.Errorf("code = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGRPCSubmitIncidentInvalid(t *testing.T) {
+	t.Setenv("INGEST_TOKEN", "ingest")
+	client := dialGRPC(t, &app{})
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer ingest")
+	lat := 35.78
+	tests := []struct {
+		name string
+		req  *unityalertsv1.SubmitIncidentRequest
+	}{
+		{"details not JSON", &unityalertsv1.SubmitIncidentRequest{Source: "NCDOT", SourceId: "1", EventType: "Crash", DetailsJson: "{"}},
+		{"details not an object", &unityalertsv1.SubmitIncidentRequest{Source: "NCDOT", SourceId: "1", EventType: "Crash", DetailsJson: "[]"}},
+		{"latitude without longitude", &unityalertsv1.SubmitIncidentRequest{Source: "NCDOT", SourceId: "1", EventType: "Crash", Latitude: &lat}},
+		{"no source ID", &unityalertsv1.SubmitIncidentRequest{Source: "NCDOT", EventType: "Crash"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.SubmitIncident(ctx, tt.req); status.Code(err) != codes.InvalidArgument {
+				t.Errorf("SubmitIncident = %v, want InvalidArgument", err)
+			}
+		})
+	}
+}
+
+func TestGRPCStreamIncidents(t *testing.T) {
+	t.Setenv("API_TOKEN", "")
+	a := &app{}
+	client := dialGRPC(t, a)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := client.StreamIncidents(ctx, &unityalertsv1.StreamIncidentsRequest{Types: []string{eventCleared}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lat, lon := 35.78, -78.64
+	at := time.Date(2024, 3, 10, 6, 30, 0, 0, time.UTC)
+	// The server subscribes once the stream starts, so broadcast until an event gets through.
+	go func() {
+		for ctx.Err() == nil {
+			a.hub.broadcast(incidentEvent{Type: eventCreated, IncidentID: 1, Source: "NCDOT"})
+			a.hub.broadcast(incidentEvent{Type: eventCleared, At: at, IncidentID: 2, Source: "NCDOT", SourceID: "7", Latitude: &lat, Longitude: &lon, Timestamp: at, Tags: []string{"i40"}})
+			time.Sleep(20 * time.Millisecond)
+		}
+	}()
+
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := event.Incident
+	if event.Type != eventCleared || !event.At.AsTime().Equal(at) {
+		t.Errorf("event = %s at %v, want cleared at %v", event.Type, event.At.AsTime(), at)
+	}
+	if i.Id != 2 || i.Status != "cleared" || i.SourceId != "7" || i.GetLatitude() != lat || i.GetLongitude() != lon || !i.Timestamp.AsTime().Equal(at) || len(i.Tags) != 1 {
+		t.Errorf("incident = %v", i)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"time"
+)
+
+// version is set at build time with -ldflags "-X main.version=v1.2.3".
+var version = ""
+
+// buildVersion is the version the binary was built as, falling back to its VCS revision.
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	revision, modified := "", false
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if revision == "" {
+		return "dev"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// defaultStallTimeout is how long a daemon job may run before /healthz reports the replica
+// stuck.
+const defaultStallTimeout = 15 * time.Minute
+
+// healthzHandler serves the liveness probe, failing once a daemon job has been running for
+// longer than stallTimeout, as when an HTTP call hangs, so Kubernetes restarts the replica.
+func (a *app) healthzHandler(stallTimeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if running, stalled := a.stalled(stallTimeout); stalled {
+			http.Error(w, fmt.Sprintf("a job has been running for %s", running.Round(time.Second)), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
+
+// handleReadyz serves the readiness probe, failing while the database is unreachable.
+func (a *app) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+	if err := a.db.PingContext(ctx); err != nil {
+		log.Printf("Warning: readiness check failed: %v", err)
+		http.Error(w, "database unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// stallTimeout reads HEALTH_STALL_TIMEOUT.
+func stallTimeout() (time.Duration, error) {
+	value := os.Getenv("HEALTH_STALL_TIMEOUT")
+	if value == "" {
+		return defaultStallTimeout, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid HEALTH_STALL_TIMEOUT %q", value)
+	}
+	return d, nil
+}
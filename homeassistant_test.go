@@ -0,0 +1,108 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mtickle/unity-alerts/incident"
+)
+
+func TestGeofenceStates(t *testing.T) {
+	downtown := &Area{Latitude: 35.7796, Longitude: -78.6382, Radius: "1mi"}
+	if err := downtown.validate(); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{Routes: []RouteConfig{
+		{Name: "Downtown Raleigh", Near: downtown},
+		{Name: "everything"},
+		{Name: "123/oakwood", Tenant: "123", Near: downtown},
+	}}
+	at := func(id int, lat, lon float64, severity string) incident.Incident {
+		return incident.Incident{
+			ID: id, EventType: "Vehicle Crash", Address: "100 block of Fayetteville St",
+			Latitude: sql.NullFloat64{Float64: lat, Valid: true}, Longitude: sql.NullFloat64{Float64: lon, Valid: true},
+			Details: []byte(`{"raw_incident": {"severity": ` + severity + `}}`),
+		}
+	}
+	incidents := []incident.Incident{
+		at(1, 35.7800, -78.6400, "1"), // About 0.1 miles away.
+		at(2, 35.7796, -78.6500, "3"), // About 0.66 miles away.
+		at(3, 35.9000, -78.6382, "3"), // Outside.
+		{ID: 4, EventType: "Flood Warning"},
+	}
+
+	geofences := geofenceStates(cfg, incidents)
+	if len(geofences) != 1 {
+		t.Fatalf("got %d geofences, want only the config file route's near area", len(geofences))
+	}
+	g := geofences[0]
+	if g.name != "Downtown Raleigh" || g.id != "downtown_raleigh" {
+		t.Errorf("geofence is named %q with ID %q", g.name, g.id)
+	}
+	if g.state.Active != 2 || g.state.MaxSeverity != 3 || g.state.NearestMiles == nil || *g.state.NearestMiles != 0.1 || *g.state.NearestSeverity != 1 {
+		state, _ := json.Marshal(g.state)
+		t.Errorf("state = %s", state)
+	}
+}
+
+func TestGeofenceID(t *testing.T) {
+	tests := map[string]string{
+		"traffic":          "traffic",
+		"Downtown Raleigh": "downtown_raleigh",
+		" I-40 / I-440 ":   "i_40_i_440",
+	}
+	for name, want := range tests {
+		if got := geofenceID(name); got != want {
+			t.Errorf("geofenceID(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestHomeAssistantSensors(t *testing.T) {
+	ha := &homeAssistant{prefix: defaultDiscoveryPrefix}
+	sensors := ha.sensors(geofence{name: "Downtown Raleigh", id: "downtown_raleigh"})
+	var topics []string
+	for _, s := range sensors {
+		topics = append(topics, s.topic)
+		var config map[string]interface{}
+		if err := json.Unmarshal(s.config, &config); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(config["name"].(string), "Downtown Raleigh ") || config["state_topic"] != "unity-alerts/geofence/downtown_raleigh/state" {
+			t.Errorf("config = %s", s.config)
+		}
+		if !ha.ownTopic(s.topic) {
+			t.Errorf("%s is not recognized as a geofence topic", s.topic)
+		}
+	}
+	want := []string{
+		"homeassistant/sensor/unity_alerts_geofence_downtown_raleigh_active/config",
+		"homeassistant/sensor/unity_alerts_geofence_downtown_raleigh_nearest/config",
+	}
+	if !reflect.DeepEqual(topics, want) {
+		t.Errorf("topics = %q, want %q", topics, want)
+	}
+	if ha.ownTopic("homeassistant/sensor/kitchen_temperature/config") {
+		t.Error("another integration's sensor is recognized as a geofence topic")
+	}
+}
+
+func TestStaleTopics(t *testing.T) {
+	retained := map[string][]byte{
+		"homeassistant/sensor/unity_alerts_geofence_downtown_active/config": []byte("{}"),
+		"homeassistant/sensor/unity_alerts_geofence_42_active/config":       []byte("{}"),
+		"unity-alerts/geofence/42/state":                                    []byte("{}"),
+		"unity-alerts/geofence/downtown/state":                              []byte("{}"),
+	}
+	current := map[string]bool{
+		"homeassistant/sensor/unity_alerts_geofence_downtown_active/config": true,
+		"unity-alerts/geofence/downtown/state":                              true,
+	}
+	want := []string{"homeassistant/sensor/unity_alerts_geofence_42_active/config", "unity-alerts/geofence/42/state"}
+	if got := staleTopics(retained, current); !reflect.DeepEqual(got, want) {
+		t.Errorf("staleTopics = %q, want %q", got, want)
+	}
+}
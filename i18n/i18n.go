@@ -0,0 +1,97 @@
+// Package i18n holds the translated strings used in alert embeds. Locale files are bundled
+// from locales/ and can be overridden or extended with JSON files in LOCALES_DIR.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+//go:embed locales/*.json
+var bundledLocales embed.FS
+
+// DefaultLanguage is the locale every other locale falls back to for missing keys.
+const DefaultLanguage = "en"
+
+// Locale maps message keys to translated embed text.
+type Locale map[string]string
+
+var (
+	localesOnce sync.Once
+	locales     map[string]Locale
+)
+
+// loadLocales reads the bundled locale files, then any overrides or additions from LOCALES_DIR.
+func loadLocales() map[string]Locale {
+	localesOnce.Do(func() {
+		locales = make(map[string]Locale)
+		entries, _ := bundledLocales.ReadDir("locales")
+		for _, entry := range entries {
+			data, err := bundledLocales.ReadFile("locales/" + entry.Name())
+			if err != nil {
+				continue
+			}
+			addLocale(entry.Name(), data)
+		}
+		if dir := os.Getenv("LOCALES_DIR"); dir != "" {
+			files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+			if err != nil {
+				log.Printf("Warning: failed to list LOCALES_DIR: %v", err)
+			}
+			for _, file := range files {
+				data, err := os.ReadFile(file)
+				if err != nil {
+					log.Printf("Warning: failed to read locale file %s: %v", file, err)
+					continue
+				}
+				addLocale(filepath.Base(file), data)
+			}
+		}
+	})
+	return locales
+}
+
+func addLocale(fileName string, data []byte) {
+	lang := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	var strs Locale
+	if err := json.Unmarshal(data, &strs); err != nil {
+		log.Printf("Warning: failed to parse locale %s: %v", fileName, err)
+		return
+	}
+	if existing, ok := locales[lang]; ok {
+		for key, value := range strs {
+			existing[key] = value
+		}
+		return
+	}
+	locales[lang] = strs
+}
+
+// For returns the locale for a language code, or an error if none is installed.
+func For(lang string) (Locale, error) {
+	if lang == "" {
+		lang = DefaultLanguage
+	}
+	locale, ok := loadLocales()[lang]
+	if !ok {
+		return nil, fmt.Errorf("no locale file for language %q", lang)
+	}
+	return locale, nil
+}
+
+// T returns the translation for key, falling back to English and then to the key itself.
+func (l Locale) T(key string) string {
+	if value, ok := l[key]; ok {
+		return value
+	}
+	if value, ok := loadLocales()[DefaultLanguage][key]; ok {
+		return value
+	}
+	return key
+}
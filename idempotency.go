@@ -0,0 +1,27 @@
+package main
+
+import (
+	"log"
+
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// deliveredBefore reports whether the route already received an alert for the incident, as
+// when a run is retried after another route failed or the message ID failed to save. The
+// earlier message then stands in for the new one. Only the route is compared, not the
+// payload: camera frames, weather and nearby cameras change between runs.
+func (a *app) deliveredBefore(route RouteConfig, p *pendingIncident) bool {
+	messageID, err := postgres.DeliveredTo(a.db, p.incident.ID, route.Name)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return false
+	}
+	if messageID == "" {
+		return false
+	}
+	log.Printf("Route %q already received an alert for incident %d; not sending it again.", route.Name, p.incident.ID)
+	for _, q := range append([]*pendingIncident{p}, p.merged...) {
+		q.delivered(route, messageID)
+	}
+	return true
+}
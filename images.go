@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/enrich"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// defaultImageURLTTL is how long a hosted image's signed URL works when IMAGE_URL_TTL is unset.
+const defaultImageURLTTL = 24 * time.Hour
+
+// imageHost serves camera images from the daemon's /images endpoint, so alerts link to them
+// instead of uploading them. That avoids Discord's upload limits and lets edits swap images.
+type imageHost struct {
+	baseURL string // Public URL of the HTTP server, e.g. https://alerts.example.com.
+	key     []byte // Signs image URLs.
+	ttl     time.Duration
+}
+
+// newImageHost configures image hosting from IMAGE_BASE_URL, IMAGE_SIGNING_KEY and IMAGE_URL_TTL.
+// It returns nil when IMAGE_BASE_URL is unset. The images are served by whichever instance
+// runs the HTTP server (HTTP_ADDR); they are shared through the database.
+func newImageHost() (*imageHost, error) {
+	baseURL := strings.TrimSuffix(os.Getenv("IMAGE_BASE_URL"), "/")
+	if baseURL == "" {
+		return nil, nil
+	}
+	key := os.Getenv("IMAGE_SIGNING_KEY")
+	if key == "" {
+		return nil, fmt.Errorf("IMAGE_BASE_URL requires IMAGE_SIGNING_KEY")
+	}
+	h := &imageHost{baseURL: baseURL, key: []byte(key), ttl: defaultImageURLTTL}
+	if v := os.Getenv("IMAGE_URL_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid IMAGE_URL_TTL %q", v)
+		}
+		h.ttl = ttl
+	}
+	return h, nil
+}
+
+// publish stores an enrichment's camera image and points the alert at its signed URL.
+// On failure the image is uploaded as an attachment as before.
+func (h *imageHost) publish(db *sql.DB, enrichment *enrich.Result) {
+	if h == nil || len(enrichment.Attachment) == 0 {
+		return
+	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		log.Printf("Warning: could not host camera image: %v", err)
+		return
+	}
+	id := hex.EncodeToString(buf)
+	expires := time.Now().Add(h.ttl)
+	if err := postgres.SaveImage(db, id, http.DetectContentType(enrichment.Attachment), enrichment.Attachment, expires); err != nil {
+		log.Printf("Warning: could not host camera image: %v", err)
+		return
+	}
+	enrichment.AttachmentURL = fmt.Sprintf("%s/images/%s?expires=%d&sig=%s", h.baseURL, id, expires.Unix(), h.sign(id, expires.Unix()))
+}
+
+func (h *imageHost) sign(id string, expires int64) string {
+	mac := hmac.New(sha256.New, h.key)
+	fmt.Fprintf(mac, "%s:%d", id, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// handler serves GET /images/<id>?expires=...&sig=... for URLs made by publish.
+func (h *imageHost) handler(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/images/")
+		expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+		if err != nil || time.Now().Unix() > expires ||
+			!hmac.Equal([]byte(r.URL.Query().Get("sig")), []byte(h.sign(id, expires))) {
+			http.Error(w, "invalid or expired link", http.StatusForbidden)
+			return
+		}
+		contentType, data, err := postgres.HostedImage(db, id)
+		if err == sql.ErrNoRows {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			log.Printf("Error serving image: %v", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", max(0, expires-time.Now().Unix())))
+		w.Write(data)
+	})
+}
@@ -0,0 +1,262 @@
+// Package incident defines the normalized incident record shared by every feed, as stored in
+// the unified_incidents table, and helpers for reading the feed-specific details.
+package incident
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Feed names used in Incident.Source.
+const (
+	SourceNCDOT        = "NCDOT"
+	SourceRWECC        = "RWECC"
+	SourceArcGISPolice = "ArcGIS_Police"
+	SourceCAP          = "CAP" // Common Alerting Protocol alerts polled by the alerter itself.
+)
+
+// Incident matches the structure of the unified_incidents table.
+type Incident struct {
+	ID               int
+	Source           string
+	SourceID         string
+	EventType        string
+	Address          string
+	Latitude         sql.NullFloat64
+	Longitude        sql.NullFloat64
+	Timestamp        time.Time
+	Details          []byte // Raw JSONB from the database
+	DiscordMessageID sql.NullString
+	IsTest           bool // Inserted by the simulate command.
+	Backfill         bool // Being sent again by the backfill command; never stored.
+
+	// Set by keyword rules during filtering. Only the tags are stored.
+	Priority int      // Added to the severity when routing and pinning.
+	Tags     []string // Shown on the alert and matched by routes and subscriptions.
+}
+
+// Severity returns the feed's severity for an incident, or 0 when the source has none.
+// NCDOT reports it in raw_incident (new format) or at the top level (old format), and CAP
+// alerts store their severity, from 1 (minor) to 4 (extreme), in raw_incident.
+func Severity(i Incident) int {
+	var details struct {
+		RawIncident struct {
+			Severity int `json:"severity"`
+		} `json:"raw_incident"`
+		Severity int `json:"severity"`
+	}
+	json.Unmarshal(i.Details, &details)
+	if details.RawIncident.Severity != 0 {
+		return details.RawIncident.Severity
+	}
+	return details.Severity
+}
+
+var leadingHouseNumber = regexp.MustCompile(`^\s*(\d+[A-Z]?\s+(BLOCK\s+(OF\s+)?)?)`)
+
+// Corridor groups incidents on the same road: the NCDOT road name when present,
+// otherwise the street part of the address with the house number removed.
+func Corridor(i Incident) string {
+	var details struct {
+		RawIncident struct {
+			Road string `json:"road"`
+		} `json:"raw_incident"`
+		Road string `json:"road"`
+	}
+	json.Unmarshal(i.Details, &details)
+	road := details.RawIncident.Road
+	if road == "" {
+		road = details.Road
+	}
+	if road == "" {
+		road = strings.ToUpper(i.Address)
+		if comma := strings.Index(road, ","); comma >= 0 {
+			road = road[:comma]
+		}
+		road = leadingHouseNumber.ReplaceAllString(road, "")
+	}
+	return strings.Join(strings.Fields(strings.ToUpper(road)), " ")
+}
+
+// Categories returns the labels an incident can be filtered on: its event type and, for
+// police incidents, the crime description from the raw feed. Empty labels are omitted.
+func Categories(i Incident) []string {
+	var details struct {
+		RawIncident struct {
+			CrimeDescription string `json:"crime_description"`
+		} `json:"raw_incident"`
+	}
+	json.Unmarshal(i.Details, &details)
+	var categories []string
+	for _, c := range []string{i.EventType, details.RawIncident.CrimeDescription} {
+		if c = strings.TrimSpace(c); c != "" {
+			categories = append(categories, c)
+		}
+	}
+	return categories
+}
+
+// Jurisdiction returns the municipality an RWECC call belongs to, or "" when the feed has none.
+func Jurisdiction(i Incident) string {
+	var details struct {
+		RawIncident struct {
+			Jurisdiction string `json:"jurisdiction"`
+		} `json:"raw_incident"`
+	}
+	json.Unmarshal(i.Details, &details)
+	return strings.TrimSpace(details.RawIncident.Jurisdiction)
+}
+
+var nonAddressChars = regexp.MustCompile(`[^A-Z0-9 ]+`)
+
+// NormalizedAddress is the address reduced to upper-case words, so repeated calls to one
+// location compare equal despite punctuation and spacing differences.
+func NormalizedAddress(i Incident) string {
+	address := nonAddressChars.ReplaceAllString(strings.ToUpper(i.Address), " ")
+	return strings.Join(strings.Fields(address), " ")
+}
+
+var blockAddress = regexp.MustCompile(`(?i)^\s*(\d+)[A-Z]?\s+(?:BLOCK\s+(?:OF\s+)?)?(\S.*)$`)
+
+// Block splits the street part of the address into its hundred block and street, e.g.
+// "1234 Oak St, Raleigh" into 1200 and "Oak St". ok is false without a house number.
+func Block(i Incident) (block int, street string, ok bool) {
+	address := i.Address
+	if comma := strings.Index(address, ","); comma >= 0 {
+		address = address[:comma]
+	}
+	m := blockAddress.FindStringSubmatch(address)
+	if m == nil {
+		return 0, "", false
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, "", false
+	}
+	return n / 100 * 100, strings.TrimSpace(m[2]), true
+}
+
+// BlockKey is the normalized hundred block of the address, e.g. "1200 OAK ST", or "" when
+// the address has no house number.
+func BlockKey(i Incident) string {
+	block, street, ok := Block(i)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%d %s", block, NormalizedAddress(Incident{Address: street}))
+}
+
+// ClosureGeometry returns the GeoJSON geometry of the road segment an NCDOT incident affects,
+// or nil when the feed has none. The feed stores it in raw_incident.polyline, usually as a
+// JSON string.
+func ClosureGeometry(i Incident) json.RawMessage {
+	var details struct {
+		RawIncident struct {
+			Polyline json.RawMessage `json:"polyline"`
+		} `json:"raw_incident"`
+	}
+	json.Unmarshal(i.Details, &details)
+	raw := details.RawIncident.Polyline
+	var encoded string
+	if json.Unmarshal(raw, &encoded) == nil {
+		raw = json.RawMessage(encoded)
+	}
+	var geometry struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	}
+	if json.Unmarshal(raw, &geometry) != nil || geometry.Type == "" || len(geometry.Coordinates) == 0 {
+		return nil
+	}
+	return raw
+}
+
+var (
+	interstateNumber = regexp.MustCompile(`^(?:INTERSTATE|I)[ -]?(\d+)\b`)
+	routeNumber      = regexp.MustCompile(`^(US|NC)[ -]?(?:HWY |HIGHWAY )?(\d+)\b`)
+)
+
+// roadSuffixes shortens street types the way NCDOT writes them.
+var roadSuffixes = map[string]string{
+	"STREET": "ST", "ROAD": "RD", "AVENUE": "AVE", "BOULEVARD": "BLVD", "DRIVE": "DR",
+	"PARKWAY": "PKWY", "HIGHWAY": "HWY", "LANE": "LN", "COURT": "CT", "PLACE": "PL",
+	"CIRCLE": "CIR", "EXPRESSWAY": "EXPY", "FREEWAY": "FWY", "TRAIL": "TRL",
+	"NORTH": "N", "SOUTH": "S", "EAST": "E", "WEST": "W",
+}
+
+// RoadName is the incident's Corridor in a canonical spelling, so "Interstate 40", "I 40" and
+// "I-40" or "Capital Boulevard" and "CAPITAL BLVD" are counted as one road.
+func RoadName(i Incident) string {
+	road := nonAddressChars.ReplaceAllString(strings.ReplaceAll(Corridor(i), "-", " "), " ")
+	words := strings.Fields(road)
+	for n, w := range words {
+		if short, ok := roadSuffixes[w]; ok {
+			words[n] = short
+		}
+	}
+	road = strings.Join(words, " ")
+	road = interstateNumber.ReplaceAllString(road, "I-$1")
+	return routeNumber.ReplaceAllString(road, "$1-$2")
+}
+
+// Weather conditions reported by WeatherCondition.
+const (
+	WeatherDry    = "dry"
+	WeatherRain   = "rain"
+	WeatherStorms = "storms"
+	WeatherSnow   = "snow/ice"
+	WeatherFog    = "fog"
+)
+
+// WeatherCondition classifies the forecast stored with the incident, or returns "" when the
+// incident has no weather.
+func WeatherCondition(i Incident) string {
+	var details struct {
+		Weather *struct {
+			ShortForecast string `json:"shortForecast"`
+		} `json:"weather"`
+	}
+	json.Unmarshal(i.Details, &details)
+	if details.Weather == nil || details.Weather.ShortForecast == "" {
+		return ""
+	}
+	forecast := strings.ToUpper(details.Weather.ShortForecast)
+	switch {
+	case strings.Contains(forecast, "THUNDER"):
+		return WeatherStorms
+	case strings.Contains(forecast, "SNOW"), strings.Contains(forecast, "SLEET"), strings.Contains(forecast, "ICE"), strings.Contains(forecast, "FREEZING"):
+		return WeatherSnow
+	case strings.Contains(forecast, "RAIN"), strings.Contains(forecast, "SHOWERS"), strings.Contains(forecast, "DRIZZLE"):
+		return WeatherRain
+	case strings.Contains(forecast, "FOG"):
+		return WeatherFog
+	}
+	return WeatherDry
+}
+
+// Polygons returns the outer rings, as [longitude, latitude] points, of the area a CAP alert
+// covers, or nil when the incident has none. They are stored in the details as a GeoJSON
+// MultiPolygon under "geometry".
+func Polygons(i Incident) [][][2]float64 {
+	var details struct {
+		Geometry struct {
+			Type        string           `json:"type"`
+			Coordinates [][][][2]float64 `json:"coordinates"`
+		} `json:"geometry"`
+	}
+	if json.Unmarshal(i.Details, &details) != nil || details.Geometry.Type != "MultiPolygon" {
+		return nil
+	}
+	var rings [][][2]float64
+	for _, polygon := range details.Geometry.Coordinates {
+		if len(polygon) > 0 && len(polygon[0]) >= 3 {
+			rings = append(rings, polygon[0])
+		}
+	}
+	return rings
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultInfluxInterval is how often metrics are pushed when INFLUX_INTERVAL is unset.
+const defaultInfluxInterval = time.Minute
+
+// influxExporter pushes the metrics to InfluxDB in line protocol, for setups that graph with
+// Grafana on InfluxDB rather than scraping /metrics.
+type influxExporter struct {
+	writeURL string // The full write endpoint, e.g. http://influx:8086/api/v2/write?org=home&bucket=alerts&precision=s.
+	token    string
+	interval time.Duration
+	lastPush time.Time
+}
+
+// newInfluxExporter configures the exporter from INFLUX_WRITE_URL, INFLUX_TOKEN and
+// INFLUX_INTERVAL. It returns nil when INFLUX_WRITE_URL is unset.
+func newInfluxExporter() (*influxExporter, error) {
+	writeURL := os.Getenv("INFLUX_WRITE_URL")
+	if writeURL == "" {
+		return nil, nil
+	}
+	e := &influxExporter{writeURL: writeURL, token: os.Getenv("INFLUX_TOKEN"), interval: defaultInfluxInterval}
+	if v := os.Getenv("INFLUX_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid INFLUX_INTERVAL %q", v)
+		}
+		e.interval = d
+	}
+	return e, nil
+}
+
+// exportMetrics pushes the metrics when the push interval has passed.
+func (a *app) exportMetrics(cfg *Config) {
+	e := a.influx
+	if e == nil || time.Since(e.lastPush) < e.interval {
+		return
+	}
+	e.lastPush = time.Now()
+	m, err := a.collectMetrics(cfg)
+	if err != nil {
+		log.Printf("Warning: could not collect metrics for InfluxDB: %v", err)
+		return
+	}
+	if err := e.push(m, e.lastPush); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// push writes one point per measurement, all stamped with at.
+func (e *influxExporter) push(m metricsSnapshot, at time.Time) error {
+	var body bytes.Buffer
+	ts := at.Unix()
+	for _, c := range m.Incidents {
+		fmt.Fprintf(&body, "unity_alerts_incidents,source=%s last_hour=%di,active=%di %d\n", influxTag(c.Source), c.LastHour, c.Active, ts)
+	}
+	fmt.Fprintf(&body, "unity_alerts_cameras captured=%di,failed=%di,placeholders=%di %d\n",
+		m.Cameras.Captured, m.Cameras.Failed, m.Cameras.Placeholders, ts)
+	fmt.Fprintf(&body, "unity_alerts_deliveries ok=%di,failed=%di,alerts=%di %d\n", m.Delivered, m.Failed, m.Latency.Alerts, ts)
+	for _, stage := range m.latencyStages() {
+		fmt.Fprintf(&body, "unity_alerts_latency,stage=%s p50=%g,p95=%g %d\n", stage.name, stage.p.P50.Seconds(), stage.p.P95.Seconds(), ts)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.writeURL, &body)
+	if err != nil {
+		return fmt.Errorf("failed to create InfluxDB request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if e.token != "" {
+		req.Header.Set("Authorization", "Token "+e.token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics to InfluxDB: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("InfluxDB returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// influxTag escapes a tag value for line protocol.
+func influxTag(v string) string {
+	return strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `).Replace(v)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/incident"
+)
+
+// maxIngestBatch caps the incidents accepted in one /ingest request.
+const maxIngestBatch = 500
+
+// ingestSources are the sources /ingest accepts: the ones alerts can be rendered for.
+var ingestSources = map[string]bool{
+	incident.SourceNCDOT:        true,
+	incident.SourceRWECC:        true,
+	incident.SourceArcGISPolice: true,
+	incident.SourceCAP:          true,
+}
+
+// ingestedIncident is one incident pushed to /ingest, in the unified_incidents layout.
+type ingestedIncident struct {
+	Source    string          `json:"source"`
+	SourceID  string          `json:"source_id"`
+	EventType string          `json:"event_type"`
+	Address   string          `json:"address"`
+	Latitude  *float64        `json:"latitude"`
+	Longitude *float64        `json:"longitude"`
+	Timestamp *time.Time      `json:"timestamp"` // RFC 3339; defaults to when it was received.
+	Details   json.RawMessage `json:"details"`   // A JSON object, as the feed's ingestor would store it.
+	Status    string          `json:"status"`    // "active" (default) or "cleared".
+}
+
+// validate checks an incident against the /ingest schema and fills in defaults.
+func (i *ingestedIncident) validate() error {
+	if !ingestSources[i.Source] {
+		return fmt.Errorf("unknown source %q", i.Source)
+	}
+	if strings.TrimSpace(i.SourceID) == "" {
+		return fmt.Errorf("source_id is required")
+	}
+	if i.Status == "" {
+		i.Status = "active"
+	}
+	if i.Status != "active" && i.Status != "cleared" {
+		return fmt.Errorf("status must be active or cleared, not %q", i.Status)
+	}
+	if i.Status == "active" && strings.TrimSpace(i.EventType) == "" {
+		return fmt.Errorf("event_type is required")
+	}
+	if (i.Latitude == nil) != (i.Longitude == nil) {
+		return fmt.Errorf("latitude and longitude must be given together")
+	}
+	if i.Latitude != nil && (*i.Latitude < -90 || *i.Latitude > 90 || *i.Longitude < -180 || *i.Longitude > 180) {
+		return fmt.Errorf("latitude or longitude out of range")
+	}
+	if len(i.Details) == 0 || string(i.Details) == "null" {
+		i.Details = json.RawMessage("{}")
+	}
+	if trimmed := bytes.TrimSpace(i.Details); len(trimmed) == 0 || trimmed[0] != '{' {
+		return fmt.Errorf("details must be a JSON object")
+	}
+	if i.Timestamp == nil {
+		now := time.Now().UTC()
+		i.Timestamp = &now
+	}
+	return nil
+}
+
+// ingestResult reports what happened to one pushed incident.
+type ingestResult struct {
+	ID     int    `json:"id,omitempty"`
+	Result string `json:"result,omitempty"` // "created", "cleared" or "unchanged".
+	Error  string `json:"error,omitempty"`
+}
+
+// requireIngestToken rejects requests without "Authorization: Bearer $INGEST_TOKEN". Unlike
+// the read-only API, /ingest is never open.
+func requireIngestToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ingestHandler serves POST /ingest, which lets scrapers and services push incidents, one JSON
+// object or an array of them, without database credentials. It is mounted only when
+// INGEST_TOKEN is set. Incidents already stored are matched by source and source_id; pushing
+// one again with status "cleared" clears it.
+func (a *app) ingestHandler() http.Handler {
+	token := os.Getenv("INGEST_TOKEN")
+	if token == "" {
+		return nil
+	}
+	return requireIngestToken(token, http.HandlerFunc(a.handleIngest))
+}
+
+func (a *app) handleIngest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 5<<20))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+	incidents, err := parseIngested(body)
+	if err != nil {
+		http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(incidents) == 0 || len(incidents) > maxIngestBatch {
+		http.Error(w, fmt.Sprintf("send between 1 and %d incidents", maxIngestBatch), http.StatusBadRequest)
+		return
+	}
+
+	// Validate everything first, so a bad batch is rejected as a whole.
+	results := make([]ingestResult, len(incidents))
+	valid := true
+	for n := range incidents {
+		if err := incidents[n].validate(); err != nil {
+			results[n].Error = err.Error()
+			valid = false
+		}
+	}
+	status := http.StatusOK
+	if valid {
+		cfg := a.currentConfig()
+		for n, i := range incidents {
+			if results[n], err = a.storeIngested(cfg, i); err != nil {
+				log.Printf("Error ingesting %s incident %s: %v", i.Source, i.SourceID, err)
+				results[n] = ingestResult{Error: "internal error"}
+				status = http.StatusInternalServerError
+			}
+		}
+	} else {
+		status = http.StatusUnprocessableEntity
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(results)
+}
+
+// parseIngested decodes one incident or an array of them, as pushed to /ingest or the
+// incident queue.
+func parseIngested(body []byte) ([]ingestedIncident, error) {
+	var incidents []ingestedIncident
+	var err error
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &incidents)
+	} else {
+		incidents = make([]ingestedIncident, 1)
+		err = json.Unmarshal(trimmed, &incidents[0])
+	}
+	return incidents, err
+}
+
+// storeIngested inserts a pushed incident unless its source and source_id are already stored,
+// and clears the stored one when the push says it cleared.
+func (a *app) storeIngested(cfg *Config, i ingestedIncident) (ingestResult, error) {
+	var id int
+	err := a.db.QueryRow(cfg.SQL("SELECT {id} FROM {incidents} WHERE {source} = $1 AND {source_id} = $2 ORDER BY {id} DESC LIMIT 1"),
+		i.Source, i.SourceID).Scan(&id)
+	switch {
+	case err == sql.ErrNoRows:
+		if i.Status == "cleared" {
+			return ingestResult{Result: "unchanged"}, nil
+		}
+		var lat, lon sql.NullFloat64
+		if i.Latitude != nil {
+			lat = sql.NullFloat64{Float64: *i.Latitude, Valid: true}
+			lon = sql.NullFloat64{Float64: *i.Longitude, Valid: true}
+		}
+		err = a.db.QueryRow(cfg.SQL(`INSERT INTO {incidents} ({source}, {source_id}, {event_type}, {address}, {latitude}, {longitude}, {timestamp}, {details}, {status}, {is_test})
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 'active', false) RETURNING {id}`),
+			i.Source, i.SourceID, i.EventType, i.Address, lat, lon, *i.Timestamp, []byte(i.Details)).Scan(&id)
+		if err != nil {
+			return ingestResult{}, fmt.Errorf("failed to insert incident: %w", err)
+		}
+		return ingestResult{ID: id, Result: "created"}, nil
+	case err != nil:
+		return ingestResult{}, fmt.Errorf("error querying incident: %w", err)
+	}
+	if i.Status != "cleared" {
+		return ingestResult{ID: id, Result: "unchanged"}, nil
+	}
+	res, err := a.db.Exec(cfg.SQL("UPDATE {incidents} SET {status} = 'cleared' WHERE {id} = $1 AND {status} = 'active'"), id)
+	if err != nil {
+		return ingestResult{}, fmt.Errorf("failed to clear incident: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ingestResult{ID: id, Result: "unchanged"}, nil
+	}
+	return ingestResult{ID: id, Result: "cleared"}, nil
+}
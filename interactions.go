@@ -0,0 +1,557 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mtickle/unity-alerts/enrich"
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/sink/discord"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// Interaction and response types from the Discord interactions API.
+const (
+	interactionPing               = 1
+	interactionApplicationCommand = 2
+	interactionMessageComponent   = 3
+
+	responsePong                 = 1
+	responseChannelMessage       = 4
+	responseDeferredMessage      = 5
+	responseUpdateMessage        = 7
+	messageFlagEphemeral         = 64
+	commandOptionSubCommand      = 1
+	commandOptionSubCommandGroup = 2
+	commandOptionString          = 3
+	commandOptionInteger         = 4
+	commandOptionBoolean         = 5
+	commandOptionChannel         = 7
+	commandOptionNumber          = 10
+	maxIncidentsPerCommandReply  = 10
+	metersPerMile                = 1609.344
+	defaultNearRadiusMiles       = 1.0
+)
+
+// Interaction is the subset of an incoming Discord interaction the bot uses.
+type Interaction struct {
+	Type          int    `json:"type"`
+	ApplicationID string `json:"application_id"`
+	Token         string `json:"token"` // Lets a deferred reply be sent for 15 minutes.
+	ChannelID     string `json:"channel_id"`
+	GuildID       string `json:"guild_id"`
+	Member        *struct {
+		User        discord.User `json:"user"`
+		Permissions string       `json:"permissions"` // Bitfield as a decimal string.
+	} `json:"member"`
+	User    *discord.User `json:"user"`
+	Message *struct {
+		ID     string            `json:"id"`
+		Embeds []json.RawMessage `json:"embeds"`
+	} `json:"message"`
+	Data struct {
+		Name     string              `json:"name"`
+		CustomID string              `json:"custom_id"`
+		Options  []InteractionOption `json:"options"`
+	} `json:"data"`
+}
+
+// user returns the invoking user, who is under member in a server and user in a DM.
+func (i Interaction) user() discord.User {
+	if i.Member != nil {
+		return i.Member.User
+	}
+	if i.User != nil {
+		return *i.User
+	}
+	return discord.User{}
+}
+
+func (i Interaction) userID() string {
+	return i.user().ID
+}
+
+// InteractionOption is one argument (or subcommand) of a slash command.
+type InteractionOption struct {
+	Name    string              `json:"name"`
+	Type    int                 `json:"type"`
+	Value   json.RawMessage     `json:"value"`
+	Options []InteractionOption `json:"options"`
+}
+
+// interactionResponse is the reply sent back on the interactions endpoint.
+type interactionResponse struct {
+	Type int         `json:"type"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+type interactionResponseData struct {
+	Content         string                   `json:"content,omitempty"`
+	Embeds          []discord.Embed          `json:"embeds,omitempty"`
+	Flags           int                      `json:"flags,omitempty"`
+	AllowedMentions *discord.AllowedMentions `json:"allowed_mentions,omitempty"`
+}
+
+// slashCommands are registered with register-commands.
+var slashCommands = []map[string]interface{}{
+	{
+		"name":        "incidents",
+		"description": "List incidents",
+		"options": []map[string]interface{}{
+			{"type": commandOptionSubCommand, "name": "active", "description": "Show currently active incidents"},
+		},
+	},
+	{
+		"name":        "near",
+		"description": "Active incidents near an address",
+		"options": []map[string]interface{}{
+			{"type": commandOptionString, "name": "address", "description": "Street address or place", "required": true},
+			{"type": commandOptionNumber, "name": "radius", "description": "Radius in miles (default 1)"},
+		},
+	},
+	{
+		"name":        "history",
+		"description": "Recent incidents at an address",
+		"options": []map[string]interface{}{
+			{"type": commandOptionString, "name": "address", "description": "Street address or part of one", "required": true},
+		},
+	},
+	{
+		"name":        "subscribe",
+		"description": "Get alerted about incidents near a location",
+		"options": []map[string]interface{}{
+			{"type": commandOptionString, "name": "location", "description": "Street address, place or lat,lon coordinates", "required": true},
+			{"type": commandOptionString, "name": "radius", "description": "Radius such as 2mi, 500m or 1km (default 1mi)"},
+			{"type": commandOptionString, "name": "types", "description": "Comma-separated incident types, e.g. fire,crash"},
+			{"type": commandOptionString, "name": "notify", "description": "Where to alert you", "choices": []map[string]string{
+				{"name": "Direct message", "value": "dm"},
+				{"name": "Ping me in this channel", "value": "here"},
+			}},
+		},
+	},
+	{
+		"name":        "unsubscribe",
+		"description": "Remove all of your incident subscriptions",
+	},
+	{
+		"name":        "preferences",
+		"description": "Show or change quiet hours and categories for your subscriptions",
+		"options": []map[string]interface{}{
+			{"type": commandOptionString, "name": "quiet_hours", "description": "No alerts during this range, e.g. 22:00-07:00, or off"},
+			{"type": commandOptionString, "name": "timezone", "description": "Timezone for quiet hours, e.g. America/New_York"},
+			{"type": commandOptionString, "name": "categories", "description": "Only alert on these, e.g. fire,crash, or all"},
+			{"type": commandOptionString, "name": "delivery", "description": "How to get alerts", "choices": []map[string]string{
+				{"name": "Real-time pings", "value": "realtime"},
+				{"name": "Daily email digest", "value": "digest"},
+			}},
+			{"type": commandOptionString, "name": "email", "description": "Address for the daily digest, or off"},
+		},
+	},
+	{
+		"name":        "watch",
+		"description": "Get pinged about any incident at an address you care about",
+		"options": []map[string]interface{}{
+			{"type": commandOptionSubCommand, "name": "add", "description": "Watch an address", "options": []map[string]interface{}{
+				{"type": commandOptionString, "name": "address", "description": "Street address, e.g. 1234 Oak St", "required": true},
+				{"type": commandOptionBoolean, "name": "block", "description": "Watch the whole hundred block"},
+				{"type": commandOptionString, "name": "notify", "description": "Where to alert you", "choices": []map[string]string{
+					{"name": "Direct message", "value": "dm"},
+					{"name": "Ping me in this channel", "value": "here"},
+				}},
+			}},
+			{"type": commandOptionSubCommand, "name": "list", "description": "Show your watchlist"},
+			{"type": commandOptionSubCommand, "name": "remove", "description": "Stop watching an address", "options": []map[string]interface{}{
+				{"type": commandOptionInteger, "name": "id", "description": "Watch ID from /watch list", "required": true},
+			}},
+		},
+	},
+	{
+		"name":                       "mute",
+		"description":                "Silence alerts for an address, road or incident type",
+		"default_member_permissions": "32", // Manage Server
+		"options": []map[string]interface{}{
+			{"type": commandOptionSubCommand, "name": "add", "description": "Add a temporary mute", "options": []map[string]interface{}{
+				{"type": commandOptionString, "name": "address", "description": "Street address to silence"},
+				{"type": commandOptionString, "name": "road", "description": "Road to silence, e.g. I-40 W"},
+				{"type": commandOptionString, "name": "type", "description": "Incident type containing this text"},
+				{"type": commandOptionString, "name": "radius", "description": "Silence everything this close to the address, e.g. 500m"},
+				{"type": commandOptionString, "name": "duration", "description": "How long, e.g. 12h or 3d (default 24h)"},
+			}},
+			{"type": commandOptionSubCommand, "name": "list", "description": "Show active mutes"},
+			{"type": commandOptionSubCommand, "name": "remove", "description": "Remove a mute", "options": []map[string]interface{}{
+				{"type": commandOptionInteger, "name": "id", "description": "Mute ID from /mute list", "required": true},
+			}},
+		},
+	},
+	{
+		"name":                       "admin",
+		"description":                "Manage this server's alert routes",
+		"default_member_permissions": "32", // Manage Server
+		"dm_permission":              false,
+		"options": []map[string]interface{}{
+			{"type": commandOptionSubCommandGroup, "name": "route", "description": "Add, remove or list routes", "options": []map[string]interface{}{
+				{"type": commandOptionSubCommand, "name": "add", "description": "Post alerts to a channel", "options": []map[string]interface{}{
+					{"type": commandOptionString, "name": "name", "description": "Route name, e.g. traffic", "required": true},
+					{"type": commandOptionChannel, "name": "channel", "description": "Channel to post in (default this one)"},
+					{"type": commandOptionString, "name": "sources", "description": "Comma-separated sources, e.g. NCDOT,RWECC (default all)"},
+					{"type": commandOptionInteger, "name": "min_severity", "description": "Only incidents at or above this severity"},
+				}},
+				{"type": commandOptionSubCommand, "name": "remove", "description": "Delete a route", "options": []map[string]interface{}{
+					{"type": commandOptionString, "name": "name", "description": "Route name from /admin route list", "required": true},
+				}},
+				{"type": commandOptionSubCommand, "name": "list", "description": "Show this server's routes"},
+			}},
+			{"type": commandOptionSubCommand, "name": "filter", "description": "Change which incidents a route posts", "options": []map[string]interface{}{
+				{"type": commandOptionString, "name": "route", "description": "Route name", "required": true},
+				{"type": commandOptionString, "name": "sources", "description": "Comma-separated sources, or all"},
+				{"type": commandOptionString, "name": "jurisdictions", "description": "Comma-separated municipalities, or all"},
+				{"type": commandOptionInteger, "name": "min_severity", "description": "Minimum severity, or 0 for any"},
+				{"type": commandOptionString, "name": "near", "description": "Only incidents near this address or lat,lon, or off"},
+				{"type": commandOptionString, "name": "radius", "description": "Radius for near, e.g. 2mi or 500m (default 1mi)"},
+			}},
+			{"type": commandOptionSubCommand, "name": "pause", "description": "Stop posting for a while", "options": []map[string]interface{}{
+				{"type": commandOptionString, "name": "route", "description": "Route name (default all routes)"},
+				{"type": commandOptionString, "name": "duration", "description": "How long, e.g. 2h or 1d (default 24h)"},
+				{"type": commandOptionString, "name": "mode", "description": "What to do with alerts meanwhile (default drop)", "choices": []map[string]string{
+					{"name": "Drop them", "value": "drop"},
+					{"name": "Hold and send them on resume", "value": "hold"},
+					{"name": "Hold and summarize them on resume", "value": "summarize"},
+				}},
+			}},
+			{"type": commandOptionSubCommand, "name": "resume", "description": "Resume paused routes", "options": []map[string]interface{}{
+				{"type": commandOptionString, "name": "route", "description": "Route name (default all routes)"},
+			}},
+		},
+	},
+}
+
+// registerSlashCommands overwrites the application's global commands with slashCommands.
+func registerSlashCommands() error {
+	appID, token := os.Getenv("DISCORD_APPLICATION_ID"), os.Getenv("DISCORD_BOT_TOKEN")
+	if appID == "" || token == "" {
+		return fmt.Errorf("DISCORD_APPLICATION_ID and DISCORD_BOT_TOKEN must be set")
+	}
+	endpoint := fmt.Sprintf("%s/applications/%s/commands", discord.APIBase, appID)
+	return discord.SendJSON("PUT", endpoint, "Bot "+token, slashCommands)
+}
+
+// option returns a named option value, or nil.
+func option(options []InteractionOption, name string) *InteractionOption {
+	for i := range options {
+		if options[i].Name == name {
+			return &options[i]
+		}
+	}
+	return nil
+}
+
+func (o *InteractionOption) stringValue() string {
+	var s string
+	if o != nil {
+		json.Unmarshal(o.Value, &s)
+	}
+	return s
+}
+
+func (o *InteractionOption) boolValue() bool {
+	var b bool
+	if o != nil {
+		json.Unmarshal(o.Value, &b)
+	}
+	return b
+}
+
+func (o *InteractionOption) floatValue(fallback float64) float64 {
+	var f float64
+	if o == nil || json.Unmarshal(o.Value, &f) != nil || f <= 0 {
+		return fallback
+	}
+	return f
+}
+
+// interactionsHandler verifies Discord's request signature and answers slash commands.
+func (a *app) interactionsHandler(publicKey ed25519.PublicKey) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		signature, err := hex.DecodeString(r.Header.Get("X-Signature-Ed25519"))
+		timestamp := r.Header.Get("X-Signature-Timestamp")
+		if err != nil || !ed25519.Verify(publicKey, append([]byte(timestamp), body...), signature) {
+			http.Error(w, "invalid request signature", http.StatusUnauthorized)
+			return
+		}
+
+		var interaction Interaction
+		if err := json.Unmarshal(body, &interaction); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		var resp interactionResponse
+		switch interaction.Type {
+		case interactionPing:
+			resp = interactionResponse{Type: responsePong}
+		case interactionApplicationCommand:
+			resp = a.handleCommand(interaction)
+		case interactionMessageComponent:
+			resp = a.handleComponent(interaction)
+		default:
+			http.Error(w, "unsupported interaction type", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// handleCommand runs a slash command and returns an ephemeral reply.
+func (a *app) handleCommand(interaction Interaction) interactionResponse {
+	cfg := a.config.Current()
+	opts := cfg.RenderOptions(RouteConfig{}, "")
+
+	var title string
+	var incidents []incident.Incident
+	var statuses []string
+	var err error
+	switch interaction.Data.Name {
+	case "incidents":
+		title = opts.T("cmd_active_title")
+		incidents, statuses, err = queryIncidents(cfg, a.db,
+			"WHERE {status} = 'active' ORDER BY {timestamp} DESC LIMIT $1", maxIncidentsPerCommandReply)
+	case "near":
+		return deferReply(interaction, func() interactionResponse { return a.handleNear(cfg, interaction, opts) })
+	case "subscribe":
+		if _, _, ok := parseCoordinates(option(interaction.Data.Options, "location").stringValue()); ok {
+			return a.handleSubscribe(interaction, opts)
+		}
+		return deferReply(interaction, func() interactionResponse { return a.handleSubscribe(interaction, opts) })
+	case "preferences":
+		return a.handlePreferences(interaction, opts)
+	case "unsubscribe":
+		removed, err := postgres.DeleteSubscriptions(a.db, interaction.userID())
+		if err != nil {
+			log.Printf("Error handling /unsubscribe: %v", err)
+			return ephemeralReply(opts.T("cmd_error"), nil)
+		}
+		return ephemeralReply(fmt.Sprintf(opts.T("sub_removed"), removed), nil)
+	case "watch":
+		return a.handleWatch(interaction, opts)
+	case "mute":
+		return a.handleMute(interaction, opts)
+	case "admin":
+		return a.handleAdmin(interaction, opts)
+	case "history":
+		address := option(interaction.Data.Options, "address").stringValue()
+		title = fmt.Sprintf(opts.T("cmd_history_title"), discord.SanitizeFeedText(address))
+		incidents, statuses, err = queryIncidents(cfg, a.db,
+			`WHERE {address} ILIKE '%' || $1 || '%' ESCAPE '\' ORDER BY {timestamp} DESC LIMIT $2`, escapeLike(address), maxIncidentsPerCommandReply)
+	default:
+		err = fmt.Errorf("unknown command %q", interaction.Data.Name)
+	}
+
+	if err != nil {
+		log.Printf("Error handling /%s: %v", interaction.Data.Name, err)
+		return ephemeralReply(opts.T("cmd_error"), nil)
+	}
+	return ephemeralReply("", []discord.Embed{buildIncidentListEmbed(title, incidents, statuses, opts)})
+}
+
+// handleNear geocodes the address and lists the active incidents around it.
+func (a *app) handleNear(cfg *Config, interaction Interaction, opts discord.RenderOptions) interactionResponse {
+	address := option(interaction.Data.Options, "address").stringValue()
+	radius := option(interaction.Data.Options, "radius").floatValue(defaultNearRadiusMiles)
+	title := fmt.Sprintf(opts.T("cmd_near_title"), radius, discord.SanitizeFeedText(address))
+	lat, lon, err := enrich.Geocode(context.Background(), os.Getenv("GOOGLE_MAPS_API_KEY"), address)
+	var incidents []incident.Incident
+	var statuses []string
+	if err == nil {
+		incidents, statuses, err = queryIncidents(cfg, a.db, `WHERE {status} = 'active' AND {latitude} IS NOT NULL AND {longitude} IS NOT NULL
+			AND ST_DWithin(ST_SetSRID(ST_MakePoint({longitude}, {latitude}), 4326)::geography, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography, $3)
+			ORDER BY ST_Distance(ST_SetSRID(ST_MakePoint({longitude}, {latitude}), 4326)::geography, ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography)
+			LIMIT $4`, lon, lat, radius*metersPerMile, maxIncidentsPerCommandReply)
+	}
+	if err != nil {
+		log.Printf("Error handling /near: %v", err)
+		return ephemeralReply(opts.T("cmd_error"), nil)
+	}
+	return ephemeralReply("", []discord.Embed{buildIncidentListEmbed(title, incidents, statuses, opts)})
+}
+
+// deferReply answers at once that a reply is coming, then edits in the one run returns. It is
+// for commands that call out to slower services, since Discord drops an interaction that
+// isn't answered within three seconds.
+func deferReply(interaction Interaction, run func() interactionResponse) interactionResponse {
+	go func() {
+		resp := run()
+		endpoint := fmt.Sprintf("%s/webhooks/%s/%s/messages/@original", discord.APIBase, interaction.ApplicationID, interaction.Token)
+		if err := discord.SendJSON("PATCH", endpoint, "", resp.Data); err != nil {
+			log.Printf("Error sending deferred reply to /%s: %v", interaction.Data.Name, err)
+		}
+	}()
+	return interactionResponse{Type: responseDeferredMessage, Data: &interactionResponseData{Flags: messageFlagEphemeral}}
+}
+
+// escapeLike escapes LIKE's wildcards, so user input matches literally with ESCAPE '\'.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// handleSubscribe geocodes the location and stores the caller's geofence subscription.
+func (a *app) handleSubscribe(interaction Interaction, opts discord.RenderOptions) interactionResponse {
+	options := interaction.Data.Options
+	userID := interaction.userID()
+	location := option(options, "location").stringValue()
+	radius, err := parseRadius(option(options, "radius").stringValue())
+	if err != nil || userID == "" {
+		return ephemeralReply(opts.T("sub_bad_radius"), nil)
+	}
+	var channelID sql.NullString
+	if option(options, "notify").stringValue() == "here" && interaction.Member != nil {
+		channelID = sql.NullString{String: interaction.ChannelID, Valid: true}
+	}
+
+	lat, lon, ok := parseCoordinates(location)
+	if !ok {
+		lat, lon, err = enrich.Geocode(context.Background(), os.Getenv("GOOGLE_MAPS_API_KEY"), location)
+	}
+	if err == nil {
+		err = postgres.AddSubscription(a.db, userID, channelID, location, lat, lon, radius, parseEventTypes(option(options, "types").stringValue()))
+	}
+	if err != nil {
+		log.Printf("Error handling /subscribe: %v", err)
+		return ephemeralReply(opts.T("cmd_error"), nil)
+	}
+	return ephemeralReply(fmt.Sprintf(opts.T("sub_created"), radius/metersPerMile, discord.SanitizeFeedText(location)), nil)
+}
+
+// handlePreferences updates the options given to /preferences and replies with the caller's
+// resulting preferences.
+func (a *app) handlePreferences(interaction Interaction, opts discord.RenderOptions) interactionResponse {
+	options := interaction.Data.Options
+	userID := interaction.userID()
+	prefs, err := postgres.SubscriberPreferences(a.db, userID)
+	if err != nil || userID == "" {
+		log.Printf("Error handling /preferences: %v", err)
+		return ephemeralReply(opts.T("cmd_error"), nil)
+	}
+	if o := option(options, "quiet_hours"); o != nil {
+		if prefs.QuietStart, prefs.QuietEnd, err = parseQuietHours(o.stringValue()); err != nil {
+			return ephemeralReply(opts.T("prefs_bad_quiet_hours"), nil)
+		}
+	}
+	if o := option(options, "timezone"); o != nil {
+		prefs.Timezone = strings.TrimSpace(o.stringValue())
+		if _, err := time.LoadLocation(prefs.Timezone); err != nil {
+			return ephemeralReply(opts.T("prefs_bad_timezone"), nil)
+		}
+	}
+	if o := option(options, "categories"); o != nil {
+		if prefs.Categories = parseEventTypes(o.stringValue()); len(prefs.Categories) == 1 && prefs.Categories[0] == "all" {
+			prefs.Categories = []string{}
+		}
+	}
+	if o := option(options, "email"); o != nil {
+		if prefs.Email, err = parseEmail(o.stringValue()); err != nil {
+			return ephemeralReply(opts.T("prefs_bad_email"), nil)
+		}
+	}
+	if o := option(options, "delivery"); o != nil {
+		prefs.Digest = o.stringValue() == "digest"
+	}
+	if prefs.Digest && prefs.Email == "" {
+		return ephemeralReply(opts.T("prefs_digest_needs_email"), nil)
+	}
+	if len(options) > 0 {
+		if err := postgres.SaveSubscriberPreferences(a.db, userID, prefs); err != nil {
+			log.Printf("Error handling /preferences: %v", err)
+			return ephemeralReply(opts.T("cmd_error"), nil)
+		}
+	}
+
+	quiet, categories := opts.T("prefs_none"), opts.T("prefs_all")
+	if prefs.QuietStart != "" {
+		quiet = fmt.Sprintf("%s–%s (%s)", prefs.QuietStart, prefs.QuietEnd, a.config.Current().Location(RouteConfig{Timezone: prefs.Timezone}))
+	}
+	if len(prefs.Categories) > 0 {
+		categories = discord.SanitizeFeedText(strings.Join(prefs.Categories, ", "))
+	}
+	delivery := opts.T("prefs_realtime")
+	if prefs.Digest {
+		delivery = fmt.Sprintf(opts.T("prefs_digest"), discord.SanitizeFeedText(prefs.Email))
+	}
+	summary := fmt.Sprintf(opts.T("prefs_summary"), quiet, categories) + "\n" + fmt.Sprintf(opts.T("prefs_delivery"), delivery)
+	return ephemeralReply(summary, nil)
+}
+
+func ephemeralReply(content string, embeds []discord.Embed) interactionResponse {
+	return interactionResponse{
+		Type: responseChannelMessage,
+		Data: &interactionResponseData{
+			Content:         content,
+			Embeds:          embeds,
+			Flags:           messageFlagEphemeral,
+			AllowedMentions: &discord.AllowedMentions{Parse: []string{}},
+		},
+	}
+}
+
+// queryIncidents loads incidents and their statuses using the given WHERE/ORDER/LIMIT clause.
+func queryIncidents(cfg *Config, db *sql.DB, clause string, args ...interface{}) ([]incident.Incident, []string, error) {
+	rows, err := db.Query(cfg.SQL("SELECT {id}, {source}, {source_id}, {event_type}, {address}, {latitude}, {longitude}, {timestamp}, {details}, {status}, {is_test}, {tags} FROM {incidents} "+clause), args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error querying incidents: %w", err)
+	}
+	defer rows.Close()
+
+	var incidents []incident.Incident
+	var statuses []string
+	for rows.Next() {
+		var i incident.Incident
+		var status string
+		if err := rows.Scan(&i.ID, &i.Source, &i.SourceID, &i.EventType, &i.Address, &i.Latitude, &i.Longitude, &i.Timestamp, &i.Details, &status, &i.IsTest, pq.Array(&i.Tags)); err != nil {
+			return nil, nil, fmt.Errorf("error scanning incident row: %w", err)
+		}
+		incidents = append(incidents, i)
+		statuses = append(statuses, status)
+	}
+	return incidents, statuses, rows.Err()
+}
+
+// buildIncidentListEmbed renders a compact list of incidents, one field each.
+func buildIncidentListEmbed(title string, incidents []incident.Incident, statuses []string, opts discord.RenderOptions) discord.Embed {
+	embed := discord.Embed{
+		Title:     discord.Truncate(title, discord.MaxEmbedTitle),
+		Color:     3447003, // Blue
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+	if len(incidents) == 0 {
+		embed.Fields = []discord.EmbedField{{Name: discord.ZeroWidthSpace, Value: opts.T("cmd_no_results")}}
+		return embed
+	}
+	for idx, i := range incidents {
+		value := fmt.Sprintf("%s\n%s (%s)", discord.SanitizeFeedText(i.Address), opts.FormatLocalTime(i.Timestamp), opts.T("status_"+statuses[idx]))
+		embed.Fields = append(embed.Fields, discord.EmbedField{
+			Name:  discord.Truncate(fmt.Sprintf("%s — %s", discord.SanitizeFeedText(i.EventType), i.Source), discord.MaxFieldName),
+			Value: discord.Truncate(value, discord.MaxFieldValue),
+		})
+	}
+	return embed
+}
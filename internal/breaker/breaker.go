@@ -0,0 +1,124 @@
+// Package breaker keeps a flaky host from slowing every request down: after Threshold failed
+// requests in a row to a host, further requests fail at once until Cooldown has passed, when a
+// single trial request decides whether the host is back.
+package breaker
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Threshold failures in a row open a host's breaker for Cooldown.
+const (
+	Threshold = 3
+	Cooldown  = 5 * time.Minute
+)
+
+// ErrOpen is returned for requests to a host whose breaker is open.
+type ErrOpen struct {
+	Host  string
+	Until time.Time
+}
+
+func (e *ErrOpen) Error() string {
+	return fmt.Sprintf("skipping %s after repeated failures until %s", e.Host, e.Until.Format(time.Kitchen))
+}
+
+type state struct {
+	failures  int
+	openUntil time.Time
+	trial     bool // A half-open trial request is in flight.
+}
+
+var (
+	mu    sync.Mutex
+	hosts = make(map[string]*state)
+)
+
+// Transport is an http.RoundTripper that counts connection errors and 5xx responses against
+// the request's host.
+type Transport struct {
+	Base http.RoundTripper // Defaults to http.DefaultTransport.
+}
+
+// Client returns an HTTP client with the given timeout whose requests go through a breaker.
+func Client(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: Transport{}}
+}
+
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if err := allow(host); err != nil {
+		return nil, err
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if req.Context().Err() != nil {
+		// The caller gave up, which says nothing about the host.
+		return resp, err
+	}
+	record(host, err == nil && resp.StatusCode < 500)
+	return resp, err
+}
+
+// allow admits a request unless the host's breaker is open. Once the cooldown has passed, one
+// request is let through as a trial.
+func allow(host string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	s := hosts[host]
+	if s == nil || s.failures < Threshold {
+		return nil
+	}
+	if s.trial || time.Now().Before(s.openUntil) {
+		return &ErrOpen{Host: host, Until: s.openUntil}
+	}
+	s.trial = true
+	return nil
+}
+
+// record counts a request's outcome, opening the breaker on the Threshold-th failure in a row
+// or a failed trial, and closing it on any success.
+func record(host string, ok bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	s := hosts[host]
+	if ok {
+		if s != nil && s.failures >= Threshold {
+			log.Printf("%s is responding again.", host)
+		}
+		delete(hosts, host)
+		return
+	}
+	if s == nil {
+		s = &state{}
+		hosts[host] = s
+	}
+	s.failures++
+	if s.failures >= Threshold {
+		if !s.trial {
+			log.Printf("Warning: %s failed %d times in a row; skipping it for %s.", host, s.failures, Cooldown)
+		}
+		s.openUntil = time.Now().Add(Cooldown)
+		s.trial = false
+	}
+}
+
+// Open lists the hosts whose breakers are currently open.
+func Open() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	var open []string
+	for host, s := range hosts {
+		if s.failures >= Threshold && time.Now().Before(s.openUntil) {
+			open = append(open, host)
+		}
+	}
+	return open
+}
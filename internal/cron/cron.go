@@ -0,0 +1,206 @@
+// Package cron parses job schedules: standard five-field cron expressions ("*/15 6-22 * * mon-fri"),
+// the @hourly, @daily, @weekly, @monthly and @yearly shorthands, and "@every <duration>".
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule gives the time a job next runs.
+type Schedule interface {
+	// Next returns the first run strictly after t, in t's location.
+	Next(t time.Time) time.Time
+}
+
+// every runs at a fixed interval.
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// fields is a cron expression, as one bit per allowed value of each field.
+type fields struct {
+	minute, hour, dom, month, dow uint64
+	anyDOM, anyDOW                bool // Whether the day fields were "*", which changes how they combine.
+}
+
+var shorthands = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+	dayNames   = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+)
+
+// Parse parses a schedule.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || d < time.Second {
+			return nil, fmt.Errorf("invalid schedule %q: @every needs a duration of at least 1s", spec)
+		}
+		return every(d), nil
+	}
+	if expr, ok := shorthands[strings.ToLower(spec)]; ok {
+		spec = expr
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: want five fields (minute hour day month weekday), a shorthand such as @daily, or @every <duration>", spec)
+	}
+	var f fields
+	var err error
+	if f.minute, err = parseField(parts[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: minute: %w", spec, err)
+	}
+	if f.hour, err = parseField(parts[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: hour: %w", spec, err)
+	}
+	if f.dom, err = parseField(parts[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: day of month: %w", spec, err)
+	}
+	if f.month, err = parseField(parts[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: month: %w", spec, err)
+	}
+	if f.dow, err = parseField(parts[4], 0, 7, dayNames); err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: day of week: %w", spec, err)
+	}
+	if f.dow&(1<<7) != 0 { // 7 is another name for Sunday.
+		f.dow |= 1
+	}
+	f.anyDOM, f.anyDOW = parts[2] == "*", parts[4] == "*"
+	return f, nil
+}
+
+// parseField parses a comma-separated list of values, ranges ("1-5") and steps ("*/10",
+// "8-18/2") between low and high.
+func parseField(s string, low, high int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(s, ",") {
+		rng, stepText, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepText)
+			}
+			step = n
+		}
+		lo, hi := low, high
+		if rng != "*" {
+			first, last, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = fieldValue(first, low, high, names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = fieldValue(last, low, high, names); err != nil {
+					return 0, err
+				}
+				if hi < lo {
+					return 0, fmt.Errorf("invalid range %q", rng)
+				}
+			} else if hasStep {
+				hi = high
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func fieldValue(s string, low, high int, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < low || v > high {
+		return 0, fmt.Errorf("%q is not between %d and %d", s, low, high)
+	}
+	return v, nil
+}
+
+// everyHour is the hour field of an expression that runs every hour.
+const everyHour = 1<<24 - 1
+
+// Next finds the next matching minute, searching up to five years ahead; an expression that
+// never matches, such as February 30th, returns the zero time.
+//
+// Across daylight saving changes it follows cron. An expression that runs every hour keeps to
+// elapsed time. One restricted to certain hours keeps to the wall clock instead: it runs once
+// in an hour the clocks repeat, and at a time the clocks skip it runs as they jump.
+func (f fields) Next(t time.Time) time.Time {
+	if f.hour == everyHour {
+		return f.search(t)
+	}
+	loc := t.Location()
+	// Search wall-clock times in UTC, where every day has 24 hours.
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+	for {
+		if wall = f.search(wall); wall.IsZero() {
+			return wall
+		}
+		next := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), 0, 0, loc)
+		if got := time.Date(next.Year(), next.Month(), next.Day(), next.Hour(), next.Minute(), 0, 0, time.UTC); !got.Equal(wall) {
+			// The clocks skipped this time, and time.Date moved it to one side of the jump.
+			start, end := next.ZoneBounds()
+			if next = start; got.Before(wall) {
+				next = end
+			}
+		}
+		if next.After(t) {
+			return next
+		}
+	}
+}
+
+// search steps through t's location from the minute after t to the first that matches.
+func (f fields) search(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if f.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !f.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if f.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if f.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches follows cron: when both day fields are restricted, either may match.
+func (f fields) dayMatches(t time.Time) bool {
+	dom := f.dom&(1<<uint(t.Day())) != 0
+	dow := f.dow&(1<<uint(t.Weekday())) != 0
+	if f.anyDOM || f.anyDOW {
+		return dom && dow
+	}
+	return dom || dow
+}
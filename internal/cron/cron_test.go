@@ -0,0 +1,107 @@
+package cron
+
+import (
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata" // So the DST cases don't depend on the system's zoneinfo.
+)
+
+const layout = "2006-01-02 15:04 MST Mon"
+
+func TestNext(t *testing.T) {
+	eastern, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04:05", s, eastern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want []string // The runs after from, each following the one before.
+	}{
+		{"every minute is strictly after", "* * * * *", at("2024-06-05 10:00:00"), []string{"2024-06-05 10:01 EDT Wed", "2024-06-05 10:02 EDT Wed"}},
+		{"seconds are dropped", "* * * * *", at("2024-06-05 10:00:59"), []string{"2024-06-05 10:01 EDT Wed"}},
+		{"weekday hours", "*/15 6-22 * * mon-fri", at("2024-06-07 22:40:00"), []string{"2024-06-07 22:45 EDT Fri", "2024-06-10 06:00 EDT Mon", "2024-06-10 06:15 EDT Mon"}},
+		{"stepped range", "0 8-18/4 * * *", at("2024-06-05 09:00:00"), []string{"2024-06-05 12:00 EDT Wed", "2024-06-05 16:00 EDT Wed", "2024-06-06 08:00 EDT Thu"}},
+		{"step from a value", "5/20 9 * * *", at("2024-06-05 09:00:00"), []string{"2024-06-05 09:05 EDT Wed", "2024-06-05 09:25 EDT Wed", "2024-06-05 09:45 EDT Wed", "2024-06-06 09:05 EDT Thu"}},
+		{"lists and names", "0 0 1 jan,JUL *", at("2024-02-01 00:00:00"), []string{"2024-07-01 00:00 EDT Mon", "2025-01-01 00:00 EST Wed"}},
+		{"31st skips short months", "0 0 31 * *", at("2024-01-31 00:00:00"), []string{"2024-03-31 00:00 EDT Sun", "2024-05-31 00:00 EDT Fri"}},
+		{"leap day", "0 12 29 feb *", at("2024-03-01 00:00:00"), []string{"2028-02-29 12:00 EST Tue"}},
+		{"February 30th never comes", "0 0 30 2 *", at("2024-01-01 00:00:00"), []string{"0001-01-01 00:00 UTC Mon"}},
+		{"day of month or of week", "0 9 13 * fri", at("2024-09-01 00:00:00"), []string{"2024-09-06 09:00 EDT Fri", "2024-09-13 09:00 EDT Fri", "2024-09-20 09:00 EDT Fri", "2024-09-27 09:00 EDT Fri", "2024-10-04 09:00 EDT Fri", "2024-10-11 09:00 EDT Fri", "2024-10-13 09:00 EDT Sun"}},
+		{"day of month with any weekday", "0 9 13 * *", at("2024-09-01 00:00:00"), []string{"2024-09-13 09:00 EDT Fri", "2024-10-13 09:00 EDT Sun"}},
+		{"day of week with any day of month", "0 9 * * 5", at("2024-09-01 00:00:00"), []string{"2024-09-06 09:00 EDT Fri", "2024-09-13 09:00 EDT Fri"}},
+		{"stepped day of month restricts", "0 0 */10 * mon", at("2024-09-01 00:00:00"), []string{"2024-09-02 00:00 EDT Mon", "2024-09-09 00:00 EDT Mon", "2024-09-11 00:00 EDT Wed"}},
+		{"7 is Sunday", "0 8 * * 7", at("2024-06-05 00:00:00"), []string{"2024-06-09 08:00 EDT Sun"}},
+		{"weekday range ending on Sunday as 7", "0 8 * * fri-7", at("2024-06-05 00:00:00"), []string{"2024-06-07 08:00 EDT Fri", "2024-06-08 08:00 EDT Sat", "2024-06-09 08:00 EDT Sun", "2024-06-14 08:00 EDT Fri"}},
+		{"@hourly", "@hourly", at("2024-06-05 10:30:00"), []string{"2024-06-05 11:00 EDT Wed", "2024-06-05 12:00 EDT Wed"}},
+		{"@weekly", "@weekly", at("2024-06-05 10:30:00"), []string{"2024-06-09 00:00 EDT Sun", "2024-06-16 00:00 EDT Sun"}},
+		{"@yearly", "@Yearly", at("2024-06-05 10:30:00"), []string{"2025-01-01 00:00 EST Wed"}},
+		{"@every", "@every 90m", at("2024-06-05 10:30:15"), []string{"2024-06-05 12:00 EDT Wed", "2024-06-05 13:30 EDT Wed"}},
+
+		// Daylight saving: on 2024-03-10 clocks jump from 02:00 EST to 03:00 EDT, and on
+		// 2024-11-03 from 02:00 EDT back to 01:00 EST.
+		{"time skipped in spring runs as the clocks jump", "30 2 * * *", at("2024-03-09 03:00:00"), []string{"2024-03-10 03:00 EDT Sun", "2024-03-11 02:30 EDT Mon"}},
+		{"times on both sides of the jump", "30 1-3 * * *", at("2024-03-10 01:00:00"), []string{"2024-03-10 01:30 EST Sun", "2024-03-10 03:00 EDT Sun", "2024-03-10 03:30 EDT Sun", "2024-03-11 01:30 EDT Mon"}},
+		{"hourly keeps to elapsed time in spring", "30 * * * *", at("2024-03-10 01:00:00"), []string{"2024-03-10 01:30 EST Sun", "2024-03-10 03:30 EDT Sun", "2024-03-10 04:30 EDT Sun"}},
+		{"every 20 minutes in spring", "*/20 * * * *", at("2024-03-10 01:30:00"), []string{"2024-03-10 01:40 EST Sun", "2024-03-10 03:00 EDT Sun", "2024-03-10 03:20 EDT Sun"}},
+		{"repeated time in autumn runs once", "30 1 * * *", at("2024-11-03 00:00:00"), []string{"2024-11-03 01:30 EDT Sun", "2024-11-04 01:30 EST Mon"}},
+		{"fixed hour skips the repeat from inside it", "45 1 * * *", at("2024-11-03 01:50:00").Add(time.Hour), []string{"2024-11-04 01:45 EST Mon"}},
+		{"hourly runs in both repeated hours", "0 * * * *", at("2024-11-03 00:30:00"), []string{"2024-11-03 01:00 EDT Sun", "2024-11-03 01:00 EST Sun", "2024-11-03 02:00 EST Sun"}},
+		{"daily across the autumn change", "@daily", at("2024-11-02 12:00:00"), []string{"2024-11-03 00:00 EDT Sun", "2024-11-04 00:00 EST Mon"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.spec, err)
+			}
+			next := tt.from
+			for n, want := range tt.want {
+				next = schedule.Next(next)
+				if got := next.Format(layout); got != want {
+					t.Fatalf("run %d after %s = %s, want %s", n+1, tt.from.Format(layout), got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"", "want five fields"},
+		{"* * * *", "want five fields"},
+		{"* * * * * *", "want five fields"},
+		{"@fortnightly", "want five fields"},
+		{"60 * * * *", "minute: \"60\" is not between 0 and 59"},
+		{"* 24 * * *", "hour: \"24\" is not between 0 and 23"},
+		{"* * 0 * *", "day of month: \"0\" is not between 1 and 31"},
+		{"* * 32 * *", "day of month"},
+		{"* * * 13 *", "month: \"13\" is not between 1 and 12"},
+		{"* * * smarch *", "month"},
+		{"* * * * 8", "day of week: \"8\" is not between 0 and 7"},
+		{"* * * * funday", "day of week"},
+		{"*/0 * * * *", "invalid step \"0\""},
+		{"*/x * * * *", "invalid step"},
+		{"5-1 * * * *", "invalid range \"5-1\""},
+		{"1,,2 * * * *", "minute"},
+		{"@every 500ms", "at least 1s"},
+		{"@every soon", "at least 1s"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(tt.spec); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Parse(%q) error = %v, want %q", tt.spec, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package discordtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// UpdateEnv names the environment variable that makes MatchFixture rewrite fixtures
+// from the recorded requests instead of comparing against them.
+const UpdateEnv = "UPDATE_FIXTURES"
+
+// RecordFixture writes every request received so far to path as indented JSON.
+func (s *Server) RecordFixture(path string) error {
+	data, err := s.fixtureJSON()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	return nil
+}
+
+// MatchFixture compares the requests received so far with a fixture written by RecordFixture.
+// With UPDATE_FIXTURES set it records the fixture instead.
+func (s *Server) MatchFixture(path string) error {
+	if os.Getenv(UpdateEnv) != "" {
+		return s.RecordFixture(path)
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read fixture (set %s=1 to record it): %w", UpdateEnv, err)
+	}
+	got, err := s.fixtureJSON()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(bytes.TrimSpace(got), bytes.TrimSpace(want)) {
+		return fmt.Errorf("requests do not match fixture %s (set %s=1 to update it)\n--- got:\n%s", path, UpdateEnv, got)
+	}
+	return nil
+}
+
+// fixtureJSON renders the recorded requests, payloads included, as indented JSON.
+func (s *Server) fixtureJSON() ([]byte, error) {
+	data, err := json.MarshalIndent(s.Requests(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding fixture: %w", err)
+	}
+	return append(data, '\n'), nil
+}
@@ -0,0 +1,228 @@
+// Package discordtest runs a fake Discord API on an httptest server so sinks can be
+// integration-tested without posting to real channels. It accepts webhook and bot-token
+// requests, keeps the messages it was sent, and records every request for comparison
+// against a fixture file.
+package discordtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/mtickle/unity-alerts/sink/discord"
+)
+
+// Request is one call the fake server received.
+type Request struct {
+	Method  string          `json:"method"`
+	Path    string          `json:"path"` // Without the API version prefix or query string.
+	Payload json.RawMessage `json:"payload,omitempty"`
+	Files   []File          `json:"files,omitempty"`
+}
+
+// File is an attachment uploaded with a multipart message.
+type File struct {
+	Field string `json:"field"`
+	Name  string `json:"name"`
+	Size  int    `json:"size"`
+}
+
+// failure is a canned error response for the next request.
+type failure struct {
+	status int
+	body   string
+}
+
+// Server is a fake Discord API. Messages get sequential IDs starting at 1.
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	requests  []Request
+	messages  map[string]map[string]json.RawMessage
+	reactions map[string][]discord.User
+	failures  []failure
+	nextID    int
+}
+
+// NewServer starts a fake Discord API. Call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		messages:  make(map[string]map[string]json.RawMessage),
+		reactions: make(map[string][]discord.User),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// WebhookURL is an incoming webhook URL served by the fake.
+func (s *Server) WebhookURL() string {
+	return s.URL + "/api/webhooks/1/token"
+}
+
+// APIBase is the bot REST endpoint served by the fake, for discord.APIBase.
+func (s *Server) APIBase() string {
+	return s.URL + "/api/v10"
+}
+
+// Requests returns every request received so far, in order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Message returns the current JSON of a message, after any edits.
+func (s *Server) Message(id string) (json.RawMessage, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.messages[id]
+	if !ok {
+		return nil, false
+	}
+	raw, _ := json.Marshal(m)
+	return raw, true
+}
+
+// FailNext makes the next request fail with status and body, e.g. a 429 with retry_after.
+// Calls queue up, one failure per request.
+func (s *Server) FailNext(status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, failure{status, body})
+}
+
+// SetReactions sets the users who reacted to a message, for any emoji.
+func (s *Server) SetReactions(messageID string, users ...discord.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reactions[messageID] = users
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	req, err := readRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+
+	if len(s.failures) > 0 {
+		f := s.failures[0]
+		s.failures = s.failures[1:]
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(f.status)
+		io.WriteString(w, f.body)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(req.Path, "/"), "/")
+	switch {
+	// POST /webhooks/{id}/{token} and POST /channels/{id}/messages
+	case r.Method == "POST" && (len(parts) == 3 && parts[0] == "webhooks" || len(parts) == 3 && parts[0] == "channels" && parts[2] == "messages"):
+		s.nextID++
+		id := fmt.Sprint(s.nextID)
+		message := map[string]json.RawMessage{}
+		json.Unmarshal(req.Payload, &message)
+		message["id"], _ = json.Marshal(id)
+		s.messages[id] = message
+		writeJSON(w, message)
+
+	// PATCH/GET /webhooks/{id}/{token}/messages/{mid} and /channels/{id}/messages/{mid}
+	case len(parts) == 5 && parts[0] == "webhooks" && parts[3] == "messages" || len(parts) == 4 && parts[0] == "channels" && parts[2] == "messages":
+		id := parts[len(parts)-1]
+		message, ok := s.messages[id]
+		if !ok {
+			http.Error(w, `{"message": "Unknown Message", "code": 10008}`, http.StatusNotFound)
+			return
+		}
+		if r.Method == "PATCH" {
+			var edit map[string]json.RawMessage
+			json.Unmarshal(req.Payload, &edit)
+			for k, v := range edit {
+				message[k] = v
+			}
+		}
+		writeJSON(w, message)
+
+	// GET /channels/{id}/messages/{mid}/reactions/{emoji}
+	case r.Method == "GET" && len(parts) == 6 && parts[4] == "reactions":
+		users := s.reactions[parts[3]]
+		if users == nil {
+			users = []discord.User{}
+		}
+		writeJSON(w, users)
+
+	// POST /channels/{id}/messages/{mid}/crosspost
+	case r.Method == "POST" && len(parts) == 5 && parts[4] == "crosspost":
+		writeJSON(w, s.messages[parts[3]])
+
+	// PUT/DELETE /channels/{id}/pins/{mid}
+	case len(parts) == 4 && parts[2] == "pins":
+		w.WriteHeader(http.StatusNoContent)
+
+	// POST /users/@me/channels opens a DM channel named after the recipient.
+	case r.Method == "POST" && req.Path == "/users/@me/channels":
+		var body struct {
+			RecipientID string `json:"recipient_id"`
+		}
+		json.Unmarshal(req.Payload, &body)
+		writeJSON(w, map[string]string{"id": "dm-" + body.RecipientID})
+
+	default:
+		http.Error(w, `{"message": "404: Not Found", "code": 0}`, http.StatusNotFound)
+	}
+}
+
+// readRequest captures the JSON body, or the payload_json and files of a multipart upload.
+func readRequest(r *http.Request) (Request, error) {
+	path := strings.TrimPrefix(r.URL.Path, "/api")
+	path = strings.TrimPrefix(path, "/v10")
+	req := Request{Method: r.Method, Path: path}
+
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return req, err
+		}
+		if len(body) > 0 {
+			req.Payload = body
+		}
+		return req, nil
+	}
+
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return req, nil
+		}
+		if err != nil {
+			return req, fmt.Errorf("error reading multipart body: %w", err)
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return req, err
+		}
+		if part.FormName() == "payload_json" {
+			req.Payload = data
+		} else {
+			req.Files = append(req.Files, File{Field: part.FormName(), Name: part.FileName(), Size: len(data)})
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,130 @@
+// Package parquet writes flat tables as Snappy-compressed Parquet files with
+// github.com/parquet-go/parquet-go. Every column is optional, so nil values are nulls.
+package parquet
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Type is a column's logical type.
+type Type int
+
+const (
+	String    Type = iota // Go string.
+	Int64                 // Go int64.
+	Double                // Go float64.
+	Bool                  // Go bool.
+	Timestamp             // Go time.Time, stored as milliseconds since the epoch.
+)
+
+// Column describes one column of the table.
+type Column struct {
+	Name string
+	Type Type
+}
+
+// Write writes rows, each holding one value per column (nil for null), as a Parquet file.
+func Write(w io.Writer, columns []Column, rows [][]interface{}) error {
+	schema := parquet.NewSchema("incidents", newTable(columns))
+	pw := parquet.NewWriter(w, schema, parquet.Compression(&parquet.Snappy))
+	for r, row := range rows {
+		if len(row) != len(columns) {
+			return fmt.Errorf("row %d has %d values, want %d", r, len(row), len(columns))
+		}
+		values := make(parquet.Row, len(columns))
+		for c, v := range row {
+			value, err := columnValue(columns[c], v)
+			if err != nil {
+				return fmt.Errorf("row %d: %w", r, err)
+			}
+			values[c] = value.Level(0, 1, c)
+			if v == nil {
+				values[c] = value.Level(0, 0, c)
+			}
+		}
+		if _, err := pw.WriteRows([]parquet.Row{values}); err != nil {
+			return fmt.Errorf("failed to write row %d: %w", r, err)
+		}
+	}
+	if err := pw.Close(); err != nil {
+		return fmt.Errorf("failed to write parquet file: %w", err)
+	}
+	return nil
+}
+
+// columnValue converts a row's value for a column, checking its Go type.
+func columnValue(col Column, v interface{}) (parquet.Value, error) {
+	if v == nil {
+		return parquet.NullValue(), nil
+	}
+	var ok bool
+	switch col.Type {
+	case String:
+		_, ok = v.(string)
+	case Int64:
+		_, ok = v.(int64)
+	case Double:
+		_, ok = v.(float64)
+	case Bool:
+		_, ok = v.(bool)
+	case Timestamp:
+		var t time.Time
+		if t, ok = v.(time.Time); ok {
+			return parquet.Int64Value(t.UnixMilli()), nil
+		}
+	}
+	if !ok {
+		return parquet.Value{}, fmt.Errorf("column %s: unexpected value of type %T", col.Name, v)
+	}
+	return parquet.ValueOf(v), nil
+}
+
+// node is the Parquet schema node of a column type.
+func node(t Type) parquet.Node {
+	switch t {
+	case Int64:
+		return parquet.Int(64)
+	case Double:
+		return parquet.Leaf(parquet.DoubleType)
+	case Bool:
+		return parquet.Leaf(parquet.BooleanType)
+	case Timestamp:
+		return parquet.Timestamp(parquet.Millisecond)
+	}
+	return parquet.String()
+}
+
+// table is the schema's root: a group whose fields keep the order of the columns, where
+// parquet.Group would sort them by name.
+type table struct {
+	parquet.Group
+	fields []parquet.Field
+}
+
+func newTable(columns []Column) table {
+	t := table{Group: parquet.Group{}}
+	for _, col := range columns {
+		n := parquet.Optional(node(col.Type))
+		t.Group[col.Name] = n
+		t.fields = append(t.fields, field{Node: n, name: col.Name})
+	}
+	return t
+}
+
+func (t table) Fields() []parquet.Field { return t.fields }
+
+// field is a named column of the table. Rows are written as parquet.Row values rather than
+// deconstructed from Go values, so Value is never called.
+type field struct {
+	parquet.Node
+	name string
+}
+
+func (f field) Name() string { return f.name }
+
+func (f field) Value(base reflect.Value) reflect.Value { return reflect.Value{} }
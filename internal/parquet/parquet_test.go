@@ -0,0 +1,143 @@
+package parquet
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// readFile reads a file back with parquet-go, as the columns and rows it was written from.
+func readFile(t *testing.T, file []byte) ([]Column, [][]interface{}) {
+	t.Helper()
+	f, err := parquet.OpenFile(bytes.NewReader(file), int64(len(file)))
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	var columns []Column
+	for _, field := range f.Schema().Fields() {
+		if !field.Optional() {
+			t.Errorf("column %s is not optional", field.Name())
+		}
+		col := Column{Name: field.Name()}
+		switch typ := field.Type(); {
+		case strings.HasPrefix(typ.String(), "TIMESTAMP"):
+			col.Type = Timestamp
+		case typ.Kind() == parquet.Int64:
+			col.Type = Int64
+		case typ.Kind() == parquet.Double:
+			col.Type = Double
+		case typ.Kind() == parquet.Boolean:
+			col.Type = Bool
+		case typ.String() == "STRING":
+			col.Type = String
+		default:
+			t.Fatalf("column %s has unexpected type %v", field.Name(), typ)
+		}
+		columns = append(columns, col)
+	}
+
+	rows := make([]parquet.Row, f.NumRows())
+	reader := parquet.NewReader(f)
+	defer reader.Close()
+	if n, err := reader.ReadRows(rows); n != len(rows) {
+		t.Fatalf("read %d of %d rows: %v", n, len(rows), err)
+	}
+	var out [][]interface{}
+	for _, row := range rows {
+		values := make([]interface{}, len(columns))
+		for _, v := range row {
+			if v.IsNull() {
+				continue
+			}
+			switch c := v.Column(); columns[c].Type {
+			case String:
+				values[c] = v.String()
+			case Int64:
+				values[c] = v.Int64()
+			case Double:
+				values[c] = v.Double()
+			case Bool:
+				values[c] = v.Boolean()
+			case Timestamp:
+				values[c] = time.UnixMilli(v.Int64()).UTC()
+			}
+		}
+		out = append(out, values)
+	}
+	return columns, out
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	columns := []Column{
+		{Name: "id", Type: Int64},
+		{Name: "address", Type: String},
+		{Name: "latitude", Type: Double},
+		{Name: "timestamp", Type: Timestamp},
+		{Name: "is_test", Type: Bool},
+	}
+	base := time.Date(2024, 3, 10, 6, 30, 0, 0, time.UTC)
+	var rows [][]interface{}
+	for n := 0; n < 21; n++ {
+		row := []interface{}{int64(n + 1), fmt.Sprintf("%d block of Hillsborough St", n*100), 35.78 + float64(n)/1000, base.Add(time.Duration(n) * time.Minute), n%3 == 0}
+		if n%4 == 1 {
+			row[2] = nil // Unlocated incidents have no coordinates.
+		}
+		if n == 7 {
+			row[1] = "Calle Peñasco ✓"
+		}
+		rows = append(rows, row)
+	}
+	rows = append(rows, []interface{}{nil, nil, nil, nil, nil})
+
+	var buf bytes.Buffer
+	if err := Write(&buf, columns, rows); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	gotColumns, gotRows := readFile(t, buf.Bytes())
+	if !reflect.DeepEqual(gotColumns, columns) {
+		t.Errorf("columns = %v, want %v", gotColumns, columns)
+	}
+	if len(gotRows) != len(rows) {
+		t.Fatalf("read %d rows, want %d", len(gotRows), len(rows))
+	}
+	for n := range rows {
+		if !reflect.DeepEqual(gotRows[n], rows[n]) {
+			t.Errorf("row %d = %v, want %v", n, gotRows[n], rows[n])
+		}
+	}
+}
+
+func TestWriteEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, []Column{{Name: "id", Type: Int64}}, nil); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	columns, rows := readFile(t, buf.Bytes())
+	if len(columns) != 1 || len(rows) != 0 {
+		t.Errorf("read %d columns and %d rows, want 1 and 0", len(columns), len(rows))
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	columns := []Column{{Name: "id", Type: Int64}, {Name: "address", Type: String}}
+	tests := []struct {
+		name string
+		rows [][]interface{}
+	}{
+		{"short row", [][]interface{}{{int64(1)}}},
+		{"wrong type", [][]interface{}{{1, "100 block of Main St"}}},
+		{"wrong type for a timestamp", [][]interface{}{{int64(1), time.Now()}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Write(&bytes.Buffer{}, columns, tt.rows); err == nil {
+				t.Error("Write succeeded, want an error")
+			}
+		})
+	}
+}
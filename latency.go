@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// latencyWindow is the span the SLO check and the metrics endpoint summarize.
+const latencyWindow = time.Hour
+
+// insertedAtWarning logs once when the incidents table has no insertion time column.
+var insertedAtWarning sync.Once
+
+// loadInsertedTimes records when the ingestor stored each pending incident, from the optional
+// created_at column. Without it, latency is only measured from the incident's own timestamp.
+func (a *app) loadInsertedTimes(cfg *Config, pending []*pendingIncident) {
+	if len(pending) == 0 {
+		return
+	}
+	byID := make(map[int]*pendingIncident)
+	var ids []int64
+	for _, p := range pending {
+		byID[p.incident.ID] = p
+		ids = append(ids, int64(p.incident.ID))
+	}
+	rows, err := a.db.Query(cfg.SQL("SELECT {id}, {created_at} FROM {incidents} WHERE {id} = ANY($1)"), pq.Array(ids))
+	if err != nil {
+		insertedAtWarning.Do(func() {
+			log.Printf("Warning: could not read incident insertion times; latency is measured from incident timestamps: %v", err)
+		})
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var insertedAt time.Time
+		if err := rows.Scan(&id, &insertedAt); err != nil {
+			log.Printf("Warning: error scanning incident insertion time: %v", err)
+			continue
+		}
+		if p := byID[id]; p != nil {
+			p.insertedAt = insertedAt
+		}
+	}
+}
+
+// checkLatencySLO warns when the 95th percentile pipeline latency over the last hour exceeds
+// latency_slo, and again once it recovers.
+func (a *app) checkLatencySLO(cfg *Config) {
+	if cfg.LatencySLO == "" {
+		return
+	}
+	slo, _ := time.ParseDuration(cfg.LatencySLO)
+	latency, err := postgres.DeliveryLatency(a.db, time.Now().Add(-latencyWindow))
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+	breached := latency.Alerts > 0 && latency.Pipeline.P95 > slo
+	if breached == a.sloBreached {
+		return
+	}
+	a.sloBreached = breached
+	if !breached {
+		log.Printf("Alert latency is back within the %s SLO (p95 %s).", slo, latency.Pipeline.P95)
+		return
+	}
+	err = fmt.Errorf("alert pipeline is falling behind: p95 latency %s over the last %s exceeds the %s SLO", latency.Pipeline.P95, latencyWindow, slo)
+	log.Printf("Warning: %v", err)
+	a.reporter.Report(err, "warning", map[string]string{"p50": latency.Pipeline.P50.String(), "p95": latency.Pipeline.P95.String()})
+}
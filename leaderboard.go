@@ -0,0 +1,248 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/sink/discord"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// leaderboardSize is how many roads and locations the leaderboard lists.
+const leaderboardSize = 10
+
+// locationRadiusMeters is how close incidents must be to count as the same location.
+const locationRadiusMeters = 150
+
+// LeaderboardConfig posts a weekly report of the roads and locations with the most incidents.
+type LeaderboardConfig struct {
+	Route string `json:"route"`
+	Day   string `json:"day,omitempty"` // Day of the week to post on, "mon" … "sun" (default "mon").
+	At    string `json:"at,omitempty"`  // Time of day in the route's timezone, "15:04" format (default 08:00).
+}
+
+func (l *LeaderboardConfig) validate(c *Config) error {
+	if l == nil {
+		return nil
+	}
+	if _, ok := c.Route(l.Route); !ok {
+		return fmt.Errorf("leaderboard.route: unknown route %q", l.Route)
+	}
+	if _, ok := weekdays[strings.ToLower(l.Day)]; l.Day != "" && !ok {
+		return fmt.Errorf("leaderboard.day: unknown day %q", l.Day)
+	}
+	if l.At != "" {
+		if _, err := time.Parse("15:04", l.At); err != nil {
+			return fmt.Errorf("leaderboard.at: invalid time %q", l.At)
+		}
+	}
+	return nil
+}
+
+// Leaderboard ranks the roads and locations with the most incidents in a period.
+type Leaderboard struct {
+	From      time.Time       `json:"from"`
+	To        time.Time       `json:"to"`
+	Roads     []RoadCount     `json:"roads"`
+	Locations []LocationCount `json:"locations"`
+}
+
+type RoadCount struct {
+	Road  string `json:"road"`
+	Count int    `json:"count"`
+}
+
+// LocationCount is a cluster of incidents within locationRadiusMeters of its first one, named
+// after its most common address.
+type LocationCount struct {
+	Address   string  `json:"address"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Count     int     `json:"count"`
+
+	addresses map[string]int
+}
+
+// computeLeaderboard ranks the incidents between from and to.
+func (a *app) computeLeaderboard(cfg *Config, from, to time.Time) (Leaderboard, error) {
+	board := Leaderboard{From: from, To: to, Roads: []RoadCount{}, Locations: []LocationCount{}}
+	rows, err := a.db.Query(cfg.SQL(`SELECT {address}, {latitude}, {longitude}, {details} FROM {incidents}
+		WHERE {timestamp} >= $1 AND {timestamp} < $2 AND NOT {is_test}
+		ORDER BY {timestamp}`), from, to)
+	if err != nil {
+		return board, fmt.Errorf("error querying incidents for leaderboard: %w", err)
+	}
+	defer rows.Close()
+
+	roads := make(map[string]int)
+	var locations []*LocationCount
+	for rows.Next() {
+		var i incident.Incident
+		var details sql.NullString
+		if err := rows.Scan(&i.Address, &i.Latitude, &i.Longitude, &details); err != nil {
+			return board, fmt.Errorf("error scanning incident row: %w", err)
+		}
+		i.Details = json.RawMessage(details.String)
+		if road := incident.RoadName(i); road != "" {
+			roads[road]++
+		}
+		if !i.Latitude.Valid || !i.Longitude.Valid {
+			continue
+		}
+		lat, lon := i.Latitude.Float64, i.Longitude.Float64
+		var loc *LocationCount
+		for _, l := range locations {
+			if distanceMeters(l.Latitude, l.Longitude, lat, lon) <= locationRadiusMeters {
+				loc = l
+				break
+			}
+		}
+		if loc == nil {
+			loc = &LocationCount{Latitude: lat, Longitude: lon, addresses: make(map[string]int)}
+			locations = append(locations, loc)
+		}
+		loc.Count++
+		loc.addresses[incident.NormalizedAddress(i)]++
+	}
+	if err := rows.Err(); err != nil {
+		return board, err
+	}
+
+	for road, n := range roads {
+		board.Roads = append(board.Roads, RoadCount{road, n})
+	}
+	sort.Slice(board.Roads, func(x, y int) bool {
+		if board.Roads[x].Count != board.Roads[y].Count {
+			return board.Roads[x].Count > board.Roads[y].Count
+		}
+		return board.Roads[x].Road < board.Roads[y].Road
+	})
+	if len(board.Roads) > leaderboardSize {
+		board.Roads = board.Roads[:leaderboardSize]
+	}
+
+	sort.SliceStable(locations, func(x, y int) bool { return locations[x].Count > locations[y].Count })
+	for _, l := range locations {
+		if len(board.Locations) == leaderboardSize || l.Count < 2 {
+			break
+		}
+		for address, n := range l.addresses {
+			if n > l.addresses[l.Address] || (n == l.addresses[l.Address] && address < l.Address) {
+				l.Address = address
+			}
+		}
+		board.Locations = append(board.Locations, *l)
+	}
+	return board, nil
+}
+
+// postLeaderboard posts the past week's leaderboard once the configured day and time have passed.
+func (a *app) postLeaderboard(cfg *Config) {
+	l := cfg.Leaderboard
+	if l == nil {
+		return
+	}
+	route, _ := cfg.Route(l.Route)
+	loc := cfg.Location(route)
+	day, at := "mon", "08:00"
+	if l.Day != "" {
+		day = strings.ToLower(l.Day)
+	}
+	if l.At != "" {
+		at = l.At
+	}
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if now.Weekday() != weekdays[day] || now.Sub(today) < time.Duration(clockMinutes(at))*time.Minute {
+		return
+	}
+	from := today.AddDate(0, 0, -7)
+	claimed, err := postgres.ClaimStatsReport(a.db, "leaderboard", from)
+	if err != nil || !claimed {
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		return
+	}
+	if err := a.sendLeaderboard(cfg, route, from, today); err != nil {
+		log.Printf("Error posting leaderboard: %v", err)
+		a.reporter.Report(fmt.Errorf("posting leaderboard: %w", err), "error", map[string]string{"route": route.Name})
+		if err := postgres.ReleaseStatsReport(a.db, "leaderboard", from); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
+
+func (a *app) sendLeaderboard(cfg *Config, route RouteConfig, from, to time.Time) error {
+	board, err := a.computeLeaderboard(cfg, from, to)
+	if err != nil {
+		return err
+	}
+	opts := cfg.RenderOptions(route, "")
+	payload := discord.WebhookPayload{Username: opts.T("bot_username"), Embeds: []discord.Embed{buildLeaderboardEmbed(board, opts)}}
+	if _, err := reportMessenger(route).Send(payload); err != nil {
+		return err
+	}
+	log.Printf("Posted leaderboard for %s to route %q.", from.Format(time.DateOnly), route.Name)
+	return nil
+}
+
+// buildLeaderboardEmbed renders the leaderboard as numbered lists of roads and locations.
+func buildLeaderboardEmbed(board Leaderboard, opts discord.RenderOptions) discord.Embed {
+	var roads, locations []string
+	for n, r := range board.Roads {
+		roads = append(roads, fmt.Sprintf("%d. %s — %d", n+1, discord.SanitizeFeedText(r.Road), r.Count))
+	}
+	for n, l := range board.Locations {
+		link := fmt.Sprintf("https://www.google.com/maps/search/?api=1&query=%f,%f", l.Latitude, l.Longitude)
+		locations = append(locations, fmt.Sprintf("%d. [%s](%s) — %d", n+1, discord.SanitizeFeedText(l.Address), link, l.Count))
+	}
+	list := func(lines []string) string {
+		if len(lines) == 0 {
+			return opts.T("leaderboard_none")
+		}
+		return discord.Truncate(strings.Join(lines, "\n"), discord.MaxFieldValue)
+	}
+	last := board.To.AddDate(0, 0, -1)
+	return discord.Embed{
+		Title: discord.Truncate("🏁 "+fmt.Sprintf(opts.T("leaderboard_title"), board.From.Format(time.DateOnly), last.Format(time.DateOnly)), discord.MaxEmbedTitle),
+		Color: 3447003, // Blue
+		Fields: []discord.EmbedField{
+			{Name: opts.T("leaderboard_roads"), Value: list(roads)},
+			{Name: opts.T("leaderboard_locations"), Value: list(locations)},
+		},
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+}
+
+// handleLeaderboard serves GET /api/leaderboard?days=7, ranking the incidents of the last days
+// full days.
+func (a *app) handleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	days, err := strconv.Atoi(r.URL.Query().Get("days"))
+	if err != nil || days <= 0 || days > 366 {
+		days = 7
+	}
+	cfg := a.currentConfig()
+	now := time.Now().In(cfg.Location(RouteConfig{}))
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	board, err := a.computeLeaderboard(cfg, today.AddDate(0, 0, -days), today)
+	if err != nil {
+		log.Printf("Error serving leaderboard: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(board)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+)
+
+// defaultRunLockKey is the pg_advisory_lock key shared by every unity-alerts instance
+// pointed at the same database. Override with RUN_LOCK_KEY when several deployments share one.
+const defaultRunLockKey int64 = 7_311_260_001
+
+// runLockKey returns the advisory lock key for this deployment.
+func runLockKey() int64 {
+	if v := os.Getenv("RUN_LOCK_KEY"); v != "" {
+		key, err := strconv.ParseInt(v, 10, 64)
+		if err == nil {
+			return key
+		}
+		log.Printf("Warning: invalid RUN_LOCK_KEY %q, using default: %v", v, err)
+	}
+	return defaultRunLockKey
+}
@@ -1,586 +1,225 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"database/sql"
-	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
 	"log"
-	"mime/multipart"
-	"net/http"
 	"os"
-	"path/filepath"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"github.com/mtickle/unity-alerts/store/postgres"
 )
 
-// UnifiedIncident matches the structure of our database table.
-type UnifiedIncident struct {
-	ID               int
-	Source           string
-	SourceID         string
-	EventType        string
-	Address          string
-	Latitude         sql.NullFloat64
-	Longitude        sql.NullFloat64
-	Timestamp        time.Time
-	Details          []byte // Raw JSONB from the database
-	DiscordMessageID sql.NullString
-}
-
-// Camera holds the info for a nearby traffic camera.
-type Camera struct {
-	Name     string
-	ImageURL string
-}
-
-// Structs for creating a rich Discord Embed message with attachments.
-type DiscordWebhookPayload struct {
-	Username  string         `json:"username"`
-	AvatarURL string         `json:"avatar_url,omitempty"`
-	Embeds    []DiscordEmbed `json:"embeds"`
-}
-
-type DiscordEmbed struct {
-	Title     string         `json:"title,omitempty"`
-	Color     int            `json:"color"`
-	Fields    []EmbedField   `json:"fields,omitempty"`
-	Footer    EmbedFooter    `json:"footer,omitempty"`
-	Timestamp string         `json:"timestamp,omitempty"`
-	Thumbnail EmbedThumbnail `json:"thumbnail,omitempty"`
-	Image     EmbedImage     `json:"image,omitempty"`
-}
-
-type EmbedThumbnail struct {
-	URL string `json:"url"`
-}
-
-type EmbedImage struct {
-	URL string `json:"url"`
-}
-
-type EmbedField struct {
-	Name   string `json:"name"`
-	Value  string `json:"value"`
-	Inline bool   `json:"inline"`
-}
+// Exit codes, so cron and systemd can tell a broken deployment from a flaky sink.
+const (
+	exitFailure = 1 // Nothing was processed: the database, config or secrets are unusable.
+	exitUsage   = 2 // Invalid flags.
+	exitPartial = 3 // The run completed, but some alerts or jobs failed, e.g. Discord was down.
+)
 
-type EmbedFooter struct {
-	Text string `json:"text"`
+// runFlags are the flags that come before any subcommand.
+type runFlags struct {
+	once   bool
+	daemon bool
+	dryRun bool
 }
 
-// captureCameraImage downloads a camera image and saves it to a temporary file.
-func captureCameraImage(db *sql.DB, incidentID int, camera Camera) (string, string, error) {
-	log.Printf("Capturing image from camera: %s", camera.Name)
-	resp, err := http.Get(camera.ImageURL)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to download image: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return "", "", fmt.Errorf("received non-200 status code for image: %s", resp.Status)
+// parseRunFlags parses the flags, printing the usage when they are invalid, and returns the
+// subcommand and its arguments, if any.
+func parseRunFlags(args []string) (runFlags, []string, error) {
+	var f runFlags
+	fs := flag.NewFlagSet("unity-alerts", flag.ContinueOnError)
+	fs.BoolVar(&f.once, "once", false, "process incidents once and exit, even if POLL_INTERVAL is set")
+	fs.BoolVar(&f.daemon, "daemon", false, "keep running, polling every POLL_INTERVAL (default 5m)")
+	fs.BoolVar(&f.dryRun, "dry-run", false, "write an HTML preview instead of sending alerts, like NOTIFY_DISCORD=0")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: unity-alerts [--once | --daemon] [--dry-run] [subcommand [args]]")
+		fs.PrintDefaults()
+		fmt.Fprintf(fs.Output(), "\nExit codes: 0 success, %d failure, %d invalid flags, %d partial failure.\n", exitFailure, exitUsage, exitPartial)
 	}
-
-	fileName := fmt.Sprintf("incident_%d_cam_%s.jpg", incidentID, time.Now().Format("20060102150405"))
-	filePath := filepath.Join(os.TempDir(), fileName)
-
-	file, err := os.Create(filePath)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to create temp file: %w", err)
-	}
-	defer file.Close()
-
-	_, err = io.Copy(file, resp.Body)
-	if err != nil {
-		os.Remove(filePath)
-		return "", "", fmt.Errorf("failed to save image to file: %w", err)
+	if err := fs.Parse(args); err != nil {
+		return f, nil, err
 	}
-
-	_, err = db.Exec("INSERT INTO camera_captures (incident_id, camera_name, file_path) VALUES ($1, $2, $3)",
-		incidentID, camera.Name, filePath)
-	if err != nil {
-		log.Printf("Warning: failed to log camera capture to DB: %v", err)
+	if f.once && f.daemon {
+		err := fmt.Errorf("--once and --daemon cannot be combined")
+		fmt.Fprintln(fs.Output(), err)
+		fs.Usage()
+		return f, nil, err
 	}
-
-	log.Printf("Successfully saved camera frame to %s", filePath)
-	return filePath, fileName, nil
+	return f, fs.Args(), nil
 }
 
-// findNearbyCameras queries the database to find the closest cameras to a given point.
-func findNearbyCameras(db *sql.DB, lat, lon float64, limit int) ([]Camera, error) {
-	var cameras []Camera
-	query := `
-		SELECT name, image_url
-		FROM traffic_cameras
-		ORDER BY geom <-> ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography
-		LIMIT $3;
-	`
-	rows, err := db.Query(query, lon, lat, limit)
+func main() {
+	log.SetOutput(redactingWriter{os.Stderr})
+	flags, args, err := parseRunFlags(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error querying for nearby cameras: %w", err)
+		os.Exit(exitUsage)
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var cam Camera
-		if err := rows.Scan(&cam.Name, &cam.ImageURL); err != nil {
-			return nil, fmt.Errorf("error scanning camera row: %w", err)
-		}
-		cameras = append(cameras, cam)
+	if len(args) > 0 && args[0] == "version" {
+		fmt.Println(buildVersion())
+		return
 	}
-	return cameras, nil
-}
-
-// sendDiscordAlert is the main router for sending a new, enriched alert.
-func sendDiscordAlert(db *sql.DB, webhookURL, mapsAPIKey string, incident UnifiedIncident) (string, error) {
-	var payload DiscordWebhookPayload
-	var attachmentPath, attachmentName string
-
-	// Only capture camera images for sources that are NOT ArcGIS_Police.
-	if incident.Source != "ArcGIS_Police" {
-		var nearbyCameras []Camera
-		if incident.Latitude.Valid && incident.Longitude.Valid {
-			var err error
-			nearbyCameras, err = findNearbyCameras(db, incident.Latitude.Float64, incident.Longitude.Float64, 3)
-			if err != nil {
-				log.Printf("Could not fetch nearby cameras: %v", err)
-			}
-		}
+	log.Printf("Starting unity-alerts %s.", buildVersion())
 
-		if len(nearbyCameras) > 0 {
-			var err error
-			attachmentPath, attachmentName, err = captureCameraImage(db, incident.ID, nearbyCameras[0])
-			if err != nil {
-				log.Printf("Failed to capture camera image: %v", err)
-				attachmentPath = ""
-			}
-		}
-		if attachmentPath != "" {
-			defer os.Remove(attachmentPath)
-		}
-
-		if incident.Source == "NCDOT" {
-			payload = buildNcdotPayload(mapsAPIKey, incident, nearbyCameras, attachmentName)
-		} else if incident.Source == "RWECC" {
-			payload = buildRweccPayload(mapsAPIKey, incident, nearbyCameras, attachmentName)
+	if err := godotenv.Load(); err != nil {
+		if err := godotenv.Load(".env.dev"); err != nil {
+			log.Println("Note: No .env or .env.dev file found, reading from system environment")
 		} else {
-			return "", fmt.Errorf("unknown incident source: %s", incident.Source)
+			log.Println("Loaded configuration from .env.dev")
 		}
 	} else {
-		payload = buildArcGisPayload(mapsAPIKey, incident)
+		log.Println("Loaded configuration from .env")
 	}
 
-	return postMultipartToWebhook(webhookURL, payload, attachmentPath)
-}
-
-// buildNcdotPayload creates the multi-embed structure for an NC DOT alert.
-func buildNcdotPayload(mapsAPIKey string, incident UnifiedIncident, nearbyCameras []Camera, attachmentName string) DiscordWebhookPayload {
-	var rawIncident struct {
-		Reason   string `json:"reason"`
-		Road     string `json:"road"`
-		Location string `json:"location"`
-		Severity int    `json:"severity"`
-	}
-	var weatherDetails *struct {
-		Temperature   int    `json:"temperature"`
-		WindSpeed     string `json:"windSpeed"`
-		ShortForecast string `json:"shortForecast"`
-		Icon          string `json:"icon"`
+	secretsProvider, err := newSecretsProvider()
+	if err != nil {
+		log.Fatalf("Error configuring secrets backend: %v", err)
 	}
-
-	var detailsMap map[string]json.RawMessage
-	if err := json.Unmarshal(incident.Details, &detailsMap); err == nil {
-		if rawJSON, ok := detailsMap["raw_incident"]; ok {
-			json.Unmarshal(rawJSON, &rawIncident)
+	if secretsProvider != nil {
+		if err := applySecrets(context.Background(), secretsProvider); err != nil {
+			log.Fatalf("Error loading secrets: %v", err)
 		}
-		if weatherJSON, ok := detailsMap["weather"]; ok && string(weatherJSON) != "null" {
-			json.Unmarshal(weatherJSON, &weatherDetails)
-		}
-	} else {
-		log.Printf("INFO: Could not parse as new format, falling back to old format for NCDOT incident.")
-		json.Unmarshal(incident.Details, &rawIncident)
-	}
-
-	var color int
-	switch rawIncident.Severity {
-	case 1:
-		color = 3066993
-	case 2:
-		color = 16776960
-	case 3:
-		color = 15158332
-	default:
-		color = 2105893
-	}
-
-	fields := []EmbedField{
-		{Name: "Reason", Value: rawIncident.Reason, Inline: false},
-		{Name: "Road", Value: rawIncident.Road, Inline: false},
-		{Name: "Location", Value: rawIncident.Location, Inline: false},
-		{Name: "Severity", Value: strconv.Itoa(rawIncident.Severity), Inline: false},
 	}
 
-	if weatherDetails != nil {
-		weatherValue := fmt.Sprintf("%s\nTemp: %d°F\nWind: %s", weatherDetails.ShortForecast, weatherDetails.Temperature, weatherDetails.WindSpeed)
-		fields = append(fields, EmbedField{Name: "Weather Conditions", Value: weatherValue, Inline: false})
-	}
-
-	if len(nearbyCameras) > 1 {
-		var cameraLinks []string
-		for i := 1; i < len(nearbyCameras); i++ {
-			cameraLinks = append(cameraLinks, fmt.Sprintf("[%s](%s)", nearbyCameras[i].Name, nearbyCameras[i].ImageURL))
+	if len(args) > 0 && args[0] == "register-commands" {
+		if err := registerSlashCommands(); err != nil {
+			log.Fatalf("Error registering slash commands: %v", err)
 		}
-		fields = append(fields, EmbedField{Name: "Other Live Cameras", Value: strings.Join(cameraLinks, "\n"), Inline: false})
+		log.Println("Slash commands registered.")
+		return
 	}
 
-	embed := DiscordEmbed{
-		Title: "🚨 NC DOT - Incident Alert 🚨", Color: color, Fields: fields,
-		Footer: EmbedFooter{Text: "Source: NC DOT API"}, Timestamp: incident.Timestamp.Format(time.RFC3339),
-	}
-
-	if mapsAPIKey != "" && incident.Latitude.Valid && incident.Longitude.Valid {
-		mapURL := fmt.Sprintf("https://maps.googleapis.com/maps/api/staticmap?center=%.6f,%.6f&zoom=14&size=300x300&markers=color:red%%7C%.6f,%.6f&key=%s",
-			incident.Latitude.Float64, incident.Longitude.Float64, incident.Latitude.Float64, incident.Longitude.Float64, mapsAPIKey)
-		embed.Thumbnail = EmbedThumbnail{URL: mapURL}
+	db := sql.OpenDB(envConnector{})
+	defer db.Close()
+	if err := connectDB(db); err != nil {
+		if len(args) == 0 {
+			recordConnectFailure(err)
+		}
+		log.Fatalf("Error connecting to database: %s", err)
 	}
+	log.Println("Successfully connected to the database.")
 
-	if attachmentName != "" {
-		embed.Image = EmbedImage{URL: "attachment://" + attachmentName}
+	notifyDiscord := os.Getenv("NOTIFY_DISCORD")
+	if flags.dryRun {
+		notifyDiscord = "0"
 	}
 
-	return DiscordWebhookPayload{Username: "Unified Alert Bot", Embeds: []DiscordEmbed{embed}}
-}
+	// stateFilename := os.Getenv("STATE_FILENAME")
+	// if stateFilename == "" {
+	// 	stateFilename = "sent_unified_alerts.json"
+	// }
+	// log.Printf("Using state file: %s", stateFilename)
 
-// buildRweccPayload creates the multi-embed structure for an RWECC alert.
-func buildRweccPayload(mapsAPIKey string, incident UnifiedIncident, nearbyCameras []Camera, attachmentName string) DiscordWebhookPayload {
-	var rawIncident struct {
-		Problem      string `json:"problem"`
-		Jurisdiction string `json:"jurisdiction"`
-	}
-	var weatherDetails *struct {
-		Temperature   int    `json:"temperature"`
-		WindSpeed     string `json:"windSpeed"`
-		ShortForecast string `json:"shortForecast"`
-		Icon          string `json:"icon"`
+	configStore, err := newConfigStore(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Fatalf("Error: %v", err)
 	}
 
-	var detailsMap map[string]json.RawMessage
-	if err := json.Unmarshal(incident.Details, &detailsMap); err == nil {
-		if rawJSON, ok := detailsMap["raw_incident"]; ok {
-			json.Unmarshal(rawJSON, &rawIncident)
-		}
-		if weatherJSON, ok := detailsMap["weather"]; ok && string(weatherJSON) != "null" {
-			json.Unmarshal(weatherJSON, &weatherDetails)
-		}
-	} else {
-		log.Printf("INFO: Could not parse as new format, falling back to old format for RWECC incident.")
-		json.Unmarshal(incident.Details, &rawIncident)
+	images, err := newImageHost()
+	if err != nil {
+		log.Fatalf("Error: %v", err)
 	}
 
-	fields := []EmbedField{
-		{Name: "Address", Value: incident.Address, Inline: false},
-		{Name: "Jurisdiction", Value: rawIncident.Jurisdiction, Inline: false},
+	influx, err := newInfluxExporter()
+	if err != nil {
+		log.Fatalf("Error: %v", err)
 	}
 
-	if weatherDetails != nil {
-		weatherValue := fmt.Sprintf("%s\nTemp: %d°F\nWind: %s", weatherDetails.ShortForecast, weatherDetails.Temperature, weatherDetails.WindSpeed)
-		fields = append(fields, EmbedField{Name: "Weather Conditions", Value: weatherValue, Inline: false})
+	a := &app{
+		db:            db,
+		reporter:      newErrorReporter(),
+		config:        configStore,
+		notifyDiscord: notifyDiscord,
+		images:        images,
+		influx:        influx,
+		homeAssistant: newHomeAssistant(),
 	}
 
-	if len(nearbyCameras) > 1 {
-		var cameraLinks []string
-		for i := 1; i < len(nearbyCameras); i++ {
-			cameraLinks = append(cameraLinks, fmt.Sprintf("[%s](%s)", nearbyCameras[i].Name, nearbyCameras[i].ImageURL))
+	if len(args) > 0 {
+		err := a.runSubcommand(args[0], args[1:])
+		flushSignal()
+		if err != nil {
+			log.Fatalf("Error: %v", err)
 		}
-		fields = append(fields, EmbedField{Name: "Other Live Cameras", Value: strings.Join(cameraLinks, "\n"), Inline: false})
-	}
-
-	embed := DiscordEmbed{
-		Title: "🔵 " + rawIncident.Problem + " 🔵", Color: 3447003, Fields: fields,
-		Footer: EmbedFooter{Text: "Source: Raleigh-Wake ECC"}, Timestamp: incident.Timestamp.Format(time.RFC3339),
+		return
 	}
 
-	if mapsAPIKey != "" && incident.Latitude.Valid && incident.Longitude.Valid {
-		mapURL := fmt.Sprintf("https://maps.googleapis.com/maps/api/staticmap?center=%.6f,%.6f&zoom=14&size=300x300&markers=color:red%%7C%.6f,%.6f&key=%s",
-			incident.Latitude.Float64, incident.Longitude.Float64, incident.Latitude.Float64, incident.Longitude.Float64, mapsAPIKey)
-		embed.Thumbnail = EmbedThumbnail{URL: mapURL}
-	}
-
-	if attachmentName != "" {
-		embed.Image = EmbedImage{URL: "attachment://" + attachmentName}
+	if notifyDiscord != "0" {
+		if err := checkWebhooks(configStore.Current()); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
 	}
 
-	return DiscordWebhookPayload{Username: "Unified Alert Bot", Embeds: []DiscordEmbed{embed}}
-}
-
-// buildArcGisPayload creates the multi-embed structure for an ArcGIS Police incident.
-func buildArcGisPayload(mapsAPIKey string, incident UnifiedIncident) DiscordWebhookPayload {
-	var rawIncident struct {
-		CaseNumber       string `json:"case_number"`
-		CrimeDescription string `json:"crime_description"`
-		Agency           string `json:"agency"`
+	var consume func(ctx context.Context, wake chan<- struct{})
+	if !flags.once {
+		if consume, err = a.queueConsumer(); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
 	}
 
-	log.Printf("DEBUG: Raw ArcGIS Details JSON received: %s", string(incident.Details))
-
-	var detailsMap map[string]json.RawMessage
-	if err := json.Unmarshal(incident.Details, &detailsMap); err == nil {
-		if rawJSON, ok := detailsMap["raw_incident"]; ok {
-			if err := json.Unmarshal(rawJSON, &rawIncident); err != nil {
-				log.Printf("ERROR: Failed to unmarshal nested ArcGIS raw_incident: %v", err)
+	if interval := os.Getenv("POLL_INTERVAL"); flags.daemon || (!flags.once && (interval != "" || consume != nil)) {
+		// Consuming from a queue, the poll is a fallback that also drives the periodic jobs.
+		pollInterval := 5 * time.Minute
+		if interval != "" {
+			if pollInterval, err = time.ParseDuration(interval); err != nil {
+				log.Fatalf("Error parsing POLL_INTERVAL: %v", err)
 			}
 		}
-	} else {
-		log.Printf("INFO: Could not parse as new format, falling back to old format for ArcGIS incident.")
-		if fallbackErr := json.Unmarshal(incident.Details, &rawIncident); fallbackErr != nil {
-			log.Printf("ERROR: Failed to unmarshal ArcGIS details in both new and old formats: %v", fallbackErr)
-		}
-	}
-
-	loc, _ := time.LoadLocation("America/New_York")
-	localTime := incident.Timestamp.In(loc)
-	formattedTime := localTime.Format("Mon, Jan 2, 3:04 PM")
-
-	fields := []EmbedField{
-		{Name: "Address", Value: incident.Address, Inline: false},
-		{Name: "Agency", Value: rawIncident.Agency, Inline: false},
-	}
-
-	if !strings.HasPrefix(rawIncident.CaseNumber, "NO_CASE-") {
-		fields = append(fields, EmbedField{Name: "Case #", Value: rawIncident.CaseNumber, Inline: false})
-	}
-
-	fields = append(fields, EmbedField{Name: "Reported", Value: formattedTime, Inline: false})
-
-	embed := DiscordEmbed{
-		Title:     "🟣 " + rawIncident.CrimeDescription + " 🟣",
-		Color:     9807270, // Purple
-		Fields:    fields,
-		Footer:    EmbedFooter{Text: "Source: Police Incidents Feed"},
-		Timestamp: incident.Timestamp.Format(time.RFC3339),
-	}
-
-	if mapsAPIKey != "" && incident.Latitude.Valid && incident.Longitude.Valid {
-		mapURL := fmt.Sprintf("https://maps.googleapis.com/maps/api/staticmap?center=%.6f,%.6f&zoom=15&size=600x400&markers=color:purple%%7C%.6f,%.6f&key=%s",
-			incident.Latitude.Float64, incident.Longitude.Float64, incident.Latitude.Float64, incident.Longitude.Float64, mapsAPIKey)
-		embed.Image = EmbedImage{URL: mapURL}
-	}
-
-	return DiscordWebhookPayload{Username: "Unified Alert Bot", Embeds: []DiscordEmbed{embed}}
-}
-
-// postMultipartToWebhook sends a message that may include a file attachment.
-func postMultipartToWebhook(webhookURL string, payload DiscordWebhookPayload, attachmentPath string) (string, error) {
-	webhookURL += "?wait=true"
-
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-
-	jsonPart, err := writer.CreateFormField("payload_json")
-	if err != nil {
-		return "", err
-	}
-	if err := json.NewEncoder(jsonPart).Encode(payload); err != nil {
-		return "", err
-	}
-
-	if attachmentPath != "" {
-		file, err := os.Open(attachmentPath)
-		if err != nil {
-			return "", err
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		if secretsProvider != nil {
+			if refresh := os.Getenv("SECRETS_REFRESH_INTERVAL"); refresh != "" {
+				refreshInterval, err := time.ParseDuration(refresh)
+				if err != nil {
+					log.Fatalf("Error parsing SECRETS_REFRESH_INTERVAL: %v", err)
+				}
+				go refreshSecrets(ctx, secretsProvider, refreshInterval)
+			}
 		}
-		defer file.Close()
-		part, err := writer.CreateFormFile("files[0]", filepath.Base(attachmentPath))
-		if err != nil {
-			return "", err
+		go configStore.Watch(ctx)
+		if err := startHTTPServer(ctx, a); err != nil {
+			log.Fatalf("Error: %v", err)
 		}
-		if _, err = io.Copy(part, file); err != nil {
-			return "", err
+		if err := startGRPCServer(ctx, a); err != nil {
+			log.Fatalf("Error: %v", err)
 		}
+		runDaemon(db, a.reporter, pollInterval, newScheduler(a), consume)
+		return
 	}
 
-	writer.Close()
-
-	req, err := http.NewRequest("POST", webhookURL, body)
-	if err != nil {
-		return "", err
-	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		respBody, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("discord returned non-2xx status: %s. Body: %s", resp.Status, string(respBody))
-	}
-
-	var message struct {
-		ID string `json:"id"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
-		return "", err
-	}
-	return message.ID, nil
-}
-
-// updateDiscordAlert edits an existing Discord message to show it's cleared.
-func updateDiscordAlert(webhookURL, messageID string, incident UnifiedIncident) error {
-	embed := DiscordEmbed{
-		Title: "✅ Incident Cleared ✅",
-		Color: 3066993, // Green
-		Fields: []EmbedField{
-			{Name: "Source", Value: incident.Source, Inline: false},
-			{Name: "Address", Value: incident.Address, Inline: false},
-		},
-		Footer:    EmbedFooter{Text: "Incident no longer in active feed"},
-		Timestamp: time.Now().UTC().Format(time.RFC3339),
-	}
-	payload := DiscordWebhookPayload{Embeds: []DiscordEmbed{embed}}
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("error creating update JSON payload: %w", err)
-	}
-	updateURL := fmt.Sprintf("%s/messages/%s", webhookURL, messageID)
-	req, err := http.NewRequest("PATCH", updateURL, bytes.NewBuffer(jsonPayload))
-	if err != nil {
-		return fmt.Errorf("error creating PATCH request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("error sending PATCH request: %w", err)
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("discord returned non-2xx status on update: %s. Body: %s", resp.Status, string(body))
-	}
-	return nil
+	os.Exit(runOnce(a))
 }
 
-func main() {
-	if err := godotenv.Load(); err != nil {
-		if err := godotenv.Load(".env.dev"); err != nil {
-			log.Println("Note: No .env or .env.dev file found, reading from system environment")
-		} else {
-			log.Println("Loaded configuration from .env.dev")
-		}
-	} else {
-		log.Println("Loaded configuration from .env")
-	}
-
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=require",
-		os.Getenv("DATABASE_HOST"), os.Getenv("DATABASE_PORT"), os.Getenv("DATABASE_USERNAME"),
-		os.Getenv("DATABASE_PASSWORD"), os.Getenv("DATABASE_NAME"))
-	db, err := sql.Open("postgres", psqlInfo)
+// runOnce processes incidents once, returning the exit code.
+func runOnce(a *app) int {
+	// Guard against an overrunning cron invocation processing the same incidents twice.
+	runLock, err := postgres.TryAcquireRunLock(context.Background(), a.db, runLockKey())
 	if err != nil {
-		log.Fatalf("Error opening database: %s", err)
+		log.Printf("Error acquiring run lock: %v", err)
+		return exitFailure
 	}
-	defer db.Close()
-	if err := db.Ping(); err != nil {
-		log.Fatalf("Error connecting to database: %s", err)
+	if runLock == nil {
+		log.Println("Another unity-alerts run is still in progress; exiting.")
+		return 0
 	}
-	log.Println("Successfully connected to the database.")
-
-	webhookURL := os.Getenv("DISCORD_HOOK")
-	mapsAPIKey := os.Getenv("GOOGLE_MAPS_API_KEY")
+	defer runLock.Release()
 
-	notifyDiscord := os.Getenv("NOTIFY_DISCORD")
-
-	// stateFilename := os.Getenv("STATE_FILENAME")
-	// if stateFilename == "" {
-	// 	stateFilename = "sent_unified_alerts.json"
-	// }
-	// log.Printf("Using state file: %s", stateFilename)
-
-	if webhookURL == "" {
-		log.Fatalln("Error: DISCORD_HOOK must be set")
-	}
-
-	// Step 1: Process New Incidents
-	rows, err := db.Query("SELECT id, source, source_id, event_type, address, latitude, longitude, timestamp, details FROM unified_incidents WHERE status = 'active' AND discord_message_id IS NULL")
+	partial, err := a.runCycle()
 	if err != nil {
-		log.Fatalf("Error querying for new incidents: %v", err)
+		a.reporter.Report(err, "fatal", nil)
+		log.Printf("Error: %v", err)
+		return exitFailure
 	}
-	defer rows.Close()
-
-	var newIncidentsFound int
-	for rows.Next() {
-		var i UnifiedIncident
-		if err := rows.Scan(&i.ID, &i.Source, &i.SourceID, &i.EventType, &i.Address, &i.Latitude, &i.Longitude, &i.Timestamp, &i.Details); err != nil {
-			log.Printf("Error scanning incident: %v", err)
-			continue
-		}
-
-		log.Printf("Found new unified incident from %s (ID: %s).", i.Source, i.SourceID)
-
-		if notifyDiscord == "0" {
-			log.Println("--- DEBUG MODE: NOTIFY_DISCORD=0 ---")
-			var prettyJSON bytes.Buffer
-			if err := json.Indent(&prettyJSON, i.Details, "", "  "); err != nil {
-				log.Printf("Error formatting JSON for debug: %v", err)
-			} else {
-				log.Println(prettyJSON.String())
-			}
-			continue
-		}
-
-		log.Println("Sending alert to Discord...")
-		messageID, err := sendDiscordAlert(db, webhookURL, mapsAPIKey, i)
-		if err != nil {
-			log.Printf("Error sending Discord alert: %v", err)
-			continue
-		}
-
-		_, err = db.Exec("UPDATE unified_incidents SET discord_message_id = $1 WHERE id = $2", messageID, i.ID)
-		if err != nil {
-			log.Printf("Error saving discord_message_id: %v", err)
-		}
-
-		newIncidentsFound++
-		time.Sleep(2 * time.Second)
-	}
-	log.Printf("Processed %d new alerts.", newIncidentsFound)
-
-	// Step 2: Process Cleared Incidents
-	clearedRows, err := db.Query("SELECT id, source, address, discord_message_id FROM unified_incidents WHERE status = 'cleared' AND discord_message_id IS NOT NULL")
-	if err != nil {
-		log.Fatalf("Error querying for cleared incidents: %v", err)
-	}
-	defer clearedRows.Close()
-
-	var clearedIncidentsUpdated int
-	for clearedRows.Next() {
-		var i UnifiedIncident
-		if err := clearedRows.Scan(&i.ID, &i.Source, &i.Address, &i.DiscordMessageID); err != nil {
-			log.Printf("Error scanning cleared incident: %v", err)
-			continue
-		}
-		log.Printf("Found cleared incident from %s (ID: %d). Updating message.", i.Source, i.ID)
-		err := updateDiscordAlert(webhookURL, i.DiscordMessageID.String, i)
-		if err != nil {
-			log.Printf("Error updating Discord alert: %v", err)
-			continue
-		}
-		_, err = db.Exec("UPDATE unified_incidents SET discord_message_id = NULL WHERE id = $1", i.ID)
-		if err != nil {
-			log.Printf("Error nullifying discord_message_id: %v", err)
-		}
-		clearedIncidentsUpdated++
-		time.Sleep(2 * time.Second)
+	if partial {
+		log.Println("Run complete, with failures.")
+		return exitPartial
 	}
-	log.Printf("Processed %d cleared alerts.", clearedIncidentsUpdated)
 	log.Println("Run complete.")
+	return 0
 }
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/internal/cron"
+)
+
+// MaintenanceWindow ignores some sources while their upstream feed is known to emit garbage
+// during its own maintenance. A window either recurs, opening at each time a cron Schedule
+// gives and lasting Duration, or runs once from Start to End. Duration defaults to a minute,
+// so a schedule such as "* 2-3 * * sun" covers exactly the minutes it matches. Times are in
+// the configured timezone.
+type MaintenanceWindow struct {
+	Sources  []string `json:"sources"`
+	Schedule string   `json:"schedule,omitempty"`
+	Duration string   `json:"duration,omitempty"` // e.g. "90m"; with schedule only.
+	Start    string   `json:"start,omitempty"`    // "2006-01-02 15:04", or RFC 3339.
+	End      string   `json:"end,omitempty"`
+	Reason   string   `json:"reason,omitempty"` // Logged while the window is open.
+}
+
+func (w MaintenanceWindow) validate(loc *time.Location) error {
+	if len(w.Sources) == 0 {
+		return fmt.Errorf("sources is required")
+	}
+	if (w.Schedule == "") == (w.Start == "" && w.End == "") {
+		return fmt.Errorf("needs either a schedule or a start and end")
+	}
+	if w.Schedule != "" {
+		if strings.HasPrefix(w.Schedule, "@every") {
+			return fmt.Errorf("schedule: @every cannot open a window")
+		}
+		if _, err := cron.Parse(w.Schedule); err != nil {
+			return fmt.Errorf("schedule: %w", err)
+		}
+		if w.Duration != "" {
+			if d, err := time.ParseDuration(w.Duration); err != nil || d <= 0 {
+				return fmt.Errorf("invalid duration %q", w.Duration)
+			}
+		}
+		return nil
+	}
+	if w.Duration != "" {
+		return fmt.Errorf("duration needs a schedule")
+	}
+	start, err := parseMaintenanceTime(w.Start, loc)
+	if err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	end, err := parseMaintenanceTime(w.End, loc)
+	if err != nil {
+		return fmt.Errorf("end: %w", err)
+	}
+	if !end.After(start) {
+		return fmt.Errorf("end must be after start")
+	}
+	return nil
+}
+
+// parseMaintenanceTime reads a window's start or end.
+func parseMaintenanceTime(s string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02 15:04", s, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q; use YYYY-MM-DD HH:MM or RFC 3339", s)
+	}
+	return t, nil
+}
+
+// open reports whether a validated window is open at t.
+func (w MaintenanceWindow) open(t time.Time, loc *time.Location) bool {
+	if w.Schedule == "" {
+		start, _ := parseMaintenanceTime(w.Start, loc)
+		end, _ := parseMaintenanceTime(w.End, loc)
+		return !t.Before(start) && t.Before(end)
+	}
+	schedule, err := cron.Parse(w.Schedule)
+	if err != nil {
+		return false
+	}
+	d := time.Minute
+	if w.Duration != "" {
+		d, _ = time.ParseDuration(w.Duration)
+	}
+	// The window is open if it last opened within d of t.
+	opened := schedule.Next(t.In(loc).Add(-d))
+	return !opened.IsZero() && !opened.After(t)
+}
+
+// inMaintenance returns the open maintenance window ignoring a source at t, if any.
+func (c *Config) inMaintenance(source string, t time.Time) (MaintenanceWindow, bool) {
+	loc := c.Location(RouteConfig{})
+	for _, w := range c.Maintenance {
+		if contains(w.Sources, source) && w.open(t, loc) {
+			return w, true
+		}
+	}
+	return MaintenanceWindow{}, false
+}
+
+// skipMaintenance marks handled the new incidents from sources in maintenance, without
+// alerting or publishing them, and returns the others.
+func (a *app) skipMaintenance(cfg *Config, incidents []incident.Incident) []incident.Incident {
+	now := time.Now()
+	ignored := make(map[string]int)
+	reasons := make(map[string]string)
+	kept := incidents[:0]
+	for _, i := range incidents {
+		if w, ok := cfg.inMaintenance(i.Source, now); ok {
+			ignored[i.Source]++
+			reasons[i.Source] = w.Reason
+			a.markHandled(cfg, i)
+			continue
+		}
+		kept = append(kept, i)
+	}
+	sources := make([]string, 0, len(ignored))
+	for source := range ignored {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	for _, source := range sources {
+		reason := ""
+		if reasons[source] != "" {
+			reason = " (" + reasons[source] + ")"
+		}
+		log.Printf("%s is in a maintenance window%s; ignored %d new incident(s).", source, reason, ignored[source])
+	}
+	return kept
+}
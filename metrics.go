@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/mtickle/unity-alerts/camera"
+	"github.com/mtickle/unity-alerts/internal/breaker"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// metricsWindow is the span the incident, delivery and latency metrics summarize.
+const metricsWindow = time.Hour
+
+// sourceCounts are one source's incidents.
+type sourceCounts struct {
+	Source   string
+	LastHour int // Reported within metricsWindow.
+	Active   int
+}
+
+// metricsSnapshot is what /metrics serves and the InfluxDB exporter pushes.
+type metricsSnapshot struct {
+	Incidents         []sourceCounts
+	Cameras           camera.Health
+	Delivered, Failed int // Deliveries within metricsWindow.
+	Latency           postgres.AlertLatency
+	OpenCircuits      []string // Hosts being skipped after repeated failures.
+}
+
+// collectMetrics gathers the current metrics.
+func (a *app) collectMetrics(cfg *Config) (metricsSnapshot, error) {
+	m := metricsSnapshot{Cameras: camera.CurrentHealth(), OpenCircuits: breaker.Open()}
+	sort.Strings(m.OpenCircuits)
+	since := time.Now().Add(-metricsWindow)
+	rows, err := a.db.Query(cfg.SQL(`SELECT {source}, count(*) FILTER (WHERE {timestamp} >= $1), count(*) FILTER (WHERE {status} = 'active')
+		FROM {incidents} WHERE NOT {is_test} AND ({timestamp} >= $1 OR {status} = 'active')
+		GROUP BY {source}`), since)
+	if err != nil {
+		return m, fmt.Errorf("error counting incidents: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c sourceCounts
+		if err := rows.Scan(&c.Source, &c.LastHour, &c.Active); err != nil {
+			return m, fmt.Errorf("error scanning incident counts: %w", err)
+		}
+		m.Incidents = append(m.Incidents, c)
+	}
+	if err := rows.Err(); err != nil {
+		return m, err
+	}
+	sort.Slice(m.Incidents, func(x, y int) bool { return m.Incidents[x].Source < m.Incidents[y].Source })
+
+	if m.Delivered, m.Failed, err = postgres.DeliveryCounts(a.db, since); err != nil {
+		return m, err
+	}
+	m.Latency, err = postgres.DeliveryLatency(a.db, since)
+	return m, err
+}
+
+// latencyStages pairs each latency stage with its name in exported metrics.
+func (m metricsSnapshot) latencyStages() []struct {
+	name string
+	p    postgres.Percentiles
+} {
+	return []struct {
+		name string
+		p    postgres.Percentiles
+	}{{"ingest", m.Latency.Ingest}, {"pipeline", m.Latency.Pipeline}, {"total", m.Latency.Total}}
+}
+
+// handleMetrics serves GET /metrics in the Prometheus text format.
+func (a *app) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	m, err := a.collectMetrics(a.currentConfig())
+	if err != nil {
+		log.Printf("Error serving metrics: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintln(w, "# HELP unity_alerts_incidents_last_hour Incidents reported over the last hour, by source.")
+	fmt.Fprintln(w, "# TYPE unity_alerts_incidents_last_hour gauge")
+	for _, c := range m.Incidents {
+		fmt.Fprintf(w, "unity_alerts_incidents_last_hour{source=%q} %d\n", c.Source, c.LastHour)
+	}
+	fmt.Fprintln(w, "# HELP unity_alerts_incidents_active Active incidents, by source.")
+	fmt.Fprintln(w, "# TYPE unity_alerts_incidents_active gauge")
+	for _, c := range m.Incidents {
+		fmt.Fprintf(w, "unity_alerts_incidents_active{source=%q} %d\n", c.Source, c.Active)
+	}
+	fmt.Fprintln(w, "# HELP unity_alerts_camera_frames_total Camera frames fetched since startup, by outcome.")
+	fmt.Fprintln(w, "# TYPE unity_alerts_camera_frames_total counter")
+	fmt.Fprintf(w, "unity_alerts_camera_frames_total{outcome=\"captured\"} %d\n", m.Cameras.Captured)
+	fmt.Fprintf(w, "unity_alerts_camera_frames_total{outcome=\"failed\"} %d\n", m.Cameras.Failed)
+	fmt.Fprintf(w, "unity_alerts_camera_frames_total{outcome=\"placeholder\"} %d\n", m.Cameras.Placeholders)
+	fmt.Fprintln(w, "# HELP unity_alerts_circuit_open Hosts skipped after repeated failures.")
+	fmt.Fprintln(w, "# TYPE unity_alerts_circuit_open gauge")
+	for _, host := range m.OpenCircuits {
+		fmt.Fprintf(w, "unity_alerts_circuit_open{host=%q} 1\n", host)
+	}
+	fmt.Fprintln(w, "# HELP unity_alerts_deliveries_last_hour Discord requests over the last hour, by outcome.")
+	fmt.Fprintln(w, "# TYPE unity_alerts_deliveries_last_hour gauge")
+	fmt.Fprintf(w, "unity_alerts_deliveries_last_hour{outcome=\"ok\"} %d\n", m.Delivered)
+	fmt.Fprintf(w, "unity_alerts_deliveries_last_hour{outcome=\"failed\"} %d\n", m.Failed)
+	fmt.Fprintln(w, "# HELP unity_alerts_alert_latency_seconds Alert latency by stage over the last hour.")
+	fmt.Fprintln(w, "# TYPE unity_alerts_alert_latency_seconds summary")
+	for _, stage := range m.latencyStages() {
+		fmt.Fprintf(w, "unity_alerts_alert_latency_seconds{stage=%q,quantile=\"0.5\"} %g\n", stage.name, stage.p.P50.Seconds())
+		fmt.Fprintf(w, "unity_alerts_alert_latency_seconds{stage=%q,quantile=\"0.95\"} %g\n", stage.name, stage.p.P95.Seconds())
+	}
+	fmt.Fprintln(w, "# HELP unity_alerts_alerts_delivered Alerts delivered over the last hour.")
+	fmt.Fprintln(w, "# TYPE unity_alerts_alerts_delivered gauge")
+	fmt.Fprintf(w, "unity_alerts_alerts_delivered %d\n", m.Latency.Alerts)
+}
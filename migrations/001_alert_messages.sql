@@ -0,0 +1,11 @@
+-- One row per Discord message posted for an incident, so every route's message
+-- can be updated when the incident clears.
+CREATE TABLE IF NOT EXISTS alert_messages (
+    id          SERIAL PRIMARY KEY,
+    incident_id INTEGER NOT NULL REFERENCES unified_incidents (id) ON DELETE CASCADE,
+    route       TEXT NOT NULL,
+    message_id  TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS alert_messages_incident_id_idx ON alert_messages (incident_id);
@@ -0,0 +1,8 @@
+-- Runtime overrides for enrichment feature flags. scope is 'global', 'source:<source>'
+-- or 'route:<route name>'; name is one of cameras, maps, weather.
+CREATE TABLE IF NOT EXISTS feature_flags (
+    scope   TEXT NOT NULL,
+    name    TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    PRIMARY KEY (scope, name)
+);
@@ -0,0 +1,2 @@
+-- Position of the incident's embed when several incidents share one batched message.
+ALTER TABLE alert_messages ADD COLUMN IF NOT EXISTS embed_index INTEGER;
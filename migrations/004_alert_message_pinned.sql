@@ -0,0 +1,2 @@
+-- Tracks messages pinned in bot-token mode so they can be unpinned when the incident clears.
+ALTER TABLE alert_messages ADD COLUMN IF NOT EXISTS pinned BOOLEAN NOT NULL DEFAULT false;
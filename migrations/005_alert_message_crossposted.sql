@@ -0,0 +1,2 @@
+-- When an alert in an announcement channel was crossposted to following servers.
+ALTER TABLE alert_messages ADD COLUMN IF NOT EXISTS crossposted_at TIMESTAMPTZ;
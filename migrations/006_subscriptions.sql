@@ -0,0 +1,15 @@
+-- Personal geofence subscriptions registered with /subscribe. A NULL channel_id
+-- means matches are sent by direct message; otherwise the user is pinged there.
+CREATE TABLE IF NOT EXISTS subscriptions (
+    id            SERIAL PRIMARY KEY,
+    user_id       TEXT NOT NULL,
+    channel_id    TEXT,
+    address       TEXT NOT NULL,
+    latitude      DOUBLE PRECISION NOT NULL,
+    longitude     DOUBLE PRECISION NOT NULL,
+    radius_meters DOUBLE PRECISION NOT NULL,
+    event_types   TEXT[] NOT NULL DEFAULT '{}',
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS subscriptions_user_id_idx ON subscriptions (user_id);
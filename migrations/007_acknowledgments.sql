@@ -0,0 +1,10 @@
+-- Who acknowledged an alert message, by button or ✅ reaction. One row per user per message.
+CREATE TABLE IF NOT EXISTS acknowledgments (
+    id         SERIAL PRIMARY KEY,
+    message_id TEXT NOT NULL,
+    user_id    TEXT NOT NULL,
+    user_name  TEXT NOT NULL,
+    method     TEXT NOT NULL,
+    acked_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (message_id, user_id)
+);
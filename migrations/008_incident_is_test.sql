@@ -0,0 +1,2 @@
+-- Marks synthetic incidents created by `unity-alerts simulate`.
+ALTER TABLE unified_incidents ADD COLUMN IF NOT EXISTS is_test BOOLEAN NOT NULL DEFAULT false;
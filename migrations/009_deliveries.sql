@@ -0,0 +1,16 @@
+-- Audit log of every outbound Discord request: when an alert went out, where, and how it went.
+CREATE TABLE IF NOT EXISTS deliveries (
+    id               BIGSERIAL PRIMARY KEY,
+    incident_id      INTEGER NOT NULL,
+    sink             TEXT NOT NULL,
+    kind             TEXT NOT NULL,
+    payload_hash     TEXT NOT NULL DEFAULT '',
+    message_id       TEXT NOT NULL DEFAULT '',
+    http_status      INTEGER NOT NULL,
+    latency_ms       BIGINT NOT NULL,
+    attachment_bytes BIGINT NOT NULL DEFAULT 0,
+    error            TEXT NOT NULL DEFAULT '',
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS deliveries_incident_id_idx ON deliveries (incident_id, created_at DESC);
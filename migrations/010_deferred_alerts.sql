@@ -0,0 +1,7 @@
+-- Incidents held for a route until its schedule window opens.
+CREATE TABLE IF NOT EXISTS deferred_alerts (
+    incident_id INTEGER NOT NULL,
+    route       TEXT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (incident_id, route)
+);
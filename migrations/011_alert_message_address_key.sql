@@ -0,0 +1,4 @@
+-- Normalized incident address, for suppressing repeat alerts at one location.
+ALTER TABLE alert_messages ADD COLUMN IF NOT EXISTS address_key TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS alert_messages_route_address_idx ON alert_messages (route, address_key, created_at DESC);
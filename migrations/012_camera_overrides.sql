@@ -0,0 +1,15 @@
+-- Cameras that point the wrong way or are permanently broken; never used for alerts.
+CREATE TABLE IF NOT EXISTS camera_denylist (
+    camera_name TEXT PRIMARY KEY,
+    reason      TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Preferred cameras for a road corridor (as grouped by batching, e.g. "I-40 W"), tried
+-- before the nearest cameras. Lower rank is preferred.
+CREATE TABLE IF NOT EXISTS camera_assignments (
+    corridor    TEXT NOT NULL,
+    camera_name TEXT NOT NULL,
+    rank        INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (corridor, camera_name)
+);
@@ -0,0 +1,16 @@
+-- Temporary silences for nuisance locations, managed with `unity-alerts mute` and /mute.
+-- Every non-empty criterion must match; with radius_meters set, the address is the center.
+CREATE TABLE IF NOT EXISTS mutes (
+    id            SERIAL PRIMARY KEY,
+    address       TEXT NOT NULL DEFAULT '',
+    road          TEXT NOT NULL DEFAULT '',
+    event_type    TEXT NOT NULL DEFAULT '',
+    latitude      DOUBLE PRECISION,
+    longitude     DOUBLE PRECISION,
+    radius_meters DOUBLE PRECISION,
+    expires_at    TIMESTAMPTZ NOT NULL,
+    created_by    TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS mutes_expires_at_idx ON mutes (expires_at);
@@ -0,0 +1,7 @@
+-- Difference hashes of "camera unavailable" placeholder frames. Frames that match one are not
+-- attached; the next camera is used instead. Add entries with `unity-alerts placeholder`.
+CREATE TABLE IF NOT EXISTS camera_placeholders (
+    hash        BIGINT PRIMARY KEY,
+    camera_name TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
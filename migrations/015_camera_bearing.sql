@@ -0,0 +1,5 @@
+-- Which road a camera watches and the compass direction it faces (degrees clockwise from north),
+-- so cameras on the incident's road and pointing toward it are preferred over closer ones on a
+-- crossing overpass. Both are optional.
+ALTER TABLE traffic_cameras ADD COLUMN IF NOT EXISTS road TEXT;
+ALTER TABLE traffic_cameras ADD COLUMN IF NOT EXISTS bearing DOUBLE PRECISION;
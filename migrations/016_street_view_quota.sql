@@ -0,0 +1,5 @@
+-- Street View images requested per day, so the streetview feature stays within its daily limit.
+CREATE TABLE IF NOT EXISTS street_view_requests (
+    day   DATE PRIMARY KEY,
+    count INTEGER NOT NULL DEFAULT 0
+);
@@ -0,0 +1,11 @@
+-- Camera images served from the daemon's /images endpoint through signed, expiring URLs
+-- instead of being uploaded to Discord (IMAGE_BASE_URL).
+CREATE TABLE IF NOT EXISTS hosted_images (
+    id           TEXT PRIMARY KEY,
+    content_type TEXT NOT NULL,
+    data         BYTEA NOT NULL,
+    expires_at   TIMESTAMPTZ NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS hosted_images_expires_at_idx ON hosted_images (expires_at);
@@ -0,0 +1,5 @@
+-- Days whose daily statistics report has been posted, so each is posted once.
+CREATE TABLE IF NOT EXISTS stats_reports (
+    day        DATE PRIMARY KEY,
+    posted_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,5 @@
+-- When each spike meta-alert last fired, so a sustained spike is reported once per cooldown.
+CREATE TABLE IF NOT EXISTS spike_alerts (
+    key         TEXT PRIMARY KEY,
+    alerted_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,4 @@
+-- Reports other than the daily statistics (the weekly leaderboard) share stats_reports.
+ALTER TABLE stats_reports ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'daily';
+ALTER TABLE stats_reports DROP CONSTRAINT IF EXISTS stats_reports_pkey;
+ALTER TABLE stats_reports ADD PRIMARY KEY (kind, day);
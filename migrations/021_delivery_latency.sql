@@ -0,0 +1,5 @@
+-- When an alerted incident happened and when the ingestor stored it, to measure alert latency.
+ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS incident_at TIMESTAMPTZ;
+ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS inserted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS deliveries_created_at_idx ON deliveries (created_at);
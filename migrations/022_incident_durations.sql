@@ -0,0 +1,17 @@
+-- How long each alerted incident took to clear, for time-to-clear percentiles by type and road.
+CREATE TABLE IF NOT EXISTS incident_durations (
+    incident_id INTEGER PRIMARY KEY,
+    source      TEXT NOT NULL,
+    event_type  TEXT NOT NULL,
+    road        TEXT NOT NULL DEFAULT '',
+    started_at  TIMESTAMPTZ NOT NULL,
+    cleared_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS incident_durations_type_road_idx ON incident_durations (event_type, road, cleared_at);
+
+-- Incidents that already received a "running long" follow-up.
+CREATE TABLE IF NOT EXISTS running_long_alerts (
+    incident_id INTEGER PRIMARY KEY,
+    posted_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,11 @@
+-- Incidents escalated to PagerDuty or Opsgenie, so each is paged once and resolved when it clears.
+CREATE TABLE IF NOT EXISTS escalations (
+    incident_id INTEGER NOT NULL,
+    name        TEXT NOT NULL,
+    dedup_key   TEXT NOT NULL,
+    opened_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    resolved_at TIMESTAMPTZ,
+    PRIMARY KEY (incident_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS escalations_open_idx ON escalations (incident_id) WHERE resolved_at IS NULL;
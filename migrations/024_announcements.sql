@@ -0,0 +1,5 @@
+-- When each route last read an alert aloud, so announcements respect the route's cooldown.
+CREATE TABLE IF NOT EXISTS announcements (
+    route        TEXT PRIMARY KEY,
+    announced_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,6 @@
+-- Open alerts about the alerting pipeline itself, such as a route whose deliveries keep failing.
+CREATE TABLE IF NOT EXISTS ops_alerts (
+    key         TEXT PRIMARY KEY,
+    alerted_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    resolved_at TIMESTAMPTZ
+);
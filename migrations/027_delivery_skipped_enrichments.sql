@@ -0,0 +1,2 @@
+-- Enrichments that failed and were left out of each alert, e.g. {cameras}.
+ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS skipped_enrichments TEXT[] NOT NULL DEFAULT '{}';
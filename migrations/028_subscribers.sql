@@ -0,0 +1,11 @@
+-- Per-user preferences for /subscribe alerts, set with /preferences. Alerts are held back
+-- between quiet_start and quiet_end ("15:04", in timezone) and, when categories is not
+-- empty, only sent for incidents whose type or crime description contains one of them.
+CREATE TABLE IF NOT EXISTS subscribers (
+    user_id     TEXT PRIMARY KEY,
+    quiet_start TEXT NOT NULL DEFAULT '',
+    quiet_end   TEXT NOT NULL DEFAULT '',
+    timezone    TEXT NOT NULL DEFAULT '',
+    categories  TEXT[] NOT NULL DEFAULT '{}',
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
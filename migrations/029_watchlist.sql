@@ -0,0 +1,22 @@
+-- Addresses or hundred blocks a user is watching, managed with `unity-alerts watch` and
+-- /watch. Matching incidents ping the user, or DM them when channel_id is NULL, before any
+-- filter, mute or route is applied. block is empty when only the exact address is watched.
+CREATE TABLE IF NOT EXISTS watchlist (
+    id         SERIAL PRIMARY KEY,
+    user_id    TEXT NOT NULL,
+    channel_id TEXT,
+    address    TEXT NOT NULL,
+    block      TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS watchlist_address_idx ON watchlist (address);
+CREATE INDEX IF NOT EXISTS watchlist_block_idx ON watchlist (block) WHERE block <> '';
+
+-- Users already pinged about an incident, so one held back for a later run isn't repeated.
+CREATE TABLE IF NOT EXISTS watch_notifications (
+    incident_id INTEGER NOT NULL,
+    user_id     TEXT NOT NULL,
+    notified_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (incident_id, user_id)
+);
@@ -0,0 +1,20 @@
+-- Communities served by one deployment, each usually a Discord guild (id is the guild ID),
+-- managed with `unity-alerts tenant`. Their routes are loaded alongside the config file's.
+CREATE TABLE IF NOT EXISTS tenants (
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    timezone   TEXT NOT NULL DEFAULT '',
+    language   TEXT NOT NULL DEFAULT '',
+    enabled    BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- A tenant's routes, each in the same JSON shape as a route in the config file. Only
+-- webhook_url and channel_id delivery is accepted, and ${VAR} references are not expanded.
+CREATE TABLE IF NOT EXISTS tenant_routes (
+    tenant_id  TEXT NOT NULL REFERENCES tenants (id) ON DELETE CASCADE,
+    name       TEXT NOT NULL,
+    config     JSONB NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (tenant_id, name)
+);
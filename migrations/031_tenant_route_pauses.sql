@@ -0,0 +1,3 @@
+-- Lets a tenant's admins pause routes with /admin pause. Paused routes are not loaded until
+-- paused_until passes or /admin resume clears it.
+ALTER TABLE tenant_routes ADD COLUMN IF NOT EXISTS paused_until TIMESTAMPTZ;
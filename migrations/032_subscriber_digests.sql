@@ -0,0 +1,12 @@
+-- Subscribers who set /preferences delivery:digest get one email a day listing the previous
+-- day's incidents in their geofences instead of real-time pings. digest_sends records which
+-- days' digests went out.
+ALTER TABLE subscribers ADD COLUMN IF NOT EXISTS email TEXT NOT NULL DEFAULT '';
+ALTER TABLE subscribers ADD COLUMN IF NOT EXISTS digest BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS digest_sends (
+    user_id TEXT NOT NULL,
+    day     DATE NOT NULL,
+    sent_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, day)
+);
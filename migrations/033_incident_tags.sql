@@ -0,0 +1,5 @@
+-- Tags added by the filter's keyword rules (e.g. interstate, school-zone), stored so that
+-- routes, digests and queries can select incidents by tag instead of by raw feed fields.
+ALTER TABLE unified_incidents ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS unified_incidents_tags_idx ON unified_incidents USING GIN (tags);
@@ -0,0 +1,9 @@
+-- The next Kafka offset to consume for each partition of the incident queue topic, saved once
+-- the incidents before it are stored so a restart or a new leader picks up where it left off.
+CREATE TABLE IF NOT EXISTS queue_offsets (
+    topic       TEXT NOT NULL,
+    partition   INT NOT NULL,
+    next_offset BIGINT NOT NULL,
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (topic, partition)
+);
@@ -0,0 +1,16 @@
+-- Pauses for channel maintenance or a webhook rotation. A pause's mode says what happens to
+-- the incidents of the routes it covers: 'drop' them, 'hold' them in deferred_alerts until it
+-- ends, or hold them and 'summarize' them in one digest. Held pauses are cleared once their
+-- incidents are released.
+--
+-- Tenant routes are paused with /admin pause or /api/pauses. The config file's routes, and
+-- every route at once, are paused with /api/pauses or by editing the pauses table, whose scope
+-- is 'global' or 'route:<route name>' like feature_flags. Resuming sets paused_until to now.
+ALTER TABLE tenant_routes ADD COLUMN IF NOT EXISTS pause_mode TEXT NOT NULL DEFAULT 'drop';
+
+CREATE TABLE IF NOT EXISTS pauses (
+    scope        TEXT PRIMARY KEY,
+    paused_until TIMESTAMPTZ NOT NULL,
+    pause_mode   TEXT NOT NULL DEFAULT 'drop',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,9 @@
+-- How far each built-in poller has read its source, keyed by poller, e.g. "cap:IPAWS": when it
+-- last polled, so a source with its own poll_interval keeps it across restarts and replicas,
+-- and the time of the latest item it stored, below which items are skipped unread.
+CREATE TABLE IF NOT EXISTS source_cursors (
+    source     TEXT PRIMARY KEY,
+    high_water TIMESTAMPTZ,
+    polled_at  TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,3 @@
+-- Alerts too long for one Discord message are split across several. Every message is recorded,
+-- numbered from 0 for the first, so clearing an incident reaches its continuations too.
+ALTER TABLE alert_messages ADD COLUMN IF NOT EXISTS part INTEGER NOT NULL DEFAULT 0;
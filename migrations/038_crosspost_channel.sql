@@ -0,0 +1,4 @@
+-- Discord limits crossposts per channel, not per route, so each crosspost records its channel.
+ALTER TABLE alert_messages ADD COLUMN IF NOT EXISTS crosspost_channel TEXT;
+
+CREATE INDEX IF NOT EXISTS alert_messages_crosspost_channel_idx ON alert_messages (crosspost_channel, crossposted_at);
@@ -0,0 +1,8 @@
+-- Each subscriber is notified once per incident, even when the incident is picked up again
+-- because a route failed.
+CREATE TABLE IF NOT EXISTS subscription_notifications (
+    incident_id INTEGER NOT NULL,
+    user_id     TEXT NOT NULL,
+    notified_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (incident_id, user_id)
+);
@@ -0,0 +1,9 @@
+-- Camera frames are uploaded from memory and never written to disk, so captures record the
+-- name the file was uploaded under rather than a path. Older rows keep just their file name.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'camera_captures' AND column_name = 'file_path') THEN
+        ALTER TABLE camera_captures RENAME COLUMN file_path TO file_name;
+    END IF;
+END $$;
+UPDATE camera_captures SET file_name = regexp_replace(file_name, '^.*/', '') WHERE file_name LIKE '%/%';
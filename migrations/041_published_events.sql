@@ -0,0 +1,8 @@
+-- Lifecycle events published once per incident, such as "created", which would otherwise be
+-- published again each run an incident waits to be sent.
+CREATE TABLE IF NOT EXISTS published_events (
+    incident_id  INTEGER NOT NULL,
+    event        TEXT NOT NULL,
+    published_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (incident_id, event)
+);
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mtickle/unity-alerts/enrich"
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/sink/discord"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// defaultMuteDuration applies when a mute is created without one.
+const defaultMuteDuration = 24 * time.Hour
+
+// newMute validates and normalizes the criteria given to `unity-alerts mute` or /mute.
+// A radius needs an address, which is geocoded as its center.
+func newMute(address, road, eventType, radius, duration, createdBy string) (postgres.Mute, error) {
+	m := postgres.Mute{
+		Address:   incident.NormalizedAddress(incident.Incident{Address: address}),
+		Road:      strings.Join(strings.Fields(strings.ToUpper(road)), " "),
+		EventType: strings.TrimSpace(eventType),
+		CreatedBy: createdBy,
+	}
+	if m.Address == "" && m.Road == "" && m.EventType == "" {
+		return m, fmt.Errorf("a mute needs an address, road or event type")
+	}
+	d, err := parseMuteDuration(duration)
+	if err != nil {
+		return m, err
+	}
+	m.ExpiresAt = time.Now().Add(d)
+
+	if strings.TrimSpace(radius) != "" {
+		if m.Address == "" {
+			return m, fmt.Errorf("a mute radius needs an address")
+		}
+		meters, err := parseRadius(radius)
+		if err != nil {
+			return m, err
+		}
+		lat, lon, err := enrich.Geocode(context.Background(), os.Getenv("GOOGLE_MAPS_API_KEY"), address)
+		if err != nil {
+			return m, err
+		}
+		m.Latitude = sql.NullFloat64{Float64: lat, Valid: true}
+		m.Longitude = sql.NullFloat64{Float64: lon, Valid: true}
+		m.RadiusMeters = sql.NullFloat64{Float64: meters, Valid: true}
+	}
+	return m, nil
+}
+
+// parseMuteDuration reads a Go duration such as "90m" or "12h", or a number of days such as "3d".
+func parseMuteDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return defaultMuteDuration, nil
+	}
+	var d time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		var n float64
+		n, err = strconv.ParseFloat(days, 64)
+		d = time.Duration(n * float64(24*time.Hour))
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid mute duration %q", s)
+	}
+	return d, nil
+}
+
+// describeMute summarizes a mute's criteria, e.g. "100 MAIN ST (within 0.3 mi) · I-40 W · ALARM".
+func describeMute(m postgres.Mute) string {
+	var parts []string
+	if m.Address != "" {
+		if m.RadiusMeters.Valid {
+			parts = append(parts, fmt.Sprintf("%s (within %.1f mi)", m.Address, m.RadiusMeters.Float64/metersPerMile))
+		} else {
+			parts = append(parts, m.Address)
+		}
+	}
+	for _, p := range []string{m.Road, m.EventType} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+// mute adds, lists or removes mutes, with the subcommands and options of /mute.
+//
+//	unity-alerts mute add [--address ...] [--road ...] [--type ...] [--radius 500m] [--duration 24h]
+//	unity-alerts mute list
+//	unity-alerts mute remove --id 12
+func (a *app) mute(args []string) error {
+	sub, args, err := subcommand("mute", args, "add", "list", "remove")
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("mute "+sub, flag.ContinueOnError)
+	switch sub {
+	case "list":
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		mutes, err := postgres.ActiveMutes(a.db)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tEXPIRES\tCREATED BY\tMATCHES")
+		for _, m := range mutes {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", m.ID, m.ExpiresAt.Local().Format(time.DateTime), m.CreatedBy, describeMute(m))
+		}
+		return w.Flush()
+	case "remove":
+		id := fs.Int("id", 0, "mute ID from mute list")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if *id == 0 {
+			return fmt.Errorf("mute remove requires --id")
+		}
+		removed, err := postgres.DeleteMute(a.db, *id)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return fmt.Errorf("no mute with ID %d", *id)
+		}
+		fmt.Printf("Removed mute %d.\n", *id)
+		return nil
+	}
+
+	address := fs.String("address", "", "silence incidents at this address")
+	road := fs.String("road", "", "silence incidents on this road, e.g. \"I-40 W\"")
+	eventType := fs.String("type", "", "silence incidents whose event type contains this text")
+	radius := fs.String("radius", "", "silence everything within this distance of --address, e.g. 500m")
+	duration := fs.String("duration", "", "how long the mute lasts, e.g. 12h or 3d (default 24h)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	m, err := newMute(*address, *road, *eventType, *radius, *duration, "cli")
+	if err != nil {
+		return err
+	}
+	id, err := postgres.AddMute(a.db, m)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created mute %d: %s, until %s.\n", id, describeMute(m), m.ExpiresAt.Local().Format(time.DateTime))
+	return nil
+}
+
+// handleMute runs the /mute add, list and remove subcommands, for server admins only.
+func (a *app) handleMute(interaction Interaction, opts discord.RenderOptions) interactionResponse {
+	if !interaction.isAdmin() {
+		return ephemeralReply(opts.T("mute_forbidden"), nil)
+	}
+	if len(interaction.Data.Options) == 0 {
+		return ephemeralReply(opts.T("cmd_error"), nil)
+	}
+	sub := interaction.Data.Options[0]
+	var err error
+	switch sub.Name {
+	case "add":
+		var m postgres.Mute
+		m, err = newMute(option(sub.Options, "address").stringValue(), option(sub.Options, "road").stringValue(),
+			option(sub.Options, "type").stringValue(), option(sub.Options, "radius").stringValue(),
+			option(sub.Options, "duration").stringValue(), interaction.user().DisplayName())
+		if err != nil {
+			return ephemeralReply(fmt.Sprintf(opts.T("mute_bad_input"), discord.SanitizeFeedText(err.Error())), nil)
+		}
+		var id int
+		if id, err = postgres.AddMute(a.db, m); err == nil {
+			return ephemeralReply(fmt.Sprintf(opts.T("mute_created"), id, discord.SanitizeFeedText(describeMute(m)), opts.FormatLocalTime(m.ExpiresAt)), nil)
+		}
+	case "list":
+		var mutes []postgres.Mute
+		if mutes, err = postgres.ActiveMutes(a.db); err == nil {
+			return ephemeralReply("", []discord.Embed{buildMuteListEmbed(mutes, opts)})
+		}
+	case "remove":
+		id := int(option(sub.Options, "id").floatValue(0))
+		var removed bool
+		if removed, err = postgres.DeleteMute(a.db, id); err == nil {
+			if !removed {
+				return ephemeralReply(fmt.Sprintf(opts.T("mute_not_found"), id), nil)
+			}
+			return ephemeralReply(fmt.Sprintf(opts.T("mute_removed"), id), nil)
+		}
+	default:
+		err = fmt.Errorf("unknown subcommand %q", sub.Name)
+	}
+	log.Printf("Error handling /mute %s: %v", sub.Name, err)
+	return ephemeralReply(opts.T("cmd_error"), nil)
+}
+
+// buildMuteListEmbed shows one field per active mute.
+func buildMuteListEmbed(mutes []postgres.Mute, opts discord.RenderOptions) discord.Embed {
+	embed := discord.Embed{
+		Title:     opts.T("mute_list_title"),
+		Color:     3447003, // Blue
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+	if len(mutes) == 0 {
+		embed.Fields = []discord.EmbedField{{Name: discord.ZeroWidthSpace, Value: opts.T("mute_none")}}
+		return embed
+	}
+	for _, m := range mutes {
+		if len(embed.Fields) == discord.MaxFieldsPerEmbed {
+			break
+		}
+		embed.Fields = append(embed.Fields, discord.EmbedField{
+			Name:  discord.Truncate(fmt.Sprintf("#%d — %s", m.ID, discord.SanitizeFeedText(m.CreatedBy)), discord.MaxFieldName),
+			Value: discord.Truncate(fmt.Sprintf(opts.T("mute_entry"), discord.SanitizeFeedText(describeMute(m)), opts.FormatLocalTime(m.ExpiresAt)), discord.MaxFieldValue),
+		})
+	}
+	return embed
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mtickle/unity-alerts/sink/discord"
+	"github.com/mtickle/unity-alerts/sink/oncall"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// OpsConfig reports failures of the alerting pipeline itself, so they aren't silent: a route
+// whose deliveries fail FailureStreak times in a row, or as many consecutive failed polls of
+// the incident database. Each is posted to WebhookURL, paged through the named Escalation, or
+// both, and followed by a recovery notice.
+type OpsConfig struct {
+	WebhookURL    string `json:"webhook_url,omitempty"`
+	Escalation    string `json:"escalation,omitempty"`     // Name of an entry in escalations.
+	FailureStreak int    `json:"failure_streak,omitempty"` // Default 3.
+	Cooldown      string `json:"cooldown,omitempty"`       // Before repeating an open alert (default "1h").
+}
+
+func (o *OpsConfig) validate(c *Config) error {
+	if o == nil {
+		return nil
+	}
+	if o.WebhookURL == "" && o.Escalation == "" {
+		return fmt.Errorf("ops needs webhook_url or escalation")
+	}
+	if o.WebhookURL != "" {
+		if err := validWebhookURL(os.ExpandEnv(o.WebhookURL)); err != nil {
+			return fmt.Errorf("ops: %w", err)
+		}
+	}
+	if o.Escalation != "" {
+		if _, ok := c.escalation(o.Escalation); !ok {
+			return fmt.Errorf("ops.escalation: unknown escalation %q", o.Escalation)
+		}
+	}
+	if o.FailureStreak < 0 {
+		return fmt.Errorf("ops.failure_streak must not be negative")
+	}
+	if o.Cooldown != "" {
+		if d, err := time.ParseDuration(o.Cooldown); err != nil || d <= 0 {
+			return fmt.Errorf("ops.cooldown: invalid duration %q", o.Cooldown)
+		}
+	}
+	return nil
+}
+
+// settings returns the configuration with defaults filled in.
+func (o OpsConfig) settings() (streak int, cooldown time.Duration) {
+	streak, cooldown = 3, time.Hour
+	if o.FailureStreak > 0 {
+		streak = o.FailureStreak
+	}
+	if o.Cooldown != "" {
+		cooldown, _ = time.ParseDuration(o.Cooldown)
+	}
+	return streak, cooldown
+}
+
+// escalation looks up an escalation by name.
+func (c *Config) escalation(name string) (EscalationConfig, bool) {
+	for _, e := range c.Escalations {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return EscalationConfig{}, false
+}
+
+// checkDeliveryFailures raises an ops alert for each route whose recent deliveries all
+// failed, and a recovery notice once one succeeds again.
+func (a *app) checkDeliveryFailures(cfg *Config) {
+	if cfg.Ops == nil {
+		return
+	}
+	threshold, cooldown := cfg.Ops.settings()
+	streaks, err := postgres.FailureStreaks(a.db)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+	opts := cfg.RenderOptions(RouteConfig{}, "")
+	for _, route := range cfg.Routes {
+		key := "delivery:" + route.Name
+		title := fmt.Sprintf(opts.T("ops_delivery_title"), route.Name)
+		streak := streaks[route.Name]
+		if streak.Failures < threshold {
+			if streak.Failures > 0 {
+				continue
+			}
+			resolved, err := postgres.ResolveOpsAlert(a.db, key)
+			if err != nil {
+				log.Printf("Warning: %v", err)
+			} else if resolved {
+				a.opsRecovered(cfg, key, title, "")
+			}
+			continue
+		}
+		claimed, err := postgres.ClaimOpsAlert(a.db, key, cooldown)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		if claimed {
+			a.opsAlert(cfg, key, title, streak.Failures, streak.LastError)
+		}
+	}
+}
+
+// pollState tracks consecutive failed polls in a file, since the database that would
+// otherwise hold it is what is failing. STATE_DIR sets its directory (default: the temp dir);
+// in one-shot mode it must outlive each run, e.g. on a persistent volume.
+type pollState struct {
+	Failures  int    `json:"failures"`
+	LastError string `json:"last_error"`
+	Alerted   bool   `json:"alerted"`
+}
+
+func pollStatePath() string {
+	dir := os.Getenv("STATE_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "unity-alerts-poll.json")
+}
+
+// recordPoll counts a poll of the incident database, raising an ops alert when FailureStreak
+// polls in a row have failed and a recovery notice at the next success.
+func (a *app) recordPoll(cfg *Config, pollErr error) {
+	if cfg.Ops == nil {
+		return
+	}
+	path := pollStatePath()
+	var state pollState
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &state)
+	}
+	const key = "poll"
+	opts := cfg.RenderOptions(RouteConfig{}, "")
+	title := opts.T("ops_poll_title")
+	if pollErr == nil {
+		if state.Failures == 0 {
+			return
+		}
+		if state.Alerted {
+			a.opsRecovered(cfg, key, title, fmt.Sprintf(opts.T("ops_recovered_after"), state.Failures))
+		}
+		if err := os.Remove(path); err != nil {
+			log.Printf("Warning: failed to reset poll state: %v", err)
+		}
+		return
+	}
+
+	state.Failures++
+	state.LastError = pollErr.Error()
+	threshold, _ := cfg.Ops.settings()
+	if state.Failures >= threshold && !state.Alerted {
+		a.opsAlert(cfg, key, title, state.Failures, state.LastError)
+		state.Alerted = true
+	}
+	data, _ := json.Marshal(state)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		log.Printf("Warning: failed to save poll state: %v", err)
+	}
+}
+
+// recordConnectFailure counts failing to reach the database at startup as a failed poll, so
+// a database that stays down raises an ops alert even though no run gets far enough to poll.
+// The app isn't set up yet, so the config is loaded here on its own.
+func recordConnectFailure(connectErr error) {
+	configStore, err := newConfigStore(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Printf("Warning: failed to load config for ops alerts: %v", err)
+		return
+	}
+	a := &app{config: configStore, reporter: newErrorReporter()}
+	a.recordPoll(configStore.Current(), fmt.Errorf("failed to connect to database: %w", connectErr))
+}
+
+// opsAlert posts and pages an ops alert.
+func (a *app) opsAlert(cfg *Config, key, title string, failures int, lastError string) {
+	log.Printf("Ops alert: %s (%d consecutive failures).", title, failures)
+	opts := cfg.RenderOptions(RouteConfig{}, "")
+	if r := []rune(lastError); len(r) > 1000 {
+		lastError = string(r[:1000]) + "…"
+	}
+	if cfg.Ops.WebhookURL != "" {
+		embed := discord.Embed{
+			Title: "⚠️ " + title,
+			Color: 15158332, // Red
+			Fields: []discord.EmbedField{
+				{Name: opts.T("ops_failures"), Value: fmt.Sprint(failures), Inline: true},
+				{Name: opts.T("ops_last_error"), Value: discord.SanitizeFeedText(lastError), Inline: false},
+			},
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		}
+		a.postOps(cfg, embed)
+	}
+	if e, ok := cfg.escalation(cfg.Ops.Escalation); ok {
+		alert := oncall.Alert{
+			DedupKey: "unity-alerts-ops-" + key,
+			Summary:  title,
+			Source:   "unity-alerts",
+			Severity: "critical",
+			Details:  map[string]string{"failures": fmt.Sprint(failures), "last_error": lastError},
+		}
+		if err := e.pager().Trigger(alert); err != nil {
+			log.Printf("Error paging ops alert: %v", err)
+		}
+	}
+}
+
+// opsRecovered posts a recovery notice and resolves the page.
+func (a *app) opsRecovered(cfg *Config, key, title, detail string) {
+	log.Printf("Ops recovered: %s.", title)
+	opts := cfg.RenderOptions(RouteConfig{}, "")
+	if cfg.Ops.WebhookURL != "" {
+		embed := discord.Embed{
+			Title:     fmt.Sprintf(opts.T("ops_recovered_title"), title),
+			Color:     3066993, // Green
+			Footer:    discord.EmbedFooter{Text: detail},
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+		}
+		a.postOps(cfg, embed)
+	}
+	if e, ok := cfg.escalation(cfg.Ops.Escalation); ok {
+		if err := e.pager().Resolve("unity-alerts-ops-" + key); err != nil {
+			log.Printf("Error resolving ops page: %v", err)
+		}
+	}
+}
+
+func (a *app) postOps(cfg *Config, embed discord.Embed) {
+	opts := cfg.RenderOptions(RouteConfig{}, "")
+	payload := discord.WebhookPayload{Username: opts.T("bot_username"), Embeds: []discord.Embed{embed}}
+	if _, err := (discord.WebhookMessenger{URL: os.ExpandEnv(cfg.Ops.WebhookURL)}).Send(payload); err != nil {
+		log.Printf("Error posting ops alert: %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/mtickle/unity-alerts/sink/discord"
+)
+
+// OverlapConfig detects reports of the same event from different feeds within one run and
+// sends them as a single alert. Precedence decides which feed's text wins in the merged embed.
+type OverlapConfig struct {
+	RadiusMeters float64 `json:"radius_meters"`
+	Window       string  `json:"window"` // Longest gap between the reports, e.g. "15m".
+
+	// Precedence lists sources, most trusted first. The first one present supplies the title,
+	// color and images, and any field not listed in Fields.
+	Precedence []string `json:"precedence"`
+
+	// Fields overrides the order for individual fields, keyed by their locale key, e.g.
+	// {"field_reason": ["RWECC"], "field_road": ["NCDOT"]}.
+	Fields map[string][]string `json:"fields,omitempty"`
+}
+
+func (o *OverlapConfig) validate() error {
+	if o == nil {
+		return nil
+	}
+	if o.RadiusMeters <= 0 {
+		return fmt.Errorf("overlap.radius_meters must be positive")
+	}
+	if d, err := time.ParseDuration(o.Window); err != nil || d <= 0 {
+		return fmt.Errorf("overlap.window: invalid duration %q", o.Window)
+	}
+	if len(o.Precedence) == 0 {
+		return fmt.Errorf("overlap.precedence must list at least one source")
+	}
+	return nil
+}
+
+// rank is the source's position in Precedence; unlisted sources come last.
+func (o *OverlapConfig) rank(source string) int {
+	for i, s := range o.Precedence {
+		if s == source {
+			return i
+		}
+	}
+	return len(o.Precedence)
+}
+
+// mergeOverlaps folds each incident into the best-ranked overlapping report from another
+// source. Merged incidents are sent as part of that report's alert.
+func (a *app) mergeOverlaps(cfg *Config, pending []*pendingIncident) {
+	o := cfg.Overlap
+	if o == nil {
+		return
+	}
+	window, _ := time.ParseDuration(o.Window)
+	sorted := append([]*pendingIncident(nil), pending...)
+	sort.SliceStable(sorted, func(x, y int) bool {
+		return o.rank(sorted[x].incident.Source) < o.rank(sorted[y].incident.Source)
+	})
+	for x, p := range sorted {
+		if p.mergedInto != nil {
+			continue
+		}
+		for _, q := range sorted[x+1:] {
+			if q.mergedInto != nil || !o.overlaps(p, q, window) {
+				continue
+			}
+			log.Printf("Incident %d (%s) reports the same event as incident %d (%s); merging.",
+				q.incident.ID, q.incident.Source, p.incident.ID, p.incident.Source)
+			q.mergedInto = p
+			p.merged = append(p.merged, q)
+		}
+	}
+}
+
+// overlaps reports whether q is a report of p's event from a feed p has not merged yet,
+// going to exactly the same routes.
+func (o *OverlapConfig) overlaps(p, q *pendingIncident, window time.Duration) bool {
+	sources := map[string]bool{p.incident.Source: true}
+	for _, m := range p.merged {
+		sources[m.incident.Source] = true
+	}
+	if sources[q.incident.Source] || !sameRoutes(p.routes, q.routes) {
+		return false
+	}
+	pi, qi := p.incident, q.incident
+	if !pi.Latitude.Valid || !pi.Longitude.Valid || !qi.Latitude.Valid || !qi.Longitude.Valid {
+		return false
+	}
+	gap := pi.Timestamp.Sub(qi.Timestamp)
+	if gap < -window || gap > window {
+		return false
+	}
+	return distanceMeters(pi.Latitude.Float64, pi.Longitude.Float64, qi.Latitude.Float64, qi.Longitude.Float64) <= o.RadiusMeters
+}
+
+func sameRoutes(x, y []RouteConfig) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	names := make(map[string]bool)
+	for _, r := range x {
+		names[r.Name] = true
+	}
+	for _, r := range y {
+		if !names[r.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+// distanceMeters is the great-circle distance between two points.
+func distanceMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusMeters = 6371000
+	rad := math.Pi / 180
+	dLat, dLon := (lat2-lat1)*rad, (lon2-lon1)*rad
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(h))
+}
+
+// sourcedPayload is one feed's rendering of a merged event.
+type sourcedPayload struct {
+	source  string
+	payload discord.WebhookPayload
+}
+
+// mergePayloads combines the alerts built for overlapping reports, primary first. The primary
+// keeps its title, color, images and extra embeds; each field comes from the source ranked
+// first for it.
+func (o *OverlapConfig) mergePayloads(opts discord.RenderOptions, reports []sourcedPayload) discord.WebhookPayload {
+	merged := reports[0].payload
+	if len(merged.Embeds) == 0 {
+		return merged
+	}
+
+	var names []string
+	fields := make(map[string]map[string][]discord.EmbedField) // Field name, then source.
+	for _, r := range reports {
+		if len(r.payload.Embeds) == 0 {
+			continue
+		}
+		for _, f := range r.payload.Embeds[0].Fields {
+			if fields[f.Name] == nil {
+				fields[f.Name] = make(map[string][]discord.EmbedField)
+				names = append(names, f.Name)
+			}
+			fields[f.Name][r.source] = append(fields[f.Name][r.source], f)
+		}
+	}
+
+	embed := merged.Embeds[0]
+	embed.Fields = nil
+	for _, name := range names {
+		var order []string
+		for key, sources := range o.Fields {
+			if opts.T(key) == name {
+				order = append(order, sources...)
+				break
+			}
+		}
+		order = append(order, o.Precedence...)
+		for _, r := range reports {
+			order = append(order, r.source)
+		}
+		for _, source := range order {
+			if f, ok := fields[name][source]; ok {
+				embed.Fields = append(embed.Fields, f...)
+				break
+			}
+		}
+	}
+	merged.Embeds = append([]discord.Embed{embed}, merged.Embeds[1:]...)
+	return merged
+}
+
+// buildAlert renders an incident's alert for a route, merged with any overlapping reports.
+func (a *app) buildAlert(cfg *Config, mapsAPIKey string, opts discord.RenderOptions, p *pendingIncident) (discord.WebhookPayload, error) {
+	payload, err := discord.BuildPayload(mapsAPIKey, p.incident, p.enrichment, opts)
+	if err != nil || len(p.merged) == 0 {
+		return payload, err
+	}
+	reports := []sourcedPayload{{p.incident.Source, payload}}
+	for _, m := range p.merged {
+		other, err := discord.BuildPayload(mapsAPIKey, m.incident, m.enrichment, opts)
+		if err != nil {
+			log.Printf("Warning: could not build merged report for incident %d: %v", m.incident.ID, err)
+			continue
+		}
+		reports = append(reports, sourcedPayload{m.incident.Source, other})
+	}
+	return cfg.Overlap.mergePayloads(opts, reports), nil
+}
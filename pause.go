@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/sink/discord"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// Routes are paused during channel maintenance or a webhook rotation: tenant routes with
+// /admin pause or /api/pauses, and the config file's routes, or every route at once, with
+// /api/pauses or the pauses table. A route paused in the default drop mode is not loaded until
+// the pause ends. One paused to hold or summarize stays loaded and its incidents are held in
+// deferred_alerts, like those outside a deferring schedule, then released once the pause ends:
+// alerted one by one, or summarized in a single digest.
+
+// globalPause is the scope of the pause covering every route.
+const globalPause = "global"
+
+// routePause is the scope of a config file route's pause.
+func routePause(name string) string {
+	return "route:" + name
+}
+
+// held reports whether a route's pause is holding its incidents now.
+func (r RouteConfig) held(now time.Time) bool {
+	return r.PausedUntil.After(now)
+}
+
+// parsePauseMode validates a pause mode, where "" means postgres.PauseDrop.
+func parsePauseMode(mode string) (string, error) {
+	switch mode = strings.ToLower(strings.TrimSpace(mode)); mode {
+	case "":
+		return postgres.PauseDrop, nil
+	case postgres.PauseDrop, postgres.PauseHold, postgres.PauseSummarize:
+		return mode, nil
+	}
+	return "", fmt.Errorf("mode must be %s, %s or %s", postgres.PauseDrop, postgres.PauseHold, postgres.PauseSummarize)
+}
+
+// withPauses returns a copy of the config with the pauses table applied. A route takes the
+// pause of its own scope or the global one, whichever ends later, over its tenant pause if
+// that ends earlier. Routes paused to drop their incidents are left out until the pause ends;
+// the others take its end and mode, which are kept once it ends until releasePaused runs.
+func (c *Config) withPauses(pauses []postgres.Pause, now time.Time) *Config {
+	if len(pauses) == 0 {
+		return c
+	}
+	scopes := make(map[string]postgres.Pause)
+	for _, p := range pauses {
+		scopes[p.Scope] = p
+	}
+	cfg := *c
+	cfg.Routes = nil
+	for _, route := range c.Routes {
+		p, ok := scopes[routePause(route.Name)]
+		if global, found := scopes[globalPause]; found && (!ok || global.PausedUntil.After(p.PausedUntil)) {
+			p, ok = global, true
+		}
+		switch {
+		case !ok || !p.PausedUntil.After(route.PausedUntil):
+		case p.PauseMode != postgres.PauseDrop:
+			route.PausedUntil, route.PauseMode = p.PausedUntil, p.PauseMode
+		case p.PausedUntil.After(now):
+			continue
+		}
+		cfg.Routes = append(cfg.Routes, route)
+	}
+	return &cfg
+}
+
+// releasePaused releases the incidents held for routes whose pause has ended, unless the
+// route is outside a deferring schedule, which then releases them when its window opens.
+func (a *app) releasePaused(cfg *Config) {
+	now := time.Now()
+	for _, route := range cfg.Routes {
+		if route.PausedUntil.IsZero() || route.held(now) {
+			continue
+		}
+		if !route.Schedule.Active(now.In(cfg.Location(route))) {
+			if !route.Schedule.Defers() {
+				log.Printf("Route %q is outside its schedule; dropping the incidents held while it was paused.", route.Name)
+				a.dropDeferred(route)
+			}
+		} else if route.PauseMode == postgres.PauseSummarize {
+			a.summarizeDeferred(cfg, route)
+		} else {
+			a.releaseDeferred(cfg, route)
+		}
+		if route.Tenant != "" {
+			if err := postgres.ClearTenantRoutePause(a.db, route.Tenant, strings.TrimPrefix(route.Name, route.Tenant+"/")); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+		log.Printf("Pause of route %q ended.", route.Name)
+	}
+	if err := postgres.ClearPauses(a.db, now); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// summarizeDeferred posts one digest of the incidents held for a route instead of alerting
+// each, and lets them go.
+func (a *app) summarizeDeferred(cfg *Config, route RouteConfig) {
+	ids, err := postgres.DeferredAlerts(a.db, route.Name)
+	if err != nil {
+		log.Printf("Error loading deferred alerts for route %q: %v", route.Name, err)
+		return
+	}
+	var held []incident.Incident
+	for _, id := range ids {
+		incidents, _, err := queryIncidents(cfg, a.db, "WHERE {id} = $1", id)
+		if err != nil {
+			log.Printf("Error loading deferred incident %d: %v", id, err)
+			return
+		}
+		if len(incidents) == 1 && cfg.Filter.Apply(&incidents[0]) {
+			held = append(held, incidents[0])
+		}
+	}
+	if len(held) > 0 {
+		opts := cfg.RenderOptions(route, "")
+		embed := buildCatchUpEmbed(held, opts)
+		embed.Title = discord.Truncate("⏸️ "+fmt.Sprintf(opts.T("pause_summary_title"), len(held)), discord.MaxEmbedTitle)
+		embed.Footer = discord.EmbedFooter{Text: opts.T("pause_summary_footer")}
+		payload := discord.WebhookPayload{Username: opts.T("bot_username"), Embeds: []discord.Embed{embed}}
+		if _, err := reportMessenger(route).Send(payload); err != nil {
+			log.Printf("Error posting pause summary to route %q: %v", route.Name, err)
+			return
+		}
+		log.Printf("Summarized %d incident(s) held while route %q was paused.", len(held), route.Name)
+	}
+	for _, id := range ids {
+		if err := postgres.DeleteDeferredAlert(a.db, id, route.Name); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
+
+// dropDeferred lets go of the incidents held for a route without sending them.
+func (a *app) dropDeferred(route RouteConfig) {
+	ids, err := postgres.DeferredAlerts(a.db, route.Name)
+	if err != nil {
+		log.Printf("Error loading deferred alerts for route %q: %v", route.Name, err)
+		return
+	}
+	for _, id := range ids {
+		if err := postgres.DeleteDeferredAlert(a.db, id, route.Name); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
+
+// handlePauses serves /api/pauses, which pauses and resumes routes:
+//
+//	POST   /api/pauses                            {"tenant": "123", "route": "traffic", "duration": "2h", "mode": "summarize"}
+//	DELETE /api/pauses?tenant=123&route=traffic
+//
+// With a tenant, it pauses the tenant's route like /admin pause and /admin resume, or all of
+// the tenant's routes when route is empty. Without one, it pauses the config file's route, or
+// every route, the tenants' included, when route is empty too. The reply is {"routes": n}, the
+// number of routes changed. Pausing changes what is sent, so like the gRPC Resend method it is
+// unavailable without API_TOKEN.
+func (a *app) handlePauses(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if os.Getenv("API_TOKEN") == "" {
+		http.Error(w, "pausing requires API_TOKEN to be set", http.StatusForbidden)
+		return
+	}
+
+	var tenant, route, mode string
+	var d time.Duration
+	if r.Method == http.MethodPost {
+		var req struct {
+			Tenant   string `json:"tenant"`
+			Route    string `json:"route"`
+			Duration string `json:"duration"`
+			Mode     string `json:"mode"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 64<<10)).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		var derr, merr error
+		d, derr = parseMuteDuration(req.Duration)
+		mode, merr = parsePauseMode(req.Mode)
+		if input := errors.Join(derr, merr); input != nil {
+			http.Error(w, input.Error(), http.StatusBadRequest)
+			return
+		}
+		tenant, route = strings.TrimSpace(req.Tenant), strings.TrimSpace(req.Route)
+	} else {
+		q := r.URL.Query()
+		tenant, route = strings.TrimSpace(q.Get("tenant")), strings.TrimSpace(q.Get("route"))
+	}
+
+	var changed int64
+	var err error
+	switch {
+	case tenant != "" && r.Method == http.MethodPost:
+		changed, err = postgres.PauseTenantRoutes(a.db, tenant, route, time.Now().Add(d), mode)
+	case tenant != "":
+		changed, err = postgres.ResumeTenantRoutes(a.db, tenant, route)
+	default:
+		changed, err = a.pauseScope(route, r.Method == http.MethodPost, postgres.Pause{PausedUntil: time.Now().Add(d), PauseMode: mode})
+	}
+	if err != nil {
+		log.Printf("Error serving /api/pauses: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if changed == 0 {
+		http.Error(w, "no such route", http.StatusNotFound)
+		return
+	}
+	log.Printf("%d route(s) changed through /api/pauses.", changed)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"routes": changed})
+}
+
+// pauseScope pauses or resumes a config file route, or every route when route is empty, and
+// returns how many routes that changes.
+func (a *app) pauseScope(route string, pause bool, p postgres.Pause) (int64, error) {
+	cfg := a.config.Current()
+	p.Scope = routePause(route)
+	changed := int64(1)
+	if route == "" {
+		tenantRoutes, err := loadTenantRoutes(a.db)
+		if err != nil {
+			return 0, err
+		}
+		p.Scope, changed = globalPause, int64(len(cfg.withTenantRoutes(tenantRoutes).Routes))
+	} else if _, ok := cfg.Route(route); !ok {
+		return 0, nil
+	}
+	if !pause {
+		resumed, err := postgres.ResumePause(a.db, p.Scope)
+		if err != nil || !resumed {
+			return 0, err
+		}
+		return changed, nil
+	}
+	return changed, postgres.SavePause(a.db, p)
+}
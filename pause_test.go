@@ -0,0 +1,104 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+func TestRouteHeld(t *testing.T) {
+	now := time.Date(2024, time.June, 7, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		route RouteConfig
+		want  bool
+	}{
+		{"not paused", RouteConfig{}, false},
+		{"held", RouteConfig{PausedUntil: now.Add(time.Hour), PauseMode: postgres.PauseHold}, true},
+		{"ended", RouteConfig{PausedUntil: now.Add(-time.Minute), PauseMode: postgres.PauseSummarize}, false},
+		{"ends now", RouteConfig{PausedUntil: now, PauseMode: postgres.PauseHold}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.route.held(now); got != tt.want {
+				t.Errorf("held() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithPauses(t *testing.T) {
+	now := time.Date(2024, time.June, 7, 12, 0, 0, 0, time.UTC)
+	later, ended := now.Add(2*time.Hour), now.Add(-time.Minute)
+	tenantUntil := now.Add(3 * time.Hour)
+	cfg := &Config{Routes: []RouteConfig{
+		{Name: "traffic"},
+		{Name: "fire"},
+		{Name: "123/alerts", Tenant: "123", PausedUntil: tenantUntil, PauseMode: postgres.PauseHold},
+	}}
+	type paused struct {
+		until time.Time
+		mode  string
+	}
+	tests := []struct {
+		name   string
+		pauses []postgres.Pause
+		want   map[string]paused // Loaded routes.
+	}{
+		{"none", nil, map[string]paused{
+			"traffic": {}, "fire": {}, "123/alerts": {tenantUntil, postgres.PauseHold}}},
+		{"route dropped", []postgres.Pause{{Scope: "route:traffic", PausedUntil: later, PauseMode: postgres.PauseDrop}}, map[string]paused{
+			"fire": {}, "123/alerts": {tenantUntil, postgres.PauseHold}}},
+		{"drop ended", []postgres.Pause{{Scope: "route:traffic", PausedUntil: ended, PauseMode: postgres.PauseDrop}}, map[string]paused{
+			"traffic": {}, "fire": {}, "123/alerts": {tenantUntil, postgres.PauseHold}}},
+		{"route held", []postgres.Pause{{Scope: "route:traffic", PausedUntil: later, PauseMode: postgres.PauseSummarize}}, map[string]paused{
+			"traffic": {later, postgres.PauseSummarize}, "fire": {}, "123/alerts": {tenantUntil, postgres.PauseHold}}},
+		{"hold ended, not yet released", []postgres.Pause{{Scope: "route:traffic", PausedUntil: ended, PauseMode: postgres.PauseHold}}, map[string]paused{
+			"traffic": {ended, postgres.PauseHold}, "fire": {}, "123/alerts": {tenantUntil, postgres.PauseHold}}},
+		{"global held, tenant pause ends later", []postgres.Pause{{Scope: "global", PausedUntil: later, PauseMode: postgres.PauseHold}}, map[string]paused{
+			"traffic": {later, postgres.PauseHold}, "fire": {later, postgres.PauseHold}, "123/alerts": {tenantUntil, postgres.PauseHold}}},
+		{"global dropped", []postgres.Pause{{Scope: "global", PausedUntil: tenantUntil.Add(time.Hour), PauseMode: postgres.PauseDrop}}, map[string]paused{}},
+		{"route pause ends after global", []postgres.Pause{
+			{Scope: "global", PausedUntil: later, PauseMode: postgres.PauseHold},
+			{Scope: "route:fire", PausedUntil: tenantUntil, PauseMode: postgres.PauseDrop},
+		}, map[string]paused{
+			"traffic": {later, postgres.PauseHold}, "123/alerts": {tenantUntil, postgres.PauseHold}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string]paused)
+			for _, route := range cfg.withPauses(tt.pauses, now).Routes {
+				got[route.Name] = paused{route.PausedUntil, route.PauseMode}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("routes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePauseMode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"", postgres.PauseDrop, false},
+		{"drop", postgres.PauseDrop, false},
+		{" Hold ", postgres.PauseHold, false},
+		{"SUMMARIZE", postgres.PauseSummarize, false},
+		{"queue", "", true},
+	}
+	for _, tt := range tests {
+		got, err := parsePauseMode(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("parsePauseMode(%q) = %q, %v", tt.in, got, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), "mode must be") {
+			t.Errorf("parsePauseMode(%q) error = %v", tt.in, err)
+		}
+	}
+}
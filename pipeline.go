@@ -0,0 +1,803 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mtickle/unity-alerts/enrich"
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/internal/syslog"
+	"github.com/mtickle/unity-alerts/sink/discord"
+	"github.com/mtickle/unity-alerts/sink/signalcli"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// app bundles the long-lived dependencies shared by every run.
+type app struct {
+	db            *sql.DB
+	reporter      ErrorReporter
+	config        *ConfigStore
+	notifyDiscord string
+	images        *imageHost      // Nil unless IMAGE_BASE_URL is set.
+	influx        *influxExporter // Nil unless INFLUX_WRITE_URL is set.
+	homeAssistant *homeAssistant  // Nil unless MQTT_URL is set.
+	sloBreached   bool            // Whether the last latency check exceeded latency_slo.
+	capLinks      map[string]bool // CAP alert links already fetched.
+	events        *eventPublisher // Nil until the first event is published.
+	hub           eventHub        // In-process event subscribers.
+	syslogWriter  *syslog.Writer  // Nil until the first event is sent to syslog.
+	syslogAddress string          // The address syslog sends to.
+	cycleMu       sync.Mutex      // Held by runCycle, and by API calls that send alerts.
+	writes        incidentWrites  // Updates to the incidents table buffered during a job.
+
+	failedDeliveries atomic.Int64 // Deliveries that failed since startup.
+	busySince        atomic.Int64 // When the daemon's running job started, in Unix nanoseconds; 0 when idle.
+}
+
+// stalled reports whether a daemon job has been running for longer than limit, and for how
+// long.
+func (a *app) stalled(limit time.Duration) (time.Duration, bool) {
+	since := a.busySince.Load()
+	if since == 0 {
+		return 0, false
+	}
+	running := time.Since(time.Unix(0, since))
+	return running, running > limit
+}
+
+// currentConfig is the loaded config with the database feature flag overrides applied, the
+// tenants' routes added and the pauses applied.
+func (a *app) currentConfig() *Config {
+	dbFeatures, err := loadFeatureFlags(a.db)
+	if err != nil {
+		log.Printf("Warning: could not load feature flag overrides: %v", err)
+	}
+	tenantRoutes, err := loadTenantRoutes(a.db)
+	if err != nil {
+		log.Printf("Warning: could not load tenant routes: %v", err)
+	}
+	pauses, err := postgres.Pauses(a.db)
+	if err != nil {
+		log.Printf("Warning: could not load pauses: %v", err)
+	}
+	return a.config.Current().withFeatureOverrides(dbFeatures).withTenantRoutes(tenantRoutes).withPauses(pauses, time.Now())
+}
+
+// flushSignal sends the alerts and clears queued for Signal routes during a run.
+func flushSignal() {
+	if err := signalcli.Flush(); err != nil {
+		log.Printf("Error sending Signal messages: %v", err)
+	}
+}
+
+// runCycle runs every job once, in order, against a single config snapshot. It stops at the
+// first incident job that fails, since the incident database is then most likely unavailable.
+// The other jobs' failures are logged and reported, and along with failed deliveries make the
+// run partly failed.
+func (a *app) runCycle() (partial bool, err error) {
+	a.cycleMu.Lock()
+	defer a.cycleMu.Unlock()
+	cfg := a.currentConfig()
+	defer flushSignal()
+
+	failed := a.failedDeliveries.Load()
+	for _, j := range jobs {
+		err := a.batchWrites(cfg, func() error { return j.run(a, cfg) })
+		if err == nil {
+			continue
+		}
+		if j.name == jobNewIncidents || j.name == jobClearedIncidents {
+			return true, fmt.Errorf("%s: %w", j.name, err)
+		}
+		log.Printf("Error during %s job: %v", j.name, err)
+		a.reporter.Report(fmt.Errorf("%s: %w", j.name, err), "error", nil)
+		partial = true
+	}
+	if n := a.failedDeliveries.Load() - failed; n > 0 {
+		log.Printf("%d delivery(s) failed during the run.", n)
+		partial = true
+	}
+	return partial, nil
+}
+
+// processNewIncidents polls the CAP feeds, then alerts on every new incident and the alerts
+// deferred until now.
+func (a *app) processNewIncidents(cfg *Config) error {
+	mapsAPIKey := os.Getenv("GOOGLE_MAPS_API_KEY")
+	a.pollCAPFeeds(cfg)
+
+	incidents, err := a.loadNewIncidents(cfg)
+	a.recordPoll(cfg, err)
+	if err != nil {
+		return err
+	}
+	incidents = a.skipMaintenance(cfg, incidents)
+	unlocated := make(map[int]bool)
+	for n := range incidents {
+		if !checkCoordinates(cfg, &incidents[n]) {
+			unlocated[incidents[n].ID] = true
+		}
+	}
+
+	if a.notifyDiscord == "0" && len(incidents) > 0 {
+		log.Println("--- DEBUG MODE: NOTIFY_DISCORD=0 ---")
+		if path, err := a.writePreview(cfg, mapsAPIKey, incidents); err != nil {
+			log.Printf("Error writing preview: %v", err)
+		} else {
+			log.Printf("Wrote preview of %d incident(s) to %s", len(incidents), path)
+		}
+	}
+
+	var stale map[int]bool
+	if a.notifyDiscord != "0" {
+		stale = staleBacklog(cfg, incidents)
+	}
+
+	var pending []*pendingIncident
+	for _, i := range incidents {
+		if !stale[i.ID] {
+			a.escalate(cfg, i)
+			a.notifyWatchers(cfg, mapsAPIKey, i)
+		}
+		var skipped []string
+		if unlocated[i.ID] {
+			skipped = []string{enrich.SkippedLocation}
+		}
+		if p := a.prepareIncident(cfg, i, skipped); p != nil {
+			p.live = true
+			pending = append(pending, p)
+		}
+	}
+	pending = a.catchUp(cfg, pending, stale)
+	a.loadInsertedTimes(cfg, pending)
+	a.mergeOverlaps(cfg, pending)
+
+	// Incidents are enriched as the queue comes to them, so a backlog's lookups don't hold
+	// up the urgent alerts, and at most max_per_run go out; the rest wait for the next run.
+	var sent []*pendingIncident
+	// Routes that batch by corridor collect their incidents here and send after the individual alerts.
+	batches := make(map[string]map[string][]*pendingIncident)
+	queue := newSendQueue(cfg, pending)
+	alerts, limit := 0, cfg.Queue.maxPerRun()
+	for queue.pending() > 0 && alerts < limit {
+		p := queue.pop()
+		if p.mergedInto != nil || !a.enrichIncident(cfg, p) {
+			continue
+		}
+		alerts++
+		sent = append(append(sent, p), p.merged...)
+		for _, route := range p.routes {
+			if corridor := incident.Corridor(p.incident); route.BatchCorridors && corridor != "" {
+				if batches[route.Name] == nil {
+					batches[route.Name] = make(map[string][]*pendingIncident)
+				}
+				batches[route.Name][corridor] = append(batches[route.Name][corridor], p)
+				continue
+			}
+			a.deliver(cfg, mapsAPIKey, route, p)
+		}
+	}
+	var left int
+	for queue.pending() > 0 {
+		if queue.pop().mergedInto == nil {
+			left++
+		}
+	}
+	if left > 0 {
+		log.Printf("Sent the per-run limit of %d alerts; %d incident(s) wait for the next run.", limit, left)
+	}
+	for routeName, corridors := range batches {
+		route, _ := cfg.Route(routeName)
+		for corridor, group := range corridors {
+			a.deliverBatch(cfg, mapsAPIKey, route, corridor, group)
+		}
+	}
+
+	for _, p := range sent {
+		if p.mergedInto == nil {
+			a.notifySubscribers(cfg, mapsAPIKey, p)
+		}
+	}
+
+	a.releasePaused(cfg)
+	a.deliverDeferred(cfg)
+
+	var newIncidentsFound int
+	for _, p := range sent {
+		if a.finishIncident(cfg, p) {
+			newIncidentsFound++
+		}
+	}
+	log.Printf("Processed %d new alerts.", newIncidentsFound)
+
+	a.syncReactionAcks(cfg)
+	a.checkSpikes(cfg)
+	return nil
+}
+
+// processClearedIncidents marks the alerts of cleared incidents, and follows up on the ones
+// still active: escalations, long-running incidents and Home Assistant's sensors.
+func (a *app) processClearedIncidents(cfg *Config) error {
+	clearedRows, err := a.db.Query(cfg.Query("cleared_incidents",
+		"SELECT {id}, {source}, {address}, {discord_message_id} FROM {incidents} WHERE {status} = 'cleared' AND {discord_message_id} IS NOT NULL"))
+	if err != nil {
+		return fmt.Errorf("querying for cleared incidents: %w", err)
+	}
+	defer clearedRows.Close()
+
+	var clearedIncidentsUpdated int
+	now := time.Now()
+	for clearedRows.Next() {
+		var i incident.Incident
+		if err := clearedRows.Scan(&i.ID, &i.Source, &i.Address, &i.DiscordMessageID); err != nil {
+			log.Printf("Error scanning cleared incident: %v", err)
+			continue
+		}
+		if _, ok := cfg.inMaintenance(i.Source, now); ok {
+			// Left until the window closes, in case the feed reopens it.
+			continue
+		}
+		if a.processClearedIncident(cfg, i) {
+			clearedIncidentsUpdated++
+			time.Sleep(2 * time.Second)
+		}
+	}
+	log.Printf("Processed %d cleared alerts.", clearedIncidentsUpdated)
+	a.resolveEscalations(cfg)
+	a.flagLongRunning(cfg)
+	a.publishHomeAssistant(cfg)
+	return clearedRows.Err()
+}
+
+// pendingIncident is a new incident being delivered to its routes during one run.
+type pendingIncident struct {
+	incident       incident.Incident
+	routes         []RouteConfig
+	enrichment     enrich.Result
+	firstMessageID string
+	done           map[string]bool // Routes that received it, in this run or an earlier one, or suppressed it as a repeat.
+	insertedAt     time.Time       // When the ingestor stored it; zero when unknown.
+	live           bool            // Picked up as it arrived, not deferred or replayed, so its latency counts.
+	budget         time.Duration   // Time left for enriching and sending; see Config.IncidentTimeout.
+	resend         bool            // Replayed on request, so sent even where the route already has an alert for it.
+
+	merged     []*pendingIncident // Other feeds' reports of the same event, sent in this alert.
+	mergedInto *pendingIncident   // Set when this report is sent as part of another's alert.
+}
+
+// loadNewIncidents reads every active incident that has not been alerted yet.
+func (a *app) loadNewIncidents(cfg *Config) ([]incident.Incident, error) {
+	rows, err := a.db.Query(cfg.Query("new_incidents",
+		"SELECT {id}, {source}, {source_id}, {event_type}, {address}, {latitude}, {longitude}, {timestamp}, {details} FROM {incidents} WHERE {status} = 'active' AND {discord_message_id} IS NULL"))
+	if err != nil {
+		return nil, fmt.Errorf("querying for new incidents: %w", err)
+	}
+	defer rows.Close()
+
+	var incidents []incident.Incident
+	for rows.Next() {
+		var i incident.Incident
+		if err := rows.Scan(&i.ID, &i.Source, &i.SourceID, &i.EventType, &i.Address, &i.Latitude, &i.Longitude, &i.Timestamp, &i.Details); err != nil {
+			log.Printf("Error scanning incident: %v", err)
+			continue
+		}
+		incidents = append(incidents, i)
+	}
+	return incidents, rows.Err()
+}
+
+// prepareIncident picks the routes for a new incident, noting the enrichments already
+// skipped. It returns nil if there is nothing to deliver. The incident is enriched by
+// enrichIncident once the send queue comes to it.
+func (a *app) prepareIncident(cfg *Config, i incident.Incident, skipped []string) (p *pendingIncident) {
+	defer recoverAndReport(a.reporter, incidentTags(i))
+
+	log.Printf("Found new unified incident from %s (ID: %s).", i.Source, i.SourceID)
+
+	if a.notifyDiscord == "0" {
+		// Dry run: runCycle writes an HTML preview instead of sending.
+		return nil
+	}
+
+	kept := cfg.Filter.Apply(&i)
+	a.publishCreated(cfg, i)
+	if !kept {
+		log.Printf("Incident %d (%s) is filtered out; marking as handled.", i.ID, i.EventType)
+		a.markHandled(cfg, i)
+		return nil
+	}
+	a.saveTags(cfg, i)
+	if id, err := postgres.MutedBy(a.db, i); err != nil {
+		log.Printf("Warning: %v", err)
+	} else if id != 0 {
+		log.Printf("Incident %d (%s) is silenced by mute %d; marking as handled.", i.ID, i.EventType, id)
+		a.markHandled(cfg, i)
+		return nil
+	}
+
+	var routes []RouteConfig
+	for _, route := range cfg.Routes {
+		if route.Matches(i) {
+			routes = append(routes, route)
+		}
+	}
+	if len(routes) == 0 {
+		log.Printf("No route accepts %s incidents; marking as handled.", i.Source)
+		a.markHandled(cfg, i)
+		return nil
+	}
+
+	// Paused routes hold the incident until they resume; routes outside their schedule drop
+	// it or hold it for the next window.
+	now := time.Now()
+	var open []RouteConfig
+	for _, route := range routes {
+		if route.held(now) {
+			log.Printf("Route %q is paused; holding incident %d.", route.Name, i.ID)
+			if err := postgres.DeferAlert(a.db, i.ID, route.Name); err != nil {
+				log.Printf("Error deferring alert: %v", err)
+			}
+		} else if route.Schedule.Active(now.In(cfg.Location(route))) {
+			open = append(open, route)
+		} else if route.Schedule.Defers() {
+			log.Printf("Route %q is outside its schedule; deferring incident %d.", route.Name, i.ID)
+			if err := postgres.DeferAlert(a.db, i.ID, route.Name); err != nil {
+				log.Printf("Error deferring alert: %v", err)
+			}
+		} else {
+			log.Printf("Route %q is outside its schedule; dropping incident %d.", route.Name, i.ID)
+		}
+	}
+	if routes = open; len(routes) == 0 {
+		a.markHandled(cfg, i)
+		return nil
+	}
+
+	return &pendingIncident{incident: i, routes: routes, enrichment: enrich.Result{Skipped: skipped}}
+}
+
+// enrichIncident runs the shared enrichment for an incident the send queue came to, and for
+// the reports merged into it. It returns false if the degradation policy holds the incident
+// for the next run, along with its merged reports; merged reports it holds are dropped from
+// the alert and wait likewise.
+func (a *app) enrichIncident(cfg *Config, p *pendingIncident) bool {
+	a.enrichPending(cfg, p)
+	if cfg.Degradation.holds(p.incident.ID, p.incident.Timestamp, p.enrichment.Skipped) {
+		return false
+	}
+	a.images.publish(a.db, &p.enrichment)
+
+	merged := p.merged[:0]
+	for _, m := range p.merged {
+		a.enrichPending(cfg, m)
+		if cfg.Degradation.holds(m.incident.ID, m.incident.Timestamp, m.enrichment.Skipped) {
+			continue
+		}
+		a.images.publish(a.db, &m.enrichment)
+		merged = append(merged, m)
+	}
+	p.merged = merged
+	return true
+}
+
+// deliverDeferred sends the incidents held for routes whose schedule window is now open,
+// unless the route is paused.
+func (a *app) deliverDeferred(cfg *Config) {
+	now := time.Now()
+	for _, route := range cfg.Routes {
+		if !route.Schedule.Defers() || !route.Schedule.Active(now.In(cfg.Location(route))) || route.held(now) {
+			continue
+		}
+		a.releaseDeferred(cfg, route)
+	}
+}
+
+// releaseDeferred sends the incidents held for a route. Incidents that cleared or are
+// filtered out in the meantime are let go.
+func (a *app) releaseDeferred(cfg *Config, route RouteConfig) {
+	ids, err := postgres.DeferredAlerts(a.db, route.Name)
+	if err != nil {
+		log.Printf("Error loading deferred alerts for route %q: %v", route.Name, err)
+		return
+	}
+	for _, id := range ids {
+		incidents, statuses, err := queryIncidents(cfg, a.db, "WHERE {id} = $1", id)
+		if err != nil {
+			log.Printf("Error loading deferred incident %d: %v", id, err)
+			continue
+		}
+		if len(incidents) == 1 && statuses[0] == "active" && cfg.Filter.Apply(&incidents[0]) {
+			log.Printf("Sending deferred incident %d to route %q.", id, route.Name)
+			p, err := a.deliverNow(cfg, incidents[0], []RouteConfig{route})
+			if err != nil {
+				log.Printf("Error sending deferred alert: %v", err)
+				continue
+			}
+			a.finishIncident(cfg, p)
+		}
+		if err := postgres.DeleteDeferredAlert(a.db, id, route.Name); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
+
+// markHandled stores an empty discord_message_id so an incident that will not be sent is
+// not picked up again.
+func (a *app) markHandled(cfg *Config, i incident.Incident) {
+	if err := a.setMessageID(cfg, i.ID, sql.NullString{Valid: true}); err != nil {
+		log.Printf("Error saving discord_message_id: %v", err)
+	}
+}
+
+// saveTags stores the tags the filter's rules gave an incident.
+func (a *app) saveTags(cfg *Config, i incident.Incident) {
+	if len(i.Tags) == 0 {
+		return
+	}
+	if err := a.setTags(cfg, i.ID, i.Tags); err != nil {
+		log.Printf("Warning: failed to save tags of incident %d: %v", i.ID, err)
+	}
+}
+
+// deliver sends one incident to one route and records the message.
+func (a *app) deliver(cfg *Config, mapsAPIKey string, route RouteConfig, p *pendingIncident) {
+	defer recoverAndReport(a.reporter, incidentTags(p.incident))
+
+	// Checked first, since the route's own earlier alert would otherwise count as a repeat.
+	if !p.resend && a.deliveredBefore(route, p) {
+		return
+	}
+	messenger := route.messengerFor(p.incident)
+	if replyTo, repeat := a.repeatOf(route, p.incident); repeat && !p.resend {
+		if route.RepeatAction != "thread" {
+			log.Printf("Suppressing repeat alert for %s in route %q.", p.incident.Address, route.Name)
+			p.delivered(route, "")
+			return
+		}
+		if bot, ok := messenger.(discord.BotMessenger); ok {
+			bot.ReplyTo = replyTo
+			messenger = bot
+		}
+	}
+
+	log.Printf("Sending alert to Discord route %q...", route.Name)
+	opts := cfg.RenderOptions(route, p.incident.Source)
+	start := time.Now()
+	payload, err := a.buildAlert(cfg, mapsAPIKey, opts, p)
+	var messageID string
+	var continuations []string
+	if err == nil {
+		messageID, continuations, err = discord.SendPayload(messenger, payload, p.enrichment, opts)
+	}
+	d := newDelivery(p.incident.ID, route.Name, "alert", payload, messageID, start, err)
+	defer p.spend(d.Latency)
+	if p.live {
+		d.IncidentAt, d.InsertedAt = p.incident.Timestamp, p.insertedAt
+	}
+	d.AttachmentBytes = attachmentSize(discord.Attachments(p.enrichment, opts)...)
+	d.Skipped = p.enrichment.Skipped
+	a.logDelivery(d)
+	if err != nil {
+		log.Printf("Error sending Discord alert to route %q: %v", route.Name, err)
+		tags := incidentTags(p.incident)
+		tags["route"] = route.Name
+		a.reporter.Report(fmt.Errorf("sending Discord alert: %w", err), "error", tags)
+		return
+	}
+	for _, q := range append([]*pendingIncident{p}, p.merged...) {
+		a.recordDelivery(cfg, route, q, messageID, sql.NullInt32{})
+		for n, id := range continuations {
+			if err := postgres.RecordAlertContinuation(a.db, q.incident.ID, route.Name, id, n+1); err != nil {
+				log.Printf("Error saving alert message: %v", err)
+			}
+		}
+	}
+
+	if p.incident.Backfill {
+		// Historical alerts are not pinned or republished.
+		time.Sleep(2 * time.Second)
+		return
+	}
+	if bot, ok := messenger.(discord.BotMessenger); ok && route.Pin.Matches(p.incident) {
+		if err := bot.Pin(messageID); err != nil {
+			log.Printf("Error pinning alert in route %q: %v", route.Name, err)
+		} else if err := postgres.MarkAlertMessagePinned(a.db, p.incident.ID, route.Name, messageID); err != nil {
+			log.Printf("Error saving pinned state: %v", err)
+		}
+	}
+	a.crosspost(route, messenger, messageID)
+	a.announce(cfg, route, p)
+	time.Sleep(2 * time.Second)
+}
+
+// repeatOf finds an alert the route sent for the same address within its repeat window.
+func (a *app) repeatOf(route RouteConfig, inc incident.Incident) (string, bool) {
+	window := route.repeatWindow()
+	key := incident.NormalizedAddress(inc)
+	if window == 0 || key == "" {
+		return "", false
+	}
+	messageID, err := postgres.RecentAlertAt(a.db, route.Name, key, window)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return "", false
+	}
+	return messageID, messageID != ""
+}
+
+// crosspost publishes a message from an announcement channel when the route opts in,
+// skipping it once the channel's hourly crosspost budget is spent. The budget is Discord's,
+// so it counts every route posting to the channel.
+func (a *app) crosspost(route RouteConfig, messenger discord.Messenger, messageID string) {
+	bot, ok := messenger.(discord.BotMessenger)
+	if !ok || !route.Crosspost {
+		return
+	}
+	used, err := postgres.CrosspostsInLastHour(a.db, bot.ChannelID)
+	if err != nil {
+		log.Printf("Warning: %v; skipping crosspost.", err)
+		return
+	}
+	if used >= route.crosspostLimit() {
+		log.Printf("Crosspost limit reached for channel %s of route %q (%d in the last hour); not crossposting.", bot.ChannelID, route.Name, used)
+		return
+	}
+	if err := bot.Crosspost(messageID); err != nil {
+		log.Printf("Error crossposting alert in route %q: %v", route.Name, err)
+		return
+	}
+	if err := postgres.MarkAlertMessageCrossposted(a.db, route.Name, messageID, bot.ChannelID); err != nil {
+		log.Printf("Error saving crosspost state: %v", err)
+	}
+}
+
+// deliverBatch sends the incidents on one corridor as grouped messages, falling back to a
+// normal alert when the corridor only has one incident this run. Incidents the route already
+// received in an earlier run are left out.
+func (a *app) deliverBatch(cfg *Config, mapsAPIKey string, route RouteConfig, corridor string, group []*pendingIncident) {
+	var unsent []*pendingIncident
+	for _, p := range group {
+		if !a.deliveredBefore(route, p) {
+			unsent = append(unsent, p)
+		}
+	}
+	if group = unsent; len(group) == 0 {
+		return
+	}
+	if len(group) == 1 {
+		a.deliver(cfg, mapsAPIKey, route, group[0])
+		return
+	}
+	defer recoverAndReport(a.reporter, map[string]string{"route": route.Name, "corridor": corridor})
+
+	// The header and cluster map take the route's settings; each incident's embed takes its
+	// source's, since a corridor can mix feeds.
+	opts := cfg.RenderOptions(route, "")
+	messenger := route.Messenger()
+
+	// Fill each message up to Discord's embed and character limits, leaving room for the header.
+	var chunks [][]*pendingIncident
+	var chunkEmbeds [][]discord.Embed
+	budget := discord.MaxCharsPerMessage - discord.EmbedLength(discord.BuildBatchHeader(corridor, discord.MaxEmbedsPerMessage, opts))
+	if opts.Enabled(discord.FeatureMaps) && mapsAPIKey != "" {
+		budget -= discord.ClusterLegendReserve
+	}
+	used := 0
+	for _, p := range group {
+		single, err := a.buildAlert(cfg, mapsAPIKey, cfg.RenderOptions(route, p.incident.Source), p)
+		if err != nil {
+			log.Printf("Error building alert for incident %d: %v", p.incident.ID, err)
+			continue
+		}
+		embed := discord.EnforceEmbedLimits(single.Embeds[0], opts.T("field_continued"))
+		n := discord.EmbedLength(embed)
+		if n > budget {
+			a.deliver(cfg, mapsAPIKey, route, p)
+			continue
+		}
+		last := len(chunks) - 1
+		if last < 0 || len(chunks[last]) >= discord.MaxEmbedsPerMessage-1 || used+n > budget {
+			chunks = append(chunks, nil)
+			chunkEmbeds = append(chunkEmbeds, nil)
+			last++
+			used = 0
+		}
+		chunks[last] = append(chunks[last], p)
+		chunkEmbeds[last] = append(chunkEmbeds[last], embed)
+		used += n
+	}
+
+	for c, chunk := range chunks {
+		header := discord.BuildBatchHeader(corridor, len(chunk), opts)
+		var members []incident.Incident
+		for _, p := range chunk {
+			members = append(members, p.incident)
+		}
+		discord.AddClusterMap(&header, mapsAPIKey, members, opts)
+		payload := discord.WebhookPayload{
+			Username: opts.T("bot_username"),
+			Embeds:   append([]discord.Embed{header}, chunkEmbeds[c]...),
+		}
+		// Camera frames are referenced by the embeds, so closure geometry only fills spare slots.
+		var attachments, geometries []discord.Attachment
+		for _, p := range chunk {
+			for _, attachment := range discord.Attachments(p.enrichment, cfg.RenderOptions(route, p.incident.Source)) {
+				if attachment.Name == p.enrichment.GeometryName {
+					geometries = append(geometries, attachment)
+				} else {
+					attachments = append(attachments, attachment)
+				}
+			}
+		}
+		attachments = append(attachments, geometries...)
+		if len(attachments) > discord.MaxAttachmentsPerMessage {
+			attachments = attachments[:discord.MaxAttachmentsPerMessage]
+		}
+
+		log.Printf("Sending %d grouped alerts for %s to Discord route %q...", len(chunk), corridor, route.Name)
+		start := time.Now()
+		messageID, err := messenger.Send(payload, attachments...)
+		for _, p := range chunk {
+			d := newDelivery(p.incident.ID, route.Name, "batch", payload, messageID, start, err)
+			if p.live {
+				d.IncidentAt, d.InsertedAt = p.incident.Timestamp, p.insertedAt
+			}
+			d.AttachmentBytes = attachmentSize(attachments...)
+			d.Skipped = p.enrichment.Skipped
+			a.logDelivery(d)
+		}
+		if err != nil {
+			log.Printf("Error sending batched Discord alert to route %q: %v", route.Name, err)
+			a.reporter.Report(fmt.Errorf("sending batched Discord alert: %w", err), "error",
+				map[string]string{"route": route.Name, "corridor": corridor})
+			continue
+		}
+		for idx, p := range chunk {
+			// Embed 0 is the shared header.
+			for _, q := range append([]*pendingIncident{p}, p.merged...) {
+				a.recordDelivery(cfg, route, q, messageID, sql.NullInt32{Int32: int32(idx + 1), Valid: true})
+			}
+		}
+		a.crosspost(route, messenger, messageID)
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func (a *app) recordDelivery(cfg *Config, route RouteConfig, p *pendingIncident, messageID string, embedIndex sql.NullInt32) {
+	if err := postgres.RecordAlertMessage(a.db, p.incident.ID, route.Name, messageID, incident.NormalizedAddress(p.incident), embedIndex); err != nil {
+		log.Printf("Error saving alert message: %v", err)
+	}
+	a.publishEvent(cfg, eventDelivered, p.incident, incidentEvent{Route: route.Name, MessageID: messageID})
+	p.delivered(route, messageID)
+}
+
+// delivered notes that a route received the incident's alert as messageID, or suppressed it
+// when messageID is "".
+func (p *pendingIncident) delivered(route RouteConfig, messageID string) {
+	if p.done == nil {
+		p.done = make(map[string]bool)
+	}
+	p.done[route.Name] = true
+	if p.firstMessageID == "" {
+		p.firstMessageID = messageID
+	}
+}
+
+// finishIncident marks the incident as alerted once every route it was sent to received it,
+// or as handled when they all suppressed it as a repeat. While a route is missing, the
+// incident stays pending and the next run sends it to that route; the routes that already
+// received it are skipped then.
+func (a *app) finishIncident(cfg *Config, p *pendingIncident) bool {
+	var missing []string
+	for _, route := range p.routes {
+		if !p.done[route.Name] {
+			missing = append(missing, route.Name)
+		}
+	}
+	if len(missing) > 0 {
+		log.Printf("Incident %d did not reach route(s) %s; retrying next run.", p.incident.ID, strings.Join(missing, ", "))
+		return false
+	}
+	if p.firstMessageID == "" {
+		a.markHandled(cfg, p.incident)
+		return false
+	}
+	if err := a.setMessageID(cfg, p.incident.ID, sql.NullString{String: p.firstMessageID, Valid: true}); err != nil {
+		log.Printf("Error saving discord_message_id: %v", err)
+		a.reporter.Report(fmt.Errorf("saving discord_message_id: %w", err), "error", incidentTags(p.incident))
+	}
+	return true
+}
+
+// processClearedIncident marks every Discord message for a cleared incident and releases its message ID.
+func (a *app) processClearedIncident(cfg *Config, i incident.Incident) bool {
+	defer recoverAndReport(a.reporter, incidentTags(i))
+
+	messages, err := postgres.AlertMessagesFor(a.db, i.ID)
+	if err != nil {
+		log.Printf("Error loading alert messages: %v", err)
+		return false
+	}
+	// Incidents alerted before routes existed only have the single discord_message_id.
+	if len(messages) == 0 && i.DiscordMessageID.String != "" {
+		messages = []postgres.AlertMessage{{Route: cfg.Routes[0].Name, MessageID: i.DiscordMessageID.String}}
+	}
+
+	// The cleared query only loads what updating the messages needs; AMQP routing keys and the
+	// cleared event need the rest.
+	full := i
+	if loaded, _, err := queryIncidents(cfg, a.db, "WHERE {id} = $1", i.ID); err == nil && len(loaded) == 1 {
+		full = loaded[0]
+	}
+
+	log.Printf("Found cleared incident from %s (ID: %d). Updating %d message(s).", i.Source, i.ID, len(messages))
+	for _, m := range messages {
+		route, ok := cfg.Route(m.Route)
+		if !ok {
+			log.Printf("Warning: route %q no longer exists; leaving its message as-is.", m.Route)
+			continue
+		}
+		opts := cfg.RenderOptions(route, i.Source)
+		var err error
+		messenger := route.messengerFor(full)
+		start := time.Now()
+		switch {
+		case m.Part > 0:
+			err = discord.UpdateContinuation(messenger, m.MessageID, opts)
+		case m.EmbedIndex.Valid:
+			err = discord.ClearBatchedEmbed(messenger, m.MessageID, int(m.EmbedIndex.Int32), i, opts)
+		default:
+			err = discord.UpdateAlert(messenger, m.MessageID, i, opts)
+		}
+		a.logDelivery(newDelivery(i.ID, route.Name, "clear", nil, m.MessageID, start, err))
+		if discord.IsNotFound(err) {
+			a.clearDeletedMessage(cfg, route, m, full)
+			continue
+		}
+		if err != nil {
+			log.Printf("Error updating Discord alert: %v", err)
+			tags := incidentTags(i)
+			tags["route"] = route.Name
+			a.reporter.Report(fmt.Errorf("updating Discord alert: %w", err), "error", tags)
+			return false
+		}
+		if bot, ok := messenger.(discord.BotMessenger); ok && m.Pinned {
+			if err := bot.Unpin(m.MessageID); err != nil {
+				log.Printf("Warning: failed to unpin cleared alert in route %q: %v", route.Name, err)
+			}
+		}
+	}
+
+	if err := a.setMessageID(cfg, i.ID, sql.NullString{}); err != nil {
+		log.Printf("Error nullifying discord_message_id: %v", err)
+	}
+	a.recordDuration(cfg, i.ID)
+	a.publishEvent(cfg, eventCleared, full, incidentEvent{})
+	return len(messages) > 0
+}
+
+// clearDeletedMessage forgets an alert message someone deleted, so the incident isn't stuck
+// retrying the edit, and reposts the cleared notice if the route asks for it.
+func (a *app) clearDeletedMessage(cfg *Config, route RouteConfig, m postgres.AlertMessage, i incident.Incident) {
+	log.Printf("Warning: alert message %s for incident %d in route %q was deleted; forgetting it.", m.MessageID, i.ID, route.Name)
+	if err := postgres.DeleteAlertMessage(a.db, i.ID, route.Name, m.MessageID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if !route.RepostCleared || m.Part > 0 {
+		return
+	}
+	opts := cfg.RenderOptions(route, i.Source)
+	payload := discord.WebhookPayload{Username: opts.T("bot_username"), Embeds: []discord.Embed{discord.BuildClearedEmbed(i, opts)}}
+	start := time.Now()
+	messageID, err := route.messengerFor(i).Send(payload)
+	a.logDelivery(newDelivery(i.ID, route.Name, "clear", payload, messageID, start, err))
+	if err != nil {
+		log.Printf("Error reposting cleared alert in route %q: %v", route.Name, err)
+	}
+}
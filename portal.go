@@ -0,0 +1,557 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/enrich"
+	"github.com/mtickle/unity-alerts/i18n"
+	"github.com/mtickle/unity-alerts/sink/discord"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+const (
+	portalSessionTTL    = 7 * 24 * time.Hour
+	portalStateTTL      = 10 * time.Minute
+	portalSessionCookie = "unity_alerts_session"
+	portalStateCookie   = "unity_alerts_oauth_state"
+	portalFlashCookie   = "unity_alerts_flash"
+)
+
+// portal is the self-service web UI at /portal/, where users sign in with Discord to manage
+// the same subscriptions, watchlist and preferences as /subscribe, /watch and /preferences.
+type portal struct {
+	a            *app
+	baseURL      string // PORTAL_URL, e.g. "https://alerts.example.org".
+	clientID     string
+	clientSecret string
+	key          []byte // Signs session cookies and CSRF tokens.
+}
+
+// portalSession is the signed-in user, kept in a signed cookie.
+type portalSession struct {
+	UserID  string `json:"id"`
+	Name    string `json:"name"`
+	Expires int64  `json:"exp"`
+}
+
+// newPortal configures the portal from PORTAL_URL, DISCORD_CLIENT_ID (or
+// DISCORD_APPLICATION_ID), DISCORD_CLIENT_SECRET and PORTAL_SESSION_KEY. It returns nil when
+// PORTAL_URL is unset. PORTAL_URL + "/portal/callback" must be registered as an OAuth2
+// redirect in the Discord application.
+func newPortal(a *app) (*portal, error) {
+	baseURL := strings.TrimSuffix(os.Getenv("PORTAL_URL"), "/")
+	if baseURL == "" {
+		return nil, nil
+	}
+	if u, err := url.Parse(baseURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("PORTAL_URL must be an http(s) URL")
+	}
+	p := &portal{
+		a:            a,
+		baseURL:      baseURL,
+		clientID:     os.Getenv("DISCORD_CLIENT_ID"),
+		clientSecret: os.Getenv("DISCORD_CLIENT_SECRET"),
+		key:          []byte(os.Getenv("PORTAL_SESSION_KEY")),
+	}
+	if p.clientID == "" {
+		p.clientID = os.Getenv("DISCORD_APPLICATION_ID")
+	}
+	if p.clientID == "" || p.clientSecret == "" {
+		return nil, fmt.Errorf("PORTAL_URL requires DISCORD_CLIENT_ID and DISCORD_CLIENT_SECRET")
+	}
+	if len(p.key) < 32 {
+		return nil, fmt.Errorf("PORTAL_SESSION_KEY must be at least 32 characters")
+	}
+	return p, nil
+}
+
+func (p *portal) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/portal/", p.handleHome)
+	mux.HandleFunc("/portal/login", p.handleLogin)
+	mux.HandleFunc("/portal/callback", p.handleCallback)
+	mux.HandleFunc("/portal/logout", p.handleLogout)
+	mux.HandleFunc("/portal/subscriptions", p.action(p.addSubscription))
+	mux.HandleFunc("/portal/subscriptions/delete", p.action(p.deleteSubscription))
+	mux.HandleFunc("/portal/subscriptions/channel", p.action(p.subscriptionChannel))
+	mux.HandleFunc("/portal/watches", p.action(p.addWatch))
+	mux.HandleFunc("/portal/watches/delete", p.action(p.deleteWatch))
+	mux.HandleFunc("/portal/watches/channel", p.action(p.watchChannel))
+	mux.HandleFunc("/portal/preferences", p.action(p.savePreferences))
+	return mux
+}
+
+// locale is the configured language, in which the portal shows its text.
+func (p *portal) locale() i18n.Locale {
+	locale, _ := i18n.For(p.a.config.Current().Language) // Checked when the config loads.
+	return locale
+}
+
+func (p *portal) sign(data string) string {
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (p *portal) setCookie(w http.ResponseWriter, name, value string, maxAge time.Duration) {
+	http.SetCookie(w, &http.Cookie{
+		Name: name, Value: value, Path: "/portal/", MaxAge: int(maxAge.Seconds()),
+		HttpOnly: true, Secure: strings.HasPrefix(p.baseURL, "https://"), SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (p *portal) clearCookie(w http.ResponseWriter, name string) {
+	p.setCookie(w, name, "", -time.Second)
+}
+
+// session returns the signed-in user, if the session cookie is valid and unexpired.
+func (p *portal) session(r *http.Request) (portalSession, bool) {
+	var s portalSession
+	c, err := r.Cookie(portalSessionCookie)
+	if err != nil {
+		return s, false
+	}
+	payload, sig, ok := strings.Cut(c.Value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(p.sign(payload))) {
+		return s, false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil || json.Unmarshal(data, &s) != nil || s.UserID == "" || time.Now().Unix() > s.Expires {
+		return s, false
+	}
+	return s, true
+}
+
+// csrfToken ties form submissions to the session cookie.
+func (p *portal) csrfToken(r *http.Request) string {
+	c, err := r.Cookie(portalSessionCookie)
+	if err != nil {
+		return ""
+	}
+	return p.sign("csrf:" + c.Value)
+}
+
+// handleLogin sends the user to Discord to sign in.
+func (p *portal) handleLogin(w http.ResponseWriter, r *http.Request) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	state := hex.EncodeToString(b)
+	p.setCookie(w, portalStateCookie, state, portalStateTTL)
+	query := url.Values{
+		"client_id":     {p.clientID},
+		"redirect_uri":  {p.baseURL + "/portal/callback"},
+		"response_type": {"code"},
+		"scope":         {"identify"},
+		"state":         {state},
+	}
+	http.Redirect(w, r, discord.OAuthAuthorizeURL+"?"+query.Encode(), http.StatusFound)
+}
+
+// handleCallback completes the Discord sign-in and starts a session.
+func (p *portal) handleCallback(w http.ResponseWriter, r *http.Request) {
+	c, err := r.Cookie(portalStateCookie)
+	state := r.URL.Query().Get("state")
+	if err != nil || state == "" || !hmac.Equal([]byte(c.Value), []byte(state)) {
+		http.Error(w, p.locale().T("portal_sign_in_expired"), http.StatusBadRequest)
+		return
+	}
+	p.clearCookie(w, portalStateCookie)
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		http.Redirect(w, r, "/portal/", http.StatusFound)
+		return
+	}
+	token, err := discord.ExchangeOAuthCode(p.clientID, p.clientSecret, code, p.baseURL+"/portal/callback")
+	var user discord.User
+	if err == nil {
+		user, err = discord.CurrentUser(token)
+	}
+	if err != nil {
+		log.Printf("Error signing in to the portal: %v", err)
+		http.Error(w, p.locale().T("portal_sign_in_failed"), http.StatusBadGateway)
+		return
+	}
+
+	data, _ := json.Marshal(portalSession{UserID: user.ID, Name: user.DisplayName(), Expires: time.Now().Add(portalSessionTTL).Unix()})
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	p.setCookie(w, portalSessionCookie, payload+"."+p.sign(payload), portalSessionTTL)
+	http.Redirect(w, r, "/portal/", http.StatusFound)
+}
+
+// authorizePost checks that a form submission is a POST from a signed-in user with a valid
+// CSRF token, replying with an error when it isn't.
+func (p *portal) authorizePost(w http.ResponseWriter, r *http.Request) (portalSession, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return portalSession{}, false
+	}
+	s, ok := p.session(r)
+	if !ok || !hmac.Equal([]byte(r.PostFormValue("csrf")), []byte(p.csrfToken(r))) {
+		http.Error(w, p.locale().T("portal_session_expired"), http.StatusForbidden)
+		return s, false
+	}
+	return s, true
+}
+
+func (p *portal) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if _, ok := p.authorizePost(w, r); ok {
+		p.clearCookie(w, portalSessionCookie)
+		http.Redirect(w, r, "/portal/", http.StatusSeeOther)
+	}
+}
+
+// action wraps a form submission: it shows fn's message, in the configured language, on the
+// next page load and redirects back to the portal. Errors are logged, and the user sees a
+// generic message.
+func (p *portal) action(fn func(s portalSession, r *http.Request, locale i18n.Locale) (string, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s, ok := p.authorizePost(w, r)
+		if !ok {
+			return
+		}
+		locale := p.locale()
+		message, err := fn(s, r, locale)
+		if err != nil {
+			log.Printf("Error handling portal request %s: %v", r.URL.Path, err)
+			message = locale.T("portal_error")
+		}
+		if message != "" {
+			p.setCookie(w, portalFlashCookie, base64.RawURLEncoding.EncodeToString([]byte(message)), time.Minute)
+		}
+		http.Redirect(w, r, "/portal/", http.StatusSeeOther)
+	}
+}
+
+// knownChannels are the channels a user has asked to be pinged in from Discord. The portal
+// only offers these and DMs, so it can't be used to ping someone into an arbitrary channel.
+func (p *portal) knownChannels(userID string) ([]string, error) {
+	subs, err := postgres.UserSubscriptions(p.a.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	watches, err := postgres.Watches(p.a.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var channels []string
+	add := func(c sql.NullString) {
+		if c.Valid && !seen[c.String] {
+			seen[c.String] = true
+			channels = append(channels, c.String)
+		}
+	}
+	for _, s := range subs {
+		add(s.ChannelID)
+	}
+	for _, w := range watches {
+		add(w.ChannelID)
+	}
+	return channels, nil
+}
+
+// notifyChoice reads the "notify" form value: "dm" or one of the user's known channels.
+func (p *portal) notifyChoice(userID string, r *http.Request) (sql.NullString, bool, error) {
+	choice := r.PostFormValue("notify")
+	if choice == "" || choice == "dm" {
+		return sql.NullString{}, true, nil
+	}
+	channels, err := p.knownChannels(userID)
+	for _, c := range channels {
+		if c == choice {
+			return sql.NullString{String: c, Valid: true}, true, err
+		}
+	}
+	return sql.NullString{}, false, err
+}
+
+func (p *portal) addSubscription(s portalSession, r *http.Request, locale i18n.Locale) (string, error) {
+	location := strings.TrimSpace(r.PostFormValue("location"))
+	radius, err := parseRadius(r.PostFormValue("radius"))
+	if err != nil || location == "" {
+		return fmt.Sprintf(locale.T("portal_bad_subscription"), maxSubscriptionRadiusMiles), nil
+	}
+	channelID, ok, err := p.notifyChoice(s.UserID, r)
+	if err != nil || !ok {
+		return locale.T("portal_choose_notify"), err
+	}
+	lat, lon, ok := parseCoordinates(location)
+	if !ok {
+		if lat, lon, err = enrich.Geocode(r.Context(), os.Getenv("GOOGLE_MAPS_API_KEY"), location); err != nil {
+			log.Printf("Warning: portal could not geocode a subscription: %v", err)
+			return locale.T("portal_location_not_found"), nil
+		}
+	}
+	if err := postgres.AddSubscription(p.a.db, s.UserID, channelID, location, lat, lon, radius, parseEventTypes(r.PostFormValue("types"))); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(locale.T("sub_created"), radius/metersPerMile, location), nil
+}
+
+func (p *portal) deleteSubscription(s portalSession, r *http.Request, locale i18n.Locale) (string, error) {
+	id, _ := strconv.Atoi(r.PostFormValue("id"))
+	if removed, err := postgres.DeleteSubscription(p.a.db, id, s.UserID); err != nil || !removed {
+		return locale.T("portal_subscription_gone"), err
+	}
+	return locale.T("portal_subscription_removed"), nil
+}
+
+func (p *portal) subscriptionChannel(s portalSession, r *http.Request, locale i18n.Locale) (string, error) {
+	id, _ := strconv.Atoi(r.PostFormValue("id"))
+	channelID, ok, err := p.notifyChoice(s.UserID, r)
+	if err != nil || !ok {
+		return locale.T("portal_choose_notify"), err
+	}
+	if updated, err := postgres.SetSubscriptionChannel(p.a.db, id, s.UserID, channelID); err != nil || !updated {
+		return locale.T("portal_subscription_gone"), err
+	}
+	return locale.T("portal_subscription_updated"), nil
+}
+
+func (p *portal) addWatch(s portalSession, r *http.Request, locale i18n.Locale) (string, error) {
+	channelID, ok, err := p.notifyChoice(s.UserID, r)
+	if err != nil || !ok {
+		return locale.T("portal_choose_notify"), err
+	}
+	w, err := newWatch(s.UserID, channelID, r.PostFormValue("address"), r.PostFormValue("block") != "")
+	if err != nil {
+		return fmt.Sprintf(locale.T("watch_bad_input"), err), nil
+	}
+	if _, err := postgres.AddWatch(p.a.db, w); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(locale.T("portal_watching"), describeWatch(w)), nil
+}
+
+func (p *portal) deleteWatch(s portalSession, r *http.Request, locale i18n.Locale) (string, error) {
+	id, _ := strconv.Atoi(r.PostFormValue("id"))
+	if removed, err := postgres.DeleteWatch(p.a.db, id, s.UserID); err != nil || !removed {
+		return locale.T("portal_watch_gone"), err
+	}
+	return locale.T("portal_watch_removed"), nil
+}
+
+func (p *portal) watchChannel(s portalSession, r *http.Request, locale i18n.Locale) (string, error) {
+	id, _ := strconv.Atoi(r.PostFormValue("id"))
+	channelID, ok, err := p.notifyChoice(s.UserID, r)
+	if err != nil || !ok {
+		return locale.T("portal_choose_notify"), err
+	}
+	if updated, err := postgres.SetWatchChannel(p.a.db, id, s.UserID, channelID); err != nil || !updated {
+		return locale.T("portal_watch_gone"), err
+	}
+	return locale.T("portal_watch_updated"), nil
+}
+
+func (p *portal) savePreferences(s portalSession, r *http.Request, locale i18n.Locale) (string, error) {
+	var prefs postgres.Subscriber
+	var err error
+	if prefs.QuietStart, prefs.QuietEnd, err = parseQuietHours(r.PostFormValue("quiet_hours")); err != nil {
+		return locale.T("portal_bad_quiet_hours"), nil
+	}
+	prefs.Timezone = strings.TrimSpace(r.PostFormValue("timezone"))
+	if _, err := time.LoadLocation(prefs.Timezone); err != nil {
+		return locale.T("prefs_bad_timezone"), nil
+	}
+	if prefs.Categories = parseEventTypes(r.PostFormValue("categories")); len(prefs.Categories) == 1 && prefs.Categories[0] == "all" {
+		prefs.Categories = []string{}
+	}
+	if prefs.Email, err = parseEmail(r.PostFormValue("email")); err != nil {
+		return locale.T("prefs_bad_email"), nil
+	}
+	if prefs.Digest = r.PostFormValue("delivery") == "digest"; prefs.Digest && prefs.Email == "" {
+		return locale.T("prefs_digest_needs_email"), nil
+	}
+	if err := postgres.SaveSubscriberPreferences(p.a.db, s.UserID, prefs); err != nil {
+		return "", err
+	}
+	return locale.T("portal_preferences_saved"), nil
+}
+
+// portalPage is the data for portalTemplate.
+type portalPage struct {
+	Session       portalSession
+	SignedIn      bool
+	CSRF          string
+	Flash         string
+	Subscriptions []postgres.Subscription
+	Watches       []postgres.Watch
+	Preferences   postgres.Subscriber
+	Channels      []string
+	Timezone      string // The configured default for quiet hours.
+	Lang          string
+	T             func(key string) string
+}
+
+// handleHome shows the sign-in link, or the signed-in user's settings.
+func (p *portal) handleHome(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/portal/" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cfg := p.a.config.Current()
+	locale := p.locale()
+	page := portalPage{Timezone: cfg.Location(RouteConfig{}).String(), Lang: cfg.Language, T: locale.T}
+	if page.Lang == "" {
+		page.Lang = i18n.DefaultLanguage
+	}
+	if c, err := r.Cookie(portalFlashCookie); err == nil {
+		flash, _ := base64.RawURLEncoding.DecodeString(c.Value)
+		page.Flash = string(flash)
+		p.clearCookie(w, portalFlashCookie)
+	}
+	page.Session, page.SignedIn = p.session(r)
+	if page.SignedIn {
+		page.CSRF = p.csrfToken(r)
+		var err error
+		page.Subscriptions, err = postgres.UserSubscriptions(p.a.db, page.Session.UserID)
+		if err == nil {
+			page.Watches, err = postgres.Watches(p.a.db, page.Session.UserID)
+		}
+		if err == nil {
+			page.Preferences, err = postgres.SubscriberPreferences(p.a.db, page.Session.UserID)
+		}
+		if err == nil {
+			page.Channels, err = p.knownChannels(page.Session.UserID)
+		}
+		if err != nil {
+			log.Printf("Error serving the portal: %v", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	if err := portalTemplate.Execute(w, page); err != nil {
+		log.Printf("Error rendering the portal: %v", err)
+	}
+}
+
+// notifyOptions fills the template's notify menu.
+type notifyOptions struct {
+	T        func(key string) string
+	Current  sql.NullString
+	Channels []string
+}
+
+var portalTemplate = template.Must(template.New("portal").Funcs(template.FuncMap{
+	"miles": func(meters float64) string { return fmt.Sprintf("%.1f", meters/metersPerMile) },
+	"join":  strings.Join,
+	"watch": describeWatch,
+	"notifyOptions": func(t func(string) string, channels []string, current ...sql.NullString) notifyOptions {
+		o := notifyOptions{T: t, Channels: channels}
+		if len(current) > 0 {
+			o.Current = current[0]
+		}
+		return o
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{call .T "portal_title"}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; line-height: 1.5; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1rem; }
+th, td { text-align: left; padding: 0.4rem; border-bottom: 1px solid #ddd; vertical-align: middle; }
+form.inline { display: inline; }
+fieldset { border: 1px solid #ddd; margin-bottom: 1.5rem; }
+label { display: block; margin: 0.4rem 0; }
+.flash { background: #eef6ff; border: 1px solid #9cc3f5; padding: 0.5rem 1rem; }
+</style>
+</head>
+<body>
+{{$t := .T}}
+<h1>{{call $t "portal_title"}}</h1>
+{{with .Flash}}<p class="flash" role="status">{{.}}</p>{{end}}
+{{if not .SignedIn}}
+<p>{{call $t "portal_sign_in_intro"}}</p>
+<p><a href="/portal/login">{{call $t "portal_sign_in"}}</a></p>
+{{else}}
+{{$csrf := .CSRF}}{{$channels := .Channels}}
+<p>{{printf (call $t "portal_signed_in_as") .Session.Name}}</p>
+<form method="post" action="/portal/logout"><input type="hidden" name="csrf" value="{{$csrf}}"><button>{{call $t "portal_sign_out"}}</button></form>
+
+<h2>{{call $t "portal_subscriptions"}}</h2>
+{{if .Subscriptions}}
+<table>
+<tr><th>{{call $t "portal_location"}}</th><th>{{call $t "portal_radius_mi"}}</th><th>{{call $t "portal_types"}}</th><th>{{call $t "portal_notify"}}</th><th></th></tr>
+{{range .Subscriptions}}
+<tr><td>{{.Address}}</td><td>{{miles .RadiusMeters}}</td><td>{{if .EventTypes}}{{join .EventTypes ", "}}{{else}}{{call $t "portal_all_types"}}{{end}}</td>
+<td><form class="inline" method="post" action="/portal/subscriptions/channel"><input type="hidden" name="csrf" value="{{$csrf}}"><input type="hidden" name="id" value="{{.ID}}">
+{{template "notify" (notifyOptions $t $channels .ChannelID)}} <button>{{call $t "portal_save"}}</button></form></td>
+<td><form class="inline" method="post" action="/portal/subscriptions/delete"><input type="hidden" name="csrf" value="{{$csrf}}"><input type="hidden" name="id" value="{{.ID}}"><button>{{call $t "portal_remove"}}</button></form></td></tr>
+{{end}}
+</table>
+{{else}}<p>{{call $t "portal_no_subscriptions"}}</p>{{end}}
+<form method="post" action="/portal/subscriptions"><fieldset><legend>{{call $t "portal_new_subscription"}}</legend>
+<input type="hidden" name="csrf" value="{{$csrf}}">
+<label>{{call $t "portal_location"}} <input name="location" required placeholder="{{call $t "portal_location_placeholder"}}"></label>
+<label>{{call $t "portal_radius"}} <input name="radius" placeholder="1mi"></label>
+<label>{{call $t "portal_types"}} <input name="types" placeholder="{{call $t "portal_types_placeholder"}}"></label>
+<label>{{call $t "portal_notify_me"}} {{template "notify" (notifyOptions $t $channels)}}</label>
+<button>{{call $t "portal_subscribe"}}</button></fieldset></form>
+
+<h2>{{call $t "portal_watchlist"}}</h2>
+{{if .Watches}}
+<table>
+<tr><th>{{call $t "portal_address"}}</th><th>{{call $t "portal_notify"}}</th><th></th></tr>
+{{range .Watches}}
+<tr><td>{{watch .}}</td>
+<td><form class="inline" method="post" action="/portal/watches/channel"><input type="hidden" name="csrf" value="{{$csrf}}"><input type="hidden" name="id" value="{{.ID}}">
+{{template "notify" (notifyOptions $t $channels .ChannelID)}} <button>{{call $t "portal_save"}}</button></form></td>
+<td><form class="inline" method="post" action="/portal/watches/delete"><input type="hidden" name="csrf" value="{{$csrf}}"><input type="hidden" name="id" value="{{.ID}}"><button>{{call $t "portal_remove"}}</button></form></td></tr>
+{{end}}
+</table>
+{{else}}<p>{{call $t "watch_none"}}</p>{{end}}
+<form method="post" action="/portal/watches"><fieldset><legend>{{call $t "portal_watch_address"}}</legend>
+<input type="hidden" name="csrf" value="{{$csrf}}">
+<label>{{call $t "portal_address"}} <input name="address" required placeholder="1234 Oak St"></label>
+<label><input type="checkbox" name="block" value="1"> {{call $t "portal_watch_block"}}</label>
+<label>{{call $t "portal_notify_me"}} {{template "notify" (notifyOptions $t $channels)}}</label>
+<button>{{call $t "portal_watch"}}</button></fieldset></form>
+
+<h2>{{call $t "portal_preferences"}}</h2>
+<form method="post" action="/portal/preferences"><fieldset><legend>{{call $t "portal_preferences_scope"}}</legend>
+<input type="hidden" name="csrf" value="{{$csrf}}">
+<label>{{call $t "portal_quiet_hours"}} <input name="quiet_hours" placeholder="22:00-07:00" value="{{with .Preferences.QuietStart}}{{.}}-{{$.Preferences.QuietEnd}}{{end}}"></label>
+<label>{{call $t "portal_timezone"}} <input name="timezone" placeholder="{{.Timezone}}" value="{{.Preferences.Timezone}}"></label>
+<label>{{call $t "portal_categories"}} <input name="categories" placeholder="{{call $t "portal_types_placeholder"}}" value="{{join .Preferences.Categories ","}}"></label>
+<label>{{call $t "portal_delivery"}} <select name="delivery">
+<option value="realtime"{{if not .Preferences.Digest}} selected{{end}}>{{call $t "portal_realtime"}}</option>
+<option value="digest"{{if .Preferences.Digest}} selected{{end}}>{{call $t "portal_digest"}}</option>
+</select></label>
+<label>{{call $t "portal_email"}} <input type="email" name="email" value="{{.Preferences.Email}}"></label>
+<button>{{call $t "portal_save_preferences"}}</button></fieldset></form>
+{{end}}
+</body>
+</html>
+{{define "notify"}}<select name="notify" aria-label="{{call .T "portal_notify_me"}}">
+<option value="dm"{{if not .Current.Valid}} selected{{end}}>{{call .T "portal_dm"}}</option>
+{{range .Channels}}<option value="{{.}}"{{if eq . $.Current.String}} selected{{end}}>{{printf (call $.T "portal_channel") .}}</option>{{end}}
+</select>{{end}}
+`))
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/camera"
+	"github.com/mtickle/unity-alerts/enrich"
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/sink/discord"
+)
+
+// previewMessage is one would-be Discord message in the dry-run preview.
+type previewMessage struct {
+	Route    string
+	Incident incident.Incident
+	Username string
+	Embeds   []discord.Embed
+}
+
+var (
+	markdownLink    = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^)\s]+)\)`)
+	markdownBold    = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	markdownEscapes = regexp.MustCompile("\\\\([\\\\*_~`|>#\\[\\]])")
+)
+
+// renderMarkdown turns the small subset of Discord markdown the alerts use into HTML.
+func renderMarkdown(s string) template.HTML {
+	s = template.HTMLEscapeString(s)
+	s = markdownLink.ReplaceAllString(s, `<a href="$2">$1</a>`)
+	s = markdownBold.ReplaceAllString(s, `<b>$1</b>`)
+	s = markdownEscapes.ReplaceAllString(s, "$1")
+	return template.HTML(s)
+}
+
+var previewTemplate = template.Must(template.New("preview").Funcs(template.FuncMap{
+	"markdown":     renderMarkdown,
+	"color":        func(c int) string { return fmt.Sprintf("#%06x", c) },
+	"isAttachment": func(url string) bool { return strings.HasPrefix(url, "attachment://") },
+}).Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>unity-alerts preview</title>
+<style>
+body { background: #313338; color: #dbdee1; font: 15px/1.4 "gg sans", "Helvetica Neue", Arial, sans-serif; margin: 0; padding: 16px 24px; }
+h1 { color: #f2f3f5; font-size: 18px; }
+.message { margin: 16px 0 24px; }
+.meta { color: #949ba4; font-size: 12px; }
+.username { color: #f2f3f5; font-weight: 600; }
+.bot { background: #5865f2; color: #fff; border-radius: 3px; font-size: 10px; padding: 1px 4px; margin-left: 4px; }
+.embed { background: #2b2d31; border-left: 4px solid; border-radius: 4px; max-width: 520px; padding: 8px 16px 16px 12px; margin-top: 4px; display: grid; grid-template-columns: auto min-content; }
+.embed-body { min-width: 0; }
+.title { color: #f2f3f5; font-weight: 600; margin-top: 8px; }
+.fields { display: flex; flex-wrap: wrap; gap: 8px 16px; margin-top: 8px; }
+.field { flex: 1 1 100%; }
+.field.inline { flex: 1 1 30%; }
+.field-name { color: #f2f3f5; font-weight: 600; font-size: 14px; }
+.field-value { white-space: pre-wrap; font-size: 14px; }
+.thumbnail img { max-width: 80px; max-height: 80px; border-radius: 4px; margin: 8px 0 0 16px; }
+.image img { max-width: 400px; border-radius: 4px; margin-top: 16px; }
+.attachment { margin-top: 16px; color: #949ba4; font-style: italic; }
+.footer { color: #b5bac1; font-size: 12px; margin-top: 8px; white-space: pre-wrap; }
+a { color: #00a8fc; text-decoration: none; }
+</style></head><body>
+<h1>Dry run — {{len .Messages}} message(s), generated {{.Generated}}</h1>
+{{range .Messages}}<div class="message">
+<div class="meta">route <b>{{.Route}}</b> · {{.Incident.Source}} incident {{.Incident.ID}} ({{.Incident.SourceID}})</div>
+<div><span class="username">{{.Username}}</span><span class="bot">BOT</span></div>
+{{range .Embeds}}<div class="embed" style="border-color: {{color .Color}}">
+<div class="embed-body">
+{{if .Title}}<div class="title">{{markdown .Title}}</div>{{end}}
+{{if .Fields}}<div class="fields">{{range .Fields}}<div class="field{{if .Inline}} inline{{end}}"><div class="field-name">{{markdown .Name}}</div><div class="field-value">{{markdown .Value}}</div></div>{{end}}</div>{{end}}
+{{with .Image.URL}}{{if isAttachment .}}<div class="attachment">[camera snapshot attached at send time]</div>{{else}}<div class="image"><img src="{{.}}"></div>{{end}}{{end}}
+{{if or .Footer.Text .Timestamp}}<div class="footer">{{.Footer.Text}}{{if and .Footer.Text .Timestamp}} • {{end}}{{.Timestamp}}</div>{{end}}
+</div>
+{{with .Thumbnail.URL}}<div class="thumbnail"><img src="{{.}}"></div>{{end}}
+</div>{{end}}
+</div>{{end}}
+</body></html>
+`))
+
+// writePreview renders the alerts this run would send as an HTML page approximating Discord,
+// in PREVIEW_DIR (default: the system temp directory), and returns its path. Each run
+// overwrites the same file, so a dry run left polling doesn't fill the directory.
+func (a *app) writePreview(cfg *Config, mapsAPIKey string, incidents []incident.Incident) (string, error) {
+	var messages []previewMessage
+	for _, i := range incidents {
+		if !cfg.Filter.Apply(&i) {
+			continue
+		}
+		var enrichment enrich.Result
+		if i.Latitude.Valid && i.Longitude.Valid {
+			// Only the read-only camera lookup; no snapshot is downloaded or recorded.
+			cameras, err := camera.FindNearby(context.Background(), a.db, incident.Corridor(i), i.Latitude.Float64, i.Longitude.Float64, camera.MaxGridCameras)
+			if err != nil {
+				log.Printf("Could not fetch nearby cameras: %v", err)
+			}
+			enrichment.NearbyCameras = cameras
+		}
+		for _, route := range cfg.Routes {
+			if !route.Matches(i) {
+				continue
+			}
+			opts := cfg.RenderOptions(route, i.Source)
+			routeEnrichment := enrichment
+			if !opts.Enabled(discord.FeatureCameras) {
+				routeEnrichment.NearbyCameras = nil
+			}
+			payload, err := discord.BuildPayload(mapsAPIKey, i, routeEnrichment, opts)
+			if err != nil {
+				log.Printf("Error building preview for incident %d: %v", i.ID, err)
+				continue
+			}
+			for _, part := range discord.SplitPayload(payload, opts.T("field_continued")) {
+				messages = append(messages, previewMessage{Route: route.Name, Incident: i, Username: part.Username, Embeds: part.Embeds})
+			}
+		}
+	}
+
+	dir := os.Getenv("PREVIEW_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	path := filepath.Join(dir, "unity-alerts-preview.html")
+	// Written aside and renamed over the old preview, so a browser never loads half a page.
+	f, err := os.CreateTemp(dir, "unity-alerts-preview-*.html")
+	if err != nil {
+		return "", fmt.Errorf("failed to create preview file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	data := struct {
+		Generated string
+		Messages  []previewMessage
+	}{time.Now().Format(time.RFC1123), messages}
+	if err := previewTemplate.Execute(f, data); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to render preview: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to write preview: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to replace preview: %w", err)
+	}
+	return path, nil
+}
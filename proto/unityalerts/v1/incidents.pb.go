@@ -0,0 +1,808 @@
+// The unity-alerts gRPC API, served on GRPC_ADDR, over TLS when GRPC_TLS_CERT and
+// GRPC_TLS_KEY are set. Calls carry the same bearer token as the REST API in "authorization"
+// metadata; SubmitIncident takes INGEST_TOKEN instead, and Resend requires API_TOKEN to be set.
+//
+// The Go code in this directory is generated from this file with go generate.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: unityalerts/v1/incidents.proto
+
+package unityalertsv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// UnifiedIncident is a row of unified_incidents.
+type UnifiedIncident struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Source        string                 `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`
+	SourceId      string                 `protobuf:"bytes,3,opt,name=source_id,json=sourceId,proto3" json:"source_id,omitempty"`
+	EventType     string                 `protobuf:"bytes,4,opt,name=event_type,json=eventType,proto3" json:"event_type,omitempty"`
+	Address       string                 `protobuf:"bytes,5,opt,name=address,proto3" json:"address,omitempty"`
+	Latitude      *float64               `protobuf:"fixed64,6,opt,name=latitude,proto3,oneof" json:"latitude,omitempty"`
+	Longitude     *float64               `protobuf:"fixed64,7,opt,name=longitude,proto3,oneof" json:"longitude,omitempty"`
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Status        string                 `protobuf:"bytes,9,opt,name=status,proto3" json:"status,omitempty"`                               // "active" or "cleared".
+	DetailsJson   string                 `protobuf:"bytes,10,opt,name=details_json,json=detailsJson,proto3" json:"details_json,omitempty"` // The feed's raw details, a JSON object.
+	Tags          []string               `protobuf:"bytes,11,rep,name=tags,proto3" json:"tags,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnifiedIncident) Reset() {
+	*x = UnifiedIncident{}
+	mi := &file_unityalerts_v1_incidents_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnifiedIncident) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnifiedIncident) ProtoMessage() {}
+
+func (x *UnifiedIncident) ProtoReflect() protoreflect.Message {
+	mi := &file_unityalerts_v1_incidents_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnifiedIncident.ProtoReflect.Descriptor instead.
+func (*UnifiedIncident) Descriptor() ([]byte, []int) {
+	return file_unityalerts_v1_incidents_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *UnifiedIncident) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *UnifiedIncident) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *UnifiedIncident) GetSourceId() string {
+	if x != nil {
+		return x.SourceId
+	}
+	return ""
+}
+
+func (x *UnifiedIncident) GetEventType() string {
+	if x != nil {
+		return x.EventType
+	}
+	return ""
+}
+
+func (x *UnifiedIncident) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *UnifiedIncident) GetLatitude() float64 {
+	if x != nil && x.Latitude != nil {
+		return *x.Latitude
+	}
+	return 0
+}
+
+func (x *UnifiedIncident) GetLongitude() float64 {
+	if x != nil && x.Longitude != nil {
+		return *x.Longitude
+	}
+	return 0
+}
+
+func (x *UnifiedIncident) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *UnifiedIncident) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *UnifiedIncident) GetDetailsJson() string {
+	if x != nil {
+		return x.DetailsJson
+	}
+	return ""
+}
+
+func (x *UnifiedIncident) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+type ListIncidentsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Hours         int32                  `protobuf:"varint,1,opt,name=hours,proto3" json:"hours,omitempty"`  // How far back to look; default 24, at most 168.
+	Route         string                 `protobuf:"bytes,2,opt,name=route,proto3" json:"route,omitempty"`   // Only incidents this route accepts, with keyword rules applied.
+	Source        string                 `protobuf:"bytes,3,opt,name=source,proto3" json:"source,omitempty"` // Only incidents from this source.
+	Limit         int32                  `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`  // Default and maximum 500.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListIncidentsRequest) Reset() {
+	*x = ListIncidentsRequest{}
+	mi := &file_unityalerts_v1_incidents_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListIncidentsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListIncidentsRequest) ProtoMessage() {}
+
+func (x *ListIncidentsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_unityalerts_v1_incidents_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListIncidentsRequest.ProtoReflect.Descriptor instead.
+func (*ListIncidentsRequest) Descriptor() ([]byte, []int) {
+	return file_unityalerts_v1_incidents_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListIncidentsRequest) GetHours() int32 {
+	if x != nil {
+		return x.Hours
+	}
+	return 0
+}
+
+func (x *ListIncidentsRequest) GetRoute() string {
+	if x != nil {
+		return x.Route
+	}
+	return ""
+}
+
+func (x *ListIncidentsRequest) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *ListIncidentsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type ListIncidentsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Incidents     []*UnifiedIncident     `protobuf:"bytes,1,rep,name=incidents,proto3" json:"incidents,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListIncidentsResponse) Reset() {
+	*x = ListIncidentsResponse{}
+	mi := &file_unityalerts_v1_incidents_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListIncidentsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListIncidentsResponse) ProtoMessage() {}
+
+func (x *ListIncidentsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_unityalerts_v1_incidents_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListIncidentsResponse.ProtoReflect.Descriptor instead.
+func (*ListIncidentsResponse) Descriptor() ([]byte, []int) {
+	return file_unityalerts_v1_incidents_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ListIncidentsResponse) GetIncidents() []*UnifiedIncident {
+	if x != nil {
+		return x.Incidents
+	}
+	return nil
+}
+
+type StreamIncidentsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Types         []string               `protobuf:"bytes,1,rep,name=types,proto3" json:"types,omitempty"`     // Event types to send; default all.
+	Sources       []string               `protobuf:"bytes,2,rep,name=sources,proto3" json:"sources,omitempty"` // Sources to send; default all.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamIncidentsRequest) Reset() {
+	*x = StreamIncidentsRequest{}
+	mi := &file_unityalerts_v1_incidents_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamIncidentsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamIncidentsRequest) ProtoMessage() {}
+
+func (x *StreamIncidentsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_unityalerts_v1_incidents_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamIncidentsRequest.ProtoReflect.Descriptor instead.
+func (*StreamIncidentsRequest) Descriptor() ([]byte, []int) {
+	return file_unityalerts_v1_incidents_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *StreamIncidentsRequest) GetTypes() []string {
+	if x != nil {
+		return x.Types
+	}
+	return nil
+}
+
+func (x *StreamIncidentsRequest) GetSources() []string {
+	if x != nil {
+		return x.Sources
+	}
+	return nil
+}
+
+// IncidentEvent mirrors the events published to NATS or Kafka.
+type IncidentEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"` // "created", "updated", "cleared" or "delivered".
+	At            *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=at,proto3" json:"at,omitempty"`
+	Incident      *UnifiedIncident       `protobuf:"bytes,3,opt,name=incident,proto3" json:"incident,omitempty"`                    // details_json is only set on created events.
+	Route         string                 `protobuf:"bytes,4,opt,name=route,proto3" json:"route,omitempty"`                          // On delivered events.
+	MessageId     string                 `protobuf:"bytes,5,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"` // On delivered events.
+	Reason        string                 `protobuf:"bytes,6,opt,name=reason,proto3" json:"reason,omitempty"`                        // On updated events.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IncidentEvent) Reset() {
+	*x = IncidentEvent{}
+	mi := &file_unityalerts_v1_incidents_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IncidentEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IncidentEvent) ProtoMessage() {}
+
+func (x *IncidentEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_unityalerts_v1_incidents_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IncidentEvent.ProtoReflect.Descriptor instead.
+func (*IncidentEvent) Descriptor() ([]byte, []int) {
+	return file_unityalerts_v1_incidents_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *IncidentEvent) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *IncidentEvent) GetAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.At
+	}
+	return nil
+}
+
+func (x *IncidentEvent) GetIncident() *UnifiedIncident {
+	if x != nil {
+		return x.Incident
+	}
+	return nil
+}
+
+func (x *IncidentEvent) GetRoute() string {
+	if x != nil {
+		return x.Route
+	}
+	return ""
+}
+
+func (x *IncidentEvent) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *IncidentEvent) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type SubmitIncidentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Source        string                 `protobuf:"bytes,1,opt,name=source,proto3" json:"source,omitempty"`
+	SourceId      string                 `protobuf:"bytes,2,opt,name=source_id,json=sourceId,proto3" json:"source_id,omitempty"`
+	EventType     string                 `protobuf:"bytes,3,opt,name=event_type,json=eventType,proto3" json:"event_type,omitempty"`
+	Address       string                 `protobuf:"bytes,4,opt,name=address,proto3" json:"address,omitempty"`
+	Latitude      *float64               `protobuf:"fixed64,5,opt,name=latitude,proto3,oneof" json:"latitude,omitempty"`
+	Longitude     *float64               `protobuf:"fixed64,6,opt,name=longitude,proto3,oneof" json:"longitude,omitempty"`
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=timestamp,proto3" json:"timestamp,omitempty"` // Defaults to when it was received.
+	DetailsJson   string                 `protobuf:"bytes,8,opt,name=details_json,json=detailsJson,proto3" json:"details_json,omitempty"`
+	Status        string                 `protobuf:"bytes,9,opt,name=status,proto3" json:"status,omitempty"` // "active" (default) or "cleared".
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitIncidentRequest) Reset() {
+	*x = SubmitIncidentRequest{}
+	mi := &file_unityalerts_v1_incidents_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitIncidentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitIncidentRequest) ProtoMessage() {}
+
+func (x *SubmitIncidentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_unityalerts_v1_incidents_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitIncidentRequest.ProtoReflect.Descriptor instead.
+func (*SubmitIncidentRequest) Descriptor() ([]byte, []int) {
+	return file_unityalerts_v1_incidents_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *SubmitIncidentRequest) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *SubmitIncidentRequest) GetSourceId() string {
+	if x != nil {
+		return x.SourceId
+	}
+	return ""
+}
+
+func (x *SubmitIncidentRequest) GetEventType() string {
+	if x != nil {
+		return x.EventType
+	}
+	return ""
+}
+
+func (x *SubmitIncidentRequest) GetAddress() string {
+	if x != nil {
+		return x.Address
+	}
+	return ""
+}
+
+func (x *SubmitIncidentRequest) GetLatitude() float64 {
+	if x != nil && x.Latitude != nil {
+		return *x.Latitude
+	}
+	return 0
+}
+
+func (x *SubmitIncidentRequest) GetLongitude() float64 {
+	if x != nil && x.Longitude != nil {
+		return *x.Longitude
+	}
+	return 0
+}
+
+func (x *SubmitIncidentRequest) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *SubmitIncidentRequest) GetDetailsJson() string {
+	if x != nil {
+		return x.DetailsJson
+	}
+	return ""
+}
+
+func (x *SubmitIncidentRequest) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+type SubmitIncidentResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Result        string                 `protobuf:"bytes,2,opt,name=result,proto3" json:"result,omitempty"` // "created", "cleared" or "unchanged".
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubmitIncidentResponse) Reset() {
+	*x = SubmitIncidentResponse{}
+	mi := &file_unityalerts_v1_incidents_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubmitIncidentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubmitIncidentResponse) ProtoMessage() {}
+
+func (x *SubmitIncidentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_unityalerts_v1_incidents_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubmitIncidentResponse.ProtoReflect.Descriptor instead.
+func (*SubmitIncidentResponse) Descriptor() ([]byte, []int) {
+	return file_unityalerts_v1_incidents_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SubmitIncidentResponse) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *SubmitIncidentResponse) GetResult() string {
+	if x != nil {
+		return x.Result
+	}
+	return ""
+}
+
+type ResendRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Route         string                 `protobuf:"bytes,2,opt,name=route,proto3" json:"route,omitempty"`  // Default: every route that accepts the incident.
+	Force         bool                   `protobuf:"varint,3,opt,name=force,proto3" json:"force,omitempty"` // Send even if the incident has cleared or the route does not accept it.
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResendRequest) Reset() {
+	*x = ResendRequest{}
+	mi := &file_unityalerts_v1_incidents_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResendRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResendRequest) ProtoMessage() {}
+
+func (x *ResendRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_unityalerts_v1_incidents_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResendRequest.ProtoReflect.Descriptor instead.
+func (*ResendRequest) Descriptor() ([]byte, []int) {
+	return file_unityalerts_v1_incidents_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ResendRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *ResendRequest) GetRoute() string {
+	if x != nil {
+		return x.Route
+	}
+	return ""
+}
+
+func (x *ResendRequest) GetForce() bool {
+	if x != nil {
+		return x.Force
+	}
+	return false
+}
+
+type ResendResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Routes        int32                  `protobuf:"varint,1,opt,name=routes,proto3" json:"routes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResendResponse) Reset() {
+	*x = ResendResponse{}
+	mi := &file_unityalerts_v1_incidents_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResendResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResendResponse) ProtoMessage() {}
+
+func (x *ResendResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_unityalerts_v1_incidents_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResendResponse.ProtoReflect.Descriptor instead.
+func (*ResendResponse) Descriptor() ([]byte, []int) {
+	return file_unityalerts_v1_incidents_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ResendResponse) GetRoutes() int32 {
+	if x != nil {
+		return x.Routes
+	}
+	return 0
+}
+
+var File_unityalerts_v1_incidents_proto protoreflect.FileDescriptor
+
+const file_unityalerts_v1_incidents_proto_rawDesc = "" +
+	"\n" +
+	"\x1eunityalerts/v1/incidents.proto\x12\x0eunityalerts.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xf7\x02\n" +
+	"\x0fUnifiedIncident\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x16\n" +
+	"\x06source\x18\x02 \x01(\tR\x06source\x12\x1b\n" +
+	"\tsource_id\x18\x03 \x01(\tR\bsourceId\x12\x1d\n" +
+	"\n" +
+	"event_type\x18\x04 \x01(\tR\teventType\x12\x18\n" +
+	"\aaddress\x18\x05 \x01(\tR\aaddress\x12\x1f\n" +
+	"\blatitude\x18\x06 \x01(\x01H\x00R\blatitude\x88\x01\x01\x12!\n" +
+	"\tlongitude\x18\a \x01(\x01H\x01R\tlongitude\x88\x01\x01\x128\n" +
+	"\ttimestamp\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12\x16\n" +
+	"\x06status\x18\t \x01(\tR\x06status\x12!\n" +
+	"\fdetails_json\x18\n" +
+	" \x01(\tR\vdetailsJson\x12\x12\n" +
+	"\x04tags\x18\v \x03(\tR\x04tagsB\v\n" +
+	"\t_latitudeB\f\n" +
+	"\n" +
+	"_longitude\"p\n" +
+	"\x14ListIncidentsRequest\x12\x14\n" +
+	"\x05hours\x18\x01 \x01(\x05R\x05hours\x12\x14\n" +
+	"\x05route\x18\x02 \x01(\tR\x05route\x12\x16\n" +
+	"\x06source\x18\x03 \x01(\tR\x06source\x12\x14\n" +
+	"\x05limit\x18\x04 \x01(\x05R\x05limit\"V\n" +
+	"\x15ListIncidentsResponse\x12=\n" +
+	"\tincidents\x18\x01 \x03(\v2\x1f.unityalerts.v1.UnifiedIncidentR\tincidents\"H\n" +
+	"\x16StreamIncidentsRequest\x12\x14\n" +
+	"\x05types\x18\x01 \x03(\tR\x05types\x12\x18\n" +
+	"\asources\x18\x02 \x03(\tR\asources\"\xd9\x01\n" +
+	"\rIncidentEvent\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12*\n" +
+	"\x02at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x02at\x12;\n" +
+	"\bincident\x18\x03 \x01(\v2\x1f.unityalerts.v1.UnifiedIncidentR\bincident\x12\x14\n" +
+	"\x05route\x18\x04 \x01(\tR\x05route\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x05 \x01(\tR\tmessageId\x12\x16\n" +
+	"\x06reason\x18\x06 \x01(\tR\x06reason\"\xd9\x02\n" +
+	"\x15SubmitIncidentRequest\x12\x16\n" +
+	"\x06source\x18\x01 \x01(\tR\x06source\x12\x1b\n" +
+	"\tsource_id\x18\x02 \x01(\tR\bsourceId\x12\x1d\n" +
+	"\n" +
+	"event_type\x18\x03 \x01(\tR\teventType\x12\x18\n" +
+	"\aaddress\x18\x04 \x01(\tR\aaddress\x12\x1f\n" +
+	"\blatitude\x18\x05 \x01(\x01H\x00R\blatitude\x88\x01\x01\x12!\n" +
+	"\tlongitude\x18\x06 \x01(\x01H\x01R\tlongitude\x88\x01\x01\x128\n" +
+	"\ttimestamp\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12!\n" +
+	"\fdetails_json\x18\b \x01(\tR\vdetailsJson\x12\x16\n" +
+	"\x06status\x18\t \x01(\tR\x06statusB\v\n" +
+	"\t_latitudeB\f\n" +
+	"\n" +
+	"_longitude\"@\n" +
+	"\x16SubmitIncidentResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x16\n" +
+	"\x06result\x18\x02 \x01(\tR\x06result\"K\n" +
+	"\rResendRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x14\n" +
+	"\x05route\x18\x02 \x01(\tR\x05route\x12\x14\n" +
+	"\x05force\x18\x03 \x01(\bR\x05force\"(\n" +
+	"\x0eResendResponse\x12\x16\n" +
+	"\x06routes\x18\x01 \x01(\x05R\x06routes2\xef\x02\n" +
+	"\tIncidents\x12\\\n" +
+	"\rListIncidents\x12$.unityalerts.v1.ListIncidentsRequest\x1a%.unityalerts.v1.ListIncidentsResponse\x12Z\n" +
+	"\x0fStreamIncidents\x12&.unityalerts.v1.StreamIncidentsRequest\x1a\x1d.unityalerts.v1.IncidentEvent0\x01\x12_\n" +
+	"\x0eSubmitIncident\x12%.unityalerts.v1.SubmitIncidentRequest\x1a&.unityalerts.v1.SubmitIncidentResponse\x12G\n" +
+	"\x06Resend\x12\x1d.unityalerts.v1.ResendRequest\x1a\x1e.unityalerts.v1.ResendResponseBDZBgithub.com/mtickle/unity-alerts/proto/unityalerts/v1;unityalertsv1b\x06proto3"
+
+var (
+	file_unityalerts_v1_incidents_proto_rawDescOnce sync.Once
+	file_unityalerts_v1_incidents_proto_rawDescData []byte
+)
+
+func file_unityalerts_v1_incidents_proto_rawDescGZIP() []byte {
+	file_unityalerts_v1_incidents_proto_rawDescOnce.Do(func() {
+		file_unityalerts_v1_incidents_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_unityalerts_v1_incidents_proto_rawDesc), len(file_unityalerts_v1_incidents_proto_rawDesc)))
+	})
+	return file_unityalerts_v1_incidents_proto_rawDescData
+}
+
+var file_unityalerts_v1_incidents_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_unityalerts_v1_incidents_proto_goTypes = []any{
+	(*UnifiedIncident)(nil),        // 0: unityalerts.v1.UnifiedIncident
+	(*ListIncidentsRequest)(nil),   // 1: unityalerts.v1.ListIncidentsRequest
+	(*ListIncidentsResponse)(nil),  // 2: unityalerts.v1.ListIncidentsResponse
+	(*StreamIncidentsRequest)(nil), // 3: unityalerts.v1.StreamIncidentsRequest
+	(*IncidentEvent)(nil),          // 4: unityalerts.v1.IncidentEvent
+	(*SubmitIncidentRequest)(nil),  // 5: unityalerts.v1.SubmitIncidentRequest
+	(*SubmitIncidentResponse)(nil), // 6: unityalerts.v1.SubmitIncidentResponse
+	(*ResendRequest)(nil),          // 7: unityalerts.v1.ResendRequest
+	(*ResendResponse)(nil),         // 8: unityalerts.v1.ResendResponse
+	(*timestamppb.Timestamp)(nil),  // 9: google.protobuf.Timestamp
+}
+var file_unityalerts_v1_incidents_proto_depIdxs = []int32{
+	9, // 0: unityalerts.v1.UnifiedIncident.timestamp:type_name -> google.protobuf.Timestamp
+	0, // 1: unityalerts.v1.ListIncidentsResponse.incidents:type_name -> unityalerts.v1.UnifiedIncident
+	9, // 2: unityalerts.v1.IncidentEvent.at:type_name -> google.protobuf.Timestamp
+	0, // 3: unityalerts.v1.IncidentEvent.incident:type_name -> unityalerts.v1.UnifiedIncident
+	9, // 4: unityalerts.v1.SubmitIncidentRequest.timestamp:type_name -> google.protobuf.Timestamp
+	1, // 5: unityalerts.v1.Incidents.ListIncidents:input_type -> unityalerts.v1.ListIncidentsRequest
+	3, // 6: unityalerts.v1.Incidents.StreamIncidents:input_type -> unityalerts.v1.StreamIncidentsRequest
+	5, // 7: unityalerts.v1.Incidents.SubmitIncident:input_type -> unityalerts.v1.SubmitIncidentRequest
+	7, // 8: unityalerts.v1.Incidents.Resend:input_type -> unityalerts.v1.ResendRequest
+	2, // 9: unityalerts.v1.Incidents.ListIncidents:output_type -> unityalerts.v1.ListIncidentsResponse
+	4, // 10: unityalerts.v1.Incidents.StreamIncidents:output_type -> unityalerts.v1.IncidentEvent
+	6, // 11: unityalerts.v1.Incidents.SubmitIncident:output_type -> unityalerts.v1.SubmitIncidentResponse
+	8, // 12: unityalerts.v1.Incidents.Resend:output_type -> unityalerts.v1.ResendResponse
+	9, // [9:13] is the sub-list for method output_type
+	5, // [5:9] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_unityalerts_v1_incidents_proto_init() }
+func file_unityalerts_v1_incidents_proto_init() {
+	if File_unityalerts_v1_incidents_proto != nil {
+		return
+	}
+	file_unityalerts_v1_incidents_proto_msgTypes[0].OneofWrappers = []any{}
+	file_unityalerts_v1_incidents_proto_msgTypes[5].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_unityalerts_v1_incidents_proto_rawDesc), len(file_unityalerts_v1_incidents_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_unityalerts_v1_incidents_proto_goTypes,
+		DependencyIndexes: file_unityalerts_v1_incidents_proto_depIdxs,
+		MessageInfos:      file_unityalerts_v1_incidents_proto_msgTypes,
+	}.Build()
+	File_unityalerts_v1_incidents_proto = out.File
+	file_unityalerts_v1_incidents_proto_goTypes = nil
+	file_unityalerts_v1_incidents_proto_depIdxs = nil
+}
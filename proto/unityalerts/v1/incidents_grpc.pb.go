@@ -0,0 +1,253 @@
+// The unity-alerts gRPC API, served on GRPC_ADDR, over TLS when GRPC_TLS_CERT and
+// GRPC_TLS_KEY are set. Calls carry the same bearer token as the REST API in "authorization"
+// metadata; SubmitIncident takes INGEST_TOKEN instead, and Resend requires API_TOKEN to be set.
+//
+// The Go code in this directory is generated from this file with go generate.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.0
+// - protoc             (unknown)
+// source: unityalerts/v1/incidents.proto
+
+package unityalertsv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Incidents_ListIncidents_FullMethodName   = "/unityalerts.v1.Incidents/ListIncidents"
+	Incidents_StreamIncidents_FullMethodName = "/unityalerts.v1.Incidents/StreamIncidents"
+	Incidents_SubmitIncident_FullMethodName  = "/unityalerts.v1.Incidents/SubmitIncident"
+	Incidents_Resend_FullMethodName          = "/unityalerts.v1.Incidents/Resend"
+)
+
+// IncidentsClient is the client API for Incidents service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type IncidentsClient interface {
+	// ListIncidents returns recent incidents, newest first.
+	ListIncidents(ctx context.Context, in *ListIncidentsRequest, opts ...grpc.CallOption) (*ListIncidentsResponse, error)
+	// StreamIncidents sends lifecycle events as they happen, until the client cancels.
+	StreamIncidents(ctx context.Context, in *StreamIncidentsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[IncidentEvent], error)
+	// SubmitIncident stores a pushed incident, like POST /ingest.
+	SubmitIncident(ctx context.Context, in *SubmitIncidentRequest, opts ...grpc.CallOption) (*SubmitIncidentResponse, error)
+	// Resend sends an incident's alert again, like the replay command.
+	Resend(ctx context.Context, in *ResendRequest, opts ...grpc.CallOption) (*ResendResponse, error)
+}
+
+type incidentsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewIncidentsClient(cc grpc.ClientConnInterface) IncidentsClient {
+	return &incidentsClient{cc}
+}
+
+func (c *incidentsClient) ListIncidents(ctx context.Context, in *ListIncidentsRequest, opts ...grpc.CallOption) (*ListIncidentsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListIncidentsResponse)
+	err := c.cc.Invoke(ctx, Incidents_ListIncidents_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *incidentsClient) StreamIncidents(ctx context.Context, in *StreamIncidentsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[IncidentEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Incidents_ServiceDesc.Streams[0], Incidents_StreamIncidents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamIncidentsRequest, IncidentEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Incidents_StreamIncidentsClient = grpc.ServerStreamingClient[IncidentEvent]
+
+func (c *incidentsClient) SubmitIncident(ctx context.Context, in *SubmitIncidentRequest, opts ...grpc.CallOption) (*SubmitIncidentResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SubmitIncidentResponse)
+	err := c.cc.Invoke(ctx, Incidents_SubmitIncident_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *incidentsClient) Resend(ctx context.Context, in *ResendRequest, opts ...grpc.CallOption) (*ResendResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ResendResponse)
+	err := c.cc.Invoke(ctx, Incidents_Resend_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// IncidentsServer is the server API for Incidents service.
+// All implementations must embed UnimplementedIncidentsServer
+// for forward compatibility.
+type IncidentsServer interface {
+	// ListIncidents returns recent incidents, newest first.
+	ListIncidents(context.Context, *ListIncidentsRequest) (*ListIncidentsResponse, error)
+	// StreamIncidents sends lifecycle events as they happen, until the client cancels.
+	StreamIncidents(*StreamIncidentsRequest, grpc.ServerStreamingServer[IncidentEvent]) error
+	// SubmitIncident stores a pushed incident, like POST /ingest.
+	SubmitIncident(context.Context, *SubmitIncidentRequest) (*SubmitIncidentResponse, error)
+	// Resend sends an incident's alert again, like the replay command.
+	Resend(context.Context, *ResendRequest) (*ResendResponse, error)
+	mustEmbedUnimplementedIncidentsServer()
+}
+
+// UnimplementedIncidentsServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedIncidentsServer struct{}
+
+func (UnimplementedIncidentsServer) ListIncidents(context.Context, *ListIncidentsRequest) (*ListIncidentsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method ListIncidents not implemented")
+}
+func (UnimplementedIncidentsServer) StreamIncidents(*StreamIncidentsRequest, grpc.ServerStreamingServer[IncidentEvent]) error {
+	return status.Error(codes.Unimplemented, "method StreamIncidents not implemented")
+}
+func (UnimplementedIncidentsServer) SubmitIncident(context.Context, *SubmitIncidentRequest) (*SubmitIncidentResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SubmitIncident not implemented")
+}
+func (UnimplementedIncidentsServer) Resend(context.Context, *ResendRequest) (*ResendResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Resend not implemented")
+}
+func (UnimplementedIncidentsServer) mustEmbedUnimplementedIncidentsServer() {}
+func (UnimplementedIncidentsServer) testEmbeddedByValue()                   {}
+
+// UnsafeIncidentsServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to IncidentsServer will
+// result in compilation errors.
+type UnsafeIncidentsServer interface {
+	mustEmbedUnimplementedIncidentsServer()
+}
+
+func RegisterIncidentsServer(s grpc.ServiceRegistrar, srv IncidentsServer) {
+	// If the following call panics, it indicates UnimplementedIncidentsServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Incidents_ServiceDesc, srv)
+}
+
+func _Incidents_ListIncidents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListIncidentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IncidentsServer).ListIncidents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Incidents_ListIncidents_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IncidentsServer).ListIncidents(ctx, req.(*ListIncidentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Incidents_StreamIncidents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamIncidentsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(IncidentsServer).StreamIncidents(m, &grpc.GenericServerStream[StreamIncidentsRequest, IncidentEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Incidents_StreamIncidentsServer = grpc.ServerStreamingServer[IncidentEvent]
+
+func _Incidents_SubmitIncident_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitIncidentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IncidentsServer).SubmitIncident(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Incidents_SubmitIncident_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IncidentsServer).SubmitIncident(ctx, req.(*SubmitIncidentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Incidents_Resend_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IncidentsServer).Resend(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Incidents_Resend_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IncidentsServer).Resend(ctx, req.(*ResendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Incidents_ServiceDesc is the grpc.ServiceDesc for Incidents service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Incidents_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "unityalerts.v1.Incidents",
+	HandlerType: (*IncidentsServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListIncidents",
+			Handler:    _Incidents_ListIncidents_Handler,
+		},
+		{
+			MethodName: "SubmitIncident",
+			Handler:    _Incidents_SubmitIncident_Handler,
+		},
+		{
+			MethodName: "Resend",
+			Handler:    _Incidents_Resend_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamIncidents",
+			Handler:       _Incidents_StreamIncidents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "unityalerts/v1/incidents.proto",
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mtickle/unity-alerts/incident"
+)
+
+// QueueConfig orders a run's alerts when there is a backlog, of which only MaxPerRun go out
+// per run. Urgent incidents go out first, then those that just happened, then the rest. So
+// that a long backlog of minor incidents still moves while urgent ones keep coming, every
+// OldestEvery-th alert is instead the incident that has waited longest since it was stored.
+type QueueConfig struct {
+	UrgentSeverity int    `json:"urgent_severity,omitempty"` // Default 3, counting keyword rule boosts.
+	FreshFor       string `json:"fresh_for,omitempty"`       // Default "15m" since the incident happened.
+	OldestEvery    int    `json:"oldest_every,omitempty"`    // Default 5.
+	MaxPerRun      int    `json:"max_per_run,omitempty"`     // Default 20 alerts, counting merged reports once.
+}
+
+func (c *QueueConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.UrgentSeverity < 0 {
+		return fmt.Errorf("queue.urgent_severity must not be negative")
+	}
+	if c.MaxPerRun < 0 {
+		return fmt.Errorf("queue.max_per_run must not be negative")
+	}
+	if c.OldestEvery < 0 || c.OldestEvery == 1 {
+		return fmt.Errorf("queue.oldest_every must be at least 2")
+	}
+	if c.FreshFor != "" {
+		if d, err := time.ParseDuration(c.FreshFor); err != nil || d <= 0 {
+			return fmt.Errorf("queue.fresh_for: invalid duration %q", c.FreshFor)
+		}
+	}
+	return nil
+}
+
+// settings returns the configuration with defaults filled in.
+func (c *QueueConfig) settings() (urgentSeverity int, freshFor time.Duration, oldestEvery int) {
+	urgentSeverity, freshFor, oldestEvery = 3, 15*time.Minute, 5
+	if c == nil {
+		return
+	}
+	if c.UrgentSeverity > 0 {
+		urgentSeverity = c.UrgentSeverity
+	}
+	if c.FreshFor != "" {
+		freshFor, _ = time.ParseDuration(c.FreshFor)
+	}
+	if c.OldestEvery > 0 {
+		oldestEvery = c.OldestEvery
+	}
+	return
+}
+
+// defaultMaxPerRun applies when queue.max_per_run is not set.
+const defaultMaxPerRun = 20
+
+// maxPerRun is how many alerts one run sends before leaving the rest of the queue for the next.
+func (c *QueueConfig) maxPerRun() int {
+	if c == nil || c.MaxPerRun == 0 {
+		return defaultMaxPerRun
+	}
+	return c.MaxPerRun
+}
+
+// Queue tiers, in the order they are sent.
+const (
+	tierUrgent = iota
+	tierFresh
+	tierBacklog
+)
+
+// queueEntry is a pending incident with its place in the queue.
+type queueEntry struct {
+	p     *pendingIncident
+	tier  int
+	rank  int
+	since time.Time // When it was stored, or else when it happened.
+	sent  bool
+}
+
+// sendQueue is a priority queue of pending incidents: urgent incidents first, then fresh ones,
+// then the backlog, and within each tier the highest rank and, among equal ranks, the freshest
+// incident. Every oldestEvery-th incident is the one waiting longest instead.
+type sendQueue struct {
+	entries     []*queueEntry // A heap by tier, rank and freshness.
+	oldest      []*queueEntry // By the time they have waited, longest first.
+	remaining   int
+	sent        int
+	oldestEvery int
+}
+
+// newSendQueue ranks each incident once and heapifies them.
+func newSendQueue(cfg *Config, pending []*pendingIncident) *sendQueue {
+	urgentSeverity, freshFor, oldestEvery := cfg.Queue.settings()
+	now := time.Now()
+	q := &sendQueue{remaining: len(pending), oldestEvery: oldestEvery}
+	for _, p := range pending {
+		e := &queueEntry{p: p, tier: tierBacklog, rank: cfg.sendRank(p.incident), since: p.insertedAt}
+		if e.since.IsZero() {
+			e.since = p.incident.Timestamp
+		}
+		switch {
+		case incident.Severity(p.incident)+p.incident.Priority >= urgentSeverity:
+			e.tier = tierUrgent
+		case now.Sub(p.incident.Timestamp) < freshFor:
+			e.tier = tierFresh
+		}
+		q.entries = append(q.entries, e)
+	}
+	heap.Init(q)
+	q.oldest = append(q.oldest, q.entries...)
+	sort.SliceStable(q.oldest, func(x, y int) bool { return q.oldest[x].since.Before(q.oldest[y].since) })
+	return q
+}
+
+func (q *sendQueue) Len() int { return len(q.entries) }
+
+func (q *sendQueue) Less(i, j int) bool {
+	x, y := q.entries[i], q.entries[j]
+	if x.tier != y.tier {
+		return x.tier < y.tier
+	}
+	if x.rank != y.rank {
+		return x.rank > y.rank
+	}
+	return x.p.incident.Timestamp.After(y.p.incident.Timestamp)
+}
+
+func (q *sendQueue) Swap(x, y int) {
+	q.entries[x], q.entries[y] = q.entries[y], q.entries[x]
+}
+
+func (q *sendQueue) Push(v interface{}) {
+	panic("sendQueue is filled by newSendQueue")
+}
+
+func (q *sendQueue) Pop() interface{} {
+	last := len(q.entries) - 1
+	e := q.entries[last]
+	q.entries = q.entries[:last]
+	return e
+}
+
+// pending is the number of incidents left to send.
+func (q *sendQueue) pending() int { return q.remaining }
+
+// pop removes and returns the incident to send next.
+func (q *sendQueue) pop() *pendingIncident {
+	var e *queueEntry
+	if q.sent%q.oldestEvery == q.oldestEvery-1 {
+		for len(q.oldest) > 0 && q.oldest[0].sent {
+			q.oldest = q.oldest[1:]
+		}
+		e = q.oldest[0]
+	} else {
+		for e = heap.Pop(q).(*queueEntry); e.sent; e = heap.Pop(q).(*queueEntry) {
+		}
+	}
+	e.sent = true
+	q.sent++
+	q.remaining--
+	return e.p
+}
+
+// sendRank orders incidents within a tier: the feed's severity, plus keyword rule boosts, plus
+// the configured priority of the incident's source.
+func (c *Config) sendRank(i incident.Incident) int {
+	return incident.Severity(i) + i.Priority + c.SourcePriority[i.Source]
+}
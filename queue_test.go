@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mtickle/unity-alerts/incident"
+)
+
+func TestSendQueue(t *testing.T) {
+	now := time.Now()
+	// pending makes an incident that happened age ago and was stored waited ago.
+	pending := func(id int, source string, severity int, age, waited time.Duration) *pendingIncident {
+		details := []byte(fmt.Sprintf(`{"raw_incident": {"severity": %d}}`, severity))
+		return &pendingIncident{
+			incident:   incident.Incident{ID: id, Source: source, Details: details, Timestamp: now.Add(-age)},
+			insertedAt: now.Add(-waited),
+		}
+	}
+	tests := []struct {
+		name    string
+		queue   *QueueConfig
+		pending []*pendingIncident
+		want    []int // Incident IDs in the order they are sent.
+	}{
+		{"empty", nil, nil, nil},
+		{"urgent, then fresh, then the backlog", nil, []*pendingIncident{
+			pending(1, "NCDOT", 1, 2*time.Hour, 2*time.Hour),
+			pending(2, "NCDOT", 1, time.Minute, time.Minute),
+			pending(3, "NCDOT", 3, 3*time.Hour, 3*time.Hour),
+		}, []int{3, 2, 1}},
+		{"rank within a tier", nil, []*pendingIncident{
+			pending(1, "NCDOT", 0, time.Hour, time.Hour),
+			pending(2, "NCDOT", 2, 2*time.Hour, time.Hour),
+			pending(3, "RWECC", 0, 3*time.Hour, time.Hour),
+		}, []int{2, 3, 1}},
+		{"freshest among equal ranks", nil, []*pendingIncident{
+			pending(1, "NCDOT", 1, 3*time.Hour, time.Minute),
+			pending(2, "NCDOT", 1, time.Hour, time.Minute),
+			pending(3, "NCDOT", 1, 2*time.Hour, time.Minute),
+		}, []int{2, 3, 1}},
+		{"urgent severity is configurable", &QueueConfig{UrgentSeverity: 2}, []*pendingIncident{
+			pending(1, "NCDOT", 1, time.Minute, time.Minute),
+			pending(2, "NCDOT", 2, time.Hour, time.Hour),
+		}, []int{2, 1}},
+		{"every third is the longest waiting", &QueueConfig{OldestEvery: 3}, []*pendingIncident{
+			pending(1, "NCDOT", 0, 5*time.Hour, 5*time.Hour),
+			pending(2, "NCDOT", 3, time.Hour, time.Minute),
+			pending(3, "NCDOT", 3, 2*time.Hour, 2*time.Minute),
+			pending(4, "NCDOT", 3, 3*time.Hour, 3*time.Minute),
+			pending(5, "NCDOT", 3, 4*time.Hour, 4*time.Minute),
+			pending(6, "NCDOT", 0, 6*time.Hour, 6*time.Hour),
+		}, []int{2, 3, 6, 4, 5, 1}},
+		{"the longest waiting is not sent twice", &QueueConfig{OldestEvery: 2}, []*pendingIncident{
+			pending(1, "NCDOT", 3, time.Hour, 3*time.Hour),
+			pending(2, "NCDOT", 3, 2*time.Hour, time.Hour),
+			pending(3, "NCDOT", 0, 3*time.Hour, 2*time.Hour),
+		}, []int{1, 3, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Queue: tt.queue, SourcePriority: map[string]int{"RWECC": 1}}
+			var got []int
+			for q := newSendQueue(cfg, tt.pending); q.pending() > 0; {
+				got = append(got, q.pop().incident.ID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("sent %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueueConfigValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		queue *QueueConfig
+		want  string // Empty when valid.
+	}{
+		{"no queue", nil, ""},
+		{"valid", &QueueConfig{UrgentSeverity: 4, FreshFor: "30m", OldestEvery: 3}, ""},
+		{"negative urgent severity", &QueueConfig{UrgentSeverity: -1}, "urgent_severity"},
+		{"negative max_per_run", &QueueConfig{MaxPerRun: -1}, "max_per_run"},
+		{"oldest every alert", &QueueConfig{OldestEvery: 1}, "oldest_every"},
+		{"invalid fresh_for", &QueueConfig{FreshFor: "soon"}, "fresh_for"},
+		{"zero fresh_for", &QueueConfig{FreshFor: "0s"}, "fresh_for"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.queue.validate()
+			if tt.want == "" && err != nil {
+				t.Errorf("validate() = %v, want nil", err)
+			}
+			if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+				t.Errorf("validate() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueueMaxPerRun(t *testing.T) {
+	tests := []struct {
+		queue *QueueConfig
+		want  int
+	}{
+		{nil, defaultMaxPerRun},
+		{&QueueConfig{}, defaultMaxPerRun},
+		{&QueueConfig{MaxPerRun: 5}, 5},
+	}
+	for _, tt := range tests {
+		if got := tt.queue.maxPerRun(); got != tt.want {
+			t.Errorf("%+v.maxPerRun() = %d, want %d", tt.queue, got, tt.want)
+		}
+	}
+}
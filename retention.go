@@ -0,0 +1,119 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// RetentionConfig archives old incidents out of the database. Cleared incidents reported more
+// than KeepDays days ago are exported, one file per day, under Dest and then deleted along with
+// their alert messages. Incidents still active are kept however old they are.
+type RetentionConfig struct {
+	KeepDays int    `json:"keep_days"`
+	Dest     string `json:"dest"`             // A directory, or s3://bucket/prefix.
+	Format   string `json:"format,omitempty"` // "parquet" (default) or "csv".
+}
+
+func (r *RetentionConfig) validate() error {
+	if r == nil {
+		return nil
+	}
+	if r.KeepDays < 1 {
+		return fmt.Errorf("retention.keep_days must be at least 1")
+	}
+	if r.Dest == "" {
+		return fmt.Errorf("retention.dest is required")
+	}
+	if r.Format != "" && r.Format != "csv" && r.Format != "parquet" {
+		return fmt.Errorf("retention.format must be csv or parquet, not %q", r.Format)
+	}
+	return nil
+}
+
+func (r *RetentionConfig) format() string {
+	if r.Format == "" {
+		return "parquet"
+	}
+	return r.Format
+}
+
+// maxArchiveDaysPerRun bounds the work of one retention run, so a first run over years of
+// history is spread over several.
+const maxArchiveDaysPerRun = 7
+
+// archiveIncidents archives the oldest days of incidents past the retention period.
+func (a *app) archiveIncidents(cfg *Config) error {
+	r := cfg.Retention
+	if r == nil {
+		return nil
+	}
+	loc := cfg.Location(RouteConfig{})
+	now := time.Now().In(loc)
+	cutoff := time.Date(now.Year(), now.Month(), now.Day()-r.KeepDays, 0, 0, 0, 0, loc)
+	for n := 0; n < maxArchiveDaysPerRun; n++ {
+		var oldest sql.NullTime
+		err := a.db.QueryRow(cfg.SQL(`SELECT MIN({timestamp}) FROM {incidents}
+			WHERE {timestamp} < $1 AND {status} <> 'active' AND {discord_message_id} IS NULL`), cutoff).Scan(&oldest)
+		if err != nil {
+			return fmt.Errorf("error finding incidents to archive: %w", err)
+		}
+		if !oldest.Valid {
+			return nil
+		}
+		t := oldest.Time.In(loc)
+		if err := a.archiveDay(cfg, r, time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveDay exports the archivable incidents reported on one day and deletes them once the
+// file is written. The file is named after the day and the incident IDs it holds, so an
+// incident that clears late is archived to a second file rather than overwriting the first.
+// Incidents with alerts not yet updated as cleared wait for that.
+func (a *app) archiveDay(cfg *Config, r *RetentionConfig, day time.Time) error {
+	incidents, statuses, err := queryIncidents(cfg, a.db, `WHERE {timestamp} >= $1 AND {timestamp} < $2
+		AND {status} <> 'active' AND {discord_message_id} IS NULL ORDER BY {id}`, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+	if len(incidents) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(incidents))
+	for n, i := range incidents {
+		ids[n] = int64(i.ID)
+	}
+	name := fmt.Sprintf("%s_%d-%d.%s", day.Format(time.DateOnly), ids[0], ids[len(ids)-1], r.format())
+	dest := strings.TrimRight(r.Dest, "/") + "/" + name
+	columns, rows := exportRows(incidents, statuses)
+	if err := exportTo(dest, r.format(), columns, rows); err != nil {
+		return fmt.Errorf("failed to archive incidents from %s: %w", day.Format(time.DateOnly), err)
+	}
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM alert_messages WHERE incident_id = ANY($1)", pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to delete archived alert messages: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM published_events WHERE incident_id = ANY($1)", pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to delete archived published events: %w", err)
+	}
+	if _, err := tx.Exec(cfg.SQL("DELETE FROM {incidents} WHERE {id} = ANY($1)"), pq.Array(ids)); err != nil {
+		return fmt.Errorf("failed to delete archived incidents: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	log.Printf("Archived %d incident(s) from %s to %s.", len(ids), day.Format(time.DateOnly), dest)
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Schedule limits a route to time windows in the route's timezone. Incidents arriving outside
+// every window are dropped, or with OutsideWindow "defer" sent when the next window opens.
+type Schedule struct {
+	Windows       []ScheduleWindow `json:"windows"`
+	OutsideWindow string           `json:"outside_window,omitempty"` // "drop" (default) or "defer".
+}
+
+// ScheduleWindow is a daily time range, e.g. 06:00–20:00 on weekdays. An End before Start
+// runs past midnight into the next day, and 00:00–24:00 is the whole day.
+type ScheduleWindow struct {
+	Days  []string `json:"days,omitempty"` // "mon" … "sun"; empty means every day.
+	Start string   `json:"start"`          // "15:04" format.
+	End   string   `json:"end"`            // "15:04" format, or "24:00" for midnight at the end of the day.
+}
+
+// endOfDay is the End of a window that lasts until midnight.
+const endOfDay = "24:00"
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func (s *Schedule) validate() error {
+	if s == nil {
+		return nil
+	}
+	if len(s.Windows) == 0 {
+		return fmt.Errorf("schedule needs at least one window")
+	}
+	if s.OutsideWindow != "" && s.OutsideWindow != "drop" && s.OutsideWindow != "defer" {
+		return fmt.Errorf("schedule.outside_window must be \"drop\" or \"defer\", not %q", s.OutsideWindow)
+	}
+	for i, w := range s.Windows {
+		for _, day := range w.Days {
+			if _, ok := weekdays[strings.ToLower(day)]; !ok {
+				return fmt.Errorf("schedule window %d: unknown day %q", i, day)
+			}
+		}
+		if _, err := time.Parse("15:04", w.Start); err != nil {
+			return fmt.Errorf("schedule window %d: invalid start: %w", i, err)
+		}
+		if _, err := time.Parse("15:04", w.End); err != nil && w.End != endOfDay {
+			return fmt.Errorf("schedule window %d: invalid end: %w", i, err)
+		}
+		if endMinutes(w.End) == clockMinutes(w.Start) {
+			return fmt.Errorf("schedule window %d: start and end are both %s; use 00:00 to 24:00 for the whole day", i, w.Start)
+		}
+	}
+	return nil
+}
+
+// Active reports whether t, already in the route's timezone, falls inside a window.
+// A route without a schedule is always active.
+func (s *Schedule) Active(t time.Time) bool {
+	if s == nil {
+		return true
+	}
+	minute := t.Hour()*60 + t.Minute()
+	for _, w := range s.Windows {
+		start, end := clockMinutes(w.Start), endMinutes(w.End)
+		switch {
+		case start <= end && minute >= start && minute < end:
+			if w.onDay(t.Weekday()) {
+				return true
+			}
+		case start > end && minute >= start:
+			if w.onDay(t.Weekday()) {
+				return true
+			}
+		case start > end && minute < end:
+			// The early-morning tail belongs to the window that opened the day before.
+			if w.onDay((t.Weekday() + 6) % 7) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Defers reports whether out-of-window incidents are held for the next window.
+func (s *Schedule) Defers() bool {
+	return s != nil && s.OutsideWindow == "defer"
+}
+
+func (w ScheduleWindow) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if weekdays[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// clockMinutes converts a validated "15:04" time to minutes after midnight.
+func clockMinutes(s string) int {
+	t, _ := time.Parse("15:04", s)
+	return t.Hour()*60 + t.Minute()
+}
+
+// endMinutes is clockMinutes for a window's End, which may also be "24:00".
+func endMinutes(s string) int {
+	if s == endOfDay {
+		return 24 * 60
+	}
+	return clockMinutes(s)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScheduleActive(t *testing.T) {
+	weekdays := &Schedule{Windows: []ScheduleWindow{{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Start: "06:00", End: "20:00"}}}
+	overnight := &Schedule{Windows: []ScheduleWindow{{Days: []string{"Fri"}, Start: "22:00", End: "02:00"}}}
+	twoWindows := &Schedule{Windows: []ScheduleWindow{{Start: "07:00", End: "09:00"}, {Start: "16:00", End: "18:30"}}}
+	allDay := &Schedule{Windows: []ScheduleWindow{{Days: []string{"sat", "sun"}, Start: "00:00", End: "24:00"}}}
+	untilMidnight := &Schedule{Windows: []ScheduleWindow{{Days: []string{"fri"}, Start: "18:00", End: "24:00"}}}
+	// 2024-06-07 is a Friday.
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, time.June, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name     string
+		schedule *Schedule
+		t        time.Time
+		want     bool
+	}{
+		{"no schedule", nil, at(8, 3, 0), true},
+		{"inside weekday window", weekdays, at(7, 12, 0), true},
+		{"start is inside", weekdays, at(7, 6, 0), true},
+		{"end is outside", weekdays, at(7, 20, 0), false},
+		{"before the window", weekdays, at(7, 5, 59), false},
+		{"weekend", weekdays, at(8, 12, 0), false},
+		{"overnight before midnight", overnight, at(7, 23, 30), true},
+		{"overnight after midnight the next day", overnight, at(8, 1, 0), true},
+		{"overnight after midnight the same day", overnight, at(7, 1, 0), false},
+		{"overnight evening on another day", overnight, at(8, 23, 0), false},
+		{"overnight end", overnight, at(8, 2, 0), false},
+		{"second window", twoWindows, at(9, 18, 0), true},
+		{"between windows", twoWindows, at(9, 12, 0), false},
+		{"all day at midnight", allDay, at(8, 0, 0), true},
+		{"all day before midnight", allDay, at(9, 23, 59), true},
+		{"all day on another day", allDay, at(7, 23, 59), false},
+		{"until midnight", untilMidnight, at(7, 23, 59), true},
+		{"past midnight", untilMidnight, at(8, 0, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.Active(tt.t); got != tt.want {
+				t.Errorf("Active(%s) = %v, want %v", tt.t.Format("Mon 15:04"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	window := ScheduleWindow{Start: "06:00", End: "20:00"}
+	tests := []struct {
+		name     string
+		schedule *Schedule
+		want     string // Empty when valid.
+	}{
+		{"no schedule", nil, ""},
+		{"valid", &Schedule{Windows: []ScheduleWindow{{Days: []string{"MON", "sun"}, Start: "06:00", End: "20:00"}}, OutsideWindow: "defer"}, ""},
+		{"no windows", &Schedule{}, "at least one window"},
+		{"unknown outside_window", &Schedule{Windows: []ScheduleWindow{window}, OutsideWindow: "queue"}, "outside_window"},
+		{"unknown day", &Schedule{Windows: []ScheduleWindow{{Days: []string{"monday"}, Start: "06:00", End: "20:00"}}}, `unknown day "monday"`},
+		{"invalid start", &Schedule{Windows: []ScheduleWindow{{Start: "6am", End: "20:00"}}}, "invalid start"},
+		{"invalid end", &Schedule{Windows: []ScheduleWindow{window, {Start: "06:00", End: "25:00"}}}, "window 1: invalid end"},
+		{"end of day", &Schedule{Windows: []ScheduleWindow{{Start: "00:00", End: "24:00"}}}, ""},
+		{"24:00 is only an end", &Schedule{Windows: []ScheduleWindow{{Start: "24:00", End: "06:00"}}}, "invalid start"},
+		{"empty window", &Schedule{Windows: []ScheduleWindow{{Start: "06:00", End: "06:00"}}}, "start and end are both 06:00"},
+		{"empty window at midnight", &Schedule{Windows: []ScheduleWindow{{Start: "00:00", End: "00:00"}}}, "00:00 to 24:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schedule.validate()
+			if tt.want == "" && err != nil {
+				t.Errorf("validate() = %v, want nil", err)
+			}
+			if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+				t.Errorf("validate() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleDefers(t *testing.T) {
+	tests := []struct {
+		schedule *Schedule
+		want     bool
+	}{
+		{nil, false},
+		{&Schedule{}, false},
+		{&Schedule{OutsideWindow: "drop"}, false},
+		{&Schedule{OutsideWindow: "defer"}, true},
+	}
+	for _, tt := range tests {
+		if got := tt.schedule.Defers(); got != tt.want {
+			t.Errorf("%+v.Defers() = %v, want %v", tt.schedule, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mtickle/unity-alerts/camera"
+	"github.com/mtickle/unity-alerts/internal/cron"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// Job names, as used in the jobs config.
+const (
+	jobNewIncidents     = "new_incidents"
+	jobClearedIncidents = "cleared_incidents"
+	jobCameraSync       = "camera_sync"
+	jobDigests          = "digests"
+	jobRetention        = "retention"
+	jobHealth           = "health"
+)
+
+// job is one part of the work done every run. In daemon mode each can run on its own schedule.
+type job struct {
+	name string
+	run  func(a *app, cfg *Config) error
+}
+
+// jobs are run in this order when several are due together.
+var jobs = []job{
+	{jobNewIncidents, (*app).processNewIncidents},
+	{jobClearedIncidents, (*app).processClearedIncidents},
+	{jobCameraSync, (*app).syncCameras},
+	{jobDigests, (*app).postReports},
+	{jobRetention, (*app).deleteExpired},
+	{jobHealth, (*app).checkHealth},
+}
+
+// defaultJobSchedules are used for jobs missing from the jobs config; other jobs run at every
+// poll.
+var defaultJobSchedules = map[string]string{
+	jobCameraSync: "@daily",
+}
+
+// jobSchedule is the schedule of a job, or "" when it runs at every poll.
+func (c *Config) jobSchedule(name string) string {
+	if spec, ok := c.Jobs[name]; ok {
+		return spec
+	}
+	return defaultJobSchedules[name]
+}
+
+func validateJobs(schedules map[string]string) error {
+	for name, spec := range schedules {
+		known := false
+		for _, j := range jobs {
+			known = known || j.name == name
+		}
+		if !known {
+			return fmt.Errorf("jobs: unknown job %q", name)
+		}
+		if spec == "" {
+			continue
+		}
+		if _, err := cron.Parse(spec); err != nil {
+			return fmt.Errorf("jobs.%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// scheduler decides which jobs are due in daemon mode. Scheduled jobs first run when the
+// daemon starts, or when their schedule changes, and then at the times the schedule gives, in
+// the configured timezone.
+type scheduler struct {
+	a     *app
+	specs map[string]string    // The schedule each job's next run was computed from.
+	next  map[string]time.Time // When each scheduled job next runs.
+}
+
+func newScheduler(a *app) *scheduler {
+	return &scheduler{a: a, specs: make(map[string]string), next: make(map[string]time.Time)}
+}
+
+// run runs the due jobs against a single config snapshot: the scheduled jobs whose time has
+// come, the unscheduled ones if poll is set, and the jobs named in force.
+func (s *scheduler) run(poll bool, force ...string) error {
+	a := s.a
+	a.cycleMu.Lock()
+	defer a.cycleMu.Unlock()
+	cfg := a.currentConfig()
+	defer flushSignal()
+
+	now := time.Now().In(cfg.Location(RouteConfig{}))
+	defer a.busySince.Store(0)
+	var errs []error
+	for _, j := range jobs {
+		if !s.due(cfg, j.name, now, poll, contains(force, j.name)) {
+			continue
+		}
+		a.busySince.Store(time.Now().UnixNano())
+		if err := a.batchWrites(cfg, func() error { return j.run(a, cfg) }); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", j.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// due reports whether a job runs now, and schedules its next run. A forced job runs whatever
+// its schedule, without moving its next scheduled run.
+func (s *scheduler) due(cfg *Config, name string, now time.Time, poll, forced bool) bool {
+	spec := cfg.jobSchedule(name)
+	if spec == "" {
+		delete(s.specs, name)
+		delete(s.next, name)
+		return poll || forced
+	}
+	schedule, err := cron.Parse(spec)
+	if err != nil {
+		return poll || forced
+	}
+	next, scheduled := s.next[name]
+	if scheduled && s.specs[name] == spec && now.Before(next) {
+		return forced
+	}
+	s.specs[name], s.next[name] = spec, schedule.Next(now)
+	return true
+}
+
+// nextRun is the earliest time a scheduled job is due, or the zero time if none is.
+func (s *scheduler) nextRun() time.Time {
+	var earliest time.Time
+	for _, next := range s.next {
+		if !next.IsZero() && (earliest.IsZero() || next.Before(earliest)) {
+			earliest = next
+		}
+	}
+	return earliest
+}
+
+// cameraRegistryClient fetches the camera registry.
+var cameraRegistryClient = &http.Client{Timeout: 30 * time.Second}
+
+// syncCameras updates traffic_cameras from the JSON list of cameras at CAMERA_REGISTRY_URL,
+// if it is set. Cameras outside bounds are skipped.
+func (a *app) syncCameras(cfg *Config) error {
+	url := os.Getenv("CAMERA_REGISTRY_URL")
+	if url == "" {
+		return nil
+	}
+	resp, err := cameraRegistryClient.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to fetch camera registry: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch camera registry: %s", resp.Status)
+	}
+	var cameras []camera.Registered
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 20<<20)).Decode(&cameras); err != nil {
+		return fmt.Errorf("failed to parse camera registry: %w", err)
+	}
+	valid := cameras[:0]
+	for _, cam := range cameras {
+		if cam.Name == "" || cam.ImageURL == "" || !cfg.Bounds.plausible(cam.Latitude, cam.Longitude) {
+			log.Printf("Warning: skipping camera registry entry %q without a name, image URL or plausible location.", cam.Name)
+			continue
+		}
+		valid = append(valid, cam)
+	}
+	added, err := camera.Sync(a.db, valid)
+	if err != nil {
+		return err
+	}
+	log.Printf("Synced %d camera(s) from the registry, %d new.", len(valid), added)
+	return nil
+}
+
+// postReports sends the digests and the scheduled reports, each of which checks its own time.
+func (a *app) postReports(cfg *Config) error {
+	a.postDailyStats(cfg)
+	a.sendDigests(cfg)
+	a.postLeaderboard(cfg)
+	a.postWeatherReport(cfg)
+	return nil
+}
+
+// deleteExpired deletes the hosted images whose URLs have expired, and archives the incidents
+// past the retention period.
+func (a *app) deleteExpired(cfg *Config) error {
+	if a.images != nil {
+		if _, err := postgres.DeleteExpiredImages(a.db); err != nil {
+			return err
+		}
+	}
+	return a.archiveIncidents(cfg)
+}
+
+// checkHealth runs the checks on the pipeline itself and exports its metrics.
+func (a *app) checkHealth(cfg *Config) error {
+	a.checkLatencySLO(cfg)
+	a.checkDeliveryFailures(cfg)
+	a.exportMetrics(cfg)
+	return nil
+}
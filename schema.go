@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// defaultIncidentsTable is the table the ingestor writes incidents to.
+const defaultIncidentsTable = "unified_incidents"
+
+// incidentColumns are the logical incident columns every query refers to as {name}.
+// created_at, when the ingestor stored the row, is optional and only used to measure latency.
+// tags is written by the alerter from the filter's keyword rules.
+var incidentColumns = []string{
+	"id", "source", "source_id", "event_type", "address", "latitude", "longitude",
+	"timestamp", "details", "status", "discord_message_id", "is_test", "created_at", "tags",
+}
+
+// incidentQueries are the queries that may be replaced wholesale in DatabaseConfig.Queries.
+var incidentQueries = map[string]bool{"new_incidents": true, "cleared_incidents": true}
+
+// DatabaseConfig maps the incidents table onto databases shaped differently from the
+// ingestor's. Queries use {incidents} for the table and {column} for each column.
+type DatabaseConfig struct {
+	Schema         string            `json:"schema,omitempty"`
+	IncidentsTable string            `json:"incidents_table,omitempty"`
+	Columns        map[string]string `json:"columns,omitempty"` // Logical name to actual column.
+
+	// Queries replaces the new_incidents or cleared_incidents SELECT. Overrides must return
+	// the same columns in the same order as the built-in query.
+	Queries map[string]string `json:"queries,omitempty"`
+}
+
+func (d DatabaseConfig) validate() error {
+	known := make(map[string]bool)
+	for _, c := range incidentColumns {
+		known[c] = true
+	}
+	for name := range d.Columns {
+		if !known[name] {
+			return fmt.Errorf("database.columns: unknown column %q", name)
+		}
+	}
+	for name := range d.Queries {
+		if !incidentQueries[name] {
+			return fmt.Errorf("database.queries: unknown query %q", name)
+		}
+	}
+	return nil
+}
+
+// SQL expands {incidents} and {column} placeholders into quoted identifiers.
+func (c *Config) SQL(query string) string {
+	d := c.Database
+	table := pq.QuoteIdentifier(defaultIncidentsTable)
+	if d.IncidentsTable != "" {
+		table = pq.QuoteIdentifier(d.IncidentsTable)
+	}
+	if d.Schema != "" {
+		table = pq.QuoteIdentifier(d.Schema) + "." + table
+	}
+	pairs := []string{"{incidents}", table}
+	for _, col := range incidentColumns {
+		name := col
+		if mapped := d.Columns[col]; mapped != "" {
+			name = mapped
+		}
+		pairs = append(pairs, "{"+col+"}", pq.QuoteIdentifier(name))
+	}
+	return strings.NewReplacer(pairs...).Replace(query)
+}
+
+// Query returns the configured override for a named incident query, or the built-in one.
+func (c *Config) Query(name, builtin string) string {
+	if override := c.Database.Queries[name]; override != "" {
+		return c.SQL(override)
+	}
+	return c.SQL(builtin)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SecretsProvider fetches configuration values (DATABASE_PASSWORD, DISCORD_HOOK, API keys)
+// from an external secrets backend.
+type SecretsProvider interface {
+	Fetch(ctx context.Context) (map[string]string, error)
+}
+
+// newSecretsProvider returns the backend selected by SECRETS_BACKEND, or nil if none is configured.
+func newSecretsProvider() (SecretsProvider, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	switch backend := os.Getenv("SECRETS_BACKEND"); backend {
+	case "":
+		return nil, nil
+	case "vault":
+		addr, token, path := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN"), os.Getenv("VAULT_SECRET_PATH")
+		if addr == "" || token == "" || path == "" {
+			return nil, fmt.Errorf("vault backend requires VAULT_ADDR, VAULT_TOKEN and VAULT_SECRET_PATH")
+		}
+		return &vaultSecrets{addr: strings.TrimRight(addr, "/"), token: token, path: strings.Trim(path, "/"), client: client}, nil
+	case "aws":
+		p := &awsSecrets{awsCredentials: awsCredentialsFromEnv(), secretID: os.Getenv("AWS_SECRET_ID"), client: client}
+		if !p.complete() || p.secretID == "" {
+			return nil, fmt.Errorf("aws backend requires AWS_REGION, AWS_SECRET_ID, AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+		}
+		return p, nil
+	default:
+		return nil, fmt.Errorf("unknown SECRETS_BACKEND: %s", backend)
+	}
+}
+
+// secretKeys are the environment variables a secrets backend may set. Anything else it
+// returns is ignored, so a shared secret can't override PATH, POLL_INTERVAL and the like.
+// SECRETS_EXTRA_KEYS adds to them, comma-separated, for the ${VAR} references of a config file.
+var secretKeys = []string{
+	"DATABASE_HOST", "DATABASE_PORT", "DATABASE_NAME", "DATABASE_USERNAME", "DATABASE_PASSWORD",
+	"DISCORD_HOOK", "DISCORD_BOT_TOKEN", "DISCORD_PUBLIC_KEY", "DISCORD_CLIENT_SECRET",
+	"GOOGLE_MAPS_API_KEY", "API_TOKEN", "INGEST_TOKEN", "IMAGE_SIGNING_KEY", "PORTAL_SESSION_KEY",
+	"SENTRY_DSN", "ERROR_REPORT_URL", "INFLUX_TOKEN", "MATRIX_ACCESS_TOKEN", "WHATSAPP_TOKEN",
+	"XMPP_PASSWORD", "AMQP_URL", "MQTT_URL", "INCIDENT_QUEUE_URL",
+}
+
+// allowedSecret reports whether a secrets backend may set an environment variable.
+func allowedSecret(key string) bool {
+	if contains(secretKeys, key) {
+		return true
+	}
+	for _, extra := range strings.Split(os.Getenv("SECRETS_EXTRA_KEYS"), ",") {
+		if strings.TrimSpace(extra) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// applySecrets fetches secrets and exports the allowed ones into the process environment, so
+// the rest of the program keeps reading configuration through os.Getenv.
+func applySecrets(ctx context.Context, provider SecretsProvider) error {
+	secrets, err := provider.Fetch(ctx)
+	if err != nil {
+		return err
+	}
+	var loaded int
+	var ignored []string
+	for key, value := range secrets {
+		if !allowedSecret(key) {
+			ignored = append(ignored, key)
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", key, err)
+		}
+		loaded++
+	}
+	if len(ignored) > 0 {
+		sort.Strings(ignored)
+		log.Printf("Warning: ignoring unknown keys from secrets backend: %s (add them to SECRETS_EXTRA_KEYS to use them).", strings.Join(ignored, ", "))
+	}
+	log.Printf("Loaded %d values from secrets backend.", loaded)
+	return nil
+}
+
+// refreshSecrets re-fetches secrets every interval until ctx is cancelled.
+func refreshSecrets(ctx context.Context, provider SecretsProvider, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := applySecrets(ctx, provider); err != nil {
+				log.Printf("Warning: failed to refresh secrets, keeping previous values: %v", err)
+			}
+		}
+	}
+}
+
+// vaultSecrets reads a KV secret (v1 or v2 engine) from HashiCorp Vault.
+type vaultSecrets struct {
+	addr   string
+	token  string
+	path   string
+	client *http.Client
+}
+
+func (v *vaultSecrets) Fetch(ctx context.Context) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/v1/%s", v.addr, v.path), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault secret: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("vault returned non-200 status: %s", resp.Status)
+	}
+
+	var body struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode vault response: %w", err)
+	}
+	// KV v2 nests the values one level deeper under data.data.
+	if nested, ok := body.Data["data"]; ok {
+		if _, hasMeta := body.Data["metadata"]; hasMeta {
+			var inner map[string]json.RawMessage
+			if err := json.Unmarshal(nested, &inner); err != nil {
+				return nil, fmt.Errorf("failed to decode vault kv2 data: %w", err)
+			}
+			body.Data = inner
+		}
+	}
+	return stringValues(body.Data), nil
+}
+
+// awsSecrets reads a JSON key/value secret from AWS Secrets Manager.
+type awsSecrets struct {
+	awsCredentials
+	secretID string
+	client   *http.Client
+}
+
+func (a *awsSecrets) Fetch(ctx context.Context) (map[string]string, error) {
+	host := fmt.Sprintf("secretsmanager.%s.amazonaws.com", a.region)
+	payload, _ := json.Marshal(map[string]string{"SecretId": a.secretID})
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://"+host+"/", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	a.sign(req, "secretsmanager", sha256Hex(payload), time.Now())
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call secrets manager: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("secrets manager returned non-200 status: %s. Body: %s", resp.Status, string(respBody))
+	}
+
+	var out struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode secrets manager response: %w", err)
+	}
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(out.SecretString), &values); err != nil {
+		return nil, fmt.Errorf("secret %s is not a JSON object: %w", a.secretID, err)
+	}
+	return stringValues(values), nil
+}
+
+// stringValues flattens a JSON object into strings, keeping non-string values as raw JSON.
+func stringValues(raw map[string]json.RawMessage) map[string]string {
+	values := make(map[string]string, len(raw))
+	for key, value := range raw {
+		var s string
+		if err := json.Unmarshal(value, &s); err == nil {
+			values[key] = s
+		} else {
+			values[key] = string(value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// startHTTPServer serves the REST API, the Discord interactions endpoint when
+// DISCORD_PUBLIC_KEY is set, /ingest when INGEST_TOKEN is set and the subscription portal
+// when PORTAL_URL is set, on HTTP_ADDR until ctx is cancelled. It does nothing when
+// HTTP_ADDR is unset.
+func startHTTPServer(ctx context.Context, a *app) error {
+	addr := os.Getenv("HTTP_ADDR")
+	if addr == "" {
+		return nil
+	}
+
+	stall, err := stallTimeout()
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	// The probes are unauthenticated, for the kubelet.
+	mux.Handle("/healthz", a.healthzHandler(stall))
+	mux.HandleFunc("/readyz", a.handleReadyz)
+	if key := os.Getenv("DISCORD_PUBLIC_KEY"); key != "" {
+		publicKey, err := hex.DecodeString(key)
+		if err != nil || len(publicKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("DISCORD_PUBLIC_KEY must be a hex-encoded Ed25519 public key")
+		}
+		mux.Handle("/interactions", a.interactionsHandler(ed25519.PublicKey(publicKey)))
+	}
+	mux.Handle("/api/deliveries", requireAPIToken(http.HandlerFunc(a.handleDeliveries)))
+	mux.Handle("/api/leaderboard", requireAPIToken(http.HandlerFunc(a.handleLeaderboard)))
+	mux.Handle("/api/pauses", requireAPIToken(http.HandlerFunc(a.handlePauses)))
+	if ingest := a.ingestHandler(); ingest != nil {
+		mux.Handle("/ingest", ingest)
+	}
+	mux.Handle("/api/cap", requireAPIToken(http.HandlerFunc(a.handleCAP)))
+	mux.Handle("/api/cap/", requireAPIToken(http.HandlerFunc(a.handleCAP)))
+	mux.Handle("/api/stream", bearerFromQuery(requireAPIToken(http.HandlerFunc(a.handleStream))))
+	mux.Handle("/api/ws", bearerFromQuery(requireAPIToken(http.HandlerFunc(a.handleFeed))))
+	mux.Handle("/metrics", requireAPIToken(http.HandlerFunc(a.handleMetrics)))
+	if a.images != nil {
+		mux.Handle("/images/", a.images.handler(a.db))
+	}
+	portal, err := newPortal(a)
+	if err != nil {
+		return err
+	}
+	if portal != nil {
+		mux.Handle("/portal/", portal.handler())
+	}
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	go func() {
+		log.Printf("Listening for HTTP requests on %s", addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Error serving HTTP: %v", err)
+		}
+	}()
+	return nil
+}
+
+// requireAPIToken rejects requests without "Authorization: Bearer $API_TOKEN" when API_TOKEN is set.
+func requireAPIToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := os.Getenv("API_TOKEN")
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleDeliveries serves GET /api/deliveries?incident_id=123&limit=50.
+func (a *app) handleDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	incidentID, _ := strconv.Atoi(r.URL.Query().Get("incident_id"))
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	deliveries, err := postgres.Deliveries(a.db, incidentID, limit)
+	if err != nil {
+		log.Printf("Error serving deliveries: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []postgres.Delivery{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
@@ -0,0 +1,55 @@
+package amqp
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"github.com/mtickle/unity-alerts/sink/discord"
+)
+
+func TestPublishing(t *testing.T) {
+	m := Messenger{Route: "downtown", Source: "NCDOT", EventType: "Crash"}
+	embeds := []discord.Embed{{Title: "Crash", Color: 0xE67E22}}
+	out, err := m.publishing("update", message{MessageID: "b", Replaces: "a", Text: "Crash", Embeds: embeds})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.ContentType != "application/json" || out.DeliveryMode != amqp.Persistent || out.MessageId != "b" || out.Type != "update" || out.Timestamp.IsZero() {
+		t.Errorf("publishing = %+v", out)
+	}
+	var got message
+	if err := json.Unmarshal(out.Body, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := message{MessageID: "b", Replaces: "a", Route: "downtown", Source: "NCDOT", EventType: "Crash", Text: "Crash", Embeds: embeds}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("body = %+v, want %+v", got, want)
+	}
+}
+
+func TestSendUnreachable(t *testing.T) {
+	// A port that was just free, so nothing is listening on it.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	for _, url := range []string{"amqp://guest:guest@" + addr + "/", "http://" + addr} {
+		m := Messenger{URL: url, Exchange: "alerts", Route: "downtown"}
+		if _, err := m.Send(discord.WebhookPayload{Content: "Crash"}); err == nil {
+			t.Errorf("Send to %s succeeded, want an error", url)
+		}
+		mu.Lock()
+		_, cached := conns[url]
+		mu.Unlock()
+		if cached {
+			t.Errorf("a failed connection to %s was kept", url)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package discord
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mtickle/unity-alerts/incident"
+)
+
+// MaxEmbedsPerMessage is Discord's per-message embed limit; one slot goes to the header.
+const MaxEmbedsPerMessage = 10
+
+// BuildBatchHeader renders the shared header embed that opens a grouped message.
+func BuildBatchHeader(corridor string, count int, opts RenderOptions) Embed {
+	return Embed{
+		Title:  Truncate("🚧 "+fmt.Sprintf(opts.T("title_batch"), count, SanitizeFeedText(corridor))+" 🚧", MaxEmbedTitle),
+		Color:  15105570, // Orange
+		Footer: EmbedFooter{Text: opts.T("footer_batch")},
+	}
+}
+
+// maxLegendEntry caps each cluster map legend line so the legend fits the message budget.
+const maxLegendEntry = 100
+
+// ClusterLegendReserve is the most characters AddClusterMap can add to a batch header.
+const ClusterLegendReserve = (MaxEmbedsPerMessage - 1) * (1 + maxLegendEntry)
+
+// AddClusterMap puts a static map with a numbered marker per incident on a batch header, with a
+// legend field for each marker. Markers are numbered by the incident's position in the message;
+// incidents without coordinates, or police incidents in privacy mode, are left off the map.
+func AddClusterMap(header *Embed, mapsAPIKey string, incidents []incident.Incident, opts RenderOptions) {
+	if !opts.Enabled(FeatureMaps) || mapsAPIKey == "" {
+		return
+	}
+	var markers []string
+	var legend []EmbedField
+	for idx, inc := range incidents {
+		if !inc.Latitude.Valid || !inc.Longitude.Valid || opts.Private(inc) || idx >= MaxEmbedsPerMessage-1 {
+			continue
+		}
+		label := fmt.Sprint(idx + 1)
+		markers = append(markers, fmt.Sprintf("markers=color:red%%7Clabel:%s%%7C%.6f,%.6f", label, inc.Latitude.Float64, inc.Longitude.Float64))
+		legend = append(legend, EmbedField{
+			Name:   label,
+			Value:  Truncate(SanitizeFeedText(inc.EventType)+" — "+SanitizeFeedText(opts.Address(inc)), maxLegendEntry),
+			Inline: true,
+		})
+	}
+	if len(markers) == 0 {
+		return
+	}
+	// Without center and zoom, Google fits the map to the markers.
+	header.Image = EmbedImage{URL: fmt.Sprintf("https://maps.googleapis.com/maps/api/staticmap?size=600x400&%s&key=%s",
+		strings.Join(markers, "&"), mapsAPIKey) + opts.mapStyle()}
+	header.Fields = append(header.Fields, legend...)
+}
+
+// ClearBatchedEmbed swaps one incident's embed in a batched message for its cleared version,
+// leaving the other incidents in the message untouched.
+func ClearBatchedEmbed(messenger Messenger, messageID string, index int, inc incident.Incident, opts RenderOptions) error {
+	embeds, err := messenger.FetchEmbeds(messageID)
+	if err != nil {
+		return fmt.Errorf("error fetching batched message: %w", err)
+	}
+	if index < 0 || index >= len(embeds) {
+		return fmt.Errorf("embed index %d out of range for message with %d embeds", index, len(embeds))
+	}
+
+	cleared, err := json.Marshal(BuildClearedEmbed(inc, opts))
+	if err != nil {
+		return fmt.Errorf("error creating cleared embed: %w", err)
+	}
+	embeds[index] = cleared
+	return messenger.Edit(messageID, map[string]interface{}{"embeds": embeds})
+}
@@ -0,0 +1,203 @@
+package discord
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// StatusError is returned when Discord answers with a non-2xx status.
+type StatusError struct {
+	StatusCode int
+	Code       int // Discord's JSON error code, when the body has one.
+	msg        string
+}
+
+func (e *StatusError) Error() string { return e.msg }
+
+// unknownMessage is Discord's error code for a message that doesn't exist.
+const unknownMessage = 10008
+
+// IsNotFound reports whether err is Discord saying the message no longer exists, typically
+// because someone deleted it. Other 404s, such as a deleted webhook or channel, are not: the
+// message may well still be there once the route is fixed.
+func IsNotFound(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound && statusErr.Code == unknownMessage
+}
+
+// statusError reads a failed response into a StatusError whose message starts with prefix.
+func statusError(resp *http.Response, prefix string) *StatusError {
+	body, _ := io.ReadAll(resp.Body)
+	var apiErr struct {
+		Code int `json:"code"`
+	}
+	json.Unmarshal(body, &apiErr)
+	return &StatusError{StatusCode: resp.StatusCode, Code: apiErr.Code, msg: fmt.Sprintf("%s: %s. Body: %s", prefix, resp.Status, string(body))}
+}
+
+// Attachment is a file uploaded with a message, held in memory so nothing is left on disk.
+type Attachment struct {
+	Name string
+	Data []byte
+}
+
+// PostWebhook sends a message that may include file attachments. Empty attachments are skipped.
+func PostWebhook(webhookURL string, payload WebhookPayload, attachments ...Attachment) (string, error) {
+	if payload.AllowedMentions == nil {
+		payload.AllowedMentions = &AllowedMentions{Parse: []string{}}
+	}
+	return PostMultipart(webhookURL+"?wait=true", "", payload, attachments...)
+}
+
+// PostMultipart POSTs a payload_json form with attachments to a Discord endpoint and
+// returns the created message's ID. authorization is sent as-is when non-empty.
+func PostMultipart(endpoint, authorization string, payload interface{}, attachments ...Attachment) (string, error) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	jsonPart, err := writer.CreateFormField("payload_json")
+	if err != nil {
+		return "", err
+	}
+	if err := json.NewEncoder(jsonPart).Encode(payload); err != nil {
+		return "", err
+	}
+
+	fileIndex := 0
+	for _, attachment := range attachments {
+		if len(attachment.Data) == 0 {
+			continue
+		}
+		if err := addMultipartFile(writer, fileIndex, attachment); err != nil {
+			return "", err
+		}
+		fileIndex++
+	}
+
+	writer.Close()
+
+	req, err := http.NewRequest("POST", endpoint, body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", statusError(resp, "discord returned non-2xx status")
+	}
+
+	var message struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
+		return "", err
+	}
+	return message.ID, nil
+}
+
+// addMultipartFile writes one attachment into the form as files[index].
+func addMultipartFile(writer *multipart.Writer, index int, attachment Attachment) error {
+	part, err := writer.CreateFormFile(fmt.Sprintf("files[%d]", index), attachment.Name)
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(attachment.Data)
+	return err
+}
+
+// PatchWebhookMessage replaces the content of a message previously sent through the webhook.
+func PatchWebhookMessage(webhookURL, messageID string, payload interface{}) error {
+	return SendJSON("PATCH", fmt.Sprintf("%s/messages/%s", webhookURL, messageID), "", payload)
+}
+
+// SendJSON makes a JSON request to a Discord endpoint; payload may be nil for bodiless requests.
+func SendJSON(method, endpoint, authorization string, payload interface{}) error {
+	var reqBody io.Reader
+	if payload != nil {
+		jsonPayload, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("error creating update JSON payload: %w", err)
+		}
+		reqBody = bytes.NewBuffer(jsonPayload)
+	}
+	req, err := http.NewRequest(method, endpoint, reqBody)
+	if err != nil {
+		return fmt.Errorf("error creating %s request: %w", method, err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := do(req)
+	if err != nil {
+		return fmt.Errorf("error sending %s request: %w", method, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return statusError(resp, "discord returned non-2xx status on update")
+	}
+	return nil
+}
+
+// maxRetryAfter caps how long a rate-limited request waits for its one retry. Longer waits
+// are left to the caller, which retries the incident on the next run.
+const maxRetryAfter = 5 * time.Second
+
+// do sends a request, retrying it once when Discord rate-limits it with a short enough wait.
+func do(req *http.Request) (*http.Response, error) {
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests || (req.Body != nil && req.GetBody == nil) {
+		return resp, err
+	}
+	wait := retryAfter(resp)
+	if wait <= 0 || wait > maxRetryAfter {
+		return resp, nil
+	}
+	resp.Body.Close()
+	time.Sleep(wait)
+	if req.GetBody != nil {
+		if req.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return client.Do(req)
+}
+
+// retryAfter reads how long a 429 response asks to wait, from the retry_after field of its
+// body or the Retry-After header. The body is left readable for the error message.
+func retryAfter(resp *http.Response) time.Duration {
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	var limit struct {
+		RetryAfter float64 `json:"retry_after"`
+	}
+	if json.Unmarshal(body, &limit) == nil && limit.RetryAfter > 0 {
+		return time.Duration(limit.RetryAfter * float64(time.Second))
+	}
+	seconds, err := strconv.ParseFloat(resp.Header.Get("Retry-After"), 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
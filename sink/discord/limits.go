@@ -0,0 +1,145 @@
+package discord
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Discord embed limits, counted in characters.
+const (
+	MaxEmbedTitle      = 256
+	MaxFieldName       = 256
+	MaxFieldValue      = 1024
+	MaxFieldsPerEmbed  = 25
+	MaxFooterText      = 2048
+	MaxCharsPerMessage = 6000
+	MaxContentLength   = 2000 // A message's content, outside its embeds.
+)
+
+// MaxAttachmentsPerMessage is the most files Discord accepts on one message.
+const MaxAttachmentsPerMessage = 10
+
+// Truncate shortens s to at most max characters, ending in an ellipsis when cut.
+func Truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max-1]) + "…"
+}
+
+// splitText breaks s into chunks of at most max characters, preferring line boundaries.
+func splitText(s string, max int) []string {
+	var chunks []string
+	var current strings.Builder
+	currentLen := 0
+	flush := func() {
+		if currentLen > 0 {
+			chunks = append(chunks, current.String())
+			current.Reset()
+			currentLen = 0
+		}
+	}
+	for _, line := range strings.Split(s, "\n") {
+		for utf8.RuneCountInString(line) > max {
+			flush()
+			runes := []rune(line)
+			chunks = append(chunks, string(runes[:max]))
+			line = string(runes[max:])
+		}
+		lineLen := utf8.RuneCountInString(line)
+		sep := 0
+		if currentLen > 0 {
+			sep = 1
+		}
+		if currentLen+sep+lineLen > max {
+			flush()
+			sep = 0
+		}
+		if sep == 1 {
+			current.WriteByte('\n')
+		}
+		current.WriteString(line)
+		currentLen += sep + lineLen
+	}
+	flush()
+	if len(chunks) == 0 {
+		return []string{""}
+	}
+	return chunks
+}
+
+// EmbedLength is the number of characters Discord counts towards the per-message total.
+func EmbedLength(e Embed) int {
+	n := utf8.RuneCountInString(e.Title) + utf8.RuneCountInString(e.Footer.Text)
+	for _, f := range e.Fields {
+		n += utf8.RuneCountInString(f.Name) + utf8.RuneCountInString(f.Value)
+	}
+	return n
+}
+
+// EnforceEmbedLimits truncates the title and footer and splits oversized field values
+// into continuation fields, so each embed is valid on its own.
+func EnforceEmbedLimits(e Embed, contSuffix string) Embed {
+	e.Title = Truncate(e.Title, MaxEmbedTitle)
+	e.Footer.Text = Truncate(e.Footer.Text, MaxFooterText)
+
+	var fields []EmbedField
+	for _, f := range e.Fields {
+		name := Truncate(f.Name, MaxFieldName)
+		for idx, part := range splitText(f.Value, MaxFieldValue) {
+			partName := name
+			if idx > 0 {
+				partName = Truncate(f.Name+" "+contSuffix, MaxFieldName)
+			}
+			fields = append(fields, EmbedField{Name: partName, Value: part, Inline: f.Inline})
+		}
+	}
+	e.Fields = fields
+	return e
+}
+
+// SplitPayload validates a payload against Discord's limits and, where one message can't hold
+// everything, spreads the content over several messages. The first message keeps the images
+// and attachment references; later ones carry continuation embeds.
+func SplitPayload(payload WebhookPayload, contSuffix string) []WebhookPayload {
+	var embeds []Embed
+	for _, e := range payload.Embeds {
+		e = EnforceEmbedLimits(e, contSuffix)
+		if len(e.Fields) <= MaxFieldsPerEmbed && EmbedLength(e) <= MaxCharsPerMessage {
+			embeds = append(embeds, e)
+			continue
+		}
+		// Too many fields or characters for one embed: move the overflow into continuation embeds.
+		first := e
+		first.Fields = nil
+		current := &first
+		for _, f := range e.Fields {
+			fieldLen := utf8.RuneCountInString(f.Name) + utf8.RuneCountInString(f.Value)
+			if len(current.Fields) >= MaxFieldsPerEmbed || EmbedLength(*current)+fieldLen > MaxCharsPerMessage {
+				embeds = append(embeds, *current)
+				current = &Embed{Title: Truncate(e.Title+" "+contSuffix, MaxEmbedTitle), Color: e.Color}
+			}
+			current.Fields = append(current.Fields, f)
+		}
+		embeds = append(embeds, *current)
+	}
+
+	var messages []WebhookPayload
+	var current *WebhookPayload
+	currentLen := 0
+	for _, e := range embeds {
+		n := EmbedLength(e)
+		if current == nil || len(current.Embeds) >= MaxEmbedsPerMessage || currentLen+n > MaxCharsPerMessage {
+			messages = append(messages, WebhookPayload{Username: payload.Username, AvatarURL: payload.AvatarURL})
+			current = &messages[len(messages)-1]
+			currentLen = 0
+		}
+		current.Embeds = append(current.Embeds, e)
+		currentLen += n
+	}
+	if len(messages) == 0 {
+		return []WebhookPayload{payload}
+	}
+	return messages
+}
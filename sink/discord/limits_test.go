@@ -0,0 +1,140 @@
+package discord
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		max  int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exactly10!", 10, "exactly10!"},
+		{"one too long", 11, "one too lo…"},
+		{"Café crème brûlée", 6, "Café …"},
+	}
+	for _, tt := range tests {
+		if got := Truncate(tt.s, tt.max); got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		max  int
+		want []string
+	}{
+		{"fits", "a\nb", 10, []string{"a\nb"}},
+		{"empty", "", 10, []string{""}},
+		{"at line boundaries", "aaaa\nbbbb\ncccc", 9, []string{"aaaa\nbbbb", "cccc"}},
+		{"long line is cut", "abcdefghij\nk", 4, []string{"abcd", "efgh", "ij\nk"}},
+		{"counts characters, not bytes", "ééé\nééé", 7, []string{"ééé\nééé"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitText(tt.s, tt.max)
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+				t.Errorf("splitText = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnforceEmbedLimits(t *testing.T) {
+	long := strings.Repeat("camera link\n", 200) // 2400 characters.
+	e := EnforceEmbedLimits(Embed{
+		Title:  strings.Repeat("T", 300),
+		Footer: EmbedFooter{Text: strings.Repeat("F", 3000)},
+		Fields: []EmbedField{{Name: "Cameras", Value: long, Inline: true}, {Name: "Status", Value: "Active"}},
+	}, "(cont.)")
+
+	if n := utf8.RuneCountInString(e.Title); n != MaxEmbedTitle {
+		t.Errorf("title has %d characters, want %d", n, MaxEmbedTitle)
+	}
+	if n := utf8.RuneCountInString(e.Footer.Text); n != MaxFooterText {
+		t.Errorf("footer has %d characters, want %d", n, MaxFooterText)
+	}
+	wantNames := []string{"Cameras", "Cameras (cont.)", "Cameras (cont.)", "Status"}
+	if len(e.Fields) != len(wantNames) {
+		t.Fatalf("got %d fields, want %d", len(e.Fields), len(wantNames))
+	}
+	var joined []string
+	for n, f := range e.Fields {
+		if f.Name != wantNames[n] {
+			t.Errorf("field %d is named %q, want %q", n, f.Name, wantNames[n])
+		}
+		if utf8.RuneCountInString(f.Value) > MaxFieldValue {
+			t.Errorf("field %d has %d characters", n, utf8.RuneCountInString(f.Value))
+		}
+		if n < 3 {
+			if !f.Inline {
+				t.Errorf("field %d lost Inline", n)
+			}
+			joined = append(joined, f.Value)
+		}
+	}
+	if strings.Join(joined, "\n") != long {
+		t.Errorf("the split field values don't add back up to the original")
+	}
+}
+
+func TestSplitPayload(t *testing.T) {
+	field := func(n int) EmbedField {
+		return EmbedField{Name: "Field", Value: strings.Repeat("x", n)}
+	}
+	embed := func(fields ...EmbedField) Embed {
+		return Embed{Title: "Incident", Fields: fields}
+	}
+	var manyFields []EmbedField
+	for i := 0; i < 30; i++ {
+		manyFields = append(manyFields, field(10))
+	}
+	var manyEmbeds []Embed
+	for i := 0; i < 12; i++ {
+		manyEmbeds = append(manyEmbeds, embed(field(10)))
+	}
+	tests := []struct {
+		name   string
+		embeds []Embed
+		want   []int // Embeds per message.
+	}{
+		{"fits", []Embed{embed(field(100))}, []int{1}},
+		{"too many fields", []Embed{embed(manyFields...)}, []int{2}},
+		{"too many characters", []Embed{embed(field(1000), field(1000), field(1000), field(1000), field(1000), field(1000), field(1000))}, []int{1, 1}},
+		{"too many embeds", manyEmbeds, []int{MaxEmbedsPerMessage, 2}},
+		{"too many characters across embeds", []Embed{embed(field(1000), field(1000), field(1000)), embed(field(1000), field(1000), field(1000))}, []int{1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages := SplitPayload(WebhookPayload{Username: "Unity Alerts", Embeds: tt.embeds}, "(cont.)")
+			if len(messages) != len(tt.want) {
+				t.Fatalf("got %d messages, want %d", len(messages), len(tt.want))
+			}
+			for n, m := range messages {
+				if len(m.Embeds) != tt.want[n] {
+					t.Errorf("message %d has %d embeds, want %d", n, len(m.Embeds), tt.want[n])
+				}
+				if m.Username != "Unity Alerts" {
+					t.Errorf("message %d lost the username", n)
+				}
+				total := 0
+				for _, e := range m.Embeds {
+					if len(e.Fields) > MaxFieldsPerEmbed {
+						t.Errorf("message %d has an embed with %d fields", n, len(e.Fields))
+					}
+					total += EmbedLength(e)
+				}
+				if total > MaxCharsPerMessage {
+					t.Errorf("message %d has %d characters", n, total)
+				}
+			}
+		})
+	}
+}
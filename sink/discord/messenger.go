@@ -0,0 +1,234 @@
+package discord
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// APIBase is the REST endpoint used in bot-token mode. Tests point it at a discordtest server.
+var APIBase = "https://discord.com/api/v10"
+
+// Messenger posts and edits alert messages in one Discord channel, either through a webhook
+// or as a bot.
+type Messenger interface {
+	Send(payload WebhookPayload, attachments ...Attachment) (string, error)
+	Edit(messageID string, payload interface{}) error
+	FetchEmbeds(messageID string) ([]json.RawMessage, error)
+}
+
+// WebhookMessenger delivers through an incoming webhook URL.
+type WebhookMessenger struct {
+	URL          string
+	TextFallback bool // Repeat the embeds as plain text in the message content.
+}
+
+func (w WebhookMessenger) Send(payload WebhookPayload, attachments ...Attachment) (string, error) {
+	if w.TextFallback {
+		payload = withTextFallback(payload).(WebhookPayload)
+	}
+	return PostWebhook(w.URL, payload, attachments...)
+}
+
+func (w WebhookMessenger) Edit(messageID string, payload interface{}) error {
+	if w.TextFallback {
+		payload = withTextFallback(payload)
+	}
+	return PatchWebhookMessage(w.URL, messageID, payload)
+}
+
+func (w WebhookMessenger) FetchEmbeds(messageID string) ([]json.RawMessage, error) {
+	return FetchEmbeds(fmt.Sprintf("%s/messages/%s", w.URL, messageID), "")
+}
+
+// BotMessenger delivers to a channel with a bot token, which additionally allows pinning.
+type BotMessenger struct {
+	Token     string
+	ChannelID string
+	AckButton bool   // Attach the Acknowledge button to new alerts.
+	ReplyTo   string // Post new messages as replies to this message.
+
+	TextFallback bool // Repeat the embeds as plain text in the message content.
+}
+
+func (b BotMessenger) authorization() string {
+	return "Bot " + b.Token
+}
+
+func (b BotMessenger) messagesURL() string {
+	return fmt.Sprintf("%s/channels/%s/messages", APIBase, b.ChannelID)
+}
+
+func (b BotMessenger) Send(payload WebhookPayload, attachments ...Attachment) (string, error) {
+	if b.TextFallback {
+		payload = withTextFallback(payload).(WebhookPayload)
+	}
+	// Bots post under their own name, so only the message body carries over from the webhook payload.
+	allowed := payload.AllowedMentions
+	if allowed == nil {
+		allowed = &AllowedMentions{Parse: []string{}}
+	}
+	message := map[string]interface{}{"embeds": payload.Embeds, "allowed_mentions": allowed}
+	if payload.Content != "" {
+		message["content"] = payload.Content
+	}
+	if b.AckButton {
+		message["components"] = AckComponents()
+	}
+	if b.ReplyTo != "" {
+		message["message_reference"] = map[string]interface{}{"message_id": b.ReplyTo, "fail_if_not_exists": false}
+	}
+	return PostMultipart(b.messagesURL(), b.authorization(), message, attachments...)
+}
+
+func (b BotMessenger) Edit(messageID string, payload interface{}) error {
+	if p, ok := payload.(WebhookPayload); ok {
+		// A full replacement is the cleared alert, which no longer takes acknowledgments.
+		payload = map[string]interface{}{"embeds": p.Embeds, "components": []interface{}{}}
+	}
+	if b.TextFallback {
+		payload = withTextFallback(payload)
+	}
+	return SendJSON("PATCH", b.messagesURL()+"/"+messageID, b.authorization(), payload)
+}
+
+func (b BotMessenger) FetchEmbeds(messageID string) ([]json.RawMessage, error) {
+	return FetchEmbeds(b.messagesURL()+"/"+messageID, b.authorization())
+}
+
+// Announce posts text as a text-to-speech message, which Discord reads aloud to members
+// viewing the channel. Posted to a voice channel, it lands in the channel's text chat and is
+// not spoken in the call.
+func (b BotMessenger) Announce(text string) (string, error) {
+	message := map[string]interface{}{"content": text, "tts": true, "allowed_mentions": AllowedMentions{Parse: []string{}}}
+	return PostMultipart(b.messagesURL(), b.authorization(), message)
+}
+
+// Pin pins a message in the bot's channel.
+func (b BotMessenger) Pin(messageID string) error {
+	return SendJSON("PUT", fmt.Sprintf("%s/channels/%s/pins/%s", APIBase, b.ChannelID, messageID), b.authorization(), nil)
+}
+
+// Unpin removes a message from the channel's pins.
+func (b BotMessenger) Unpin(messageID string) error {
+	return SendJSON("DELETE", fmt.Sprintf("%s/channels/%s/pins/%s", APIBase, b.ChannelID, messageID), b.authorization(), nil)
+}
+
+// Crosspost publishes a message in an announcement channel to every following server.
+func (b BotMessenger) Crosspost(messageID string) error {
+	return SendJSON("POST", b.messagesURL()+"/"+messageID+"/crosspost", b.authorization(), nil)
+}
+
+// User is a Discord user as it appears in interactions and reaction lists.
+type User struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	GlobalName string `json:"global_name"`
+}
+
+// DisplayName is the name shown in Discord, falling back to the account name.
+func (u User) DisplayName() string {
+	if u.GlobalName != "" {
+		return u.GlobalName
+	}
+	return u.Username
+}
+
+// Reactions lists the users who reacted to a message with emoji.
+func (b BotMessenger) Reactions(messageID, emoji string) ([]User, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/%s/reactions/%s?limit=100", b.messagesURL(), messageID, url.PathEscape(emoji)), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", b.authorization())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching reactions: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("discord returned non-200 status fetching reactions: %s", resp.Status)
+	}
+	var users []User
+	if err := json.NewDecoder(resp.Body).Decode(&users); err != nil {
+		return nil, fmt.Errorf("error decoding reactions: %w", err)
+	}
+	return users, nil
+}
+
+// AckButtonID is the custom_id of the Acknowledge button on bot-mode alerts.
+const AckButtonID = "ack"
+
+// AckEmoji is the reaction that counts as an acknowledgment.
+const AckEmoji = "✅"
+
+// AckComponents is the action row holding the Acknowledge button.
+func AckComponents() []map[string]interface{} {
+	return []map[string]interface{}{{
+		"type": 1,
+		"components": []map[string]interface{}{{
+			"type":      2,
+			"style":     3, // Green
+			"label":     "Acknowledge",
+			"emoji":     map[string]string{"name": AckEmoji},
+			"custom_id": AckButtonID,
+		}},
+	}}
+}
+
+// OpenDMChannel returns the ID of the bot's direct-message channel with a user.
+func OpenDMChannel(token, userID string) (string, error) {
+	body, err := json.Marshal(map[string]string{"recipient_id": userID})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("POST", APIBase+"/users/@me/channels", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bot "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error opening DM channel: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("discord returned non-200 status opening DM channel: %s", resp.Status)
+	}
+	var channel struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&channel); err != nil {
+		return "", fmt.Errorf("error decoding DM channel: %w", err)
+	}
+	return channel.ID, nil
+}
+
+// FetchEmbeds reads the raw embeds of an existing message.
+func FetchEmbeds(endpoint, authorization string) ([]json.RawMessage, error) {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching message: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, statusError(resp, "discord returned non-200 status fetching message")
+	}
+	var message struct {
+		Embeds []json.RawMessage `json:"embeds"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&message); err != nil {
+		return nil, fmt.Errorf("error decoding message: %w", err)
+	}
+	return message.Embeds, nil
+}
@@ -0,0 +1,201 @@
+package discord_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mtickle/unity-alerts/internal/discordtest"
+	"github.com/mtickle/unity-alerts/sink/discord"
+)
+
+func alertPayload(title string) discord.WebhookPayload {
+	return discord.WebhookPayload{
+		Username: "Unified Alert Bot",
+		Embeds: []discord.Embed{{
+			Title:  title,
+			Color:  15158332,
+			Fields: []discord.EmbedField{{Name: "Road", Value: "I-40 W", Inline: true}},
+		}},
+	}
+}
+
+func TestWebhookSendAndEdit(t *testing.T) {
+	srv := discordtest.NewServer()
+	defer srv.Close()
+	m := discord.WebhookMessenger{URL: srv.WebhookURL()}
+
+	id, err := m.Send(alertPayload("Vehicle Crash"), discord.Attachment{Name: "camera.jpg", Data: []byte("jpeg")})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if id != "1" {
+		t.Errorf("Send returned message %q, want 1", id)
+	}
+	if err := discord.UpdateContinuation(m, id, discord.DefaultRenderOptions()); err != nil {
+		t.Fatalf("UpdateContinuation: %v", err)
+	}
+
+	if err := srv.MatchFixture(filepath.Join("testdata", "webhook_send_edit.json")); err != nil {
+		t.Error(err)
+	}
+	raw, ok := srv.Message(id)
+	if !ok {
+		t.Fatalf("message %s was not kept", id)
+	}
+	var message struct {
+		Content string            `json:"content"`
+		Embeds  []json.RawMessage `json:"embeds"`
+	}
+	if err := json.Unmarshal(raw, &message); err != nil {
+		t.Fatal(err)
+	}
+	if message.Content == "" || len(message.Embeds) != 0 {
+		t.Errorf("edited message = %s, want the continuation notice without embeds", raw)
+	}
+}
+
+func TestEditDeletedMessage(t *testing.T) {
+	srv := discordtest.NewServer()
+	defer srv.Close()
+	m := discord.WebhookMessenger{URL: srv.WebhookURL()}
+
+	err := m.Edit("42", alertPayload("Vehicle Crash"))
+	if !discord.IsNotFound(err) {
+		t.Errorf("Edit of a missing message returned %v, want a not-found error", err)
+	}
+	if _, err := m.Send(alertPayload("Vehicle Crash")); discord.IsNotFound(err) {
+		t.Errorf("Send returned a not-found error: %v", err)
+	}
+}
+
+func TestEditNotFound(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"unknown message", `{"message": "Unknown Message", "code": 10008}`, true},
+		{"unknown webhook", `{"message": "Unknown Webhook", "code": 10015}`, false},
+		{"unknown channel", `{"message": "Unknown Channel", "code": 10003}`, false},
+		{"no JSON body", `404 page not found`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := discordtest.NewServer()
+			defer srv.Close()
+			m := discord.WebhookMessenger{URL: srv.WebhookURL()}
+			id, err := m.Send(alertPayload("Vehicle Crash"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			srv.FailNext(http.StatusNotFound, tt.body)
+			err = m.Edit(id, alertPayload("Cleared"))
+			var statusErr *discord.StatusError
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+				t.Fatalf("Edit error = %v, want a 404 status error", err)
+			}
+			if got := discord.IsNotFound(err); got != tt.want {
+				t.Errorf("IsNotFound(%v) = %v, want %v", err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantErr   bool
+		wantCalls int
+	}{
+		{"short wait is retried", `{"message": "You are being rate limited.", "retry_after": 0.01, "global": false}`, false, 2},
+		{"long wait is returned", `{"message": "You are being rate limited.", "retry_after": 60, "global": false}`, true, 1},
+		{"missing wait is returned", `{"message": "You are being rate limited."}`, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := discordtest.NewServer()
+			defer srv.Close()
+			m := discord.WebhookMessenger{URL: srv.WebhookURL()}
+			srv.FailNext(http.StatusTooManyRequests, tt.body)
+
+			_, err := m.Send(alertPayload("Vehicle Crash"), discord.Attachment{Name: "camera.jpg", Data: []byte("jpeg")})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send error = %v, want error %v", err, tt.wantErr)
+			}
+			var statusErr *discord.StatusError
+			if tt.wantErr && (!errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusTooManyRequests) {
+				t.Errorf("Send error = %v, want a 429 status error", err)
+			}
+			requests := srv.Requests()
+			if len(requests) != tt.wantCalls {
+				t.Fatalf("server got %d requests, want %d", len(requests), tt.wantCalls)
+			}
+			// The retried upload must carry the whole body again.
+			last := requests[len(requests)-1]
+			if len(last.Files) != 1 || len(last.Payload) == 0 {
+				t.Errorf("last request = %+v, want the payload and one file", last)
+			}
+		})
+	}
+}
+
+func TestRateLimitRetryJSON(t *testing.T) {
+	srv := discordtest.NewServer()
+	defer srv.Close()
+	m := discord.WebhookMessenger{URL: srv.WebhookURL()}
+	id, err := m.Send(alertPayload("Vehicle Crash"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv.FailNext(http.StatusTooManyRequests, `{"retry_after": 0.01}`)
+	if err := m.Edit(id, alertPayload("Cleared")); err != nil {
+		t.Fatalf("Edit: %v", err)
+	}
+	raw, _ := srv.Message(id)
+	if !strings.Contains(string(raw), "Cleared") {
+		t.Errorf("message after retried edit = %s, want the new title", raw)
+	}
+}
+
+func TestBotMessenger(t *testing.T) {
+	srv := discordtest.NewServer()
+	defer srv.Close()
+	defer func(base string) { discord.APIBase = base }(discord.APIBase)
+	discord.APIBase = srv.APIBase()
+	m := discord.BotMessenger{Token: "token", ChannelID: "100"}
+
+	id, err := m.Send(alertPayload("Vehicle Crash"))
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := m.Pin(id); err != nil {
+		t.Fatalf("Pin: %v", err)
+	}
+	if err := m.Crosspost(id); err != nil {
+		t.Fatalf("Crosspost: %v", err)
+	}
+	if err := m.Unpin(id); err != nil {
+		t.Fatalf("Unpin: %v", err)
+	}
+
+	var got []string
+	for _, r := range srv.Requests() {
+		got = append(got, r.Method+" "+r.Path)
+	}
+	want := []string{
+		"POST /channels/100/messages",
+		"PUT /channels/100/pins/1",
+		"POST /channels/100/messages/1/crosspost",
+		"DELETE /channels/100/pins/1",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,28 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mtickle/unity-alerts/incident"
+)
+
+// navigationLinks links to turn-by-turn directions to the incident in Google Maps, Waze and
+// Apple Maps, or returns "" when the incident has no point to navigate to. Discord only makes
+// http(s) links tappable, so these are the apps' universal links, which open the app on phones
+// that have it as google.navigation: and waze:// would, and the web version elsewhere.
+func navigationLinks(inc incident.Incident, opts RenderOptions) string {
+	// CAP alerts cover an area, and private incidents must not give away the building.
+	if !inc.Latitude.Valid || !inc.Longitude.Valid || inc.Source == incident.SourceCAP || opts.Private(inc) {
+		return ""
+	}
+	lat, lon := inc.Latitude.Float64, inc.Longitude.Float64
+	if lat == 0 && lon == 0 {
+		return ""
+	}
+	return strings.Join([]string{
+		fmt.Sprintf("[Google Maps](https://www.google.com/maps/dir/?api=1&destination=%.6f,%.6f&travelmode=driving)", lat, lon),
+		fmt.Sprintf("[Waze](https://waze.com/ul?ll=%.6f,%.6f&navigate=yes)", lat, lon),
+		fmt.Sprintf("[Apple Maps](https://maps.apple.com/?daddr=%.6f,%.6f&dirflg=d)", lat, lon),
+	}, " · ")
+}
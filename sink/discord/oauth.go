@@ -0,0 +1,63 @@
+package discord
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OAuthAuthorizeURL is where users are sent to sign in with Discord.
+const OAuthAuthorizeURL = "https://discord.com/oauth2/authorize"
+
+var oauthClient = &http.Client{Timeout: 10 * time.Second}
+
+// ExchangeOAuthCode trades the code from an OAuth2 redirect for the user's access token.
+func ExchangeOAuthCode(clientID, clientSecret, code, redirectURI string) (string, error) {
+	form := url.Values{
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+	}
+	resp, err := oauthClient.Post(APIBase+"/oauth2/token", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("error exchanging OAuth code: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", &StatusError{StatusCode: resp.StatusCode, msg: fmt.Sprintf("discord returned non-200 status exchanging OAuth code: %s", resp.Status)}
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("error decoding OAuth token: %w", err)
+	}
+	return token.AccessToken, nil
+}
+
+// CurrentUser returns the user an OAuth2 access token with the identify scope belongs to.
+func CurrentUser(accessToken string) (User, error) {
+	var user User
+	req, err := http.NewRequest("GET", APIBase+"/users/@me", nil)
+	if err != nil {
+		return user, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	resp, err := oauthClient.Do(req)
+	if err != nil {
+		return user, fmt.Errorf("error fetching user: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return user, &StatusError{StatusCode: resp.StatusCode, msg: fmt.Sprintf("discord returned non-200 status fetching user: %s", resp.Status)}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return user, fmt.Errorf("error decoding user: %w", err)
+	}
+	return user, nil
+}
@@ -0,0 +1,502 @@
+// Package discord renders incidents as Discord embeds and delivers them through webhooks or a
+// bot token, within Discord's message limits and with feed text neutralised.
+package discord
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/camera"
+	"github.com/mtickle/unity-alerts/enrich"
+	"github.com/mtickle/unity-alerts/incident"
+)
+
+// Structs for creating a rich Discord Embed message with attachments.
+type WebhookPayload struct {
+	Username        string           `json:"username"`
+	AvatarURL       string           `json:"avatar_url,omitempty"`
+	Content         string           `json:"content,omitempty"`
+	Embeds          []Embed          `json:"embeds"`
+	AllowedMentions *AllowedMentions `json:"allowed_mentions,omitempty"`
+}
+
+type Embed struct {
+	Title     string         `json:"title,omitempty"`
+	Color     int            `json:"color"`
+	Fields    []EmbedField   `json:"fields,omitempty"`
+	Footer    EmbedFooter    `json:"footer,omitempty"`
+	Timestamp string         `json:"timestamp,omitempty"`
+	Thumbnail EmbedThumbnail `json:"thumbnail,omitempty"`
+	Image     EmbedImage     `json:"image,omitempty"`
+}
+
+type EmbedThumbnail struct {
+	URL string `json:"url"`
+}
+
+type EmbedImage struct {
+	URL string `json:"url"`
+}
+
+type EmbedField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type EmbedFooter struct {
+	Text string `json:"text"`
+}
+
+// BuildPayload picks the payload builder for the incident's source.
+func BuildPayload(mapsAPIKey string, inc incident.Incident, enrichment enrich.Result, opts RenderOptions) (WebhookPayload, error) {
+	var payload WebhookPayload
+	switch inc.Source {
+	case incident.SourceNCDOT:
+		payload = buildNcdotPayload(mapsAPIKey, inc, enrichment.OtherCameras(), enrichment.ImageURL(), opts)
+	case incident.SourceRWECC:
+		payload = buildRweccPayload(mapsAPIKey, inc, enrichment.OtherCameras(), enrichment.ImageURL(), opts)
+	case incident.SourceArcGISPolice:
+		payload = buildArcGisPayload(mapsAPIKey, inc, enrichment.ImageURL(), enrichment.StreetViewURL, opts)
+	case incident.SourceCAP:
+		payload = buildCapPayload(mapsAPIKey, inc, opts)
+	default:
+		return WebhookPayload{}, fmt.Errorf("unknown incident source: %s", inc.Source)
+	}
+	if inc.IsTest && len(payload.Embeds) > 0 {
+		payload.Embeds[0].Title = opts.T("title_test_prefix") + " " + payload.Embeds[0].Title
+	}
+	if inc.Backfill && len(payload.Embeds) > 0 {
+		payload.Embeds[0].Title = opts.T("title_backfill_prefix") + " " + payload.Embeds[0].Title
+	}
+	if len(inc.Tags) > 0 && len(payload.Embeds) > 0 && !opts.minimal() {
+		payload.Embeds[0].Fields = append(payload.Embeds[0].Fields, EmbedField{Name: opts.T("field_tags"), Value: SanitizeFeedText(strings.Join(inc.Tags, ", "))})
+	}
+	if opts.Enabled(FeatureNavigate) && len(payload.Embeds) > 0 {
+		if links := navigationLinks(inc, opts); links != "" {
+			payload.Embeds[0].Fields = append(payload.Embeds[0].Fields, EmbedField{Name: opts.T("field_navigate"), Value: links})
+		}
+	}
+	if opts.detailed() && !opts.Private(inc) && len(payload.Embeds) > 0 {
+		addRawDetails(&payload.Embeds[0], inc, opts)
+	}
+	return payload, nil
+}
+
+// addRawDetails lists every field of the feed's raw incident, for detailed routes, and puts
+// the incident's IDs in the footer. Incidents generalized for privacy never get them.
+func addRawDetails(embed *Embed, inc incident.Incident, opts RenderOptions) {
+	raw := make(map[string]json.RawMessage)
+	var detailsMap map[string]json.RawMessage
+	if json.Unmarshal(inc.Details, &detailsMap) == nil {
+		if rawJSON, ok := detailsMap["raw_incident"]; ok {
+			json.Unmarshal(rawJSON, &raw)
+		} else {
+			raw = detailsMap
+		}
+	}
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var lines []string
+	for _, key := range keys {
+		value := strings.TrimSpace(string(raw[key]))
+		var s string
+		if json.Unmarshal(raw[key], &s) == nil {
+			value = s
+		}
+		if value == "" || value == "null" {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", SanitizeFeedText(key), SanitizeFeedText(value)))
+	}
+	if len(lines) > 0 {
+		embed.Fields = append(embed.Fields, EmbedField{Name: opts.T("field_raw_details"), Value: strings.Join(lines, "\n")})
+	}
+	ids := fmt.Sprintf(opts.T("footer_ids"), inc.ID, SanitizeFeedText(inc.SourceID))
+	if embed.Footer.Text != "" {
+		ids = embed.Footer.Text + " · " + ids
+	}
+	embed.Footer.Text = ids
+}
+
+// SendAlert builds the alert for an already-enriched incident and posts it to one channel.
+// It also returns the payload that was sent, for the delivery log.
+func SendAlert(messenger Messenger, mapsAPIKey string, inc incident.Incident, enrichment enrich.Result, opts RenderOptions) (string, WebhookPayload, error) {
+	payload, err := BuildPayload(mapsAPIKey, inc, enrichment, opts)
+	if err != nil {
+		return "", payload, err
+	}
+	messageID, _, err := SendPayload(messenger, payload, enrichment, opts)
+	return messageID, payload, err
+}
+
+// Attachments lists the enrichment files the route's features allow: the camera frame with
+// cameras on, and the closure geometry with maps on.
+func Attachments(enrichment enrich.Result, opts RenderOptions) []Attachment {
+	var attachments []Attachment
+	if opts.Enabled(FeatureCameras) && len(enrichment.Attachment) > 0 && enrichment.AttachmentURL == "" {
+		attachments = append(attachments, Attachment{Name: enrichment.AttachmentName, Data: enrichment.Attachment})
+	}
+	if opts.Enabled(FeatureMaps) && len(enrichment.Geometry) > 0 {
+		attachments = append(attachments, Attachment{Name: enrichment.GeometryName, Data: enrichment.Geometry})
+	}
+	return attachments
+}
+
+// SendPayload posts a built alert with the incident's camera frame and closure geometry, splitting it across
+// messages when it exceeds Discord's limits. It returns the first message's ID and those of
+// the continuations that went out.
+func SendPayload(messenger Messenger, payload WebhookPayload, enrichment enrich.Result, opts RenderOptions) (string, []string, error) {
+	messages := SplitPayload(payload, opts.T("field_continued"))
+	messageID, err := messenger.Send(messages[0], Attachments(enrichment, opts)...)
+	if err != nil {
+		return "", nil, err
+	}
+	var continuations []string
+	for _, continuation := range messages[1:] {
+		id, err := messenger.Send(continuation)
+		if err != nil {
+			log.Printf("Warning: failed to send continuation message: %v", err)
+			continue
+		}
+		continuations = append(continuations, id)
+	}
+	return messageID, continuations, nil
+}
+
+// buildNcdotPayload creates the multi-embed structure for an NC DOT alert.
+func buildNcdotPayload(mapsAPIKey string, inc incident.Incident, otherCameras []camera.Camera, cameraImageURL string, opts RenderOptions) WebhookPayload {
+	var rawIncident struct {
+		Reason   string `json:"reason"`
+		Road     string `json:"road"`
+		Location string `json:"location"`
+		Severity int    `json:"severity"`
+	}
+	var weatherDetails *struct {
+		Temperature   int    `json:"temperature"`
+		WindSpeed     string `json:"windSpeed"`
+		ShortForecast string `json:"shortForecast"`
+		Icon          string `json:"icon"`
+	}
+
+	var detailsMap map[string]json.RawMessage
+	if err := json.Unmarshal(inc.Details, &detailsMap); err == nil {
+		if rawJSON, ok := detailsMap["raw_incident"]; ok {
+			json.Unmarshal(rawJSON, &rawIncident)
+		}
+		if weatherJSON, ok := detailsMap["weather"]; ok && string(weatherJSON) != "null" {
+			json.Unmarshal(weatherJSON, &weatherDetails)
+		}
+	} else {
+		log.Printf("INFO: Could not parse as new format, falling back to old format for NCDOT incident.")
+		json.Unmarshal(inc.Details, &rawIncident)
+	}
+
+	var color int
+	switch rawIncident.Severity {
+	case 1:
+		color = 3066993
+	case 2:
+		color = 16776960
+	case 3:
+		color = 15158332
+	default:
+		color = 2105893
+	}
+
+	fields := []EmbedField{
+		{Name: opts.T("field_reason"), Value: SanitizeFeedText(rawIncident.Reason), Inline: false},
+		{Name: opts.T("field_road"), Value: SanitizeFeedText(rawIncident.Road), Inline: false},
+		{Name: opts.T("field_location"), Value: SanitizeFeedText(rawIncident.Location), Inline: false},
+	}
+	if !opts.minimal() {
+		fields = append(fields, EmbedField{Name: opts.T("field_severity"), Value: strconv.Itoa(rawIncident.Severity), Inline: false})
+	}
+	fields = append(fields, EmbedField{Name: opts.T("field_reported"), Value: opts.FormatLocalTime(inc.Timestamp), Inline: false})
+
+	if opts.Enabled(FeatureWeather) && weatherDetails != nil {
+		weatherValue := fmt.Sprintf("%s\n%s: %d°F\n%s: %s", SanitizeFeedText(weatherDetails.ShortForecast), opts.T("weather_temp"), weatherDetails.Temperature, opts.T("weather_wind"), SanitizeFeedText(weatherDetails.WindSpeed))
+		fields = append(fields, EmbedField{Name: opts.T("field_weather"), Value: weatherValue, Inline: false})
+	}
+
+	if opts.Enabled(FeatureCameras) && len(otherCameras) > 0 {
+		var cameraLinks []string
+		for _, cam := range otherCameras {
+			cameraLinks = append(cameraLinks, fmt.Sprintf("[%s](%s)", SanitizeFeedText(cam.Name), cam.ImageURL))
+		}
+		fields = append(fields, EmbedField{Name: opts.T("field_other_cameras"), Value: strings.Join(cameraLinks, "\n"), Inline: false})
+	}
+
+	embed := Embed{
+		Title: "🚨 " + opts.T("title_ncdot") + " 🚨", Color: color, Fields: fields,
+		Footer: EmbedFooter{Text: opts.T("footer_ncdot")}, Timestamp: inc.Timestamp.Format(time.RFC3339),
+	}
+
+	if opts.Enabled(FeatureMaps) && mapsAPIKey != "" && inc.Latitude.Valid && inc.Longitude.Valid {
+		mapURL := fmt.Sprintf("https://maps.googleapis.com/maps/api/staticmap?center=%.6f,%.6f&zoom=14&size=300x300&markers=color:red%%7C%.6f,%.6f&key=%s",
+			inc.Latitude.Float64, inc.Longitude.Float64, inc.Latitude.Float64, inc.Longitude.Float64, mapsAPIKey) + opts.mapStyle()
+		embed.Thumbnail = EmbedThumbnail{URL: mapURL}
+	}
+
+	if opts.Enabled(FeatureCameras) && cameraImageURL != "" {
+		embed.Image = EmbedImage{URL: cameraImageURL}
+	}
+
+	return WebhookPayload{Username: opts.T("bot_username"), Embeds: []Embed{embed}}
+}
+
+// buildRweccPayload creates the multi-embed structure for an RWECC alert.
+func buildRweccPayload(mapsAPIKey string, inc incident.Incident, otherCameras []camera.Camera, cameraImageURL string, opts RenderOptions) WebhookPayload {
+	var rawIncident struct {
+		Problem      string `json:"problem"`
+		Jurisdiction string `json:"jurisdiction"`
+	}
+	var weatherDetails *struct {
+		Temperature   int    `json:"temperature"`
+		WindSpeed     string `json:"windSpeed"`
+		ShortForecast string `json:"shortForecast"`
+		Icon          string `json:"icon"`
+	}
+
+	var detailsMap map[string]json.RawMessage
+	if err := json.Unmarshal(inc.Details, &detailsMap); err == nil {
+		if rawJSON, ok := detailsMap["raw_incident"]; ok {
+			json.Unmarshal(rawJSON, &rawIncident)
+		}
+		if weatherJSON, ok := detailsMap["weather"]; ok && string(weatherJSON) != "null" {
+			json.Unmarshal(weatherJSON, &weatherDetails)
+		}
+	} else {
+		log.Printf("INFO: Could not parse as new format, falling back to old format for RWECC incident.")
+		json.Unmarshal(inc.Details, &rawIncident)
+	}
+
+	fields := []EmbedField{{Name: opts.T("field_address"), Value: SanitizeFeedText(inc.Address), Inline: false}}
+	if !opts.minimal() {
+		fields = append(fields, EmbedField{Name: opts.T("field_jurisdiction"), Value: SanitizeFeedText(rawIncident.Jurisdiction), Inline: false})
+	}
+	fields = append(fields, EmbedField{Name: opts.T("field_reported"), Value: opts.FormatLocalTime(inc.Timestamp), Inline: false})
+
+	if opts.Enabled(FeatureWeather) && weatherDetails != nil {
+		weatherValue := fmt.Sprintf("%s\n%s: %d°F\n%s: %s", SanitizeFeedText(weatherDetails.ShortForecast), opts.T("weather_temp"), weatherDetails.Temperature, opts.T("weather_wind"), SanitizeFeedText(weatherDetails.WindSpeed))
+		fields = append(fields, EmbedField{Name: opts.T("field_weather"), Value: weatherValue, Inline: false})
+	}
+
+	if opts.Enabled(FeatureCameras) && len(otherCameras) > 0 {
+		var cameraLinks []string
+		for _, cam := range otherCameras {
+			cameraLinks = append(cameraLinks, fmt.Sprintf("[%s](%s)", SanitizeFeedText(cam.Name), cam.ImageURL))
+		}
+		fields = append(fields, EmbedField{Name: opts.T("field_other_cameras"), Value: strings.Join(cameraLinks, "\n"), Inline: false})
+	}
+
+	embed := Embed{
+		Title: "🔵 " + SanitizeFeedText(rawIncident.Problem) + " 🔵", Color: 3447003, Fields: fields,
+		Footer: EmbedFooter{Text: opts.T("footer_rwecc")}, Timestamp: inc.Timestamp.Format(time.RFC3339),
+	}
+
+	if opts.Enabled(FeatureMaps) && mapsAPIKey != "" && inc.Latitude.Valid && inc.Longitude.Valid {
+		mapURL := fmt.Sprintf("https://maps.googleapis.com/maps/api/staticmap?center=%.6f,%.6f&zoom=14&size=300x300&markers=color:red%%7C%.6f,%.6f&key=%s",
+			inc.Latitude.Float64, inc.Longitude.Float64, inc.Latitude.Float64, inc.Longitude.Float64, mapsAPIKey) + opts.mapStyle()
+		embed.Thumbnail = EmbedThumbnail{URL: mapURL}
+	}
+
+	if opts.Enabled(FeatureCameras) && cameraImageURL != "" {
+		embed.Image = EmbedImage{URL: cameraImageURL}
+	}
+
+	return WebhookPayload{Username: opts.T("bot_username"), Embeds: []Embed{embed}}
+}
+
+// buildArcGisPayload creates the multi-embed structure for an ArcGIS Police incident.
+func buildArcGisPayload(mapsAPIKey string, inc incident.Incident, cameraImageURL, streetViewURL string, opts RenderOptions) WebhookPayload {
+	var rawIncident struct {
+		CaseNumber       string `json:"case_number"`
+		CrimeDescription string `json:"crime_description"`
+		Agency           string `json:"agency"`
+	}
+
+	log.Printf("DEBUG: Raw ArcGIS Details JSON received: %s", string(inc.Details))
+
+	var detailsMap map[string]json.RawMessage
+	if err := json.Unmarshal(inc.Details, &detailsMap); err == nil {
+		if rawJSON, ok := detailsMap["raw_incident"]; ok {
+			if err := json.Unmarshal(rawJSON, &rawIncident); err != nil {
+				log.Printf("ERROR: Failed to unmarshal nested ArcGIS raw_incident: %v", err)
+			}
+		}
+	} else {
+		log.Printf("INFO: Could not parse as new format, falling back to old format for ArcGIS incident.")
+		if fallbackErr := json.Unmarshal(inc.Details, &rawIncident); fallbackErr != nil {
+			log.Printf("ERROR: Failed to unmarshal ArcGIS details in both new and old formats: %v", fallbackErr)
+		}
+	}
+
+	fields := []EmbedField{{Name: opts.T("field_address"), Value: SanitizeFeedText(opts.Address(inc)), Inline: false}}
+	if !opts.minimal() {
+		fields = append(fields, EmbedField{Name: opts.T("field_agency"), Value: SanitizeFeedText(rawIncident.Agency), Inline: false})
+		if !opts.Privacy && !strings.HasPrefix(rawIncident.CaseNumber, "NO_CASE-") {
+			fields = append(fields, EmbedField{Name: opts.T("field_case_number"), Value: SanitizeFeedText(rawIncident.CaseNumber), Inline: false})
+		}
+	}
+
+	fields = append(fields, EmbedField{Name: opts.T("field_reported"), Value: opts.FormatLocalTime(inc.Timestamp), Inline: false})
+
+	embed := Embed{
+		Title:     "🟣 " + SanitizeFeedText(rawIncident.CrimeDescription) + " 🟣",
+		Color:     9807270, // Purple
+		Fields:    fields,
+		Footer:    EmbedFooter{Text: opts.T("footer_arcgis")},
+		Timestamp: inc.Timestamp.Format(time.RFC3339),
+	}
+
+	if opts.Enabled(FeatureMaps) && mapsAPIKey != "" && inc.Latitude.Valid && inc.Longitude.Valid {
+		mapURL := fmt.Sprintf("https://maps.googleapis.com/maps/api/staticmap?center=%.6f,%.6f&zoom=15&size=600x400&markers=color:purple%%7C%.6f,%.6f&key=%s",
+			inc.Latitude.Float64, inc.Longitude.Float64, inc.Latitude.Float64, inc.Longitude.Float64, mapsAPIKey) + opts.mapStyle()
+		if opts.Privacy {
+			// Show the neighborhood rather than the building.
+			mapURL = fmt.Sprintf("https://maps.googleapis.com/maps/api/staticmap?center=%.3f,%.3f&zoom=13&size=600x400&key=%s",
+				inc.Latitude.Float64, inc.Longitude.Float64, mapsAPIKey) + opts.mapStyle()
+		}
+		embed.Image = EmbedImage{URL: mapURL}
+	}
+
+	// A camera frame or, failing that, Street View imagery takes the large slot when enabled for
+	// police incidents, and the map moves to the thumbnail.
+	var photoURL string
+	if opts.Enabled(FeatureCameras) && cameraImageURL != "" {
+		photoURL = cameraImageURL
+	} else if opts.Enabled(FeatureStreetView) && !opts.Privacy && streetViewURL != "" {
+		photoURL = streetViewURL
+	}
+	if photoURL != "" {
+		if embed.Image.URL != "" {
+			embed.Thumbnail = EmbedThumbnail{URL: embed.Image.URL}
+		}
+		embed.Image = EmbedImage{URL: photoURL}
+	}
+
+	return WebhookPayload{Username: opts.T("bot_username"), Embeds: []Embed{embed}}
+}
+
+// maxMapPolygonPoints caps the polygon drawn on a CAP alert's map, keeping the URL short.
+const maxMapPolygonPoints = 60
+
+// buildCapPayload creates the embed for a Common Alerting Protocol alert, such as a weather
+// warning or an emergency-management notice, with the area it covers drawn on the map.
+func buildCapPayload(mapsAPIKey string, inc incident.Incident, opts RenderOptions) WebhookPayload {
+	var details struct {
+		Feed        string `json:"feed"`
+		RawIncident struct {
+			Headline     string `json:"headline"`
+			Description  string `json:"description"`
+			Instruction  string `json:"instruction"`
+			Severity     int    `json:"severity"`
+			SeverityName string `json:"severity_name"`
+			Urgency      string `json:"urgency"`
+			Expires      string `json:"expires"`
+		} `json:"raw_incident"`
+	}
+	json.Unmarshal(inc.Details, &details)
+	alert := details.RawIncident
+
+	var color int
+	switch alert.Severity {
+	case 4:
+		color = 10038562 // Dark red
+	case 3:
+		color = 15158332 // Red
+	case 2:
+		color = 15105570 // Orange
+	default:
+		color = 16776960 // Yellow
+	}
+
+	title := alert.Headline
+	if title == "" {
+		title = inc.EventType
+	}
+	fields := []EmbedField{{Name: opts.T("field_area"), Value: Truncate(SanitizeFeedText(inc.Address), MaxFieldValue)}}
+	if alert.Description != "" && !opts.minimal() {
+		fields = append(fields, EmbedField{Name: opts.T("field_description"), Value: Truncate(SanitizeFeedText(alert.Description), MaxFieldValue)})
+	}
+	if alert.SeverityName != "" && !opts.minimal() {
+		fields = append(fields, EmbedField{Name: opts.T("field_severity"), Value: SanitizeFeedText(strings.TrimSpace(alert.SeverityName + " · " + alert.Urgency)), Inline: true})
+	}
+	if expires, err := time.Parse(time.RFC3339, alert.Expires); err == nil {
+		fields = append(fields, EmbedField{Name: opts.T("field_expires"), Value: opts.FormatLocalTime(expires), Inline: true})
+	}
+	if alert.Instruction != "" {
+		fields = append(fields, EmbedField{Name: opts.T("field_instruction"), Value: Truncate(SanitizeFeedText(alert.Instruction), MaxFieldValue)})
+	}
+	fields = append(fields, EmbedField{Name: opts.T("field_reported"), Value: opts.FormatLocalTime(inc.Timestamp)})
+
+	embed := Embed{
+		Title:     Truncate("⚠️ "+SanitizeFeedText(title), MaxEmbedTitle),
+		Color:     color,
+		Fields:    fields,
+		Footer:    EmbedFooter{Text: fmt.Sprintf(opts.T("footer_cap"), SanitizeFeedText(details.Feed))},
+		Timestamp: inc.Timestamp.Format(time.RFC3339),
+	}
+
+	if opts.Enabled(FeatureMaps) && mapsAPIKey != "" {
+		if rings := incident.Polygons(inc); len(rings) > 0 {
+			ring := rings[0]
+			step := (len(ring) + maxMapPolygonPoints - 1) / maxMapPolygonPoints
+			var path strings.Builder
+			path.WriteString("fillcolor:0xFF000033%7Ccolor:0xFF0000CC%7Cweight:2")
+			for n := 0; n < len(ring); n += step {
+				fmt.Fprintf(&path, "%%7C%.4f,%.4f", ring[n][1], ring[n][0])
+			}
+			fmt.Fprintf(&path, "%%7C%.4f,%.4f", ring[0][1], ring[0][0])
+			embed.Image = EmbedImage{URL: fmt.Sprintf("https://maps.googleapis.com/maps/api/staticmap?size=600x400&path=%s&key=%s", path.String(), mapsAPIKey) + opts.mapStyle()}
+		} else if inc.Latitude.Valid && inc.Longitude.Valid {
+			embed.Image = EmbedImage{URL: fmt.Sprintf("https://maps.googleapis.com/maps/api/staticmap?center=%.6f,%.6f&zoom=10&size=600x400&key=%s",
+				inc.Latitude.Float64, inc.Longitude.Float64, mapsAPIKey) + opts.mapStyle()}
+		}
+	}
+
+	return WebhookPayload{Username: opts.T("bot_username"), Embeds: []Embed{embed}}
+}
+
+// UpdateAlert edits an existing Discord message to show it's cleared.
+func UpdateAlert(messenger Messenger, messageID string, inc incident.Incident, opts RenderOptions) error {
+	payload := WebhookPayload{Embeds: []Embed{BuildClearedEmbed(inc, opts)}}
+	return messenger.Edit(messageID, payload)
+}
+
+// UpdateContinuation edits a continuation of a split alert once the incident clears, leaving
+// a pointer to the cleared notice in the first message.
+func UpdateContinuation(messenger Messenger, messageID string, opts RenderOptions) error {
+	payload := WebhookPayload{Content: opts.T("cleared_continuation"), Embeds: []Embed{}}
+	return messenger.Edit(messageID, payload)
+}
+
+// UpdateReplaced edits an alert that was sent again, pointing readers to the newer message.
+func UpdateReplaced(messenger Messenger, messageID string, opts RenderOptions) error {
+	payload := WebhookPayload{Content: opts.T("alert_replaced"), Embeds: []Embed{}}
+	return messenger.Edit(messageID, payload)
+}
+
+// BuildClearedEmbed renders the replacement embed for a cleared incident.
+func BuildClearedEmbed(inc incident.Incident, opts RenderOptions) Embed {
+	return Embed{
+		Title: "✅ " + opts.T("title_cleared") + " ✅",
+		Color: 3066993, // Green
+		Fields: []EmbedField{
+			{Name: opts.T("field_source"), Value: SanitizeFeedText(inc.Source), Inline: false},
+			{Name: opts.T("field_address"), Value: SanitizeFeedText(opts.Address(inc)), Inline: false},
+			{Name: opts.T("field_cleared"), Value: opts.FormatLocalTime(time.Now()), Inline: false},
+		},
+		Footer:    EmbedFooter{Text: opts.T("footer_cleared")},
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,142 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/i18n"
+	"github.com/mtickle/unity-alerts/incident"
+)
+
+// Names of the optional enrichment stages that can be toggled per route.
+const (
+	FeatureCameras = "cameras"
+	FeatureMaps    = "maps"
+	FeatureWeather = "weather"
+
+	// FeatureStreetView shows a Street View image on police alerts. Off unless enabled.
+	FeatureStreetView = "streetview"
+
+	// FeatureNavigate adds links to directions to the incident in Google Maps, Waze and Apple
+	// Maps.
+	FeatureNavigate = "navigate"
+
+	// FeatureRunningLong follows up on alerts for incidents active far longer than usual.
+	// Off unless enabled.
+	FeatureRunningLong = "running_long"
+)
+
+// Verbosity presets for a route's alerts.
+const (
+	// VerbosityMinimal shows only what happened, where and when: no secondary fields, tags,
+	// weather or camera images.
+	VerbosityMinimal = "minimal"
+	// VerbosityStandard is the default.
+	VerbosityStandard = "standard"
+	// VerbosityDetailed adds every raw field from the feed and the incident's IDs.
+	VerbosityDetailed = "detailed"
+)
+
+// Map styles accepted in the config's map_style settings.
+const (
+	MapStyleLight = "light"
+	MapStyleDark  = "dark"
+	MapStyleAuto  = "auto" // Dark overnight in the route's timezone.
+)
+
+// darkMapStyle restyles Google static maps to a dark basemap that sits well in Discord's dark theme.
+var darkMapStyle = []string{
+	"element:geometry|color:0x242f3e",
+	"element:labels.text.stroke|color:0x242f3e",
+	"element:labels.text.fill|color:0x746855",
+	"feature:road|element:geometry|color:0x38414e",
+	"feature:road|element:geometry.stroke|color:0x212a37",
+	"feature:road.highway|element:geometry|color:0x746855",
+	"feature:water|element:geometry|color:0x17263c",
+}
+
+// DefaultTimezone is used when neither the route nor the config names one.
+const DefaultTimezone = "America/New_York"
+
+// RenderOptions carries the per-route presentation settings used by the payload builders.
+type RenderOptions struct {
+	Location *time.Location
+	Locale   i18n.Locale
+	Features map[string]bool
+	DarkMaps bool // Render static maps with the dark style.
+
+	// Privacy generalizes police incidents for public channels: addresses are shown as their
+	// hundred block, maps are zoomed out without a marker, and case numbers and Street View
+	// are left out.
+	Privacy bool
+
+	// Verbosity is VerbosityMinimal, VerbosityDetailed, or "" or VerbosityStandard.
+	Verbosity string
+}
+
+// DefaultRenderOptions renders in the default timezone and language.
+func DefaultRenderOptions() RenderOptions {
+	loc, err := time.LoadLocation(DefaultTimezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	locale, _ := i18n.For(i18n.DefaultLanguage)
+	return RenderOptions{Location: loc, Locale: locale}
+}
+
+// T translates an embed string into the route's language.
+func (o RenderOptions) T(key string) string {
+	return o.Locale.T(key)
+}
+
+// Enabled reports whether an enrichment stage is switched on for this route.
+// Options built without flags enable everything.
+func (o RenderOptions) Enabled(feature string) bool {
+	enabled, ok := o.Features[feature]
+	return !ok || enabled
+}
+
+// minimal reports whether alerts leave out secondary fields.
+func (o RenderOptions) minimal() bool {
+	return o.Verbosity == VerbosityMinimal
+}
+
+// detailed reports whether alerts include every raw field.
+func (o RenderOptions) detailed() bool {
+	return o.Verbosity == VerbosityDetailed
+}
+
+// FormatLocalTime renders a timestamp for humans in the route's timezone.
+func (o RenderOptions) FormatLocalTime(t time.Time) string {
+	return t.In(o.Location).Format(o.T("time_format"))
+}
+
+// Address is the incident's address as the route may show it: the hundred block, e.g.
+// "1200 block of Oak St", for police incidents in privacy mode.
+func (o RenderOptions) Address(inc incident.Incident) string {
+	if !o.Private(inc) {
+		return inc.Address
+	}
+	if block, street, ok := incident.Block(inc); ok {
+		return fmt.Sprintf(o.T("address_block"), block, street)
+	}
+	return inc.Address
+}
+
+// Private reports whether the incident's location is generalized on this route.
+func (o RenderOptions) Private(inc incident.Incident) bool {
+	return o.Privacy && inc.Source == incident.SourceArcGISPolice
+}
+
+// mapStyle returns the query parameters that style a static map URL, if any.
+func (o RenderOptions) mapStyle() string {
+	if !o.DarkMaps {
+		return ""
+	}
+	var params strings.Builder
+	for _, style := range darkMapStyle {
+		params.WriteString("&style=" + strings.ReplaceAll(style, "|", "%7C"))
+	}
+	return params.String()
+}
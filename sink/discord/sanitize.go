@@ -0,0 +1,131 @@
+package discord
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ZeroWidthSpace breaks up mention and link syntax without changing what readers see.
+const ZeroWidthSpace = "\u200b"
+
+var (
+	markdownEscaper = strings.NewReplacer(
+		`\`, `\\`, `*`, `\*`, `_`, `\_`, `~`, `\~`, "`", "\\`", `|`, `\|`,
+		`>`, `\>`, `#`, `\#`, `[`, `\[`, `]`, `\]`,
+	)
+	// Matches user, role and channel mentions like <@123>, <@!123>, <@&123> and <#123>.
+	mentionPattern   = regexp.MustCompile(`<([@#][!&]?)(\d+)>`)
+	urlSchemePattern = regexp.MustCompile(`(?i)\b(https?)://`)
+)
+
+// SanitizeFeedText neutralises text that comes from external feeds before it goes into an embed:
+// markdown is escaped, @everyone/@here and ID mentions can't resolve, and bare URLs don't autolink.
+func SanitizeFeedText(s string) string {
+	s = mentionPattern.ReplaceAllString(s, "<$1"+ZeroWidthSpace+"$2>")
+	s = markdownEscaper.Replace(s)
+	s = strings.ReplaceAll(s, "@everyone", "@"+ZeroWidthSpace+"everyone")
+	s = strings.ReplaceAll(s, "@here", "@"+ZeroWidthSpace+"here")
+	s = urlSchemePattern.ReplaceAllString(s, "$1:"+ZeroWidthSpace+"//")
+	return s
+}
+
+// AllowedMentions controls which mentions in a message may ping. An empty Parse list
+// disables pings entirely, as a second line of defence behind SanitizeFeedText.
+type AllowedMentions struct {
+	Parse []string `json:"parse"`
+	Roles []string `json:"roles,omitempty"`
+	Users []string `json:"users,omitempty"`
+}
+
+var (
+	markdownLink    = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^)\s]+)\)`)
+	markdownEscaped = regexp.MustCompile(`\\([\\*_~` + "`" + `|>#\[\]])`)
+)
+
+// PlainText renders a message for services without markdown, such as SMS-like chats and
+// email: one line per title and field, links spelled out, and formatting and escapes removed.
+func PlainText(content string, embeds []Embed) string {
+	var lines []string
+	if content != "" {
+		lines = append(lines, content)
+	}
+	lines = append(lines, embedLines(embeds, false)...)
+	text := strings.Join(lines, "\n")
+	text = markdownLink.ReplaceAllString(text, "$1 ($2)")
+	text = strings.ReplaceAll(text, "**", "")
+	text = markdownEscaped.ReplaceAllString(text, "$1")
+	return strings.ReplaceAll(text, ZeroWidthSpace, "")
+}
+
+// AccessibleText renders embeds as message content for screen readers and clients that hide
+// embeds. It is PlainText's layout without the decorative emoji around titles, and keeps the
+// markdown escapes since Discord renders content as markdown.
+func AccessibleText(embeds []Embed) string {
+	text := strings.Join(embedLines(embeds, true), "\n")
+	text = markdownLink.ReplaceAllString(text, "$1 ($2)")
+	return Truncate(strings.ReplaceAll(text, ZeroWidthSpace, ""), MaxContentLength)
+}
+
+// embedLines lists each embed's title, fields as "Name: value", and footer.
+func embedLines(embeds []Embed, plainTitles bool) []string {
+	var lines []string
+	for _, e := range embeds {
+		title := e.Title
+		if plainTitles {
+			title = strings.TrimFunc(title, isDecoration)
+		}
+		if title != "" {
+			lines = append(lines, title)
+		}
+		for _, f := range e.Fields {
+			if f.Name == ZeroWidthSpace {
+				lines = append(lines, f.Value)
+			} else {
+				lines = append(lines, f.Name+": "+f.Value)
+			}
+		}
+		if e.Footer.Text != "" {
+			lines = append(lines, e.Footer.Text)
+		}
+	}
+	return lines
+}
+
+// isDecoration matches the emoji and spacing that frame alert titles, such as "🚨 … 🚨".
+func isDecoration(r rune) bool {
+	return unicode.IsSpace(r) || unicode.Is(unicode.So, r) || r == '\uFE0F'
+}
+
+// withTextFallback adds AccessibleText to a message's content, after any content it already
+// has. Edits that only carry embeds, such as a cleared batch, get their content replaced.
+func withTextFallback(payload interface{}) interface{} {
+	switch p := payload.(type) {
+	case WebhookPayload:
+		text := AccessibleText(p.Embeds)
+		if p.Content != "" {
+			text = p.Content + "\n" + text
+		}
+		p.Content = Truncate(text, MaxContentLength)
+		return p
+	case map[string]interface{}:
+		var embeds []Embed
+		switch e := p["embeds"].(type) {
+		case []Embed:
+			embeds = e
+		case []json.RawMessage:
+			for _, raw := range e {
+				var embed Embed
+				if json.Unmarshal(raw, &embed) == nil {
+					embeds = append(embeds, embed)
+				}
+			}
+		default:
+			return p
+		}
+		p["content"] = AccessibleText(embeds)
+		return p
+	}
+	return payload
+}
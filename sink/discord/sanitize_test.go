@@ -0,0 +1,26 @@
+package discord
+
+import "testing"
+
+func TestSanitizeFeedText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain text", "Vehicle Crash on I-40 W", "Vehicle Crash on I-40 W"},
+		{"markdown", "**LANE** _closed_ ~~now~~ `x` || > # [a](b)", `\*\*LANE\*\* \_closed\_ \~\~now\~\~ \` + "`x\\`" + ` \|\| \> \# \[a\](b)`},
+		{"backslash", `C:\temp`, `C:\\temp`},
+		{"everyone and here", "@everyone @here", "@\u200beveryone @\u200bhere"},
+		{"user mention", "<@123> <@!456>", "<@\u200b123\\> <@!\u200b456\\>"},
+		{"role and channel mentions", "<@&789> <#42>", "<@&\u200b789\\> <\\#\u200b42\\>"},
+		{"links", "see https://example.com and HTTP://x.y", "see https:\u200b//example.com and HTTP:\u200b//x.y"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SanitizeFeedText(tt.in); got != tt.want {
+				t.Errorf("SanitizeFeedText(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
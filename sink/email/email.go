@@ -0,0 +1,120 @@
+// Package email delivers alerts as plain-text email over SMTP. Mail cannot be edited, so a
+// clear is sent as a reply to the alert's message, which threads it in most mail clients.
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/sink/discord"
+)
+
+// Messenger sends mail through the SMTP server at Addr (host:port), upgrading to TLS when the
+// server offers STARTTLS, and authenticating when Username is set.
+type Messenger struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// Send mails the alert with its attachments and returns the Message-ID.
+func (m Messenger) Send(payload discord.WebhookPayload, attachments ...discord.Attachment) (string, error) {
+	return m.mail(payload.Content, payload.Embeds, "", attachments)
+}
+
+// Edit mails the new version as a reply to messageID. payload is a discord.WebhookPayload
+// or any value with the same "content" and "embeds" fields.
+func (m Messenger) Edit(messageID string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	var p struct {
+		Content string          `json:"content"`
+		Embeds  []discord.Embed `json:"embeds"`
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		return fmt.Errorf("failed to read edit payload: %w", err)
+	}
+	_, err = m.mail(p.Content, p.Embeds, messageID, nil)
+	return err
+}
+
+// FetchEmbeds is unsupported: sent mail is not kept.
+func (m Messenger) FetchEmbeds(messageID string) ([]json.RawMessage, error) {
+	return nil, fmt.Errorf("email messages cannot be fetched")
+}
+
+func (m Messenger) mail(content string, embeds []discord.Embed, inReplyTo string, attachments []discord.Attachment) (string, error) {
+	text := discord.PlainText(content, embeds)
+	subject, _, _ := strings.Cut(text, "\n")
+	host, _, _ := net.SplitHostPort(m.Addr)
+	var id [12]byte
+	rand.Read(id[:])
+	messageID := fmt.Sprintf("<%s@%s>", hex.EncodeToString(id[:]), host)
+
+	var msg bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&msg, "%s: %s\r\n", k, v) }
+	header("From", m.From)
+	header("To", strings.Join(m.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", messageID)
+	if inReplyTo != "" {
+		header("In-Reply-To", inReplyTo)
+		header("References", inReplyTo)
+	}
+	header("MIME-Version", "1.0")
+
+	w := multipart.NewWriter(&msg)
+	header("Content-Type", "multipart/mixed; boundary="+w.Boundary())
+	msg.WriteString("\r\n")
+	part, _ := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	writeBase64(part, []byte(text))
+	for _, a := range attachments {
+		part, _ := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {http.DetectContentType(a.Data)},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Name})},
+		})
+		writeBase64(part, a.Data)
+	}
+	w.Close()
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	if err := smtp.SendMail(m.Addr, auth, m.From, m.To, msg.Bytes()); err != nil {
+		return "", fmt.Errorf("error sending email: %w", err)
+	}
+	return messageID, nil
+}
+
+// writeBase64 writes data base64-encoded in 76-character lines.
+func writeBase64(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		w.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	w.Write([]byte(encoded + "\r\n"))
+}
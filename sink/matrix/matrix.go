@@ -0,0 +1,224 @@
+// Package matrix delivers alerts to a Matrix room through the client-server API. It renders
+// the Discord payloads the rest of the program builds as HTML, so routes can target either.
+package matrix
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/mtickle/unity-alerts/sink/discord"
+)
+
+// embedsKey carries the original embeds in each event, so edits can start from them.
+const embedsKey = "com.github.mtickle.unity_alerts.embeds"
+
+// Messenger posts to one Matrix room as the user owning AccessToken. It implements
+// discord.Messenger: Send posts the alert and uploads its attachments, and Edit replaces the
+// alert's text with an m.replace edit.
+type Messenger struct {
+	Homeserver  string // Base URL, e.g. https://matrix.org.
+	AccessToken string
+	RoomID      string
+}
+
+// Send posts the payload as one HTML message, then each attachment as an image or file
+// event. It returns the message's event ID.
+func (m Messenger) Send(payload discord.WebhookPayload, attachments ...discord.Attachment) (string, error) {
+	content := messageContent(payload.Content, payload.Embeds)
+	eventID, err := m.sendEvent(content)
+	if err != nil {
+		return "", err
+	}
+	for _, attachment := range attachments {
+		uri, err := m.upload(attachment)
+		if err != nil {
+			return eventID, err
+		}
+		msgtype := "m.file"
+		if ext := strings.ToLower(path.Ext(attachment.Name)); ext == ".jpg" || ext == ".jpeg" || ext == ".png" {
+			msgtype = "m.image"
+		}
+		_, err = m.sendEvent(map[string]interface{}{
+			"msgtype": msgtype,
+			"body":    attachment.Name,
+			"url":     uri,
+			"info":    map[string]interface{}{"size": len(attachment.Data), "mimetype": http.DetectContentType(attachment.Data)},
+		})
+		if err != nil {
+			return eventID, err
+		}
+	}
+	return eventID, nil
+}
+
+// Edit replaces a message's text. payload is a discord.WebhookPayload or any value with the
+// same "content" and "embeds" fields.
+func (m Messenger) Edit(messageID string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	var p struct {
+		Content string          `json:"content"`
+		Embeds  []discord.Embed `json:"embeds"`
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		return fmt.Errorf("failed to read edit payload: %w", err)
+	}
+	newContent := messageContent(p.Content, p.Embeds)
+	edit := map[string]interface{}{
+		"msgtype":        "m.text",
+		"body":           "* " + newContent["body"].(string),
+		"format":         "org.matrix.custom.html",
+		"formatted_body": "* " + newContent["formatted_body"].(string),
+		"m.new_content":  newContent,
+		"m.relates_to":   map[string]interface{}{"rel_type": "m.replace", "event_id": messageID},
+	}
+	_, err = m.sendEvent(edit)
+	return err
+}
+
+// FetchEmbeds returns the embeds of a message's latest version.
+func (m Messenger) FetchEmbeds(messageID string) ([]json.RawMessage, error) {
+	var event struct {
+		Content  map[string]json.RawMessage `json:"content"`
+		Unsigned struct {
+			Relations struct {
+				Replace struct {
+					Content struct {
+						NewContent map[string]json.RawMessage `json:"m.new_content"`
+					} `json:"content"`
+				} `json:"m.replace"`
+			} `json:"m.relations"`
+		} `json:"unsigned"`
+	}
+	endpoint := fmt.Sprintf("/_matrix/client/v3/rooms/%s/event/%s", url.PathEscape(m.RoomID), url.PathEscape(messageID))
+	if err := m.do("GET", endpoint, "", nil, &event); err != nil {
+		return nil, err
+	}
+	raw := event.Unsigned.Relations.Replace.Content.NewContent[embedsKey]
+	if raw == nil {
+		raw = event.Content[embedsKey]
+	}
+	var embeds []json.RawMessage
+	if err := json.Unmarshal(raw, &embeds); err != nil {
+		return nil, fmt.Errorf("matrix event %s has no alert embeds", messageID)
+	}
+	return embeds, nil
+}
+
+// sendEvent posts an m.room.message event and returns its ID.
+func (m Messenger) sendEvent(content map[string]interface{}) (string, error) {
+	var txn [8]byte
+	rand.Read(txn[:])
+	endpoint := fmt.Sprintf("/_matrix/client/v3/rooms/%s/send/m.room.message/%s", url.PathEscape(m.RoomID), hex.EncodeToString(txn[:]))
+	body, _ := json.Marshal(content)
+	var out struct {
+		EventID string `json:"event_id"`
+	}
+	if err := m.do("PUT", endpoint, "application/json", body, &out); err != nil {
+		return "", err
+	}
+	return out.EventID, nil
+}
+
+// upload stores a file in the homeserver's media repository and returns its mxc:// URI.
+func (m Messenger) upload(attachment discord.Attachment) (string, error) {
+	var out struct {
+		ContentURI string `json:"content_uri"`
+	}
+	endpoint := "/_matrix/media/v3/upload?filename=" + url.QueryEscape(attachment.Name)
+	if err := m.do("POST", endpoint, http.DetectContentType(attachment.Data), attachment.Data, &out); err != nil {
+		return "", err
+	}
+	return out.ContentURI, nil
+}
+
+func (m Messenger) do(method, endpoint, contentType string, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, strings.TrimRight(m.Homeserver, "/")+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.AccessToken)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request to matrix: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("matrix returned non-200 status: %s. Body: %s", resp.Status, string(respBody))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding matrix response: %w", err)
+	}
+	return nil
+}
+
+// messageContent renders embeds as an m.text message with plain and HTML bodies.
+func messageContent(text string, embeds []discord.Embed) map[string]interface{} {
+	var plain, rich []string
+	if text != "" {
+		plain = append(plain, text)
+		rich = append(rich, markdownToHTML(text))
+	}
+	for _, e := range embeds {
+		if e.Title != "" {
+			plain = append(plain, e.Title)
+			rich = append(rich, "<h4>"+html.EscapeString(e.Title)+"</h4>")
+		}
+		for _, f := range e.Fields {
+			if f.Name == discord.ZeroWidthSpace {
+				plain = append(plain, f.Value)
+				rich = append(rich, markdownToHTML(f.Value))
+				continue
+			}
+			plain = append(plain, f.Name+": "+f.Value)
+			rich = append(rich, "<b>"+html.EscapeString(f.Name)+":</b> "+markdownToHTML(f.Value))
+		}
+		for _, img := range []string{e.Image.URL, e.Thumbnail.URL} {
+			// Attachments are uploaded separately; other images are linked.
+			if strings.HasPrefix(img, "http") {
+				plain = append(plain, img)
+				rich = append(rich, fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(img), "🗺️"))
+			}
+		}
+		if e.Footer.Text != "" {
+			plain = append(plain, e.Footer.Text)
+			rich = append(rich, "<i>"+html.EscapeString(e.Footer.Text)+"</i>")
+		}
+	}
+	return map[string]interface{}{
+		"msgtype":        "m.text",
+		"body":           strings.Join(plain, "\n"),
+		"format":         "org.matrix.custom.html",
+		"formatted_body": strings.Join(rich, "<br>"),
+		embedsKey:        embeds,
+	}
+}
+
+var (
+	markdownLink = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^)\s]+)\)`)
+	markdownBold = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+)
+
+// markdownToHTML converts the Discord markdown used in alerts (links, bold and line breaks).
+func markdownToHTML(s string) string {
+	s = html.EscapeString(s)
+	s = markdownLink.ReplaceAllString(s, `<a href="$2">$1</a>`)
+	s = markdownBold.ReplaceAllString(s, `<b>$1</b>`)
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
@@ -0,0 +1,123 @@
+// Package oncall opens and resolves alerts in incident-management services, so an incident
+// can page whoever is on call instead of waiting for someone to read a channel.
+package oncall
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Alert is what gets paged. DedupKey identifies it again when it is resolved.
+type Alert struct {
+	DedupKey string
+	Summary  string
+	Source   string
+	Severity string // PagerDuty severity: critical, error, warning or info.
+	Details  map[string]string
+}
+
+// Pager is an incident-management service.
+type Pager interface {
+	Trigger(a Alert) error
+	Resolve(dedupKey string) error
+}
+
+// PagerDuty sends Events API v2 events to the service owning RoutingKey.
+type PagerDuty struct {
+	RoutingKey string
+}
+
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+func (p PagerDuty) Trigger(a Alert) error {
+	return p.send(map[string]interface{}{
+		"routing_key":  p.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    a.DedupKey,
+		"payload": map[string]interface{}{
+			"summary":        a.Summary,
+			"source":         a.Source,
+			"severity":       a.Severity,
+			"custom_details": a.Details,
+		},
+	})
+}
+
+func (p PagerDuty) Resolve(dedupKey string) error {
+	return p.send(map[string]interface{}{
+		"routing_key":  p.RoutingKey,
+		"event_action": "resolve",
+		"dedup_key":    dedupKey,
+	})
+}
+
+func (p PagerDuty) send(event map[string]interface{}) error {
+	body, _ := json.Marshal(event)
+	return post("pagerduty", pagerDutyEventsURL, "", body)
+}
+
+// Opsgenie creates alerts through the Alert API with an API integration's key. APIURL is
+// https://api.opsgenie.com unless set, e.g. to https://api.eu.opsgenie.com.
+type Opsgenie struct {
+	APIKey string
+	APIURL string
+}
+
+// opsgeniePriority maps PagerDuty severities to Opsgenie priorities.
+var opsgeniePriority = map[string]string{"critical": "P1", "error": "P2", "warning": "P3", "info": "P5"}
+
+func (o Opsgenie) Trigger(a Alert) error {
+	body, _ := json.Marshal(map[string]interface{}{
+		"message":  truncate(a.Summary, 130),
+		"alias":    a.DedupKey,
+		"source":   a.Source,
+		"priority": opsgeniePriority[a.Severity],
+		"details":  a.Details,
+	})
+	return post("opsgenie", o.baseURL()+"/v2/alerts", "GenieKey "+o.APIKey, body)
+}
+
+func (o Opsgenie) Resolve(dedupKey string) error {
+	endpoint := fmt.Sprintf("%s/v2/alerts/%s/close?identifierType=alias", o.baseURL(), url.PathEscape(dedupKey))
+	return post("opsgenie", endpoint, "GenieKey "+o.APIKey, []byte(`{"source":"unity-alerts"}`))
+}
+
+func (o Opsgenie) baseURL() string {
+	if o.APIURL == "" {
+		return "https://api.opsgenie.com"
+	}
+	return strings.TrimRight(o.APIURL, "/")
+}
+
+func post(service, endpoint, authorization string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request to %s: %w", service, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s returned non-2xx status: %s. Body: %s", service, resp.Status, string(respBody))
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,131 @@
+// Package signalcli delivers alerts to a Signal group through the signal-cli REST API
+// (github.com/bbernhard/signal-cli-rest-api). Signal messages cannot be edited, so alerts and
+// clears are queued and sent together, as few messages as possible, when Flush is called at
+// the end of each run.
+package signalcli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/mtickle/unity-alerts/sink/discord"
+)
+
+// maxBatch is the most alerts combined into one Signal message.
+const maxBatch = 10
+
+// Messenger queues messages for one Signal group, sent from Number.
+type Messenger struct {
+	APIURL    string // e.g. http://signal-cli:8080.
+	Number    string // The registered sender, e.g. +19195550100.
+	Recipient string // Group ID ("group.…") or phone number.
+}
+
+type queued struct {
+	text        string
+	attachments []discord.Attachment
+}
+
+var (
+	mu      sync.Mutex
+	nextID  int
+	pending = make(map[Messenger][]queued)
+)
+
+// Send queues the alert. The returned ID only identifies it locally: Signal assigns none
+// until the batch is sent.
+func (m Messenger) Send(payload discord.WebhookPayload, attachments ...discord.Attachment) (string, error) {
+	var images []discord.Attachment
+	for _, a := range attachments {
+		if ext := strings.ToLower(path.Ext(a.Name)); ext == ".jpg" || ext == ".jpeg" || ext == ".png" {
+			images = append(images, a)
+		}
+	}
+	return m.enqueue(queued{text: discord.PlainText(payload.Content, payload.Embeds), attachments: images}), nil
+}
+
+// Edit queues the new version as a follow-up message, since Signal cannot edit.
+func (m Messenger) Edit(messageID string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	var p struct {
+		Content string          `json:"content"`
+		Embeds  []discord.Embed `json:"embeds"`
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		return fmt.Errorf("failed to read edit payload: %w", err)
+	}
+	m.enqueue(queued{text: discord.PlainText(p.Content, p.Embeds)})
+	return nil
+}
+
+// FetchEmbeds is unsupported: sent messages are not kept.
+func (m Messenger) FetchEmbeds(messageID string) ([]json.RawMessage, error) {
+	return nil, fmt.Errorf("signal messages cannot be fetched")
+}
+
+func (m Messenger) enqueue(q queued) string {
+	mu.Lock()
+	defer mu.Unlock()
+	nextID++
+	pending[m] = append(pending[m], q)
+	return fmt.Sprintf("signal-%d-%d", len(pending[m]), nextID)
+}
+
+// Flush sends everything queued, up to maxBatch alerts per message. Messages that fail are
+// dropped, and the first error is returned.
+func Flush() error {
+	mu.Lock()
+	batches := pending
+	pending = make(map[Messenger][]queued)
+	mu.Unlock()
+
+	var firstErr error
+	for m, messages := range batches {
+		for len(messages) > 0 {
+			n := min(len(messages), maxBatch)
+			if err := m.send(messages[:n]); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			messages = messages[n:]
+		}
+	}
+	return firstErr
+}
+
+// send posts one combined message through the /v2/send endpoint.
+func (m Messenger) send(messages []queued) error {
+	var texts, attachments []string
+	for _, q := range messages {
+		texts = append(texts, q.text)
+		for _, a := range q.attachments {
+			attachments = append(attachments, fmt.Sprintf("data:%s;filename=%s;base64,%s",
+				http.DetectContentType(a.Data), a.Name, base64.StdEncoding.EncodeToString(a.Data)))
+		}
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"message":            strings.Join(texts, "\n\n———\n\n"),
+		"number":             m.Number,
+		"recipients":         []string{m.Recipient},
+		"base64_attachments": attachments,
+	})
+	resp, err := http.Post(strings.TrimRight(m.APIURL, "/")+"/v2/send", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error sending to signal-cli: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("signal-cli returned non-2xx status: %s. Body: %s", resp.Status, string(respBody))
+	}
+	return nil
+}
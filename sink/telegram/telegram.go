@@ -0,0 +1,132 @@
+// Package telegram delivers alerts to a Telegram chat through the Bot API.
+package telegram
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/mtickle/unity-alerts/sink/discord"
+)
+
+// maxMessageLength is the Bot API's limit on a text message, in UTF-16 code units; runes are
+// a close enough proxy for alert text.
+const maxMessageLength = 4096
+
+// Messenger posts to one chat as the bot owning Token. Edits replace the message text.
+type Messenger struct {
+	Token  string
+	ChatID string // Numeric ID or @channelusername.
+}
+
+// Send posts the payload as plain text, then each image attachment as a photo. It returns
+// the text message's ID.
+func (m Messenger) Send(payload discord.WebhookPayload, attachments ...discord.Attachment) (string, error) {
+	var out struct {
+		MessageID int `json:"message_id"`
+	}
+	err := m.call("sendMessage", map[string]interface{}{
+		"chat_id":                  m.ChatID,
+		"text":                     truncate(discord.PlainText(payload.Content, payload.Embeds)),
+		"disable_web_page_preview": true,
+	}, &out)
+	if err != nil {
+		return "", err
+	}
+	messageID := strconv.Itoa(out.MessageID)
+	for _, a := range attachments {
+		if ext := strings.ToLower(path.Ext(a.Name)); ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
+			continue
+		}
+		if err := m.sendPhoto(a, out.MessageID); err != nil {
+			return messageID, err
+		}
+	}
+	return messageID, nil
+}
+
+// Edit replaces a message's text. payload is a discord.WebhookPayload or any value with the
+// same "content" and "embeds" fields.
+func (m Messenger) Edit(messageID string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	var p struct {
+		Content string          `json:"content"`
+		Embeds  []discord.Embed `json:"embeds"`
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		return fmt.Errorf("failed to read edit payload: %w", err)
+	}
+	id, err := strconv.Atoi(messageID)
+	if err != nil {
+		return fmt.Errorf("invalid telegram message ID %q", messageID)
+	}
+	return m.call("editMessageText", map[string]interface{}{
+		"chat_id":                  m.ChatID,
+		"message_id":               id,
+		"text":                     truncate(discord.PlainText(p.Content, p.Embeds)),
+		"disable_web_page_preview": true,
+	}, nil)
+}
+
+// FetchEmbeds is unsupported: the Bot API cannot read messages back.
+func (m Messenger) FetchEmbeds(messageID string) ([]json.RawMessage, error) {
+	return nil, fmt.Errorf("telegram messages cannot be fetched")
+}
+
+// sendPhoto uploads an image as a reply to the alert text.
+func (m Messenger) sendPhoto(a discord.Attachment, replyTo int) error {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	w.WriteField("chat_id", m.ChatID)
+	w.WriteField("reply_to_message_id", strconv.Itoa(replyTo))
+	part, err := w.CreateFormFile("photo", a.Name)
+	if err != nil {
+		return err
+	}
+	part.Write(a.Data)
+	w.Close()
+	return m.do("sendPhoto", w.FormDataContentType(), body.Bytes(), nil)
+}
+
+func (m Messenger) call(method string, params map[string]interface{}, out interface{}) error {
+	body, _ := json.Marshal(params)
+	return m.do(method, "application/json", body, out)
+}
+
+func (m Messenger) do(method, contentType string, body []byte, out interface{}) error {
+	resp, err := http.Post("https://api.telegram.org/bot"+m.Token+"/"+method, contentType, bytes.NewReader(body))
+	if err != nil {
+		// The URL carries the token; don't let it reach the logs.
+		return fmt.Errorf("error sending request to telegram %s", method)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		OK          bool            `json:"ok"`
+		Description string          `json:"description"`
+		Result      json.RawMessage `json:"result"`
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err := json.Unmarshal(respBody, &result); err != nil || !result.OK {
+		return fmt.Errorf("telegram %s failed: %s %s", method, resp.Status, result.Description)
+	}
+	if out != nil {
+		return json.Unmarshal(result.Result, out)
+	}
+	return nil
+}
+
+func truncate(s string) string {
+	if r := []rune(s); len(r) > maxMessageLength {
+		return string(r[:maxMessageLength-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,193 @@
+// Package whatsapp delivers alerts through the WhatsApp Business Cloud API. Business accounts
+// may only start conversations with approved template messages, so each alert fills in a
+// template: its image header gets the camera or map image, {{1}} the alert title and {{2}}
+// the details on one line.
+package whatsapp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path"
+	"strings"
+
+	"github.com/mtickle/unity-alerts/sink/discord"
+)
+
+const graphURL = "https://graph.facebook.com/v20.0"
+
+// maxParamLength keeps template parameters well inside the API's limit on the rendered body.
+const maxParamLength = 900
+
+// Messenger sends templates from the business phone number PhoneNumberID to each of To.
+// Messages cannot be edited, so clears are sent as ClearedTemplate, or not at all when it is
+// empty.
+type Messenger struct {
+	Token           string
+	PhoneNumberID   string
+	To              []string
+	Template        string
+	ClearedTemplate string
+	Language        string // Template language code, e.g. "en_US".
+}
+
+// Send sends the template to every recipient, with the first image attachment, or else the
+// first linked embed image, as the header. It returns the first message's ID.
+func (m Messenger) Send(payload discord.WebhookPayload, attachments ...discord.Attachment) (string, error) {
+	var header map[string]string
+	for _, a := range attachments {
+		if ext := strings.ToLower(path.Ext(a.Name)); ext == ".jpg" || ext == ".jpeg" || ext == ".png" {
+			id, err := m.upload(a)
+			if err != nil {
+				return "", err
+			}
+			header = map[string]string{"id": id}
+			break
+		}
+	}
+	for _, e := range payload.Embeds {
+		if header == nil && strings.HasPrefix(e.Image.URL, "https://") {
+			header = map[string]string{"link": e.Image.URL}
+		}
+	}
+	return m.sendTemplate(m.Template, discord.PlainText(payload.Content, payload.Embeds), header)
+}
+
+// Edit sends the cleared template with the new text.
+func (m Messenger) Edit(messageID string, payload interface{}) error {
+	if m.ClearedTemplate == "" {
+		return nil
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	var p struct {
+		Content string          `json:"content"`
+		Embeds  []discord.Embed `json:"embeds"`
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		return fmt.Errorf("failed to read edit payload: %w", err)
+	}
+	_, err = m.sendTemplate(m.ClearedTemplate, discord.PlainText(p.Content, p.Embeds), nil)
+	return err
+}
+
+// FetchEmbeds is unsupported: sent messages cannot be read back.
+func (m Messenger) FetchEmbeds(messageID string) ([]json.RawMessage, error) {
+	return nil, fmt.Errorf("whatsapp messages cannot be fetched")
+}
+
+// sendTemplate fills in the template's parameters, and its image header when header (an
+// uploaded media "id" or a "link") is set.
+func (m Messenger) sendTemplate(template, text string, header map[string]string) (string, error) {
+	title, details, _ := strings.Cut(text, "\n")
+	var components []interface{}
+	if header != nil {
+		components = append(components, map[string]interface{}{
+			"type":       "header",
+			"parameters": []interface{}{map[string]interface{}{"type": "image", "image": header}},
+		})
+	}
+	components = append(components, map[string]interface{}{
+		"type": "body",
+		"parameters": []interface{}{
+			map[string]string{"type": "text", "text": param(title)},
+			map[string]string{"type": "text", "text": param(details)},
+		},
+	})
+
+	var firstID string
+	var firstErr error
+	for _, to := range m.To {
+		body, _ := json.Marshal(map[string]interface{}{
+			"messaging_product": "whatsapp",
+			"to":                to,
+			"type":              "template",
+			"template": map[string]interface{}{
+				"name":       template,
+				"language":   map[string]string{"code": m.Language},
+				"components": components,
+			},
+		})
+		var out struct {
+			Messages []struct {
+				ID string `json:"id"`
+			} `json:"messages"`
+		}
+		if err := m.do("/messages", "application/json", body, &out); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if firstID == "" && len(out.Messages) > 0 {
+			firstID = out.Messages[0].ID
+		}
+	}
+	return firstID, firstErr
+}
+
+// upload stores an image with the phone number's media and returns its ID.
+func (m Messenger) upload(a discord.Attachment) (string, error) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	w.WriteField("messaging_product", "whatsapp")
+	contentType := http.DetectContentType(a.Data)
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Disposition": {fmt.Sprintf(`form-data; name="file"; filename=%q`, a.Name)},
+		"Content-Type":        {contentType},
+	})
+	if err != nil {
+		return "", err
+	}
+	part.Write(a.Data)
+	w.WriteField("type", contentType)
+	w.Close()
+	var out struct {
+		ID string `json:"id"`
+	}
+	if err := m.do("/media", w.FormDataContentType(), body.Bytes(), &out); err != nil {
+		return "", err
+	}
+	return out.ID, nil
+}
+
+func (m Messenger) do(endpoint, contentType string, body []byte, out interface{}) error {
+	req, err := http.NewRequest(http.MethodPost, graphURL+"/"+m.PhoneNumberID+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.Token)
+	req.Header.Set("Content-Type", contentType)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request to whatsapp: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("whatsapp returned non-200 status: %s. Body: %s", resp.Status, string(respBody))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding whatsapp response: %w", err)
+	}
+	return nil
+}
+
+// param flattens text into a template parameter, which may not contain line breaks, tabs or
+// runs of spaces, and may not be empty.
+func param(s string) string {
+	s = strings.Join(strings.Fields(strings.ReplaceAll(s, "\n", " · ")), " ")
+	if s == "" {
+		return "-"
+	}
+	if r := []rune(s); len(r) > maxParamLength {
+		return string(r[:maxParamLength-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,254 @@
+// Package xmpp delivers alerts to an XMPP multi-user chat room. Alerts are sent as plain
+// text, followed by one message per linked image with an out-of-band URL so clients show it
+// inline, and clears correct the original message (XEP-0308).
+package xmpp
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"mellium.im/sasl"
+	"mellium.im/xmlstream"
+	"mellium.im/xmpp"
+	"mellium.im/xmpp/dial"
+	"mellium.im/xmpp/jid"
+	"mellium.im/xmpp/muc"
+	"mellium.im/xmpp/mux"
+	"mellium.im/xmpp/oob"
+	"mellium.im/xmpp/stanza"
+
+	"github.com/mtickle/unity-alerts/sink/discord"
+)
+
+const (
+	// setupTimeout bounds connecting, logging in and joining the room.
+	setupTimeout = 30 * time.Second
+	// sendTimeout bounds sending a message.
+	sendTimeout = 10 * time.Second
+	// nsCorrect is Last Message Correction (XEP-0308).
+	nsCorrect = "urn:xmpp:message-correct:0"
+)
+
+// rootCAs verifies the server's certificate after STARTTLS; nil uses the system roots.
+var rootCAs *x509.CertPool
+
+// Messenger posts to Room as JID. Server (host:port) overrides the SRV lookup.
+type Messenger struct {
+	JID      string
+	Password string
+	Server   string
+	Room     string // e.g. alerts@conference.example.org.
+	Nick     string
+}
+
+// Connections stay open between runs in daemon mode and are re-established when they drop.
+var (
+	mu      sync.Mutex
+	clients = make(map[Messenger]*client)
+)
+
+// message is a groupchat message.
+type message struct {
+	body     string
+	oobURL   string // Out-of-band URL, shown inline as an image by most clients.
+	replaces string // ID of an earlier message this corrects.
+}
+
+// Send posts the alert text and its linked images, and returns the text message's ID.
+// Uploaded attachments are not sent: XMPP only links to images, so they appear when the
+// image host publishes them.
+func (m Messenger) Send(payload discord.WebhookPayload, attachments ...discord.Attachment) (string, error) {
+	id, err := m.send(message{body: discord.PlainText(payload.Content, payload.Embeds)})
+	if err != nil {
+		return "", err
+	}
+	for _, e := range payload.Embeds {
+		for _, img := range []string{e.Image.URL, e.Thumbnail.URL} {
+			if strings.HasPrefix(img, "https://") || strings.HasPrefix(img, "http://") {
+				if _, err := m.send(message{body: img, oobURL: img}); err != nil {
+					return id, err
+				}
+			}
+		}
+	}
+	return id, nil
+}
+
+// Edit corrects a message's text. payload is a discord.WebhookPayload or any value with the
+// same "content" and "embeds" fields.
+func (m Messenger) Edit(messageID string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	var p struct {
+		Content string          `json:"content"`
+		Embeds  []discord.Embed `json:"embeds"`
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		return fmt.Errorf("failed to read edit payload: %w", err)
+	}
+	_, err = m.send(message{body: discord.PlainText(p.Content, p.Embeds), replaces: messageID})
+	return err
+}
+
+// FetchEmbeds is unsupported: the room's messages are not read back.
+func (m Messenger) FetchEmbeds(messageID string) ([]json.RawMessage, error) {
+	return nil, fmt.Errorf("xmpp messages cannot be fetched")
+}
+
+// send delivers a message over the room's connection, reconnecting once if it has dropped.
+func (m Messenger) send(msg message) (string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	for attempt := 0; ; attempt++ {
+		c, err := m.client()
+		if err != nil {
+			return "", err
+		}
+		id, err := c.send(msg)
+		if err == nil || attempt > 0 {
+			return id, err
+		}
+		c.close()
+		delete(clients, m)
+	}
+}
+
+// client returns the open connection to the room, dialing and joining if there is none.
+func (m Messenger) client() (*client, error) {
+	if c, ok := clients[m]; ok {
+		select {
+		case <-c.done:
+			c.close()
+			delete(clients, m)
+		default:
+			return c, nil
+		}
+	}
+	c, err := m.dial()
+	if err != nil {
+		return nil, err
+	}
+	clients[m] = c
+	return c, nil
+}
+
+// client is a session joined to a room. Once joined, it answers what the server sends on its
+// own, and done is closed when the session ends.
+type client struct {
+	session *xmpp.Session
+	room    jid.JID
+	done    chan struct{}
+}
+
+// dial logs in with STARTTLS and joins the room, without its history. Server (host:port) is
+// dialed if set; otherwise the JID's domain is looked up for its _xmpp-client SRV record,
+// falling back to port 5222. The server assigns the session's resource, so one room's session
+// never replaces another's.
+func (m Messenger) dial() (*client, error) {
+	origin, err := jid.Parse(m.JID)
+	if err != nil || origin.Localpart() == "" {
+		return nil, fmt.Errorf("invalid XMPP JID %q", m.JID)
+	}
+	room, err := jid.Parse(m.Room)
+	if err != nil || room.Localpart() == "" {
+		return nil, fmt.Errorf("invalid XMPP room %q", m.Room)
+	}
+	occupant, err := room.WithResource(m.Nick)
+	if err != nil {
+		return nil, fmt.Errorf("invalid XMPP nick %q: %w", m.Nick, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), setupTimeout)
+	defer cancel()
+	var conn net.Conn
+	if m.Server != "" {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", m.Server)
+	} else {
+		conn, err = (&dial.Dialer{NoTLS: true}).Dial(ctx, "tcp", origin)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to XMPP server: %w", err)
+	}
+	session, err := xmpp.NewClientSession(ctx, origin.Bare(), conn,
+		xmpp.StartTLS(&tls.Config{ServerName: origin.Domainpart(), RootCAs: rootCAs, MinVersion: tls.VersionTLS12}),
+		xmpp.SASL("", m.Password, sasl.ScramSha256, sasl.ScramSha1, sasl.Plain),
+		xmpp.BindResource(),
+	)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to log in to XMPP server: %w", err)
+	}
+
+	c := &client{session: session, room: room, done: make(chan struct{})}
+	joinCtx, refused := context.WithCancelCause(ctx)
+	rooms := &muc.Client{}
+	handler := mux.New(stanza.NSClient,
+		muc.HandleClient(rooms),
+		mux.PresenceFunc(stanza.ErrorPresence, xml.Name{Local: "error"}, func(p stanza.Presence, _ xmlstream.TokenReadEncoder) error {
+			if p.From.Equal(occupant) {
+				refused(fmt.Errorf("XMPP room %s refused to let us join", m.Room))
+			}
+			return nil
+		}),
+	)
+	go func() {
+		defer close(c.done)
+		session.Serve(handler)
+	}()
+	if _, err := rooms.Join(joinCtx, occupant, session, muc.MaxHistory(0)); err != nil {
+		c.close()
+		if cause := context.Cause(joinCtx); cause != nil {
+			err = cause
+		}
+		return nil, fmt.Errorf("failed to join XMPP room %s: %w", m.Room, err)
+	}
+	return c, nil
+}
+
+// send sends a message to the room and returns its ID.
+func (c *client) send(msg message) (string, error) {
+	var b [8]byte
+	rand.Read(b[:])
+	id := hex.EncodeToString(b[:])
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	if err := c.session.Send(ctx, groupchat(c.room, id, msg)); err != nil {
+		return "", fmt.Errorf("failed to send XMPP message: %w", err)
+	}
+	return id, nil
+}
+
+// groupchat is the stanza for a message to a room.
+func groupchat(room jid.JID, id string, msg message) xml.TokenReader {
+	payload := []xml.TokenReader{
+		xmlstream.Wrap(xmlstream.Token(xml.CharData(msg.body)), xml.StartElement{Name: xml.Name{Local: "body"}}),
+	}
+	if msg.oobURL != "" {
+		payload = append(payload, oob.Data{URL: msg.oobURL}.TokenReader())
+	}
+	if msg.replaces != "" {
+		payload = append(payload, xmlstream.Wrap(nil, xml.StartElement{
+			Name: xml.Name{Space: nsCorrect, Local: "replace"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "id"}, Value: msg.replaces}},
+		}))
+	}
+	return stanza.Message{To: room, Type: stanza.GroupChatMessage, ID: id}.Wrap(xmlstream.MultiReader(payload...))
+}
+
+// close ends the stream and the connection.
+func (c *client) close() {
+	c.session.Close()
+	c.session.Conn().Close()
+}
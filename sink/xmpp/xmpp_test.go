@@ -0,0 +1,291 @@
+package xmpp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mtickle/unity-alerts/sink/discord"
+)
+
+const (
+	nsClient = "jabber:client"
+	nsStream = "http://etherx.jabber.org/streams"
+	nsTLS    = "urn:ietf:params:xml:ns:xmpp-tls"
+	nsSASL   = "urn:ietf:params:xml:ns:xmpp-sasl"
+	nsBind   = "urn:ietf:params:xml:ns:xmpp-bind"
+	nsMUC    = "http://jabber.org/protocol/muc"
+)
+
+// element is one top-level stanza as the fake server read it.
+type element struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Inner   string     `xml:",innerxml"`
+}
+
+func (e element) attr(name string) string {
+	for _, a := range e.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// fakeServer is an XMPP server for example.com that accepts one client logging in as
+// alerts with password s3cret.
+type fakeServer struct {
+	noTLS     bool   // Refuse STARTTLS.
+	joinReply string // Stanzas sent in reply to a MUC join presence.
+}
+
+// trustCert makes the sink trust a new self-signed certificate for example.com and returns it.
+func trustCert(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		DNSNames:              []string{"example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	rootCAs = pool
+	t.Cleanup(func() { rootCAs = nil })
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// start listens for one client and sends every stanza it reads to the returned channel, which
+// is closed when the client goes away.
+func (s fakeServer) start(t *testing.T) (string, <-chan element) {
+	t.Helper()
+	cert := trustCert(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	elements := make(chan element, 32)
+	go func() {
+		defer close(elements)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { conn.Close() }()
+		dec := xml.NewDecoder(conn)
+		secure, authed := false, false
+		for {
+			e, err := readElement(dec)
+			if err != nil {
+				return
+			}
+			if e.XMLName.Space == nsStream && e.XMLName.Local == "stream" {
+				fmt.Fprintf(conn, "<?xml version='1.0'?><stream:stream from='example.com' id='s1' xmlns='%s' xmlns:stream='%s' version='1.0'><stream:features>%s</stream:features>",
+					nsClient, nsStream, s.features(secure, authed))
+				continue
+			}
+			elements <- e
+			switch e.XMLName.Local {
+			case "starttls":
+				if s.noTLS {
+					fmt.Fprintf(conn, "<failure xmlns='%s'/>", nsTLS)
+					return
+				}
+				fmt.Fprintf(conn, "<proceed xmlns='%s'/>", nsTLS)
+				tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}})
+				if tlsConn.Handshake() != nil {
+					return
+				}
+				conn, dec, secure = tlsConn, xml.NewDecoder(tlsConn), true
+			case "auth":
+				creds, _ := base64.StdEncoding.DecodeString(e.Inner)
+				if string(creds) != "\x00alerts\x00s3cret" {
+					fmt.Fprintf(conn, "<failure xmlns='%s'><not-authorized/></failure>", nsSASL)
+					return
+				}
+				fmt.Fprintf(conn, "<success xmlns='%s'/>", nsSASL)
+				dec, authed = xml.NewDecoder(conn), true
+			case "iq":
+				fmt.Fprintf(conn, "<iq type='result' id='%s'><bind xmlns='%s'><jid>alerts@example.com/4db06f06</jid></bind></iq>", e.attr("id"), nsBind)
+			case "presence":
+				fmt.Fprint(conn, s.joinReply)
+			}
+		}
+	}()
+	return ln.Addr().String(), elements
+}
+
+func (s fakeServer) features(secure, authed bool) string {
+	switch {
+	case !secure && !s.noTLS:
+		return fmt.Sprintf("<starttls xmlns='%s'><required/></starttls>", nsTLS)
+	case !authed:
+		return fmt.Sprintf("<mechanisms xmlns='%s'><mechanism>PLAIN</mechanism></mechanisms>", nsSASL)
+	}
+	return fmt.Sprintf("<bind xmlns='%s'/>", nsBind)
+}
+
+// readElement returns the next top-level element, or just the start of a stream header.
+func readElement(dec *xml.Decoder) (element, error) {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return element{}, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "stream" {
+				return element{XMLName: t.Name}, nil
+			}
+			var e element
+			err := dec.DecodeElement(&e, &t)
+			return e, err
+		case xml.EndElement:
+			return element{}, io.EOF
+		}
+	}
+}
+
+const (
+	room     = "alerts@conference.example.com"
+	occupant = room + "/Unity Alerts"
+)
+
+var joined = fmt.Sprintf("<presence from='%s/dispatcher'><x xmlns='%s#user'><item role='participant'/></x></presence>"+
+	"<message from='%s' type='groupchat'><subject>Traffic</subject></message>"+
+	"<presence from='%s'><x xmlns='%s#user'><item role='participant'/><status code='110'/></x></presence>",
+	room, nsMUC, room, occupant, nsMUC)
+
+// disconnect closes the messenger's cached connection, if it has one.
+func disconnect(m Messenger) {
+	mu.Lock()
+	defer mu.Unlock()
+	if c, ok := clients[m]; ok {
+		c.close()
+		delete(clients, m)
+	}
+}
+
+func TestSendAndEdit(t *testing.T) {
+	addr, elements := fakeServer{joinReply: joined}.start(t)
+	m := Messenger{JID: "alerts@example.com", Password: "s3cret", Server: addr, Room: room, Nick: "Unity Alerts"}
+	defer disconnect(m)
+
+	payload := discord.WebhookPayload{Content: "Vehicle Crash on I-40 <W> & US-1", Embeds: []discord.Embed{{
+		Image: discord.EmbedImage{URL: "https://cdn.example.com/camera.jpg?a=1&b=2"},
+	}}}
+	id, err := m.Send(payload)
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if err := m.Edit(id, discord.WebhookPayload{Content: "Cleared"}); err != nil {
+		t.Fatalf("Edit: %v", err)
+	}
+	disconnect(m)
+
+	var got []element
+	for e := range elements {
+		got = append(got, e)
+	}
+	var names []string
+	for _, e := range got {
+		names = append(names, e.XMLName.Local)
+	}
+	if want := "starttls auth iq presence message message message"; strings.Join(names, " ") != want {
+		t.Fatalf("server read %q, want %q", strings.Join(names, " "), want)
+	}
+	if presence := got[3]; presence.attr("to") != occupant || !strings.Contains(presence.Inner, "maxstanzas") {
+		t.Errorf("join presence = %+v", presence)
+	}
+
+	type body struct {
+		Body    string `xml:"body"`
+		URL     string `xml:"jabber:x:oob x>url"`
+		Replace struct {
+			ID string `xml:"id,attr"`
+		} `xml:"urn:xmpp:message-correct:0 replace"`
+	}
+	messages := got[4:]
+	var bodies []body
+	for _, message := range messages {
+		if message.attr("to") != room || message.attr("type") != "groupchat" {
+			t.Errorf("message attributes = %v, want groupchat to %s", message.Attrs, room)
+		}
+		var b body
+		if err := xml.Unmarshal([]byte("<message>"+message.Inner+"</message>"), &b); err != nil {
+			t.Fatalf("message is not well-formed: %v\n%s", err, message.Inner)
+		}
+		bodies = append(bodies, b)
+	}
+	if messages[0].attr("id") != id || !strings.HasPrefix(bodies[0].Body, "Vehicle Crash on I-40 <W> & US-1") {
+		t.Errorf("alert = %+v with ID %s, want ID %s", bodies[0], messages[0].attr("id"), id)
+	}
+	if bodies[1].Body != "https://cdn.example.com/camera.jpg?a=1&b=2" || bodies[1].URL != bodies[1].Body {
+		t.Errorf("image = %+v", bodies[1])
+	}
+	if !strings.HasPrefix(bodies[2].Body, "Cleared") || bodies[2].Replace.ID != id {
+		t.Errorf("correction = %+v, want one replacing %s", bodies[2], id)
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	refused := fmt.Sprintf("<presence from='%s' type='error'><error type='auth'><registration-required xmlns='urn:ietf:params:xml:ns:xmpp-stanzas'/></error></presence>", occupant)
+	tests := []struct {
+		name     string
+		server   fakeServer
+		password string
+		want     string
+	}{
+		{"STARTTLS refused", fakeServer{noTLS: true}, "s3cret", "failed to log in"},
+		{"wrong password", fakeServer{}, "wrong", "failed to log in"},
+		{"join refused", fakeServer{joinReply: refused}, "s3cret", "refused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, _ := tt.server.start(t)
+			m := Messenger{JID: "alerts@example.com", Password: tt.password, Server: addr, Room: room, Nick: "Unity Alerts"}
+			defer disconnect(m)
+			if _, err := m.Send(discord.WebhookPayload{Content: "Crash"}); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Send error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendInvalidJID(t *testing.T) {
+	for _, jid := range []string{"example.com", "@example.com", "alerts@"} {
+		m := Messenger{JID: jid, Password: "s3cret", Server: "127.0.0.1:1", Room: room, Nick: "Unity Alerts"}
+		if _, err := m.Send(discord.WebhookPayload{Content: "Crash"}); err == nil || !strings.Contains(err.Error(), "invalid XMPP JID") {
+			t.Errorf("Send as %q error = %v, want an invalid JID error", jid, err)
+		}
+	}
+}
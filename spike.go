@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/sink/discord"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// SpikeConfig posts a meta-alert when a source, or one of its event types, reports far more
+// incidents than usual for the time of day, such as during a major storm.
+type SpikeConfig struct {
+	Route  string `json:"route"`
+	Window string `json:"window,omitempty"` // Span counted, e.g. "1h" (the default).
+
+	// BaselineDays is how many preceding days, at the same time of day, make up "normal"
+	// (default 28).
+	BaselineDays int `json:"baseline_days,omitempty"`
+
+	// Threshold is how many standard deviations above the baseline mean count as a spike
+	// (default 3). MinCount ignores windows with fewer incidents than this (default 5).
+	Threshold float64 `json:"threshold,omitempty"`
+	MinCount  int     `json:"min_count,omitempty"`
+
+	Cooldown string `json:"cooldown,omitempty"` // Quiet period before the same spike is reported again (default "3h").
+}
+
+func (s *SpikeConfig) validate(c *Config) error {
+	if s == nil {
+		return nil
+	}
+	if _, ok := c.Route(s.Route); !ok {
+		return fmt.Errorf("spikes.route: unknown route %q", s.Route)
+	}
+	for name, v := range map[string]string{"window": s.Window, "cooldown": s.Cooldown} {
+		if v == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(v); err != nil || d <= 0 {
+			return fmt.Errorf("spikes.%s: invalid duration %q", name, v)
+		}
+	}
+	if s.BaselineDays < 0 || s.Threshold < 0 || s.MinCount < 0 {
+		return fmt.Errorf("spikes: baseline_days, threshold and min_count must not be negative")
+	}
+	return nil
+}
+
+// settings returns the configuration with defaults filled in.
+func (s SpikeConfig) settings() (window time.Duration, days int, threshold float64, minCount int, cooldown time.Duration) {
+	window, cooldown = time.Hour, 3*time.Hour
+	days, threshold, minCount = 28, 3, 5
+	if s.Window != "" {
+		window, _ = time.ParseDuration(s.Window)
+	}
+	if s.Cooldown != "" {
+		cooldown, _ = time.ParseDuration(s.Cooldown)
+	}
+	if s.BaselineDays > 0 {
+		days = s.BaselineDays
+	}
+	if s.Threshold > 0 {
+		threshold = s.Threshold
+	}
+	if s.MinCount > 0 {
+		minCount = s.MinCount
+	}
+	return
+}
+
+// spike is a source, or a source's event type, running above its usual rate.
+type spike struct {
+	Source    string
+	EventType string // Empty for the source as a whole.
+	Count     int
+	Mean      float64 // Baseline count for the same window of the day.
+	StdDev    float64
+}
+
+func (s spike) key() string {
+	if s.EventType == "" {
+		return s.Source
+	}
+	return s.Source + " / " + s.EventType
+}
+
+// checkSpikes compares the latest window's incident counts with the same window on previous
+// days and posts a meta-alert for each new spike.
+func (a *app) checkSpikes(cfg *Config) {
+	if cfg.Spikes == nil {
+		return
+	}
+	route, _ := cfg.Route(cfg.Spikes.Route)
+	window, days, threshold, minCount, cooldown := cfg.Spikes.settings()
+	label := cfg.Spikes.Window
+	if label == "" {
+		label = "1h"
+	}
+	spikes, err := a.findSpikes(cfg, time.Now(), window, days, threshold, minCount)
+	if err != nil {
+		log.Printf("Error checking for incident spikes: %v", err)
+		return
+	}
+	for _, s := range spikes {
+		if _, ok := cfg.inMaintenance(s.Source, time.Now()); ok {
+			continue
+		}
+		claimed, err := postgres.ClaimSpikeAlert(a.db, s.key(), cooldown)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		log.Printf("Incident spike: %s has %d incidents in the last %s, against %.1f normally.", s.key(), s.Count, label, s.Mean)
+		opts := cfg.RenderOptions(route, "")
+		payload := discord.WebhookPayload{Username: opts.T("bot_username"), Embeds: []discord.Embed{buildSpikeEmbed(s, label, days, opts)}}
+		if _, err := reportMessenger(route).Send(payload); err != nil {
+			log.Printf("Error posting spike alert: %v", err)
+		}
+	}
+}
+
+// findSpikes counts incidents per source and per source and type in the window ending at now,
+// and in the same window on each of the previous days. A count is a spike when it reaches
+// minCount and exceeds the baseline mean by threshold standard deviations. The deviation is
+// never taken as less than a Poisson process's, so sources that are usually quiet need more
+// than a couple of extra incidents to trip it.
+func (a *app) findSpikes(cfg *Config, now time.Time, window time.Duration, days int, threshold float64, minCount int) ([]spike, error) {
+	rows, err := a.db.Query(cfg.SQL(`SELECT {source}, {event_type}, {timestamp} FROM {incidents}
+		WHERE {timestamp} > $1 AND {timestamp} <= $2 AND NOT {is_test}`), now.Add(-time.Duration(days)*24*time.Hour-window), now)
+	if err != nil {
+		return nil, fmt.Errorf("error querying incidents for spikes: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[spike][]int) // Keyed by source and type, one count per day back.
+	for rows.Next() {
+		var source, eventType string
+		var ts time.Time
+		if err := rows.Scan(&source, &eventType, &ts); err != nil {
+			return nil, fmt.Errorf("error scanning incident row: %w", err)
+		}
+		age := now.Sub(ts)
+		day := int(age / (24 * time.Hour))
+		if day > days || age-time.Duration(day)*24*time.Hour >= window {
+			continue
+		}
+		for _, key := range []spike{{Source: source}, {Source: source, EventType: strings.TrimSpace(eventType)}} {
+			if counts[key] == nil {
+				counts[key] = make([]int, days+1)
+			}
+			counts[key][day]++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var spikes []spike
+	for key, c := range counts {
+		if c[0] < minCount {
+			continue
+		}
+		var sum, sumSq float64
+		for _, n := range c[1:] {
+			sum += float64(n)
+			sumSq += float64(n) * float64(n)
+		}
+		key.Count = c[0]
+		key.Mean = sum / float64(days)
+		key.StdDev = math.Sqrt(math.Max(sumSq/float64(days)-key.Mean*key.Mean, 0))
+		if float64(key.Count) > key.Mean+threshold*math.Max(key.StdDev, math.Sqrt(math.Max(key.Mean, 1))) {
+			spikes = append(spikes, key)
+		}
+	}
+	sort.Slice(spikes, func(x, y int) bool { return spikes[x].key() < spikes[y].key() })
+	return spikes, nil
+}
+
+// buildSpikeEmbed renders a spike meta-alert.
+func buildSpikeEmbed(s spike, window string, days int, opts discord.RenderOptions) discord.Embed {
+	name := discord.SanitizeFeedText(s.key())
+	title := fmt.Sprintf(opts.T("spike_title_none"), name)
+	if s.Mean > 0 {
+		title = fmt.Sprintf(opts.T("spike_title"), name, float64(s.Count)/s.Mean)
+	}
+	return discord.Embed{
+		Title: discord.Truncate("📈 "+title, discord.MaxEmbedTitle),
+		Color: 15105570, // Orange
+		Fields: []discord.EmbedField{
+			{Name: fmt.Sprintf(opts.T("spike_window"), window), Value: fmt.Sprint(s.Count), Inline: true},
+			{Name: fmt.Sprintf(opts.T("spike_normal"), days), Value: fmt.Sprintf("%.1f ± %.1f", s.Mean, s.StdDev), Inline: true},
+		},
+		Footer:    discord.EmbedFooter{Text: opts.T("spike_footer")},
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/chart"
+	"github.com/mtickle/unity-alerts/sink/discord"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// statsAverageDays is how many preceding days the report compares against.
+const statsAverageDays = 7
+
+// statsTopTypes is how many event types the report lists.
+const statsTopTypes = 5
+
+// StatsConfig posts a report on the previous day's incidents to a route each morning.
+type StatsConfig struct {
+	Route string `json:"route"`
+	At    string `json:"at,omitempty"` // Time of day in the route's timezone, "15:04" format (default 07:00).
+}
+
+func (s *StatsConfig) validate(c *Config) error {
+	if s == nil {
+		return nil
+	}
+	if _, ok := c.Route(s.Route); !ok {
+		return fmt.Errorf("stats.route: unknown route %q", s.Route)
+	}
+	if s.At != "" {
+		if _, err := time.Parse("15:04", s.At); err != nil {
+			return fmt.Errorf("stats.at: invalid time %q", s.At)
+		}
+	}
+	return nil
+}
+
+// dailyStats summarizes one day's incidents against the days before it.
+type dailyStats struct {
+	Day       time.Time
+	Total     int
+	BySource  map[string]int
+	TopTypes  []typeCount
+	Hourly    [24]int
+	AvgHourly [24]float64 // Mean per hour over the preceding statsAverageDays days.
+	AvgTotal  float64
+}
+
+type typeCount struct {
+	EventType string
+	Count     int
+}
+
+// busiestHour is the hour with the most incidents.
+func (s dailyStats) busiestHour() int {
+	busiest := 0
+	for h, n := range s.Hourly {
+		if n > s.Hourly[busiest] {
+			busiest = h
+		}
+	}
+	return busiest
+}
+
+// postDailyStats posts yesterday's report once the configured time of day has passed.
+func (a *app) postDailyStats(cfg *Config) {
+	if cfg.Stats == nil {
+		return
+	}
+	route, _ := cfg.Route(cfg.Stats.Route)
+	loc := cfg.Location(route)
+	at := cfg.Stats.At
+	if at == "" {
+		at = "07:00"
+	}
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if now.Sub(today) < time.Duration(clockMinutes(at))*time.Minute {
+		return
+	}
+	day := today.AddDate(0, 0, -1)
+	claimed, err := postgres.ClaimStatsReport(a.db, "daily", day)
+	if err != nil || !claimed {
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		return
+	}
+	if err := a.sendStats(cfg, route, day); err != nil {
+		log.Printf("Error posting daily stats: %v", err)
+		a.reporter.Report(fmt.Errorf("posting daily stats: %w", err), "error", map[string]string{"route": route.Name})
+		if err := postgres.ReleaseStatsReport(a.db, "daily", day); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
+
+// sendStats computes and posts the report for the day starting at day.
+func (a *app) sendStats(cfg *Config, route RouteConfig, day time.Time) error {
+	stats, err := a.computeStats(cfg, day)
+	if err != nil {
+		return err
+	}
+	opts := cfg.RenderOptions(route, "")
+	labels := make([]string, 24)
+	values := make([]float64, 24)
+	for h := range stats.Hourly {
+		if h%3 == 0 {
+			labels[h] = fmt.Sprint(h)
+		}
+		values[h] = float64(stats.Hourly[h])
+	}
+	png, err := chart.Bars(opts.T("stats_chart_title"), labels, values, stats.AvgHourly[:], stats.busiestHour())
+	if err != nil {
+		return err
+	}
+
+	payload := discord.WebhookPayload{Username: opts.T("bot_username"), Embeds: []discord.Embed{buildStatsEmbed(stats, opts)}}
+	_, err = reportMessenger(route).Send(payload, discord.Attachment{Name: statsChartName, Data: png})
+	if err == nil {
+		log.Printf("Posted daily stats for %s to route %q.", day.Format(time.DateOnly), route.Name)
+	}
+	return err
+}
+
+// reportMessenger sends reports to a route. They are not alerts, so carry no Acknowledge button.
+func reportMessenger(route RouteConfig) discord.Messenger {
+	messenger := route.Messenger()
+	if bot, ok := messenger.(discord.BotMessenger); ok {
+		bot.AckButton = false
+		messenger = bot
+	}
+	return messenger
+}
+
+// computeStats counts the incidents in the day starting at day and the statsAverageDays before it.
+func (a *app) computeStats(cfg *Config, day time.Time) (dailyStats, error) {
+	stats := dailyStats{Day: day, BySource: make(map[string]int)}
+	from, to := day.AddDate(0, 0, -statsAverageDays), day.AddDate(0, 0, 1)
+	rows, err := a.db.Query(cfg.SQL(`SELECT {source}, {event_type}, {timestamp} FROM {incidents}
+		WHERE {timestamp} >= $1 AND {timestamp} < $2 AND NOT {is_test}`), from, to)
+	if err != nil {
+		return stats, fmt.Errorf("error querying incidents for stats: %w", err)
+	}
+	defer rows.Close()
+
+	types := make(map[string]int)
+	var before [24]int
+	for rows.Next() {
+		var source, eventType string
+		var ts time.Time
+		if err := rows.Scan(&source, &eventType, &ts); err != nil {
+			return stats, fmt.Errorf("error scanning incident row: %w", err)
+		}
+		hour := ts.In(day.Location()).Hour()
+		if ts.Before(day) {
+			before[hour]++
+			continue
+		}
+		stats.Total++
+		stats.BySource[source]++
+		types[strings.TrimSpace(eventType)]++
+		stats.Hourly[hour]++
+	}
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	for h, n := range before {
+		stats.AvgHourly[h] = float64(n) / statsAverageDays
+		stats.AvgTotal += stats.AvgHourly[h]
+	}
+	for t, n := range types {
+		stats.TopTypes = append(stats.TopTypes, typeCount{t, n})
+	}
+	sort.Slice(stats.TopTypes, func(x, y int) bool {
+		if stats.TopTypes[x].Count != stats.TopTypes[y].Count {
+			return stats.TopTypes[x].Count > stats.TopTypes[y].Count
+		}
+		return stats.TopTypes[x].EventType < stats.TopTypes[y].EventType
+	})
+	if len(stats.TopTypes) > statsTopTypes {
+		stats.TopTypes = stats.TopTypes[:statsTopTypes]
+	}
+	return stats, nil
+}
+
+// statsChartName is the file name of the hourly chart attached to the report.
+const statsChartName = "daily_stats.png"
+
+// buildStatsEmbed renders the report, with the hourly chart as its image.
+func buildStatsEmbed(stats dailyStats, opts discord.RenderOptions) discord.Embed {
+	total := fmt.Sprintf(opts.T("stats_total_value"), stats.Total, stats.AvgTotal)
+	if stats.AvgTotal > 0 {
+		total += fmt.Sprintf(" (%+.0f%%)", (float64(stats.Total)/stats.AvgTotal-1)*100)
+	}
+
+	var sources []string
+	for source := range stats.BySource {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	var bySource []string
+	for _, source := range sources {
+		bySource = append(bySource, fmt.Sprintf("%s: %d", source, stats.BySource[source]))
+	}
+
+	var topTypes []string
+	for n, t := range stats.TopTypes {
+		topTypes = append(topTypes, fmt.Sprintf("%d. %s — %d", n+1, discord.SanitizeFeedText(t.EventType), t.Count))
+	}
+
+	busiest := stats.busiestHour()
+	hour := time.Date(2000, 1, 1, busiest, 0, 0, 0, time.UTC).Format(opts.T("ack_time_format"))
+
+	fields := []discord.EmbedField{{Name: opts.T("stats_total"), Value: total}}
+	if stats.Total > 0 {
+		fields = append(fields,
+			discord.EmbedField{Name: opts.T("stats_by_source"), Value: strings.Join(bySource, "\n"), Inline: true},
+			discord.EmbedField{Name: opts.T("stats_top_types"), Value: discord.Truncate(strings.Join(topTypes, "\n"), discord.MaxFieldValue), Inline: true},
+			discord.EmbedField{Name: opts.T("stats_busiest_hour"), Value: fmt.Sprintf(opts.T("stats_busiest_value"), hour, stats.Hourly[busiest])},
+		)
+	}
+	return discord.Embed{
+		Title:     discord.Truncate("📊 "+fmt.Sprintf(opts.T("stats_title"), stats.Day.Format(time.DateOnly)), discord.MaxEmbedTitle),
+		Color:     3447003, // Blue
+		Fields:    fields,
+		Footer:    discord.EmbedFooter{Text: opts.T("stats_footer")},
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Image:     discord.EmbedImage{URL: "attachment://" + statsChartName},
+	}
+}
+
+// stats posts the daily report for a given day right away, whether or not it was posted before.
+//
+//	unity-alerts stats [--day 2024-05-01] [--to route-name]
+func (a *app) stats(args []string) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	dayFlag := fs.String("day", "", "day to report on, YYYY-MM-DD (default: yesterday)")
+	to := fs.String("to", "", "route to post to (default: stats.route from the config)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg := a.currentConfig()
+	name := *to
+	if name == "" && cfg.Stats != nil {
+		name = cfg.Stats.Route
+	}
+	route, ok := cfg.Route(name)
+	if !ok {
+		return fmt.Errorf("no route to post to; pass --to or configure stats.route")
+	}
+	loc := cfg.Location(route)
+	now := time.Now().In(loc)
+	day := time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, loc)
+	if *dayFlag != "" {
+		var err error
+		if day, err = time.ParseInLocation(time.DateOnly, *dayFlag, loc); err != nil {
+			return fmt.Errorf("invalid --day %q", *dayFlag)
+		}
+	}
+	return a.sendStats(cfg, route, day)
+}
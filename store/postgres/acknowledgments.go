@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Acknowledgment is one user's ack of an alert message.
+type Acknowledgment struct {
+	UserName string
+	AckedAt  time.Time
+}
+
+// RecordAcknowledgment stores a user's ack of a message, reporting whether it is new.
+func RecordAcknowledgment(db *sql.DB, messageID, userID, userName, method string) (bool, error) {
+	res, err := db.Exec(`INSERT INTO acknowledgments (message_id, user_id, user_name, method) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (message_id, user_id) DO NOTHING`, messageID, userID, userName, method)
+	if err != nil {
+		return false, fmt.Errorf("failed to record acknowledgment: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Acknowledgments lists the acks of a message, oldest first.
+func Acknowledgments(db *sql.DB, messageID string) ([]Acknowledgment, error) {
+	rows, err := db.Query("SELECT user_name, acked_at FROM acknowledgments WHERE message_id = $1 ORDER BY acked_at", messageID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying acknowledgments: %w", err)
+	}
+	defer rows.Close()
+
+	var acks []Acknowledgment
+	for rows.Next() {
+		var a Acknowledgment
+		if err := rows.Scan(&a.UserName, &a.AckedAt); err != nil {
+			return nil, fmt.Errorf("error scanning acknowledgment row: %w", err)
+		}
+		acks = append(acks, a)
+	}
+	return acks, rows.Err()
+}
@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// maxConnectBackoff caps the wait between connection attempts.
+const maxConnectBackoff = 30 * time.Second
+
+// Connect pings the database, retrying up to retries times with exponential backoff and
+// jitter so a database restarting during a deploy doesn't stop the service from starting.
+// Only errors that can pass on their own are retried; a wrong password or a missing database
+// fails at once.
+func Connect(db *sql.DB, retries int, backoff time.Duration) error {
+	for attempt := 0; ; attempt++ {
+		err := db.Ping()
+		if err == nil || attempt == retries || !transient(err) {
+			return err
+		}
+		// Wait between half and all of the backoff, so restarted replicas don't retry in step.
+		wait := backoff/2 + rand.N(backoff/2+1)
+		log.Printf("Warning: database not reachable (%v); retrying in %s.", err, wait.Round(time.Millisecond))
+		time.Sleep(wait)
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+}
+
+// transient reports whether a connection error is the database being down or still starting:
+// a refused or reset connection, one closed before Postgres answered, a timeout, or Postgres
+// declining connections while it starts up.
+func transient(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "57P03" // cannot_connect_now
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	// Some resets only arrive as text, e.g. through a TLS handshake or a proxy.
+	if strings.Contains(err.Error(), "connection reset by peer") {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
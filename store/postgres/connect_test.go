@@ -0,0 +1,39 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"refused", &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, true},
+		{"reset", &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, true},
+		{"reset as text", errors.New("read tcp 10.0.0.2:51234->10.0.0.5:5432: read: connection reset by peer"), true},
+		{"closed before answering", io.EOF, true},
+		{"closed mid-message", fmt.Errorf("pq: %w", io.ErrUnexpectedEOF), true},
+		{"timeout", &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}, true},
+		{"starting up", &pq.Error{Code: "57P03"}, true},
+		{"wrong password", &pq.Error{Code: "28P01"}, false},
+		{"missing database", &pq.Error{Code: "3D000"}, false},
+		{"unknown host", &net.DNSError{Err: "no such host", Name: "db.invalid", IsNotFound: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := transient(tt.err); got != tt.want {
+				t.Errorf("transient(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
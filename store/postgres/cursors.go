@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Cursor is how far a built-in poller has read its source.
+type Cursor struct {
+	HighWater sql.NullTime // The time of the latest item stored.
+	PolledAt  sql.NullTime
+}
+
+// SourceCursor returns a poller's cursor, which is empty if it has never polled.
+func SourceCursor(db *sql.DB, source string) (Cursor, error) {
+	var c Cursor
+	err := db.QueryRow("SELECT high_water, polled_at FROM source_cursors WHERE source = $1", source).Scan(&c.HighWater, &c.PolledAt)
+	if err == sql.ErrNoRows {
+		return c, nil
+	}
+	if err != nil {
+		return c, fmt.Errorf("error querying source cursor: %w", err)
+	}
+	return c, nil
+}
+
+// SaveSourceCursor records a poll, moving the high-water mark forward to highWater unless it
+// is zero.
+func SaveSourceCursor(db *sql.DB, source string, polledAt, highWater time.Time) error {
+	var hw sql.NullTime
+	if !highWater.IsZero() {
+		hw = sql.NullTime{Time: highWater, Valid: true}
+	}
+	_, err := db.Exec(`INSERT INTO source_cursors (source, high_water, polled_at) VALUES ($1, $2, $3)
+		ON CONFLICT (source) DO UPDATE SET high_water = GREATEST(source_cursors.high_water, EXCLUDED.high_water),
+			polled_at = EXCLUDED.polled_at, updated_at = NOW()`, source, hw, polledAt)
+	if err != nil {
+		return fmt.Errorf("failed to save source cursor: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// DeferAlert holds an incident for a route until the route's schedule window opens.
+func DeferAlert(db *sql.DB, incidentID int, route string) error {
+	_, err := db.Exec("INSERT INTO deferred_alerts (incident_id, route) VALUES ($1, $2) ON CONFLICT DO NOTHING", incidentID, route)
+	if err != nil {
+		return fmt.Errorf("failed to defer alert: %w", err)
+	}
+	return nil
+}
+
+// DeferredAlerts lists the incidents held for a route, oldest first.
+func DeferredAlerts(db *sql.DB, route string) ([]int, error) {
+	rows, err := db.Query("SELECT incident_id FROM deferred_alerts WHERE route = $1 ORDER BY created_at", route)
+	if err != nil {
+		return nil, fmt.Errorf("error querying deferred alerts: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning deferred alert row: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// DeleteDeferredAlert releases an incident held for a route.
+func DeleteDeferredAlert(db *sql.DB, incidentID int, route string) error {
+	_, err := db.Exec("DELETE FROM deferred_alerts WHERE incident_id = $1 AND route = $2", incidentID, route)
+	if err != nil {
+		return fmt.Errorf("failed to delete deferred alert: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Delivery is one outbound message (or edit) recorded in the deliveries audit table.
+type Delivery struct {
+	IncidentID      int           `json:"incident_id"`
+	Sink            string        `json:"sink"` // Route name.
+	Kind            string        `json:"kind"` // "alert", "batch" or "clear".
+	PayloadHash     string        `json:"payload_hash,omitempty"`
+	MessageID       string        `json:"message_id,omitempty"`
+	HTTPStatus      int           `json:"http_status"` // 0 when the request never got a response.
+	Latency         time.Duration `json:"-"`
+	AttachmentBytes int64         `json:"attachment_bytes"`
+	Skipped         []string      `json:"skipped_enrichments,omitempty"` // Enrichments that failed and were left out.
+	Error           string        `json:"error,omitempty"`
+	CreatedAt       time.Time     `json:"created_at"`
+
+	// IncidentAt and InsertedAt are when the incident happened and when the ingestor stored it,
+	// for alerts. Either is zero when unknown.
+	IncidentAt time.Time `json:"-"`
+	InsertedAt time.Time `json:"-"`
+}
+
+// MarshalJSON reports latency in milliseconds and omits unknown incident times.
+func (d Delivery) MarshalJSON() ([]byte, error) {
+	type plain Delivery
+	return json.Marshal(struct {
+		plain
+		Latency    int64      `json:"latency_ms"`
+		IncidentAt *time.Time `json:"incident_at,omitempty"`
+		InsertedAt *time.Time `json:"inserted_at,omitempty"`
+	}{plain(d), d.Latency.Milliseconds(), timeOrNil(d.IncidentAt), timeOrNil(d.InsertedAt)})
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// nullTime stores a zero time as NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// InsertDelivery appends a row to the deliveries audit table.
+func InsertDelivery(db *sql.DB, d Delivery) error {
+	_, err := db.Exec(`INSERT INTO deliveries (incident_id, sink, kind, payload_hash, message_id, http_status, latency_ms, attachment_bytes, error, incident_at, inserted_at, skipped_enrichments)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, COALESCE($12::text[], '{}'))`,
+		d.IncidentID, d.Sink, d.Kind, d.PayloadHash, d.MessageID, d.HTTPStatus, d.Latency.Milliseconds(), d.AttachmentBytes, d.Error,
+		nullTime(d.IncidentAt), nullTime(d.InsertedAt), pq.Array(d.Skipped))
+	if err != nil {
+		return fmt.Errorf("failed to record delivery: %w", err)
+	}
+	return nil
+}
+
+// Deliveries lists the most recent deliveries, for one incident when incidentID is non-zero.
+func Deliveries(db *sql.DB, incidentID, limit int) ([]Delivery, error) {
+	rows, err := db.Query(`SELECT incident_id, sink, kind, payload_hash, message_id, http_status, latency_ms, attachment_bytes, error, created_at, incident_at, inserted_at, skipped_enrichments
+		FROM deliveries WHERE $1 = 0 OR incident_id = $1 ORDER BY created_at DESC LIMIT $2`, incidentID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []Delivery
+	for rows.Next() {
+		var d Delivery
+		var latencyMS int64
+		var incidentAt, insertedAt sql.NullTime
+		if err := rows.Scan(&d.IncidentID, &d.Sink, &d.Kind, &d.PayloadHash, &d.MessageID, &d.HTTPStatus, &latencyMS, &d.AttachmentBytes, &d.Error, &d.CreatedAt, &incidentAt, &insertedAt, pq.Array(&d.Skipped)); err != nil {
+			return nil, fmt.Errorf("error scanning delivery row: %w", err)
+		}
+		d.Latency = time.Duration(latencyMS) * time.Millisecond
+		d.IncidentAt, d.InsertedAt = incidentAt.Time, insertedAt.Time
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// Percentiles are the median and 95th percentile of a latency.
+type Percentiles struct {
+	P50, P95 time.Duration
+}
+
+// AlertLatency summarizes how long successful alerts took to reach Discord, in stages:
+// Ingest from the incident to the ingestor storing it, Pipeline from then (or from the
+// incident, when the insertion time is unknown) to delivery, and Total from the incident to
+// delivery.
+type AlertLatency struct {
+	Alerts                  int
+	Ingest, Pipeline, Total Percentiles
+}
+
+// DeliveryLatency summarizes the latency of alerts delivered since a time.
+func DeliveryLatency(db *sql.DB, since time.Time) (AlertLatency, error) {
+	var l AlertLatency
+	var stages [6]sql.NullFloat64
+	err := db.QueryRow(`
+		WITH alerts AS (
+			SELECT extract(epoch FROM inserted_at - incident_at) AS ingest,
+				extract(epoch FROM created_at - coalesce(inserted_at, incident_at)) AS pipeline,
+				extract(epoch FROM created_at - incident_at) AS total
+			FROM deliveries
+			WHERE kind IN ('alert', 'batch') AND http_status = 200 AND incident_at IS NOT NULL AND created_at >= $1
+		)
+		SELECT count(*),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY ingest), percentile_cont(0.95) WITHIN GROUP (ORDER BY ingest),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY pipeline), percentile_cont(0.95) WITHIN GROUP (ORDER BY pipeline),
+			percentile_cont(0.5) WITHIN GROUP (ORDER BY total), percentile_cont(0.95) WITHIN GROUP (ORDER BY total)
+		FROM alerts`, since).Scan(&l.Alerts, &stages[0], &stages[1], &stages[2], &stages[3], &stages[4], &stages[5])
+	if err != nil {
+		return l, fmt.Errorf("error querying delivery latency: %w", err)
+	}
+	seconds := func(v sql.NullFloat64) time.Duration {
+		return time.Duration(v.Float64 * float64(time.Second)).Round(time.Millisecond)
+	}
+	l.Ingest = Percentiles{seconds(stages[0]), seconds(stages[1])}
+	l.Pipeline = Percentiles{seconds(stages[2]), seconds(stages[3])}
+	l.Total = Percentiles{seconds(stages[4]), seconds(stages[5])}
+	return l, nil
+}
+
+// DeliveryCounts counts the deliveries since a time that succeeded and that failed.
+func DeliveryCounts(db *sql.DB, since time.Time) (ok, failed int, err error) {
+	err = db.QueryRow(`SELECT count(*) FILTER (WHERE http_status = 200), count(*) FILTER (WHERE http_status <> 200)
+		FROM deliveries WHERE created_at >= $1`, since).Scan(&ok, &failed)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error counting deliveries: %w", err)
+	}
+	return ok, failed, nil
+}
+
+// FailureStreak is a run of failed deliveries to one sink.
+type FailureStreak struct {
+	Failures  int
+	LastError string
+}
+
+// FailureStreaks returns, per sink, the failed deliveries of the last day since its most
+// recent successful one. Sinks whose latest delivery succeeded are left out.
+func FailureStreaks(db *sql.DB) (map[string]FailureStreak, error) {
+	rows, err := db.Query(`SELECT d.sink, count(*), (array_agg(d.error ORDER BY d.created_at DESC))[1] FROM deliveries d
+		WHERE d.error <> '' AND d.created_at > now() - interval '1 day'
+		AND d.created_at > COALESCE((SELECT max(s.created_at) FROM deliveries s WHERE s.sink = d.sink AND s.error = ''), '-infinity')
+		GROUP BY d.sink`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying delivery failure streaks: %w", err)
+	}
+	defer rows.Close()
+	streaks := make(map[string]FailureStreak)
+	for rows.Next() {
+		var sink string
+		var streak FailureStreak
+		if err := rows.Scan(&sink, &streak.Failures, &streak.LastError); err != nil {
+			return nil, fmt.Errorf("error scanning failure streak: %w", err)
+		}
+		streaks[sink] = streak
+	}
+	return streaks, rows.Err()
+}
+
+// ClaimOpsAlert opens an ops alert for key, reporting false if one is already open and was
+// raised within the cooldown.
+func ClaimOpsAlert(db *sql.DB, key string, cooldown time.Duration) (bool, error) {
+	res, err := db.Exec(`INSERT INTO ops_alerts (key) VALUES ($1)
+		ON CONFLICT (key) DO UPDATE SET alerted_at = now(), resolved_at = NULL
+		WHERE ops_alerts.resolved_at IS NOT NULL OR ops_alerts.alerted_at < now() - $2 * interval '1 second'`, key, cooldown.Seconds())
+	if err != nil {
+		return false, fmt.Errorf("failed to claim ops alert: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ResolveOpsAlert closes the open ops alert for key, reporting false if there was none.
+func ResolveOpsAlert(db *sql.DB, key string) (bool, error) {
+	res, err := db.Exec("UPDATE ops_alerts SET resolved_at = now() WHERE key = $1 AND resolved_at IS NULL", key)
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve ops alert: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
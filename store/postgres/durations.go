@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// durationHistoryDays limits time-to-clear percentiles to recently cleared incidents.
+const durationHistoryDays = 180
+
+// RecordIncidentDuration stores that an incident which started at startedAt has cleared.
+// Only the first clear of an incident is kept.
+func RecordIncidentDuration(db *sql.DB, incidentID int, source, eventType, road string, startedAt time.Time) error {
+	_, err := db.Exec(`INSERT INTO incident_durations (incident_id, source, event_type, road, started_at)
+		VALUES ($1, $2, $3, $4, $5) ON CONFLICT (incident_id) DO NOTHING`, incidentID, source, eventType, road, startedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record incident duration: %w", err)
+	}
+	return nil
+}
+
+// ClearTimePercentile returns the time within which the fraction p of incidents of a type
+// cleared, and how many incidents that is based on. It uses incidents on the same road when
+// there are at least minSamples of them, and otherwise all incidents of the type. It returns
+// zero when there are too few either way.
+func ClearTimePercentile(db *sql.DB, eventType, road string, p float64, minSamples int) (time.Duration, int, error) {
+	for _, sameRoad := range []bool{true, false} {
+		var seconds sql.NullFloat64
+		var samples int
+		err := db.QueryRow(`SELECT percentile_cont($1) WITHIN GROUP (ORDER BY extract(epoch FROM cleared_at - started_at)), count(*)
+			FROM incident_durations
+			WHERE event_type = $2 AND (NOT $3 OR road = $4) AND cleared_at > now() - make_interval(days => $5)`,
+			p, eventType, sameRoad, road, durationHistoryDays).Scan(&seconds, &samples)
+		if err != nil {
+			return 0, 0, fmt.Errorf("error querying clear time percentile: %w", err)
+		}
+		if samples >= minSamples {
+			return time.Duration(seconds.Float64 * float64(time.Second)), samples, nil
+		}
+	}
+	return 0, 0, nil
+}
+
+// ClaimRunningLong records that an incident got its "running long" follow-up, reporting false
+// if it already had one.
+func ClaimRunningLong(db *sql.DB, incidentID int) (bool, error) {
+	res, err := db.Exec("INSERT INTO running_long_alerts (incident_id) VALUES ($1) ON CONFLICT (incident_id) DO NOTHING", incidentID)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim running long alert: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
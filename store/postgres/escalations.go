@@ -0,0 +1,33 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ClaimEscalation records that an incident is being escalated under the named rule, reporting
+// false if it already was.
+func ClaimEscalation(db *sql.DB, incidentID int, name, dedupKey string) (bool, error) {
+	res, err := db.Exec("INSERT INTO escalations (incident_id, name, dedup_key) VALUES ($1, $2, $3) ON CONFLICT (incident_id, name) DO NOTHING",
+		incidentID, name, dedupKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim escalation: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ReleaseEscalation forgets a claim whose alert could not be opened, so the next run retries.
+func ReleaseEscalation(db *sql.DB, incidentID int, name string) error {
+	_, err := db.Exec("DELETE FROM escalations WHERE incident_id = $1 AND name = $2", incidentID, name)
+	return err
+}
+
+// ResolveEscalation marks an escalation's alert as resolved.
+func ResolveEscalation(db *sql.DB, incidentID int, name string) error {
+	_, err := db.Exec("UPDATE escalations SET resolved_at = now() WHERE incident_id = $1 AND name = $2", incidentID, name)
+	if err != nil {
+		return fmt.Errorf("failed to resolve escalation: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ClaimEvent records that a lifecycle event is being published for an incident, reporting
+// false when it already was.
+func ClaimEvent(db *sql.DB, incidentID int, event string) (bool, error) {
+	res, err := db.Exec("INSERT INTO published_events (incident_id, event) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+		incidentID, event)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim %s event: %w", event, err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
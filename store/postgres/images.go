@@ -0,0 +1,38 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SaveImage stores an image to be served until expiresAt.
+func SaveImage(db *sql.DB, id, contentType string, data []byte, expiresAt time.Time) error {
+	_, err := db.Exec("INSERT INTO hosted_images (id, content_type, data, expires_at) VALUES ($1, $2, $3, $4)",
+		id, contentType, data, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to save hosted image: %w", err)
+	}
+	return nil
+}
+
+// HostedImage returns an unexpired image and its content type, or sql.ErrNoRows.
+func HostedImage(db *sql.DB, id string) (string, []byte, error) {
+	var contentType string
+	var data []byte
+	err := db.QueryRow("SELECT content_type, data FROM hosted_images WHERE id = $1 AND expires_at > now()", id).
+		Scan(&contentType, &data)
+	if err != nil {
+		return "", nil, err
+	}
+	return contentType, data, nil
+}
+
+// DeleteExpiredImages removes images whose URLs have expired.
+func DeleteExpiredImages(db *sql.DB) (int64, error) {
+	res, err := db.Exec("DELETE FROM hosted_images WHERE expires_at <= now()")
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired images: %w", err)
+	}
+	return res.RowsAffected()
+}
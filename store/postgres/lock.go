@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// RunLock is a session-level Postgres advisory lock pinned to a single connection.
+// The lock lives as long as the connection, so it is released even if the process dies.
+type RunLock struct {
+	conn *sql.Conn
+	key  int64
+}
+
+// TryAcquireRunLock attempts to take the run lock without blocking.
+// It returns a nil lock and no error if another instance already holds it.
+func TryAcquireRunLock(ctx context.Context, db *sql.DB, key int64) (*RunLock, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve connection for advisory lock: %w", err)
+	}
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to query advisory lock: %w", err)
+	}
+	if !acquired {
+		conn.Close()
+		return nil, nil
+	}
+	return &RunLock{conn: conn, key: key}, nil
+}
+
+// Release unlocks and returns the pinned connection to the pool.
+func (l *RunLock) Release() {
+	if _, err := l.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", l.key); err != nil {
+		log.Printf("Warning: failed to release advisory lock: %v", err)
+	}
+	l.conn.Close()
+}
+
+// LeaderElector tracks whether this replica holds the run lock. Only the leader sends alerts;
+// standbys keep trying to take the lock so one of them takes over if the leader's
+// database session goes away.
+type LeaderElector struct {
+	db   *sql.DB
+	key  int64
+	lock *RunLock
+}
+
+// NewLeaderElector returns an elector competing for the advisory lock key.
+func NewLeaderElector(db *sql.DB, key int64) *LeaderElector {
+	return &LeaderElector{db: db, key: key}
+}
+
+// IsLeader confirms an existing lease is still alive, or tries to acquire one.
+func (e *LeaderElector) IsLeader(ctx context.Context) bool {
+	if e.lock != nil {
+		err := e.lock.conn.PingContext(ctx)
+		if err == nil {
+			return true
+		}
+		log.Printf("Lost leadership, lock connection is gone: %v", err)
+		e.lock.conn.Close()
+		e.lock = nil
+	}
+
+	lock, err := TryAcquireRunLock(ctx, e.db, e.key)
+	if err != nil {
+		log.Printf("Error during leader election: %v", err)
+		return false
+	}
+	if lock == nil {
+		return false
+	}
+	log.Println("Acquired leadership; this replica will send alerts.")
+	e.lock = lock
+	return true
+}
+
+// Resign gives up leadership so a standby can take over immediately.
+func (e *LeaderElector) Resign() {
+	if e.lock != nil {
+		e.lock.Release()
+		e.lock = nil
+		log.Println("Resigned leadership.")
+	}
+}
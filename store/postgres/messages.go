@@ -0,0 +1,137 @@
+// Package postgres stores alert bookkeeping (posted messages, deliveries, acknowledgments and
+// subscriptions) and the run lock in the Postgres database that holds the incidents.
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// AlertMessage is a Discord message posted to one route for an incident.
+type AlertMessage struct {
+	Route      string
+	MessageID  string
+	EmbedIndex sql.NullInt32 // Set when the message batches several incidents.
+	Part       int           // 0 for the alert, or its position among the messages of a split alert.
+	Pinned     bool
+}
+
+// RecordAlertMessage remembers which message a route received for an incident.
+func RecordAlertMessage(db *sql.DB, incidentID int, route, messageID, addressKey string, embedIndex sql.NullInt32) error {
+	_, err := db.Exec("INSERT INTO alert_messages (incident_id, route, message_id, address_key, embed_index) VALUES ($1, $2, $3, $4, $5)",
+		incidentID, route, messageID, addressKey, embedIndex)
+	if err != nil {
+		return fmt.Errorf("failed to record alert message: %w", err)
+	}
+	return nil
+}
+
+// RecordAlertContinuation remembers a further message of an alert split across several.
+func RecordAlertContinuation(db *sql.DB, incidentID int, route, messageID string, part int) error {
+	_, err := db.Exec("INSERT INTO alert_messages (incident_id, route, message_id, part) VALUES ($1, $2, $3, $4)",
+		incidentID, route, messageID, part)
+	if err != nil {
+		return fmt.Errorf("failed to record alert continuation: %w", err)
+	}
+	return nil
+}
+
+// DeliveredTo returns the message a route already received for an incident, or "" if there
+// is none.
+func DeliveredTo(db *sql.DB, incidentID int, route string) (string, error) {
+	var messageID string
+	err := db.QueryRow("SELECT message_id FROM alert_messages WHERE incident_id = $1 AND route = $2 AND part = 0 ORDER BY id LIMIT 1",
+		incidentID, route).Scan(&messageID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up delivered alert: %w", err)
+	}
+	return messageID, nil
+}
+
+// RecentAlertAt returns the newest message a route received within window for an address,
+// or "" if there is none.
+func RecentAlertAt(db *sql.DB, route, addressKey string, window time.Duration) (string, error) {
+	var messageID string
+	err := db.QueryRow(`SELECT message_id FROM alert_messages
+		WHERE route = $1 AND address_key = $2 AND part = 0 AND created_at > now() - make_interval(secs => $3)
+		ORDER BY created_at DESC LIMIT 1`, route, addressKey, window.Seconds()).Scan(&messageID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error querying recent alerts: %w", err)
+	}
+	return messageID, nil
+}
+
+// DeleteAlertMessages forgets the messages a route received for an incident.
+func DeleteAlertMessages(db *sql.DB, incidentID int, route string) error {
+	_, err := db.Exec("DELETE FROM alert_messages WHERE incident_id = $1 AND route = $2", incidentID, route)
+	if err != nil {
+		return fmt.Errorf("failed to delete alert messages: %w", err)
+	}
+	return nil
+}
+
+// DeleteAlertMessage forgets one message a route received for an incident.
+func DeleteAlertMessage(db *sql.DB, incidentID int, route, messageID string) error {
+	_, err := db.Exec("DELETE FROM alert_messages WHERE incident_id = $1 AND route = $2 AND message_id = $3", incidentID, route, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to delete alert message: %w", err)
+	}
+	return nil
+}
+
+// MarkAlertMessagePinned records that a route's message for an incident was pinned.
+func MarkAlertMessagePinned(db *sql.DB, incidentID int, route, messageID string) error {
+	_, err := db.Exec("UPDATE alert_messages SET pinned = true WHERE incident_id = $1 AND route = $2 AND message_id = $3",
+		incidentID, route, messageID)
+	if err != nil {
+		return fmt.Errorf("failed to mark alert message pinned: %w", err)
+	}
+	return nil
+}
+
+// MarkAlertMessageCrossposted records that a message was published from a channel to
+// following servers.
+func MarkAlertMessageCrossposted(db *sql.DB, route, messageID, channelID string) error {
+	_, err := db.Exec("UPDATE alert_messages SET crossposted_at = now(), crosspost_channel = $3 WHERE route = $1 AND message_id = $2", route, messageID, channelID)
+	if err != nil {
+		return fmt.Errorf("failed to mark alert message crossposted: %w", err)
+	}
+	return nil
+}
+
+// CrosspostsInLastHour counts the distinct messages crossposted from a channel in the past
+// hour, by any route.
+func CrosspostsInLastHour(db *sql.DB, channelID string) (int, error) {
+	var n int
+	err := db.QueryRow("SELECT count(DISTINCT message_id) FROM alert_messages WHERE crosspost_channel = $1 AND crossposted_at > now() - interval '1 hour'", channelID).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("error counting crossposts: %w", err)
+	}
+	return n, nil
+}
+
+// AlertMessagesFor lists every message posted for an incident.
+func AlertMessagesFor(db *sql.DB, incidentID int) ([]AlertMessage, error) {
+	rows, err := db.Query("SELECT route, message_id, embed_index, part, pinned FROM alert_messages WHERE incident_id = $1 ORDER BY id", incidentID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying alert messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []AlertMessage
+	for rows.Next() {
+		var m AlertMessage
+		if err := rows.Scan(&m.Route, &m.MessageID, &m.EmbedIndex, &m.Part, &m.Pinned); err != nil {
+			return nil, fmt.Errorf("error scanning alert message row: %w", err)
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
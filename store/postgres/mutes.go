@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mtickle/unity-alerts/incident"
+)
+
+// Mute silences incidents matching every non-empty criterion until it expires.
+type Mute struct {
+	ID           int
+	Address      string // Normalized; the center of the radius when RadiusMeters is set.
+	Road         string // Normalized corridor, e.g. "I-40 W".
+	EventType    string // Matched as a case-insensitive substring.
+	Latitude     sql.NullFloat64
+	Longitude    sql.NullFloat64
+	RadiusMeters sql.NullFloat64
+	ExpiresAt    time.Time
+	CreatedBy    string
+}
+
+// AddMute stores a mute and returns its ID.
+func AddMute(db *sql.DB, m Mute) (int, error) {
+	var id int
+	err := db.QueryRow(`INSERT INTO mutes (address, road, event_type, latitude, longitude, radius_meters, expires_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		m.Address, m.Road, m.EventType, m.Latitude, m.Longitude, m.RadiusMeters, m.ExpiresAt, m.CreatedBy).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save mute: %w", err)
+	}
+	return id, nil
+}
+
+// DeleteMute removes a mute, reporting whether it existed.
+func DeleteMute(db *sql.DB, id int) (bool, error) {
+	res, err := db.Exec("DELETE FROM mutes WHERE id = $1", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete mute: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ActiveMutes lists the mutes that have not expired, soonest expiry first.
+func ActiveMutes(db *sql.DB) ([]Mute, error) {
+	rows, err := db.Query(`SELECT id, address, road, event_type, latitude, longitude, radius_meters, expires_at, created_by
+		FROM mutes WHERE expires_at > now() ORDER BY expires_at`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying mutes: %w", err)
+	}
+	defer rows.Close()
+
+	var mutes []Mute
+	for rows.Next() {
+		var m Mute
+		if err := rows.Scan(&m.ID, &m.Address, &m.Road, &m.EventType, &m.Latitude, &m.Longitude, &m.RadiusMeters, &m.ExpiresAt, &m.CreatedBy); err != nil {
+			return nil, fmt.Errorf("error scanning mute row: %w", err)
+		}
+		mutes = append(mutes, m)
+	}
+	return mutes, rows.Err()
+}
+
+// MutedBy returns the ID of an active mute matching the incident, or 0.
+func MutedBy(db *sql.DB, inc incident.Incident) (int, error) {
+	var id int
+	err := db.QueryRow(`SELECT id FROM mutes
+		WHERE expires_at > now()
+		  AND (address = '' OR radius_meters IS NOT NULL OR address = $1)
+		  AND (road = '' OR road = $2)
+		  AND (event_type = '' OR $3 ILIKE '%' || replace(replace(replace(event_type, '\', '\\'), '%', '\%'), '_', '\_') || '%' ESCAPE '\')
+		  AND (radius_meters IS NULL OR ($4::float8 IS NOT NULL AND $5::float8 IS NOT NULL
+		       AND ST_DWithin(ST_SetSRID(ST_MakePoint(longitude, latitude), 4326)::geography,
+		                      ST_SetSRID(ST_MakePoint($4, $5), 4326)::geography, radius_meters)))
+		ORDER BY id LIMIT 1`,
+		incident.NormalizedAddress(inc), incident.Corridor(inc), inc.EventType, inc.Longitude, inc.Latitude).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error checking mutes: %w", err)
+	}
+	return id, nil
+}
@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Pause pauses a route of the config file, or every route, until PausedUntil.
+type Pause struct {
+	Scope       string // "global" or "route:<route name>", as in feature_flags.
+	PausedUntil time.Time
+	PauseMode   string // One of PauseDrop, PauseHold or PauseSummarize.
+}
+
+// Pauses lists every pause, including those that ended but whose held incidents were not
+// released yet.
+func Pauses(db *sql.DB) ([]Pause, error) {
+	rows, err := db.Query("SELECT scope, paused_until, pause_mode FROM pauses ORDER BY scope")
+	if err != nil {
+		return nil, fmt.Errorf("error querying pauses: %w", err)
+	}
+	defer rows.Close()
+
+	var pauses []Pause
+	for rows.Next() {
+		var p Pause
+		if err := rows.Scan(&p.Scope, &p.PausedUntil, &p.PauseMode); err != nil {
+			return nil, fmt.Errorf("error scanning pause row: %w", err)
+		}
+		pauses = append(pauses, p)
+	}
+	return pauses, rows.Err()
+}
+
+// SavePause pauses a scope, replacing any pause it already has.
+func SavePause(db *sql.DB, p Pause) error {
+	_, err := db.Exec(`INSERT INTO pauses (scope, paused_until, pause_mode) VALUES ($1, $2, $3)
+		ON CONFLICT (scope) DO UPDATE SET paused_until = EXCLUDED.paused_until, pause_mode = EXCLUDED.pause_mode`,
+		p.Scope, p.PausedUntil, p.PauseMode)
+	if err != nil {
+		return fmt.Errorf("failed to save pause: %w", err)
+	}
+	return nil
+}
+
+// ResumePause ends a scope's pause, reporting whether it had one. Like ResumeTenantRoutes, a
+// pause ends rather than being deleted, so the next run releases the incidents it held.
+func ResumePause(db *sql.DB, scope string) (bool, error) {
+	res, err := db.Exec("UPDATE pauses SET paused_until = LEAST(paused_until, now()) WHERE scope = $1", scope)
+	if err != nil {
+		return false, fmt.Errorf("failed to resume pause: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ClearPauses forgets the pauses that ended by the given time, once the incidents they held
+// are released.
+func ClearPauses(db *sql.DB, ended time.Time) error {
+	if _, err := db.Exec("DELETE FROM pauses WHERE paused_until <= $1", ended); err != nil {
+		return fmt.Errorf("failed to clear ended pauses: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// QueueOffset returns the next offset to consume from a partition of the incident queue, and
+// false if none has been saved.
+func QueueOffset(db *sql.DB, topic string, partition int32) (int64, bool, error) {
+	var offset int64
+	err := db.QueryRow("SELECT next_offset FROM queue_offsets WHERE topic = $1 AND partition = $2", topic, partition).Scan(&offset)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("error querying queue offset: %w", err)
+	}
+	return offset, true, nil
+}
+
+// SaveQueueOffset records the next offset to consume from a partition of the incident queue.
+func SaveQueueOffset(db *sql.DB, topic string, partition int32, offset int64) error {
+	_, err := db.Exec(`INSERT INTO queue_offsets (topic, partition, next_offset) VALUES ($1, $2, $3)
+		ON CONFLICT (topic, partition) DO UPDATE SET next_offset = EXCLUDED.next_offset, updated_at = NOW()`, topic, partition, offset)
+	if err != nil {
+		return fmt.Errorf("failed to save queue offset: %w", err)
+	}
+	return nil
+}
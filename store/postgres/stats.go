@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ClaimStatsReport marks a report of a kind ("daily" or "leaderboard") for the period starting
+// on day as posted, reporting false if it already was.
+func ClaimStatsReport(db *sql.DB, kind string, day time.Time) (bool, error) {
+	res, err := db.Exec("INSERT INTO stats_reports (kind, day) VALUES ($1, $2) ON CONFLICT (kind, day) DO NOTHING", kind, day.Format(time.DateOnly))
+	if err != nil {
+		return false, fmt.Errorf("failed to claim stats report: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ReleaseStatsReport forgets a claimed report, so a failed one is retried.
+func ReleaseStatsReport(db *sql.DB, kind string, day time.Time) error {
+	if _, err := db.Exec("DELETE FROM stats_reports WHERE kind = $1 AND day = $2", kind, day.Format(time.DateOnly)); err != nil {
+		return fmt.Errorf("failed to release stats report: %w", err)
+	}
+	return nil
+}
+
+// ClaimSpikeAlert records a spike meta-alert for key, reporting false if one was recorded
+// within the cooldown.
+func ClaimSpikeAlert(db *sql.DB, key string, cooldown time.Duration) (bool, error) {
+	res, err := db.Exec(`INSERT INTO spike_alerts (key) VALUES ($1)
+		ON CONFLICT (key) DO UPDATE SET alerted_at = now()
+		WHERE spike_alerts.alerted_at < now() - $2 * interval '1 second'`, key, cooldown.Seconds())
+	if err != nil {
+		return false, fmt.Errorf("failed to claim spike alert: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ClaimAnnouncement records a spoken announcement for the route, reporting false if there
+// was one within the cooldown.
+func ClaimAnnouncement(db *sql.DB, route string, cooldown time.Duration) (bool, error) {
+	res, err := db.Exec(`INSERT INTO announcements (route) VALUES ($1)
+		ON CONFLICT (route) DO UPDATE SET announced_at = now()
+		WHERE announcements.announced_at < now() - $2 * interval '1 second'`, route, cooldown.Seconds())
+	if err != nil {
+		return false, fmt.Errorf("failed to claim announcement: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
@@ -0,0 +1,23 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// TakeStreetViewQuota counts one Street View image against today's limit, reporting false
+// without counting once the limit is reached.
+func TakeStreetViewQuota(db *sql.DB, limit int) (bool, error) {
+	var count int
+	err := db.QueryRow(`INSERT INTO street_view_requests (day, count) VALUES (current_date, 1)
+		ON CONFLICT (day) DO UPDATE SET count = street_view_requests.count + 1
+		WHERE street_view_requests.count < $1
+		RETURNING count`, limit).Scan(&count)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error counting Street View request: %w", err)
+	}
+	return true, nil
+}
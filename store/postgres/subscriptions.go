@@ -0,0 +1,208 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/mtickle/unity-alerts/incident"
+)
+
+// Subscription is a user's personal geofence registered with /subscribe.
+type Subscription struct {
+	ID           int
+	UserID       string
+	ChannelID    sql.NullString // Ping the user here; DM them when unset.
+	Address      string         // As the user gave it.
+	RadiusMeters float64
+	EventTypes   []string
+	Subscriber
+}
+
+// Subscriber holds a user's preferences for all of their subscriptions, set with /preferences.
+type Subscriber struct {
+	QuietStart string   // "15:04"; empty when the user has no quiet hours.
+	QuietEnd   string   // May be before QuietStart to run past midnight.
+	Timezone   string   // IANA name for the quiet hours; empty means the configured timezone.
+	Categories []string // Substrings of the incident categories to alert on; empty means all.
+	Email      string   // Where the daily digest is sent.
+	Digest     bool     // A daily digest email instead of real-time pings.
+}
+
+// DigestSubscriber is a user who gets the daily digest.
+type DigestSubscriber struct {
+	UserID string
+	Subscriber
+}
+
+// AddSubscription stores a new geofence subscription.
+func AddSubscription(db *sql.DB, userID string, channelID sql.NullString, address string, lat, lon, radiusMeters float64, eventTypes []string) error {
+	_, err := db.Exec(`INSERT INTO subscriptions (user_id, channel_id, address, latitude, longitude, radius_meters, event_types)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`, userID, channelID, address, lat, lon, radiusMeters, pq.Array(eventTypes))
+	if err != nil {
+		return fmt.Errorf("failed to save subscription: %w", err)
+	}
+	return nil
+}
+
+// DeleteSubscriptions removes every subscription a user has registered.
+func DeleteSubscriptions(db *sql.DB, userID string) (int64, error) {
+	res, err := db.Exec("DELETE FROM subscriptions WHERE user_id = $1", userID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete subscriptions: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// DeleteSubscription removes one of a user's subscriptions, reporting whether it existed.
+func DeleteSubscription(db *sql.DB, id int, userID string) (bool, error) {
+	res, err := db.Exec("DELETE FROM subscriptions WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete subscription: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// SetSubscriptionChannel changes where one of a user's subscriptions notifies them, reporting
+// whether it existed. A NULL channelID means by DM.
+func SetSubscriptionChannel(db *sql.DB, id int, userID string, channelID sql.NullString) (bool, error) {
+	res, err := db.Exec("UPDATE subscriptions SET channel_id = $3 WHERE id = $1 AND user_id = $2", id, userID, channelID)
+	if err != nil {
+		return false, fmt.Errorf("failed to update subscription: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// UserSubscriptions lists a user's subscriptions, oldest first.
+func UserSubscriptions(db *sql.DB, userID string) ([]Subscription, error) {
+	rows, err := db.Query(`SELECT id, user_id, channel_id, address, radius_meters, event_types
+		FROM subscriptions WHERE user_id = $1 ORDER BY id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []Subscription
+	for rows.Next() {
+		var s Subscription
+		if err := rows.Scan(&s.ID, &s.UserID, &s.ChannelID, &s.Address, &s.RadiusMeters, pq.Array(&s.EventTypes)); err != nil {
+			return nil, fmt.Errorf("error scanning subscription row: %w", err)
+		}
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+// SubscriberPreferences returns a user's preferences, or the zero Subscriber when none are set.
+func SubscriberPreferences(db *sql.DB, userID string) (Subscriber, error) {
+	var s Subscriber
+	err := db.QueryRow("SELECT quiet_start, quiet_end, timezone, categories, email, digest FROM subscribers WHERE user_id = $1", userID).
+		Scan(&s.QuietStart, &s.QuietEnd, &s.Timezone, pq.Array(&s.Categories), &s.Email, &s.Digest)
+	if err != nil && err != sql.ErrNoRows {
+		return s, fmt.Errorf("error loading subscriber preferences: %w", err)
+	}
+	return s, nil
+}
+
+// SaveSubscriberPreferences creates or replaces a user's preferences.
+func SaveSubscriberPreferences(db *sql.DB, userID string, s Subscriber) error {
+	_, err := db.Exec(`INSERT INTO subscribers (user_id, quiet_start, quiet_end, timezone, categories, email, digest)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id) DO UPDATE SET quiet_start = EXCLUDED.quiet_start, quiet_end = EXCLUDED.quiet_end,
+		    timezone = EXCLUDED.timezone, categories = EXCLUDED.categories, email = EXCLUDED.email,
+		    digest = EXCLUDED.digest, updated_at = now()`,
+		userID, s.QuietStart, s.QuietEnd, s.Timezone, pq.Array(s.Categories), s.Email, s.Digest)
+	if err != nil {
+		return fmt.Errorf("failed to save subscriber preferences: %w", err)
+	}
+	return nil
+}
+
+// SubscriptionsMatching finds the subscriptions whose geofence contains the incident and
+// whose type filter (if any) appears in its event type, with each user's preferences. Each
+// user is notified once.
+func SubscriptionsMatching(db *sql.DB, inc incident.Incident) ([]Subscription, error) {
+	rows, err := db.Query(`SELECT DISTINCT ON (s.user_id) s.id, s.user_id, s.channel_id,
+		    COALESCE(p.quiet_start, ''), COALESCE(p.quiet_end, ''), COALESCE(p.timezone, ''), COALESCE(p.categories, '{}'), COALESCE(p.digest, false)
+		FROM subscriptions s LEFT JOIN subscribers p ON p.user_id = s.user_id
+		WHERE ST_DWithin(ST_SetSRID(ST_MakePoint(longitude, latitude), 4326)::geography,
+		                 ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography, radius_meters)
+		  AND (cardinality(event_types) = 0 OR EXISTS (SELECT 1 FROM unnest(event_types) t WHERE $3 ILIKE '%' || replace(replace(replace(t, '\', '\\'), '%', '\%'), '_', '\_') || '%' ESCAPE '\'))
+		ORDER BY s.user_id, s.id`, inc.Longitude.Float64, inc.Latitude.Float64, inc.EventType)
+	if err != nil {
+		return nil, fmt.Errorf("error querying subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []Subscription
+	for rows.Next() {
+		var s Subscription
+		if err := rows.Scan(&s.ID, &s.UserID, &s.ChannelID, &s.QuietStart, &s.QuietEnd, &s.Timezone, pq.Array(&s.Categories), &s.Digest); err != nil {
+			return nil, fmt.Errorf("error scanning subscription row: %w", err)
+		}
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+// DigestSubscribers lists the users who chose the daily digest and gave an email address.
+func DigestSubscribers(db *sql.DB) ([]DigestSubscriber, error) {
+	rows, err := db.Query(`SELECT user_id, quiet_start, quiet_end, timezone, categories, email, digest
+		FROM subscribers WHERE digest AND email <> '' ORDER BY user_id`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying digest subscribers: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []DigestSubscriber
+	for rows.Next() {
+		var s DigestSubscriber
+		if err := rows.Scan(&s.UserID, &s.QuietStart, &s.QuietEnd, &s.Timezone, pq.Array(&s.Categories), &s.Email, &s.Digest); err != nil {
+			return nil, fmt.Errorf("error scanning digest subscriber row: %w", err)
+		}
+		subs = append(subs, s)
+	}
+	return subs, rows.Err()
+}
+
+// ClaimDigest marks a user's digest for the day as sent, reporting false if it already was.
+func ClaimDigest(db *sql.DB, userID string, day time.Time) (bool, error) {
+	res, err := db.Exec("INSERT INTO digest_sends (user_id, day) VALUES ($1, $2) ON CONFLICT DO NOTHING", userID, day.Format(time.DateOnly))
+	if err != nil {
+		return false, fmt.Errorf("failed to claim digest: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// ReleaseDigest forgets a claimed digest, so a failed one is retried.
+func ReleaseDigest(db *sql.DB, userID string, day time.Time) error {
+	if _, err := db.Exec("DELETE FROM digest_sends WHERE user_id = $1 AND day = $2", userID, day.Format(time.DateOnly)); err != nil {
+		return fmt.Errorf("failed to release digest: %w", err)
+	}
+	return nil
+}
+
+// ClaimSubscriptionNotification records that a subscriber is being notified of an incident,
+// reporting false when they already were.
+func ClaimSubscriptionNotification(db *sql.DB, incidentID int, userID string) (bool, error) {
+	res, err := db.Exec(`INSERT INTO subscription_notifications (incident_id, user_id) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, incidentID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to record subscription notification: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ReleaseSubscriptionNotification forgets a claimed notification, so a failed one is retried
+// when the incident is picked up again.
+func ReleaseSubscriptionNotification(db *sql.DB, incidentID int, userID string) error {
+	if _, err := db.Exec("DELETE FROM subscription_notifications WHERE incident_id = $1 AND user_id = $2", incidentID, userID); err != nil {
+		return fmt.Errorf("failed to release subscription notification: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Tenant is one community served by the deployment, usually a Discord guild.
+type Tenant struct {
+	ID       string
+	Name     string
+	Timezone string // Default for the tenant's routes.
+	Language string // Default for the tenant's routes.
+	Enabled  bool
+}
+
+// TenantRoute is a tenant's route in the config file's JSON shape.
+type TenantRoute struct {
+	TenantID    string
+	Name        string
+	Config      json.RawMessage
+	PausedUntil sql.NullTime
+	PauseMode   string // One of PauseDrop, PauseHold or PauseSummarize.
+}
+
+// What a paused tenant route does with the incidents it would have alerted.
+const (
+	PauseDrop      = "drop"      // Leave them unsent.
+	PauseHold      = "hold"      // Hold them, then alert each once the pause ends.
+	PauseSummarize = "summarize" // Hold them, then post one digest once the pause ends.
+)
+
+// Holds reports whether the route's pause holds its incidents rather than dropping them.
+func (r TenantRoute) Holds() bool {
+	return r.PauseMode == PauseHold || r.PauseMode == PauseSummarize
+}
+
+// SaveTenant creates a tenant or updates its settings.
+func SaveTenant(db *sql.DB, t Tenant) error {
+	_, err := db.Exec(`INSERT INTO tenants (id, name, timezone, language, enabled) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO UPDATE SET name = EXCLUDED.name, timezone = EXCLUDED.timezone,
+		    language = EXCLUDED.language, enabled = EXCLUDED.enabled`,
+		t.ID, t.Name, t.Timezone, t.Language, t.Enabled)
+	if err != nil {
+		return fmt.Errorf("failed to save tenant: %w", err)
+	}
+	return nil
+}
+
+// DeleteTenant removes a tenant and its routes, reporting whether it existed.
+func DeleteTenant(db *sql.DB, id string) (bool, error) {
+	res, err := db.Exec("DELETE FROM tenants WHERE id = $1", id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete tenant: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Tenants lists every tenant by ID.
+func Tenants(db *sql.DB) ([]Tenant, error) {
+	rows, err := db.Query("SELECT id, name, timezone, language, enabled FROM tenants ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("error querying tenants: %w", err)
+	}
+	defer rows.Close()
+
+	var tenants []Tenant
+	for rows.Next() {
+		var t Tenant
+		if err := rows.Scan(&t.ID, &t.Name, &t.Timezone, &t.Language, &t.Enabled); err != nil {
+			return nil, fmt.Errorf("error scanning tenant row: %w", err)
+		}
+		tenants = append(tenants, t)
+	}
+	return tenants, rows.Err()
+}
+
+// FindTenant looks up a tenant by ID, reporting whether it exists.
+func FindTenant(db *sql.DB, id string) (Tenant, bool, error) {
+	t := Tenant{ID: id}
+	err := db.QueryRow("SELECT name, timezone, language, enabled FROM tenants WHERE id = $1", id).
+		Scan(&t.Name, &t.Timezone, &t.Language, &t.Enabled)
+	if err == sql.ErrNoRows {
+		return t, false, nil
+	}
+	if err != nil {
+		return t, false, fmt.Errorf("error querying tenant: %w", err)
+	}
+	return t, true, nil
+}
+
+// SaveTenantRoute creates or replaces one of a tenant's routes.
+func SaveTenantRoute(db *sql.DB, r TenantRoute) error {
+	_, err := db.Exec(`INSERT INTO tenant_routes (tenant_id, name, config) VALUES ($1, $2, $3)
+		ON CONFLICT (tenant_id, name) DO UPDATE SET config = EXCLUDED.config, updated_at = now()`,
+		r.TenantID, r.Name, []byte(r.Config))
+	if err != nil {
+		return fmt.Errorf("failed to save tenant route: %w", err)
+	}
+	return nil
+}
+
+// DeleteTenantRoute removes one of a tenant's routes, reporting whether it existed.
+func DeleteTenantRoute(db *sql.DB, tenantID, name string) (bool, error) {
+	res, err := db.Exec("DELETE FROM tenant_routes WHERE tenant_id = $1 AND name = $2", tenantID, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete tenant route: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// PauseTenantRoutes pauses a tenant's route until the given time, or all of its routes when
+// name is empty, or those of every tenant when tenantID is empty too, and returns how many
+// were changed.
+func PauseTenantRoutes(db *sql.DB, tenantID, name string, until time.Time, mode string) (int64, error) {
+	res, err := db.Exec(`UPDATE tenant_routes SET paused_until = $3, pause_mode = $4, updated_at = now()
+		WHERE ($1 = '' OR tenant_id = $1) AND ($2 = '' OR name = $2)`, tenantID, name, until, mode)
+	if err != nil {
+		return 0, fmt.Errorf("failed to pause tenant routes: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// ResumeTenantRoutes ends the pauses PauseTenantRoutes would set for the same tenant and name,
+// and returns how many routes matched. A pause that holds incidents ends rather than being
+// cleared, so the next run releases them.
+func ResumeTenantRoutes(db *sql.DB, tenantID, name string) (int64, error) {
+	res, err := db.Exec(`UPDATE tenant_routes SET updated_at = now(),
+		    paused_until = CASE WHEN paused_until > now() THEN now() ELSE paused_until END
+		WHERE ($1 = '' OR tenant_id = $1) AND ($2 = '' OR name = $2)`, tenantID, name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resume tenant routes: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// ClearTenantRoutePause forgets a route's pause once the incidents it held are released,
+// unless it has been paused again meanwhile.
+func ClearTenantRoutePause(db *sql.DB, tenantID, name string) error {
+	_, err := db.Exec(`UPDATE tenant_routes SET paused_until = NULL, pause_mode = $3
+		WHERE tenant_id = $1 AND name = $2 AND paused_until <= now()`, tenantID, name, PauseDrop)
+	if err != nil {
+		return fmt.Errorf("failed to clear tenant route pause: %w", err)
+	}
+	return nil
+}
+
+// TenantRoutes lists the routes of enabled tenants that are unpaused or hold their incidents,
+// or every route of one tenant when tenantID is set, with the tenant each belongs to.
+func TenantRoutes(db *sql.DB, tenantID string) ([]TenantRoute, []Tenant, error) {
+	rows, err := db.Query(`SELECT r.tenant_id, r.name, r.config, r.paused_until, r.pause_mode, t.name, t.timezone, t.language, t.enabled
+		FROM tenant_routes r JOIN tenants t ON t.id = r.tenant_id
+		WHERE ($1 = '' AND t.enabled AND (r.paused_until IS NULL OR r.paused_until <= now() OR r.pause_mode <> 'drop')) OR r.tenant_id = $1
+		ORDER BY r.tenant_id, r.name`, tenantID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error querying tenant routes: %w", err)
+	}
+	defer rows.Close()
+
+	var routes []TenantRoute
+	var tenants []Tenant
+	for rows.Next() {
+		var r TenantRoute
+		var t Tenant
+		var config []byte
+		if err := rows.Scan(&r.TenantID, &r.Name, &config, &r.PausedUntil, &r.PauseMode, &t.Name, &t.Timezone, &t.Language, &t.Enabled); err != nil {
+			return nil, nil, fmt.Errorf("error scanning tenant route row: %w", err)
+		}
+		r.Config, t.ID = config, r.TenantID
+		routes = append(routes, r)
+		tenants = append(tenants, t)
+	}
+	return routes, tenants, rows.Err()
+}
@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mtickle/unity-alerts/incident"
+)
+
+// Watch is an address, or its whole hundred block, that a user wants to hear about
+// regardless of filters.
+type Watch struct {
+	ID        int
+	UserID    string
+	ChannelID sql.NullString // Ping the user here; DM them when unset.
+	Address   string         // Normalized.
+	Block     string         // incident.BlockKey of the address; empty to match the address only.
+	CreatedAt time.Time
+}
+
+// AddWatch stores a watchlist entry and returns its ID.
+func AddWatch(db *sql.DB, w Watch) (int, error) {
+	var id int
+	err := db.QueryRow(`INSERT INTO watchlist (user_id, channel_id, address, block) VALUES ($1, $2, $3, $4) RETURNING id`,
+		w.UserID, w.ChannelID, w.Address, w.Block).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save watch: %w", err)
+	}
+	return id, nil
+}
+
+// DeleteWatch removes a watchlist entry, reporting whether it existed. A non-empty userID
+// only removes the user's own entry.
+func DeleteWatch(db *sql.DB, id int, userID string) (bool, error) {
+	res, err := db.Exec("DELETE FROM watchlist WHERE id = $1 AND ($2 = '' OR user_id = $2)", id, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete watch: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// SetWatchChannel changes where one of a user's watchlist entries notifies them, reporting
+// whether it existed. A NULL channelID means by DM.
+func SetWatchChannel(db *sql.DB, id int, userID string, channelID sql.NullString) (bool, error) {
+	res, err := db.Exec("UPDATE watchlist SET channel_id = $3 WHERE id = $1 AND user_id = $2", id, userID, channelID)
+	if err != nil {
+		return false, fmt.Errorf("failed to update watch: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Watches lists a user's watchlist, or everyone's when userID is empty.
+func Watches(db *sql.DB, userID string) ([]Watch, error) {
+	rows, err := db.Query(`SELECT id, user_id, channel_id, address, block, created_at FROM watchlist
+		WHERE $1 = '' OR user_id = $1 ORDER BY id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying watchlist: %w", err)
+	}
+	return scanWatches(rows)
+}
+
+// WatchesMatching finds the entries watching the incident's address or hundred block. Each
+// user is notified once.
+func WatchesMatching(db *sql.DB, inc incident.Incident) ([]Watch, error) {
+	rows, err := db.Query(`SELECT DISTINCT ON (user_id) id, user_id, channel_id, address, block, created_at FROM watchlist
+		WHERE (block = '' AND address = $1) OR (block <> '' AND block = $2)
+		ORDER BY user_id, id`, incident.NormalizedAddress(inc), incident.BlockKey(inc))
+	if err != nil {
+		return nil, fmt.Errorf("error querying watchlist: %w", err)
+	}
+	return scanWatches(rows)
+}
+
+func scanWatches(rows *sql.Rows) ([]Watch, error) {
+	defer rows.Close()
+	var watches []Watch
+	for rows.Next() {
+		var w Watch
+		if err := rows.Scan(&w.ID, &w.UserID, &w.ChannelID, &w.Address, &w.Block, &w.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning watchlist row: %w", err)
+		}
+		watches = append(watches, w)
+	}
+	return watches, rows.Err()
+}
+
+// ClaimWatchNotification records that a user is being pinged about an incident, reporting
+// false when they already were.
+func ClaimWatchNotification(db *sql.DB, incidentID int, userID string) (bool, error) {
+	res, err := db.Exec(`INSERT INTO watch_notifications (incident_id, user_id) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, incidentID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to record watch notification: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
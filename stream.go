@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// streamKeepAlive is how often an idle stream gets a comment, so proxies don't time it out.
+const streamKeepAlive = 30 * time.Second
+
+// eventFilter reads the types and sources query parameters, comma-separated lists of the
+// lifecycle events and incident sources a live stream wants. By default it sends created,
+// updated and cleared events from every source.
+func eventFilter(q url.Values) func(incidentEvent) bool {
+	types := map[string]bool{eventCreated: true, eventUpdated: true, eventCleared: true}
+	if v := q.Get("types"); v != "" {
+		types = splitSet(v)
+	}
+	sources := splitSet(q.Get("sources"))
+	return func(event incidentEvent) bool {
+		return types[event.Type] && (len(sources) == 0 || sources[event.Source])
+	}
+}
+
+func splitSet(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			set[v] = true
+		}
+	}
+	return set
+}
+
+// bearerFromQuery lets a ?token= parameter stand in for the Authorization header, for
+// browser clients like EventSource that cannot set headers.
+func bearerFromQuery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("token"); token != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleStream serves GET /api/stream?types=created,cleared&sources=NCDOT, which pushes
+// incident lifecycle events as Server-Sent Events until the client disconnects. Each event
+// is named after its type and its data is the same JSON published to NATS or Kafka.
+func (a *app) handleStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	wanted := eventFilter(r.URL.Query())
+
+	events, unsubscribe := a.hub.subscribe()
+	defer unsubscribe()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("X-Accel-Buffering", "no")
+	fmt.Fprint(w, "retry: 5000\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case event := <-events:
+			if !wanted(event) {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Warning: failed to encode %s event: %v", event.Type, err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
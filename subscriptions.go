@@ -0,0 +1,199 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/enrich"
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/sink/discord"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// maxSubscriptionRadiusMiles keeps a personal geofence from covering the whole feed.
+const maxSubscriptionRadiusMiles = 25
+
+// parseRadius reads a radius such as "2mi", "2 mi", "1.5" (miles) or "800m" into meters.
+func parseRadius(s string) (float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return defaultNearRadiusMiles * metersPerMile, nil
+	}
+	scale := metersPerMile
+	switch {
+	case strings.HasSuffix(s, "km"):
+		s, scale = strings.TrimSuffix(s, "km"), 1000
+	case strings.HasSuffix(s, "mi"):
+		s = strings.TrimSuffix(s, "mi")
+	case strings.HasSuffix(s, "m"):
+		s, scale = strings.TrimSuffix(s, "m"), 1
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid radius %q", s)
+	}
+	meters := v * scale
+	if meters > maxSubscriptionRadiusMiles*metersPerMile {
+		return 0, fmt.Errorf("radius %q exceeds %d mi", s, maxSubscriptionRadiusMiles)
+	}
+	return meters, nil
+}
+
+// parseEventTypes splits a comma-separated type filter such as "fire,crash".
+func parseEventTypes(s string) []string {
+	types := []string{}
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// parseCoordinates reads a location given as "lat,lon", such as a user's home coordinates,
+// so it can be subscribed to without geocoding.
+func parseCoordinates(s string) (lat, lon float64, ok bool) {
+	latText, lonText, found := strings.Cut(s, ",")
+	if !found {
+		return 0, 0, false
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(latText), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, false
+	}
+	lon, err = strconv.ParseFloat(strings.TrimSpace(lonText), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
+
+// parseQuietHours reads a range such as "22:00-07:00" into its start and end. "off" clears them.
+func parseQuietHours(s string) (start, end string, err error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "off") {
+		return "", "", nil
+	}
+	start, end, _ = strings.Cut(s, "-")
+	start, end = strings.TrimSpace(start), strings.TrimSpace(end)
+	for _, t := range []string{start, end} {
+		if _, err := time.Parse("15:04", t); err != nil {
+			return "", "", fmt.Errorf("invalid quiet hours %q", s)
+		}
+	}
+	if start == end {
+		return "", "", fmt.Errorf("quiet hours %q are empty", s)
+	}
+	return start, end, nil
+}
+
+// quietNow reports whether the subscriber's quiet hours include now.
+func quietNow(cfg *Config, s postgres.Subscriber, now time.Time) bool {
+	if s.QuietStart == "" || s.QuietEnd == "" {
+		return false
+	}
+	loc := cfg.Location(RouteConfig{Timezone: s.Timezone})
+	quiet := &Schedule{Windows: []ScheduleWindow{{Start: s.QuietStart, End: s.QuietEnd}}}
+	return quiet.Active(now.In(loc))
+}
+
+// wantsIncident reports whether one of the subscriber's categories appears in the incident's
+// type, crime description or tags. A subscriber without categories wants every incident.
+func wantsIncident(s postgres.Subscriber, inc incident.Incident) bool {
+	if len(s.Categories) == 0 {
+		return true
+	}
+	for _, category := range append(incident.Categories(inc), inc.Tags...) {
+		for _, want := range s.Categories {
+			if strings.Contains(strings.ToLower(category), want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// notifySubscribers DMs or pings every user whose subscription matches a new incident, once
+// each: an incident picked up again because a route failed doesn't notify them twice.
+func (a *app) notifySubscribers(cfg *Config, mapsAPIKey string, p *pendingIncident) {
+	defer recoverAndReport(a.reporter, incidentTags(p.incident))
+
+	token := os.Getenv("DISCORD_BOT_TOKEN")
+	if token == "" || !p.incident.Latitude.Valid || !p.incident.Longitude.Valid {
+		return
+	}
+	subs, err := postgres.SubscriptionsMatching(a.db, p.incident)
+	if err != nil {
+		log.Printf("Error loading subscriptions: %v", err)
+		return
+	}
+	now := time.Now()
+	wanted := subs[:0]
+	for _, s := range subs {
+		if s.Digest || !wantsIncident(s.Subscriber, p.incident) {
+			continue
+		}
+		if quietNow(cfg, s.Subscriber, now) {
+			log.Printf("Subscription %d is in its quiet hours; not notifying.", s.ID)
+			continue
+		}
+		wanted = append(wanted, s)
+	}
+	if subs = wanted; len(subs) == 0 {
+		return
+	}
+
+	opts := cfg.RenderOptions(RouteConfig{}, p.incident.Source)
+	payload, err := discord.BuildPayload(mapsAPIKey, p.incident, enrich.Result{NearbyCameras: p.enrichment.NearbyCameras}, opts)
+	if err != nil {
+		log.Printf("Error building subscription alert: %v", err)
+		return
+	}
+	for idx := range payload.Embeds {
+		payload.Embeds[idx] = discord.EnforceEmbedLimits(payload.Embeds[idx], opts.T("field_continued"))
+	}
+
+	notified := 0
+	for _, s := range subs {
+		if claimed, err := postgres.ClaimSubscriptionNotification(a.db, p.incident.ID, s.UserID); err != nil || !claimed {
+			if err != nil {
+				log.Printf("Warning: %v", err)
+			}
+			continue
+		}
+		if err := notifyUser(token, s.UserID, s.ChannelID, payload, opts.T("sub_ping")); err != nil {
+			log.Printf("Error notifying subscriber for subscription %d: %v", s.ID, err)
+			if err := postgres.ReleaseSubscriptionNotification(a.db, p.incident.ID, s.UserID); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+			continue
+		}
+		notified++
+		time.Sleep(500 * time.Millisecond)
+	}
+	if notified > 0 {
+		log.Printf("Notified %d subscriber(s) of incident %d.", notified, p.incident.ID)
+	}
+}
+
+// notifyUser sends an alert to a user by DM, or when channelID is set posts it there with
+// ping (a format taking the user ID) as the message content.
+func notifyUser(token, userID string, channelID sql.NullString, payload discord.WebhookPayload, ping string) error {
+	target := channelID.String
+	if channelID.Valid {
+		payload.Content = fmt.Sprintf(ping, userID)
+		payload.AllowedMentions = &discord.AllowedMentions{Parse: []string{}, Users: []string{userID}}
+	} else {
+		var err error
+		if target, err = discord.OpenDMChannel(token, userID); err != nil {
+			return err
+		}
+	}
+	_, err := (discord.BotMessenger{Token: token, ChannelID: target}).Send(payload)
+	return err
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/internal/syslog"
+)
+
+// SyslogConfig sends incident lifecycle events to a syslog collector, such as a SIEM's, so
+// security teams can correlate incidents near their facilities with other events.
+type SyslogConfig struct {
+	// Address is udp://host:514, tcp://host:601 or tls://host:6514. ${VAR} references are
+	// expanded.
+	Address string `json:"address"`
+	// Format is "cef" (default), ArcSight CEF inside each syslog message, or "rfc5424" for a
+	// plain message with the incident as structured data.
+	Format string `json:"format,omitempty"`
+	// Route, if set, only sends the incidents that route accepts, e.g. one limited with near to
+	// the area around a facility.
+	Route string `json:"route,omitempty"`
+	// Facility is the syslog facility number (default 16, local0).
+	Facility *int `json:"facility,omitempty"`
+}
+
+func (s *SyslogConfig) validate(c *Config) error {
+	if s == nil {
+		return nil
+	}
+	if _, err := syslog.NewWriter(os.ExpandEnv(s.Address)); err != nil {
+		return fmt.Errorf("syslog.address: %w", err)
+	}
+	if s.Format != "" && s.Format != "cef" && s.Format != "rfc5424" {
+		return fmt.Errorf("syslog.format must be cef or rfc5424")
+	}
+	if s.Route != "" {
+		if _, ok := c.Route(s.Route); !ok {
+			return fmt.Errorf("syslog.route: no route named %q", s.Route)
+		}
+	}
+	if s.Facility != nil && (*s.Facility < 0 || *s.Facility > 23) {
+		return fmt.Errorf("syslog.facility must be between 0 and 23")
+	}
+	return nil
+}
+
+// syslogIncidentID is the structured data ID of RFC 5424 messages, under the private
+// enterprise number reserved for documentation.
+const syslogIncidentID = "incident@32473"
+
+// sendSyslog sends a lifecycle event to the syslog collector when one is configured. Delivered
+// events are left out: they describe the alerts, not the incident.
+func (a *app) sendSyslog(cfg *Config, event incidentEvent, i incident.Incident) {
+	s := cfg.Syslog
+	if s == nil || event.Type == eventDelivered {
+		return
+	}
+	if s.Route != "" {
+		if route, ok := cfg.Route(s.Route); !ok || !route.Matches(i) {
+			return
+		}
+	}
+	address := os.ExpandEnv(s.Address)
+	if a.syslogWriter == nil || a.syslogAddress != address {
+		if a.syslogWriter != nil {
+			a.syslogWriter.Close()
+		}
+		writer, err := syslog.NewWriter(address)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			return
+		}
+		a.syslogWriter, a.syslogAddress = writer, address
+	}
+
+	facility := 16
+	if s.Facility != nil {
+		facility = *s.Facility
+	}
+	severity := incident.Severity(i)
+	hostname, _ := os.Hostname()
+	msg := syslog.Message{
+		Facility: facility,
+		Severity: syslog.Notice,
+		Time:     event.At,
+		Hostname: hostname,
+		AppName:  "unity-alerts",
+		MsgID:    event.Type,
+	}
+	switch {
+	case event.Type == eventCleared:
+		msg.Severity = syslog.Info
+	case severity >= 3:
+		msg.Severity = syslog.Warning
+	}
+
+	var lat, lon, start string
+	if event.Latitude != nil && event.Longitude != nil {
+		lat, lon = strconv.FormatFloat(*event.Latitude, 'f', 6, 64), strconv.FormatFloat(*event.Longitude, 'f', 6, 64)
+	}
+	if !event.Timestamp.IsZero() {
+		start = strconv.FormatInt(event.Timestamp.UnixMilli(), 10)
+	}
+	if s.Format == "rfc5424" {
+		msg.Data = []syslog.Element{{ID: syslogIncidentID, Params: [][2]string{
+			{"id", strconv.Itoa(event.IncidentID)},
+			{"source", event.Source},
+			{"sourceId", event.SourceID},
+			{"eventType", event.EventType},
+			{"severity", strconv.Itoa(severity)},
+			{"lat", lat},
+			{"lon", lon},
+			{"tags", strings.Join(event.Tags, ",")},
+			{"reason", event.Reason},
+		}}}
+		msg.Msg = fmt.Sprintf("%s %s at %s", event.EventType, event.Type, event.Address)
+	} else {
+		msg.Msg = syslog.CEF("unity-alerts", "unity-alerts", "1", event.Source+":"+event.Type, event.EventType, cefSeverity(event.Type, severity), [][2]string{
+			{"rt", strconv.FormatInt(event.At.UnixMilli(), 10)},
+			{"start", start},
+			{"act", event.Type},
+			{"cat", event.EventType},
+			{"msg", event.Address},
+			{"externalId", strconv.Itoa(event.IncidentID)},
+			{"dlat", lat},
+			{"dlong", lon},
+			{"cs1Label", "source"},
+			{"cs1", event.Source},
+			{"cs2Label", "sourceId"},
+			{"cs2", event.SourceID},
+			{"cs3Label", "tags"},
+			{"cs3", strings.Join(event.Tags, ",")},
+			{"reason", event.Reason},
+		})
+	}
+	if err := a.syslogWriter.Write(syslog.Format(msg)); err != nil {
+		log.Printf("Warning: failed to send %s event for incident %d to syslog: %v", event.Type, event.IncidentID, err)
+	}
+}
+
+// cefSeverity maps the feed's severity (NCDOT 1–3, CAP 1–4) onto CEF's 0–10 scale.
+func cefSeverity(eventType string, severity int) int {
+	switch {
+	case eventType == eventCleared:
+		return 1
+	case severity <= 0:
+		return 3
+	case severity >= 4:
+		return 10
+	}
+	return 2 + 2*severity
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mtickle/unity-alerts/i18n"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// tenantRouteName is how a tenant's route is named among the configured routes.
+func tenantRouteName(tenantID, name string) string {
+	return tenantID + "/" + name
+}
+
+// parseTenantRoute reads a tenant route's JSON into a route owned by the tenant, which
+// defaults to the tenant's timezone and language.
+func parseTenantRoute(r postgres.TenantRoute, t postgres.Tenant) (RouteConfig, error) {
+	var route RouteConfig
+	if err := json.Unmarshal(r.Config, &route); err != nil {
+		return route, fmt.Errorf("route %q: %w", tenantRouteName(r.TenantID, r.Name), err)
+	}
+	route.Name, route.Tenant = tenantRouteName(r.TenantID, r.Name), r.TenantID
+	if r.PausedUntil.Valid && r.Holds() {
+		route.PausedUntil, route.PauseMode = r.PausedUntil.Time, r.PauseMode
+	}
+	if route.Timezone == "" {
+		route.Timezone = t.Timezone
+	}
+	if route.Language == "" {
+		route.Language = t.Language
+	}
+	return route, route.validate()
+}
+
+// loadTenantRoutes reads the routes of every enabled tenant. A route that fails validation
+// is left out with a warning rather than stopping the others.
+func loadTenantRoutes(db *sql.DB) ([]RouteConfig, error) {
+	stored, tenants, err := postgres.TenantRoutes(db, "")
+	if err != nil {
+		return nil, err
+	}
+	var routes []RouteConfig
+	for n, r := range stored {
+		route, err := parseTenantRoute(r, tenants[n])
+		if err != nil {
+			log.Printf("Warning: skipping tenant %s's route: %v", r.TenantID, err)
+			continue
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// withTenantRoutes returns a copy of the config with the tenants' routes added after the
+// configured ones. A tenant route whose name is already taken is left out.
+func (c *Config) withTenantRoutes(tenantRoutes []RouteConfig) *Config {
+	if len(tenantRoutes) == 0 {
+		return c
+	}
+	cfg := *c
+	cfg.Routes = append([]RouteConfig(nil), c.Routes...)
+	for _, route := range tenantRoutes {
+		if _, taken := c.Route(route.Name); taken {
+			log.Printf("Warning: tenant route %q has the same name as a configured route; skipping it.", route.Name)
+			continue
+		}
+		cfg.Routes = append(cfg.Routes, route)
+	}
+	return &cfg
+}
+
+// tenant manages tenants and their routes, with the subcommands of /admin route.
+//
+//	unity-alerts tenant add --id 123456789 --name "Oakwood Neighbors" [--timezone ...] [--language es] [--disabled]
+//	unity-alerts tenant list
+//	unity-alerts tenant remove --id 123456789
+//	unity-alerts tenant route add --tenant 123456789 --name alerts --file route.json
+//	unity-alerts tenant route remove --tenant 123456789 --name alerts
+func (a *app) tenant(args []string) error {
+	sub, args, err := subcommand("tenant", args, "add", "list", "remove", "route")
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("tenant "+sub, flag.ContinueOnError)
+	switch sub {
+	case "add":
+		id := fs.String("id", "", "tenant ID, usually the Discord guild ID; an existing tenant is updated")
+		name := fs.String("name", "", "display name")
+		timezone := fs.String("timezone", "", "default timezone for the tenant's routes")
+		language := fs.String("language", "", "default language for the tenant's routes")
+		disabled := fs.Bool("disabled", false, "stop delivering to the tenant's routes")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if *id == "" || *name == "" {
+			return fmt.Errorf("tenant add requires --id and --name")
+		}
+		if *timezone != "" {
+			if _, err := time.LoadLocation(*timezone); err != nil {
+				return fmt.Errorf("invalid timezone: %w", err)
+			}
+		}
+		if *language != "" {
+			if _, err := i18n.For(*language); err != nil {
+				return err
+			}
+		}
+		if err := postgres.SaveTenant(a.db, postgres.Tenant{ID: *id, Name: *name, Timezone: *timezone, Language: *language, Enabled: !*disabled}); err != nil {
+			return err
+		}
+		fmt.Printf("Saved tenant %s (%s).\n", *id, *name)
+		return nil
+	case "list":
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		tenants, err := postgres.Tenants(a.db)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tENABLED\tTIMEZONE\tLANGUAGE\tROUTES")
+		for _, t := range tenants {
+			routes, _, err := postgres.TenantRoutes(a.db, t.ID)
+			if err != nil {
+				return err
+			}
+			var names []string
+			for _, r := range routes {
+				names = append(names, r.Name)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\t%v\n", t.ID, t.Name, t.Enabled, t.Timezone, t.Language, names)
+		}
+		return w.Flush()
+	case "remove":
+		id := fs.String("id", "", "remove the tenant with this ID and all of its routes")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if *id == "" {
+			return fmt.Errorf("tenant remove requires --id")
+		}
+		removed, err := postgres.DeleteTenant(a.db, *id)
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return fmt.Errorf("no tenant with ID %s", *id)
+		}
+		fmt.Printf("Removed tenant %s.\n", *id)
+		return nil
+	}
+	return a.tenantRoute(args)
+}
+
+// tenantRoute adds or removes a tenant's route, like /admin route add and remove.
+func (a *app) tenantRoute(args []string) error {
+	sub, args, err := subcommand("tenant route", args, "add", "remove")
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("tenant route "+sub, flag.ContinueOnError)
+	tenantID := fs.String("tenant", "", "ID of the tenant the route belongs to")
+	name := fs.String("name", "", "route name")
+	var file *string
+	if sub == "add" {
+		file = fs.String("file", "", "JSON file holding the route, in the config file's route format (- for stdin)")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *tenantID == "" || *name == "" {
+		return fmt.Errorf("tenant route %s requires --tenant and --name", sub)
+	}
+	if sub == "add" {
+		return a.setTenantRoute(*tenantID, *name, *file)
+	}
+	removed, err := postgres.DeleteTenantRoute(a.db, *tenantID, *name)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("tenant %s has no route %q", *tenantID, *name)
+	}
+	fmt.Printf("Deleted route %q of tenant %s.\n", *name, *tenantID)
+	return nil
+}
+
+// setTenantRoute validates a route read from file and stores it for the tenant.
+func (a *app) setTenantRoute(tenantID, name, file string) error {
+	if file == "" {
+		return fmt.Errorf("tenant route add requires --file")
+	}
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read route: %w", err)
+	}
+
+	tenant, found, err := postgres.FindTenant(a.db, tenantID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("no tenant with ID %s", tenantID)
+	}
+	stored := postgres.TenantRoute{TenantID: tenantID, Name: name, Config: data}
+	if _, err := parseTenantRoute(stored, tenant); err != nil {
+		return err
+	}
+	if err := postgres.SaveTenantRoute(a.db, stored); err != nil {
+		return err
+	}
+	fmt.Printf("Saved route %q of tenant %s.\n", name, tenantID)
+	return nil
+}
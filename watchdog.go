@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/internal/systemd"
+)
+
+// watchdog feeds the systemd watchdog while the daemon is healthy: idle between runs, or
+// making its way through a run's jobs. Once a single job has run for longer than WatchdogSec,
+// as when an HTTP call is stuck, the pings stop and systemd restarts the service. WatchdogSec
+// must therefore exceed the longest a job legitimately takes.
+type watchdog struct {
+	a        *app
+	interval time.Duration
+}
+
+// startWatchdog pings the systemd watchdog until ctx is done, if it is enabled.
+func startWatchdog(ctx context.Context, a *app) {
+	interval, err := systemd.WatchdogInterval()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if interval == 0 {
+		return
+	}
+	w := &watchdog{a: a, interval: interval}
+	go w.run(ctx)
+	log.Printf("Feeding the systemd watchdog every %s.", interval/2)
+}
+
+func (w *watchdog) run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval / 2)
+	defer ticker.Stop()
+	stalled := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if running, ok := w.a.stalled(w.interval); ok {
+			if !stalled {
+				log.Printf("Warning: a job has been running for %s; no longer feeding the systemd watchdog.", running.Round(time.Second))
+				stalled = true
+			}
+			continue
+		}
+		stalled = false
+		if _, err := systemd.Notify("WATCHDOG=1"); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
+
+// notifyStatus sets the status line systemctl status shows, along with any other state such as
+// READY=1.
+func notifyStatus(status string, state ...string) {
+	message := "STATUS=" + strings.ReplaceAll(status, "\n", " ")
+	for _, s := range state {
+		message += "\n" + s
+	}
+	if _, err := systemd.Notify(message); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mtickle/unity-alerts/enrich"
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/sink/discord"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// newWatch normalizes an address given to `unity-alerts watch` or /watch. With block set the
+// whole hundred block is watched, which needs an address with a house number.
+func newWatch(userID string, channelID sql.NullString, address string, block bool) (postgres.Watch, error) {
+	inc := incident.Incident{Address: address}
+	w := postgres.Watch{UserID: userID, ChannelID: channelID, Address: incident.NormalizedAddress(inc)}
+	if w.Address == "" {
+		return w, fmt.Errorf("a watch needs an address")
+	}
+	if block {
+		if w.Block = incident.BlockKey(inc); w.Block == "" {
+			return w, fmt.Errorf("watching a block needs an address with a house number")
+		}
+	}
+	return w, nil
+}
+
+// describeWatch shows what an entry matches, e.g. "1200 block of OAK ST" or "1234 OAK ST".
+func describeWatch(w postgres.Watch) string {
+	if w.Block != "" {
+		block, street, _ := incident.Block(incident.Incident{Address: w.Block})
+		return fmt.Sprintf("%d block of %s", block, street)
+	}
+	return w.Address
+}
+
+// notifyWatchers pings everyone watching the incident's address or block as soon as it is
+// found, before filters, mutes and routing decide whether it is posted anywhere else.
+func (a *app) notifyWatchers(cfg *Config, mapsAPIKey string, i incident.Incident) {
+	defer recoverAndReport(a.reporter, incidentTags(i))
+
+	token := os.Getenv("DISCORD_BOT_TOKEN")
+	if token == "" || a.notifyDiscord == "0" {
+		return
+	}
+	watches, err := postgres.WatchesMatching(a.db, i)
+	if err != nil {
+		log.Printf("Error loading watchlist: %v", err)
+		return
+	}
+	if len(watches) == 0 {
+		return
+	}
+
+	opts := cfg.RenderOptions(RouteConfig{}, i.Source)
+	payload, err := discord.BuildPayload(mapsAPIKey, i, enrich.Result{}, opts)
+	if err != nil {
+		log.Printf("Error building watchlist alert: %v", err)
+		return
+	}
+	for idx := range payload.Embeds {
+		payload.Embeds[idx] = discord.EnforceEmbedLimits(payload.Embeds[idx], opts.T("field_continued"))
+	}
+
+	for _, w := range watches {
+		if claimed, err := postgres.ClaimWatchNotification(a.db, i.ID, w.UserID); err != nil || !claimed {
+			if err != nil {
+				log.Printf("Warning: %v", err)
+			}
+			continue
+		}
+		if err := notifyUser(token, w.UserID, w.ChannelID, payload, opts.T("watch_ping")); err != nil {
+			log.Printf("Error notifying watcher for watch %d: %v", w.ID, err)
+			continue
+		}
+		log.Printf("Notified user %s of incident %d on their watchlist.", w.UserID, i.ID)
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// watch adds, lists or removes watchlist entries, with the subcommands and options of /watch.
+//
+//	unity-alerts watch add --user 1234 --address "1234 Oak St" [--block] [--channel 5678]
+//	unity-alerts watch list [--user 1234]
+//	unity-alerts watch remove --id 12
+func (a *app) watch(args []string) error {
+	sub, args, err := subcommand("watch", args, "add", "list", "remove")
+	if err != nil {
+		return err
+	}
+	fs := flag.NewFlagSet("watch "+sub, flag.ContinueOnError)
+	switch sub {
+	case "list":
+		userID := fs.String("user", "", "only list this Discord user's entries")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		watches, err := postgres.Watches(a.db, *userID)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tUSER\tCHANNEL\tMATCHES")
+		for _, entry := range watches {
+			channel := "DM"
+			if entry.ChannelID.Valid {
+				channel = entry.ChannelID.String
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", entry.ID, entry.UserID, channel, describeWatch(entry))
+		}
+		return w.Flush()
+	case "remove":
+		id := fs.Int("id", 0, "watch ID from watch list")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if *id == 0 {
+			return fmt.Errorf("watch remove requires --id")
+		}
+		removed, err := postgres.DeleteWatch(a.db, *id, "")
+		if err != nil {
+			return err
+		}
+		if !removed {
+			return fmt.Errorf("no watch with ID %d", *id)
+		}
+		fmt.Printf("Removed watch %d.\n", *id)
+		return nil
+	}
+
+	userID := fs.String("user", "", "Discord user ID to notify")
+	address := fs.String("address", "", "address to watch")
+	block := fs.Bool("block", false, "watch the address's whole hundred block")
+	channel := fs.String("channel", "", "ping the user in this channel instead of by DM")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *userID == "" {
+		return fmt.Errorf("watch add requires --user")
+	}
+	w, err := newWatch(*userID, sql.NullString{String: *channel, Valid: *channel != ""}, *address, *block)
+	if err != nil {
+		return err
+	}
+	id, err := postgres.AddWatch(a.db, w)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created watch %d: %s.\n", id, describeWatch(w))
+	return nil
+}
+
+// handleWatch runs the /watch add, list and remove subcommands on the caller's own watchlist.
+func (a *app) handleWatch(interaction Interaction, opts discord.RenderOptions) interactionResponse {
+	userID := interaction.userID()
+	if len(interaction.Data.Options) == 0 || userID == "" {
+		return ephemeralReply(opts.T("cmd_error"), nil)
+	}
+	sub := interaction.Data.Options[0]
+	var err error
+	switch sub.Name {
+	case "add":
+		var channelID sql.NullString
+		if option(sub.Options, "notify").stringValue() == "here" && interaction.Member != nil {
+			channelID = sql.NullString{String: interaction.ChannelID, Valid: true}
+		}
+		var w postgres.Watch
+		w, err = newWatch(userID, channelID, option(sub.Options, "address").stringValue(), option(sub.Options, "block").boolValue())
+		if err != nil {
+			return ephemeralReply(fmt.Sprintf(opts.T("watch_bad_input"), discord.SanitizeFeedText(err.Error())), nil)
+		}
+		var id int
+		if id, err = postgres.AddWatch(a.db, w); err == nil {
+			return ephemeralReply(fmt.Sprintf(opts.T("watch_created"), id, discord.SanitizeFeedText(describeWatch(w))), nil)
+		}
+	case "list":
+		var watches []postgres.Watch
+		if watches, err = postgres.Watches(a.db, userID); err == nil {
+			return ephemeralReply("", []discord.Embed{buildWatchListEmbed(watches, opts)})
+		}
+	case "remove":
+		id := int(option(sub.Options, "id").floatValue(0))
+		var removed bool
+		if removed, err = postgres.DeleteWatch(a.db, id, userID); err == nil {
+			if !removed {
+				return ephemeralReply(fmt.Sprintf(opts.T("watch_not_found"), id), nil)
+			}
+			return ephemeralReply(fmt.Sprintf(opts.T("watch_removed"), id), nil)
+		}
+	default:
+		err = fmt.Errorf("unknown subcommand %q", sub.Name)
+	}
+	log.Printf("Error handling /watch %s: %v", sub.Name, err)
+	return ephemeralReply(opts.T("cmd_error"), nil)
+}
+
+// buildWatchListEmbed shows one field per watchlist entry.
+func buildWatchListEmbed(watches []postgres.Watch, opts discord.RenderOptions) discord.Embed {
+	embed := discord.Embed{
+		Title:     opts.T("watch_list_title"),
+		Color:     3447003, // Blue
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+	if len(watches) == 0 {
+		embed.Fields = []discord.EmbedField{{Name: discord.ZeroWidthSpace, Value: opts.T("watch_none")}}
+		return embed
+	}
+	for _, w := range watches {
+		if len(embed.Fields) == discord.MaxFieldsPerEmbed {
+			break
+		}
+		embed.Fields = append(embed.Fields, discord.EmbedField{
+			Name:  fmt.Sprintf("#%d", w.ID),
+			Value: discord.Truncate(discord.SanitizeFeedText(describeWatch(w)), discord.MaxFieldValue),
+		})
+	}
+	return embed
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mtickle/unity-alerts/incident"
+	"github.com/mtickle/unity-alerts/sink/discord"
+	"github.com/mtickle/unity-alerts/store/postgres"
+)
+
+// Rows in the weather report need this many incidents in the condition, and this many hours
+// of it, to be listed in the embed.
+const (
+	weatherMinIncidents = 5
+	weatherMinHours     = 6
+)
+
+// weatherReportRows is how many of the strongest correlations the embed lists.
+const weatherReportRows = 10
+
+// weatherReportName is the file name of the CSV attached to the report.
+const weatherReportName = "weather_correlation.csv"
+
+// allRoads labels the report's totals across every road.
+const allRoads = "*"
+
+// WeatherReportConfig posts a monthly report of how weather changes incident rates.
+type WeatherReportConfig struct {
+	Route string `json:"route"`
+	At    string `json:"at,omitempty"` // Time of day on the 1st in the route's timezone, "15:04" format (default 09:00).
+}
+
+func (w *WeatherReportConfig) validate(c *Config) error {
+	if w == nil {
+		return nil
+	}
+	if _, ok := c.Route(w.Route); !ok {
+		return fmt.Errorf("weather_report.route: unknown route %q", w.Route)
+	}
+	if w.At != "" {
+		if _, err := time.Parse("15:04", w.At); err != nil {
+			return fmt.Errorf("weather_report.at: invalid time %q", w.At)
+		}
+	}
+	return nil
+}
+
+// weatherRow is the incident rate on a road in one weather condition.
+type weatherRow struct {
+	Road      string
+	Condition string
+	Incidents int
+	Hours     int     // Hours of the month with this condition.
+	Ratio     float64 // Rate relative to dry hours on the same road; 0 when there were none.
+}
+
+func (r weatherRow) rate() float64 {
+	return float64(r.Incidents) / float64(r.Hours)
+}
+
+// computeWeatherReport correlates weather with incidents between from and to. The weather
+// stored with incidents is the only record of conditions, so each hour takes the condition
+// most incidents in it were reported under, and hours without incidents are left out.
+func (a *app) computeWeatherReport(cfg *Config, from, to time.Time) ([]weatherRow, error) {
+	incidents, _, err := queryIncidents(cfg, a.db, "WHERE {timestamp} >= $1 AND {timestamp} < $2 AND NOT {is_test}", from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	votes := make(map[int64]map[string]int) // Conditions reported, by hour since the epoch.
+	for _, i := range incidents {
+		if condition := incident.WeatherCondition(i); condition != "" {
+			hour := i.Timestamp.Unix() / 3600
+			if votes[hour] == nil {
+				votes[hour] = make(map[string]int)
+			}
+			votes[hour][condition]++
+		}
+	}
+	conditions := make(map[int64]string)
+	hours := make(map[string]int)
+	for hour, v := range votes {
+		best := ""
+		for condition, n := range v {
+			if best == "" || n > v[best] || (n == v[best] && condition < best) {
+				best = condition
+			}
+		}
+		conditions[hour] = best
+		hours[best]++
+	}
+
+	type rowKey struct{ road, condition string }
+	counts := make(map[rowKey]int)
+	for _, i := range incidents {
+		condition, ok := conditions[i.Timestamp.Unix()/3600]
+		if !ok {
+			continue
+		}
+		counts[rowKey{allRoads, condition}]++
+		if road := incident.RoadName(i); road != "" {
+			counts[rowKey{road, condition}]++
+		}
+	}
+
+	var rows []weatherRow
+	for key, n := range counts {
+		rows = append(rows, weatherRow{Road: key.road, Condition: key.condition, Incidents: n, Hours: hours[key.condition]})
+	}
+	for n, row := range rows {
+		dry := counts[rowKey{row.Road, incident.WeatherDry}]
+		if row.Condition != incident.WeatherDry && dry > 0 {
+			rows[n].Ratio = row.rate() / (float64(dry) / float64(hours[incident.WeatherDry]))
+		}
+	}
+	sort.Slice(rows, func(x, y int) bool {
+		if rows[x].Road != rows[y].Road {
+			return rows[x].Road < rows[y].Road
+		}
+		return rows[x].Condition < rows[y].Condition
+	})
+	return rows, nil
+}
+
+// postWeatherReport posts last month's report once the configured time on the 1st has passed.
+func (a *app) postWeatherReport(cfg *Config) {
+	w := cfg.WeatherReport
+	if w == nil {
+		return
+	}
+	route, _ := cfg.Route(w.Route)
+	loc := cfg.Location(route)
+	at := w.At
+	if at == "" {
+		at = "09:00"
+	}
+	now := time.Now().In(loc)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	if now.Day() != 1 || now.Sub(month) < time.Duration(clockMinutes(at))*time.Minute {
+		return
+	}
+	from := month.AddDate(0, -1, 0)
+	claimed, err := postgres.ClaimStatsReport(a.db, "weather", from)
+	if err != nil || !claimed {
+		if err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		return
+	}
+	if err := a.sendWeatherReport(cfg, route, from, month); err != nil {
+		log.Printf("Error posting weather report: %v", err)
+		a.reporter.Report(fmt.Errorf("posting weather report: %w", err), "error", map[string]string{"route": route.Name})
+		if err := postgres.ReleaseStatsReport(a.db, "weather", from); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
+
+func (a *app) sendWeatherReport(cfg *Config, route RouteConfig, from, to time.Time) error {
+	rows, err := a.computeWeatherReport(cfg, from, to)
+	if err != nil {
+		return err
+	}
+	data, err := weatherCSV(rows)
+	if err != nil {
+		return err
+	}
+	opts := cfg.RenderOptions(route, "")
+	payload := discord.WebhookPayload{Username: opts.T("bot_username"), Embeds: []discord.Embed{buildWeatherReportEmbed(rows, from, opts)}}
+	if _, err := reportMessenger(route).Send(payload, discord.Attachment{Name: weatherReportName, Data: data}); err != nil {
+		return err
+	}
+	log.Printf("Posted weather report for %s to route %q.", from.Format("2006-01"), route.Name)
+	return nil
+}
+
+// weatherCSV lists every row of the report.
+func weatherCSV(rows []weatherRow) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"road", "condition", "incidents", "hours", "incidents_per_hour", "ratio_vs_dry"})
+	for _, r := range rows {
+		w.Write([]string{r.Road, r.Condition, strconv.Itoa(r.Incidents), strconv.Itoa(r.Hours),
+			strconv.FormatFloat(r.rate(), 'f', 3, 64), strconv.FormatFloat(r.Ratio, 'f', 2, 64)})
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// buildWeatherReportEmbed lists the strongest increases in incident rates during bad weather.
+func buildWeatherReportEmbed(rows []weatherRow, month time.Time, opts discord.RenderOptions) discord.Embed {
+	var notable []weatherRow
+	for _, r := range rows {
+		if r.Ratio > 1 && r.Incidents >= weatherMinIncidents && r.Hours >= weatherMinHours {
+			notable = append(notable, r)
+		}
+	}
+	sort.SliceStable(notable, func(x, y int) bool { return notable[x].Ratio > notable[y].Ratio })
+	if len(notable) > weatherReportRows {
+		notable = notable[:weatherReportRows]
+	}
+
+	var lines []string
+	for _, r := range notable {
+		road := r.Road
+		if road == allRoads {
+			road = opts.T("weather_report_all_roads")
+		}
+		lines = append(lines, fmt.Sprintf(opts.T("weather_report_line"), discord.SanitizeFeedText(road), r.Ratio, r.Condition, r.Incidents, r.Hours))
+	}
+	value := opts.T("weather_report_none")
+	if len(lines) > 0 {
+		value = discord.Truncate(strings.Join(lines, "\n"), discord.MaxFieldValue)
+	}
+	return discord.Embed{
+		Title:     discord.Truncate("🌧️ "+fmt.Sprintf(opts.T("weather_report_title"), month.Format("January 2006")), discord.MaxEmbedTitle),
+		Color:     3447003, // Blue
+		Fields:    []discord.EmbedField{{Name: opts.T("weather_report_field"), Value: value}},
+		Footer:    discord.EmbedFooter{Text: opts.T("weather_report_footer")},
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+}